@@ -0,0 +1,48 @@
+package hcat
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+// VaultCachePolicy controls how the secrets under a Vault path are cached,
+// for secrets that shouldn't be kept around any longer than needed.
+type VaultCachePolicy struct {
+	// PathPrefix selects the secrets the policy applies to, those whose path
+	// starts with it (eg. "secret/prod/"). When more than one policy matches
+	// the one with the longest prefix is used.
+	PathPrefix string
+
+	// NoCache keeps the secrets out of the Watcher's Cache (Store). They are
+	// handed directly from the dependency to the templates.
+	NoCache bool
+
+	// TTL is the longest the secrets are used before being read from Vault
+	// again, regardless of their lease. Zero uses the lease.
+	TTL time.Duration
+}
+
+// vaultCachePolicy returns the policy for the dependency, the one with the
+// longest matching prefix. Returns false if the dependency isn't on a Vault
+// path or no policy matches.
+func vaultCachePolicy(policies []VaultCachePolicy, d dep.Dependency) (
+	VaultCachePolicy, bool) {
+	pq, ok := d.(idep.VaultPathQuery)
+	if !ok {
+		return VaultCachePolicy{}, false
+	}
+	path := strings.TrimPrefix(pq.VaultPath(), "/")
+
+	var found VaultCachePolicy
+	longest := -1
+	for _, p := range policies {
+		prefix := strings.TrimPrefix(p.PathPrefix, "/")
+		if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+			found, longest = p, len(prefix)
+		}
+	}
+	return found, longest >= 0
+}
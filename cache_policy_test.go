@@ -0,0 +1,93 @@
+package hcat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+func TestVaultCachePolicy(t *testing.T) {
+	t.Parallel()
+
+	policies := []VaultCachePolicy{
+		{PathPrefix: "secret/", TTL: time.Minute},
+		{PathPrefix: "/secret/prod/", NoCache: true},
+		{PathPrefix: "pki/", TTL: time.Hour},
+	}
+	cases := []struct {
+		name string
+		path string
+		exp  string
+		ok   bool
+	}{
+		{"prefix", "secret/foo", "secret/", true},
+		{"longest-prefix", "secret/prod/db", "/secret/prod/", true},
+		{"leading-slash", "/pki/issue/web", "pki/", true},
+		{"no-match", "aws/creds/deploy", "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, ok := vaultCachePolicy(policies, &idep.FakeVaultDep{Path: tc.path})
+			if ok != tc.ok || p.PathPrefix != tc.exp {
+				t.Errorf("bad policy for %q, exp: %q, act: %#v", tc.path, tc.exp, p)
+			}
+		})
+	}
+
+	t.Run("not-vault", func(t *testing.T) {
+		if _, ok := vaultCachePolicy(policies, &idep.FakeDep{Name: "secret/"}); ok {
+			t.Error("expected no policy")
+		}
+	})
+}
+
+func TestWatcherVaultCachePolicy(t *testing.T) {
+	t.Parallel()
+
+	st := NewStore()
+	w := NewWatcher(WatcherInput{
+		Cache: st,
+		VaultCachePolicies: []VaultCachePolicy{
+			{PathPrefix: "secret/", TTL: time.Minute},
+			{PathPrefix: "secret/sensitive/", NoCache: true},
+		},
+	})
+	defer w.Stop()
+	n := fakeNotifier("foo")
+	w.Register(n)
+	rec := w.Recaller(n)
+
+	fetch := func(d *idep.FakeVaultDep) {
+		if _, ok := rec(d); ok {
+			t.Fatal("expected no data before fetching")
+		}
+		if err := w.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		data, ok := rec(d)
+		if !ok || data != d.Path {
+			t.Fatalf("bad data: %v, %v", data, ok)
+		}
+	}
+
+	t.Run("no-cache", func(t *testing.T) {
+		d := &idep.FakeVaultDep{Path: "secret/sensitive/db"}
+		fetch(d)
+		if _, ok := st.Recall(d.ID()); ok {
+			t.Error("data should not be cached")
+		}
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		d := &idep.FakeVaultDep{Path: "secret/app"}
+		fetch(d)
+		if _, ok := st.Recall(d.ID()); !ok {
+			t.Error("data should be cached")
+		}
+		if age := d.GetOptions().MaxAge; age != time.Minute {
+			t.Errorf("bad max age: %v", age)
+		}
+	})
+}
@@ -25,7 +25,53 @@ type Clients interface {
 // Metadata returned by external dependency Fetch-ing.
 // LastIndex is used with the Consul backend. Needed to track changes.
 // LastContact is used to help calculate staleness of records.
+// Degraded is set (by the watcher) when the data was served from the Consul
+// agent's cache after the queries to the servers failed.
 type ResponseMetadata struct {
 	LastIndex   uint64
 	LastContact time.Duration
+	Degraded    bool
+}
+
+// LeaseEventType is the type of a LeaseEvent.
+type LeaseEventType int
+
+const (
+	// LeaseAcquired is sent when a secret with a lease is fetched.
+	LeaseAcquired LeaseEventType = iota
+	// LeaseRenewed is sent when a secret's lease is renewed.
+	LeaseRenewed
+	// LeaseExpiring is sent when a secret's lease is close to expiring and
+	// can't be renewed (further), the secret will be fetched again.
+	LeaseExpiring
+	// LeaseRevoked is sent when renewing a secret's lease fails, eg. because
+	// it was revoked. Err is the error.
+	LeaseRevoked
+)
+
+func (t LeaseEventType) String() string {
+	switch t {
+	case LeaseAcquired:
+		return "acquired"
+	case LeaseRenewed:
+		return "renewed"
+	case LeaseExpiring:
+		return "expiring"
+	case LeaseRevoked:
+		return "revoked"
+	}
+	return fmt.Sprintf("LeaseEventType(%d)", int(t))
+}
+
+// LeaseEvent is an event in the lifecycle of the lease of a Vault secret.
+type LeaseEvent struct {
+	Type LeaseEventType
+	// ID is the ID of the dependency the secret is for.
+	ID      string
+	LeaseID string
+	// LeaseDuration is the duration of the lease as of the event.
+	LeaseDuration time.Duration
+	Renewable     bool
+	// Err is the error for LeaseRevoked events.
+	Err error
 }
@@ -7,7 +7,8 @@ external dependency as used by this library.
 
 It is a sub-package as all the current dependency implentations are contained
 in an internal/ package as it needs some significant refactoring that shouldn't
-interfere with the initial release.
+interfere with the initial release. See the depext package for the types and
+helpers to implement one.
 
 */
 package dep
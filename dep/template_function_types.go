@@ -1,11 +1,19 @@
 package dep
 
 import (
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/hashicorp/consul/api"
 )
 
+// SchemaVersion is the version of the field sets of the dependencies' data
+// types (eg. HealthService) as serialized by the Store (see hcat.Store.Encode).
+// It is increased when fields are removed, renamed or change meaning, so data
+// encoded by an older version is migrated instead of silently misread.
+const SchemaVersion = 1
+
 // Node is a node entry in Consul
 type Node struct {
 	ID              string
@@ -25,6 +33,16 @@ type CatalogNode struct {
 // ServiceTags is a slice of tags assigned to a Service
 type ServiceTags []string
 
+// Contains returns true if the tags contain the tag.
+func (t ServiceTags) Contains(tag string) bool {
+	for _, v := range t {
+		if v == tag {
+			return true
+		}
+	}
+	return false
+}
+
 // CatalogNodeService is a service on a single node.
 type CatalogNodeService struct {
 	ID                string
@@ -42,6 +60,44 @@ type CatalogSnippet struct {
 	Tags ServiceTags
 }
 
+// Namespace is a Consul Enterprise namespace.
+type Namespace struct {
+	Name        string
+	Description string
+	Meta        map[string]string
+}
+
+// Partition is a Consul Enterprise admin partition.
+type Partition struct {
+	Name        string
+	Description string
+}
+
+// ExportedServices is a Consul exported-services config entry, the services
+// a partition exports to other partitions and cluster peers.
+type ExportedServices struct {
+	// Name is the name of the partition the services are exported from.
+	Name     string
+	Services []ExportedService
+	Meta     map[string]string
+}
+
+// ExportedService is a service, or all services (name "*") in the namespace,
+// exported to its consumers.
+type ExportedService struct {
+	Name      string
+	Namespace string
+	Consumers []ServiceConsumer
+}
+
+// ServiceConsumer is a consumer of an exported service, one of a partition,
+// a cluster peer or a sameness group.
+type ServiceConsumer struct {
+	Partition     string
+	Peer          string
+	SamenessGroup string
+}
+
 // HealthService is a service entry in Consul.
 type HealthService struct {
 	Node                   string
@@ -62,6 +118,132 @@ type HealthService struct {
 	Port                   int
 	Weights                api.AgentWeights
 	Namespace              string
+	// Locality is the service's region and zone, the node's if the service
+	// has none. Nil unless set in Consul (1.17 and later).
+	Locality *Locality
+}
+
+// Locality is the region and zone of a service or node, eg. the cloud
+// provider's.
+type Locality struct {
+	Region string
+	Zone   string
+}
+
+// EffectiveWeight returns the weight of the service instance based on its
+// current health status. The Passing weight for passing instances, the Warning
+// weight for those with warnings and 0 otherwise.
+func (s *HealthService) EffectiveWeight() int {
+	switch s.Status {
+	case api.HealthPassing:
+		return s.Weights.Passing
+	case api.HealthWarning:
+		return s.Weights.Warning
+	default:
+		return 0
+	}
+}
+
+// AddressFor returns the service's address for the named tagged address, eg.
+// "lan", "wan", "lan_ipv6" or "virtual". The service's tagged addresses take
+// precedence over the node's. Falls back to the default Address if neither
+// has the tagged address.
+func (s *HealthService) AddressFor(name string) string {
+	if sa, ok := s.ServiceTaggedAddresses[name]; ok && sa.Address != "" {
+		return sa.Address
+	}
+	if addr, ok := s.NodeTaggedAddresses[name]; ok && addr != "" {
+		return addr
+	}
+	return s.Address
+}
+
+// PortFor returns the service's port for the named tagged address. Falls
+// back to the default Port if the service has no port for it.
+func (s *HealthService) PortFor(name string) int {
+	if sa, ok := s.ServiceTaggedAddresses[name]; ok && sa.Port != 0 {
+		return sa.Port
+	}
+	return s.Port
+}
+
+// LocalTo returns how local the service is to the region and zone: 2 if it
+// is in both, 1 if it is in the region but not the zone (or the zone is
+// empty) and 0 otherwise, including when it has no Locality.
+func (s *HealthService) LocalTo(region, zone string) int {
+	switch {
+	case s.Locality == nil || s.Locality.Region != region:
+		return 0
+	case zone != "" && s.Locality.Zone == zone:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// InMaintenance returns true if the service instance, or its node, is in
+// maintenance mode.
+func (s *HealthService) InMaintenance() bool {
+	return s.Status == api.HealthMaint
+}
+
+// DrainBuckets are service instances split into those that are active and
+// those that are draining, so the draining ones can be rendered differently
+// (eg. as backup servers) instead of being removed abruptly.
+type DrainBuckets struct {
+	Active   []*HealthService
+	Draining []*HealthService
+}
+
+// SRVRecord is a service instance as a DNS SRV record (RFC 2782).
+type SRVRecord struct {
+	Priority int
+	Weight   int
+	Port     int
+	// Target is the name of the instance's node, Address its address for the
+	// target's A/AAAA record.
+	Target  string
+	Address string
+}
+
+// NodeCoordinate is a node's network coordinate in Consul, used to estimate
+// the round trip time between nodes.
+type NodeCoordinate struct {
+	Node    string
+	Segment string
+	Coord   *Coordinate
+}
+
+// Coordinate is a Vivaldi network coordinate as computed by Consul.
+type Coordinate struct {
+	Vec        []float64
+	Error      float64
+	Adjustment float64
+	Height     float64
+}
+
+// DistanceTo returns the estimated round trip time to the other coordinate.
+// It uses the same calculation as Consul's `consul rtt` command. Returns an
+// error if the coordinates aren't compatible.
+func (c *Coordinate) DistanceTo(other *Coordinate) (time.Duration, error) {
+	if c == nil || other == nil {
+		return 0, fmt.Errorf("coordinate: missing coordinate")
+	}
+	if len(c.Vec) != len(other.Vec) {
+		return 0, fmt.Errorf("coordinate: dimensionality mismatch (%d != %d)",
+			len(c.Vec), len(other.Vec))
+	}
+	var sum float64
+	for i := range c.Vec {
+		diff := c.Vec[i] - other.Vec[i]
+		sum += diff * diff
+	}
+	dist := math.Sqrt(sum) + c.Height + other.Height
+	// only use the adjustment if it doesn't drive the distance negative
+	if adjusted := dist + c.Adjustment + other.Adjustment; adjusted > 0.0 {
+		dist = adjusted
+	}
+	return time.Duration(dist * float64(time.Second)), nil
 }
 
 // KvValue is here to type the KV return string
@@ -69,6 +251,34 @@ type KvValue string
 
 type KVExists bool
 
+// KvChange is the value of a watched key along with its previous value. It is
+// returned for key lookups with the "diff=true" option so custom Notifiers
+// can act only on meaningful transitions. Old is nil on the first lookup and
+// either is nil when the key doesn't exist.
+type KvChange struct {
+	Old *KeyPair
+	New *KeyPair
+}
+
+// Changed returns true if the key was created, deleted or its value changed.
+// Writes that don't change the value return false.
+func (c KvChange) Changed() bool {
+	switch {
+	case c.Old == nil || c.New == nil:
+		return c.Old != c.New
+	default:
+		return c.Old.Value != c.New.Value
+	}
+}
+
+// Value returns the current value of the key, empty if it doesn't exist.
+func (c KvChange) Value() KvValue {
+	if c.New == nil {
+		return ""
+	}
+	return KvValue(c.New.Value)
+}
+
 // KeyPair is a simple Key-Value pair
 type KeyPair struct {
 	Path   string
@@ -84,6 +294,17 @@ type KeyPair struct {
 	Session     string
 }
 
+// Locked returns true if the key is held by a session (acquired as a lock).
+func (p *KeyPair) Locked() bool {
+	return p != nil && p.Session != ""
+}
+
+// HasFlag returns true if all the bits of flag are set in the key's Flags,
+// for applications using them as typed hints.
+func (p *KeyPair) HasFlag(flag uint64) bool {
+	return p != nil && p.Flags&flag == flag
+}
+
 // Secret is the structure returned for every secret within Vault.
 type Secret struct {
 	// The request ID that generated this response
@@ -132,3 +353,80 @@ type SecretWrapInfo struct {
 	CreationTime    time.Time
 	WrappedAccessor string
 }
+
+// AWSCredentials are the credentials from Vault's AWS secrets engine, either
+// an IAM user's or temporary STS ones.
+type AWSCredentials struct {
+	AccessKey string
+	SecretKey string
+	// SessionToken is set for STS credentials
+	SessionToken string
+	// ARN is the ARN of the assumed role or federated user, for STS
+	// credentials
+	ARN string
+
+	LeaseID       string
+	LeaseDuration time.Duration
+	Renewable     bool
+}
+
+// GCPToken is an OAuth2 access token from Vault's GCP secrets engine.
+type GCPToken struct {
+	Token     string
+	ExpiresAt time.Time
+	TTL       time.Duration
+}
+
+// AzureCredentials are the service principal credentials from Vault's Azure
+// secrets engine.
+type AzureCredentials struct {
+	ClientID     string
+	ClientSecret string
+
+	LeaseID       string
+	LeaseDuration time.Duration
+	Renewable     bool
+}
+
+// IdentityEntity is an entity of Vault's identity secrets engine, a client
+// known to Vault with its aliases in the auth methods.
+type IdentityEntity struct {
+	ID       string
+	Name     string
+	Disabled bool
+	Policies []string
+	Metadata map[string]string
+	Aliases  []*IdentityAlias
+	// GroupIDs are the IDs of all the groups the entity is a member of,
+	// DirectGroupIDs those it is a direct member of and InheritedGroupIDs
+	// those it is a member of through their subgroups.
+	GroupIDs          []string
+	DirectGroupIDs    []string
+	InheritedGroupIDs []string
+}
+
+// IdentityAlias is the alias of an identity entity or group in an auth
+// method, eg. a user name or a group of the auth method's provider.
+type IdentityAlias struct {
+	ID            string
+	Name          string
+	MountAccessor string
+	MountPath     string
+	MountType     string
+	Metadata      map[string]string
+}
+
+// IdentityGroup is a group of Vault's identity secrets engine.
+type IdentityGroup struct {
+	ID   string
+	Name string
+	// Type is "internal", for groups whose members are managed in Vault, or
+	// "external" for those mapped to a group of an auth method by the Alias.
+	Type            string
+	Policies        []string
+	Metadata        map[string]string
+	MemberEntityIDs []string
+	MemberGroupIDs  []string
+	ParentGroupIDs  []string
+	Alias           *IdentityAlias
+}
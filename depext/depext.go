@@ -0,0 +1,162 @@
+/*
+Package depext provides the types and helpers for implementing dependencies
+outside of hcat.
+
+A dependency needs to implement the dep.Dependency interface. Beyond that
+hcat looks for a few optional interfaces to decide how to handle it.
+
+  - Embed IsConsul or IsVault to mark which upstream the dependency queries.
+    This picks the retry function (WatcherInput's ConsulRetryFunc or
+    VaultRetryFunc) used when fetching it fails.
+  - Embed IsExec for dependencies running commands, so they can be denied
+    with the WatcherInput's DenyDependencies (see hcat.ExecDependencies).
+  - Embed IsBlocking for dependencies using Consul's blocking queries. A nil
+    result from a blocking query is treated as "no change yet" and is not
+    stored.
+  - Implement QueryOptionsSetter to have the options (wait index and time,
+    stale reads, etc.) set before each Fetch.
+
+See the package example for a complete dependency.
+*/
+package depext
+
+import (
+	"regexp"
+	"sync"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+// Type annotations, embed these in the dependency's struct.
+type (
+	// IsConsul marks the dependency as querying Consul.
+	IsConsul = idep.IsConsul
+	// IsVault marks the dependency as querying Vault.
+	IsVault = idep.IsVault
+	// IsExec marks the dependency as running commands.
+	IsExec = idep.IsExec
+	// IsBlocking marks the dependency as using blocking queries.
+	IsBlocking = idep.IsBlocking
+)
+
+// Interfaces for the type annotations, implemented by embedding the above.
+type (
+	ConsulType    = idep.ConsulType
+	VaultType     = idep.VaultType
+	ExecType      = idep.ExecType
+	BlockingQuery = idep.BlockingQuery
+)
+
+// QueryOptions are the options set on the dependency before each Fetch.
+type QueryOptions = idep.QueryOptions
+
+// QueryOptionsSetter is implemented by dependencies that want the
+// QueryOptions set before each Fetch.
+type QueryOptionsSetter = idep.QueryOptionsSetter
+
+// Regular expression fragments for parsing the dependency's string format,
+// as used by the built in dependencies. Each has a named capture group for
+// use with RegexpMatch.
+//
+// For example, the format of the health.service dependency is
+// `tag.name@dc~near|filter`, matched by:
+//
+//	regexp.MustCompile(`\A` + TagRe + ServiceNameRe + DatacenterRe +
+//		NearRe + FilterRe + `\z`)
+const (
+	// DatacenterRe matches an optional `@dc`, captured as "dc".
+	DatacenterRe = idep.DatacenterRe
+	// KeyRe matches a KV key, captured as "key".
+	KeyRe = idep.KeyRe
+	// FilterRe matches an optional `|filter,filter`, captured as "filter".
+	FilterRe = idep.FilterRe
+	// ServiceNameRe matches a service name, captured as "name".
+	ServiceNameRe = idep.ServiceNameRe
+	// NodeNameRe matches a node name, captured as "name".
+	NodeNameRe = idep.NodeNameRe
+	// NearRe matches an optional `~node`, captured as "near".
+	NearRe = idep.NearRe
+	// PrefixRe matches a KV prefix, captured as "prefix".
+	PrefixRe = idep.PrefixRe
+	// TagRe matches an optional `tag.` prefix, captured as "tag".
+	TagRe = idep.TagRe
+)
+
+// RegexpMatch matches the string against the regular expression and returns
+// the named capture groups, keyed by name. Groups that didn't match are
+// empty strings and no match returns an empty map.
+func RegexpMatch(re *regexp.Regexp, s string) map[string]string {
+	return idep.RegexpMatch(re, s)
+}
+
+// ConsulMetadata returns the ResponseMetadata for the metadata of a Consul
+// (blocking) query.
+func ConsulMetadata(qm *consulapi.QueryMeta) *dep.ResponseMetadata {
+	if qm == nil {
+		return &dep.ResponseMetadata{}
+	}
+	return &dep.ResponseMetadata{
+		LastIndex:   qm.LastIndex,
+		LastContact: qm.LastContact,
+	}
+}
+
+// RespWithMetadata returns the data from Fetch with ResponseMetadata for
+// dependencies that don't have an index of their own (eg. aren't Consul
+// blocking queries). The index is the current time so each fetch counts as
+// new, hcat then compares the data with the previous data to detect changes.
+func RespWithMetadata(data interface{}) (interface{}, *dep.ResponseMetadata, error) {
+	return data, &dep.ResponseMetadata{
+		LastIndex: uint64(time.Now().Unix()),
+	}, nil
+}
+
+// Stopper implements the stopping of a dependency. Embed it (as a pointer
+// created with NewStopper) to get the dependency's Stop method and use
+// Stopped or Sleep in Fetch to return early when stopped.
+type Stopper struct {
+	once   sync.Once
+	stopCh chan struct{}
+}
+
+// NewStopper returns a new Stopper.
+func NewStopper() *Stopper {
+	return &Stopper{stopCh: make(chan struct{})}
+}
+
+// Stop stops the dependency. It is safe to call more than once.
+func (s *Stopper) Stop() {
+	s.once.Do(func() { close(s.stopCh) })
+}
+
+// StopCh returns the channel that is closed when stopped.
+func (s *Stopper) StopCh() <-chan struct{} {
+	return s.stopCh
+}
+
+// Stopped returns dep.ErrStopped if stopped, nil otherwise. Use it at the
+// start of Fetch.
+func (s *Stopper) Stopped() error {
+	select {
+	case <-s.stopCh:
+		return dep.ErrStopped
+	default:
+		return nil
+	}
+}
+
+// Sleep waits for the duration, returning early with dep.ErrStopped if
+// stopped. Use it to poll upstreams that don't support blocking queries, eg.
+// sleeping before each fetch after the first (when the QueryOptions'
+// WaitIndex is not zero).
+func (s *Stopper) Sleep(d time.Duration) error {
+	select {
+	case <-s.stopCh:
+		return dep.ErrStopped
+	case <-time.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,50 @@
+package depext
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+)
+
+func TestRegexpMatch(t *testing.T) {
+	re := regexp.MustCompile(`\A` + TagRe + ServiceNameRe + DatacenterRe +
+		NearRe + FilterRe + `\z`)
+	m := RegexpMatch(re, "primary.web@dc1~agent|passing,warning")
+	exp := map[string]string{
+		"tag":    "primary",
+		"name":   "web",
+		"dc":     "dc1",
+		"near":   "agent",
+		"filter": "passing,warning",
+	}
+	for k, v := range exp {
+		if m[k] != v {
+			t.Errorf("bad %s, exp: %q, act: %q", k, v, m[k])
+		}
+	}
+
+	if m := RegexpMatch(re, "bad name"); len(m) != 0 {
+		t.Errorf("expected no match, got: %v", m)
+	}
+}
+
+func TestStopper(t *testing.T) {
+	s := NewStopper()
+	if err := s.Stopped(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Sleep(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Stop()
+	s.Stop() // safe to call again
+	if err := s.Stopped(); err != dep.ErrStopped {
+		t.Errorf("expected ErrStopped, got: %v", err)
+	}
+	if err := s.Sleep(time.Minute); err != dep.ErrStopped {
+		t.Errorf("expected ErrStopped, got: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package depext_test
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/hashicorp/hcat/depext"
+)
+
+// ServiceMetaQuery returns the service's meta, as seen in the catalog.
+type ServiceMetaQuery struct {
+	depext.IsConsul
+	depext.IsBlocking
+	*depext.Stopper
+
+	name, dc string
+	opts     depext.QueryOptions
+}
+
+var serviceMetaRe = regexp.MustCompile(
+	`\A` + depext.ServiceNameRe + depext.DatacenterRe + `\z`)
+
+func NewServiceMetaQuery(s string) (*ServiceMetaQuery, error) {
+	if !serviceMetaRe.MatchString(s) {
+		return nil, fmt.Errorf("service.meta: invalid format: %q", s)
+	}
+	m := depext.RegexpMatch(serviceMetaRe, s)
+	return &ServiceMetaQuery{
+		Stopper: depext.NewStopper(),
+		name:    m["name"],
+		dc:      m["dc"],
+	}, nil
+}
+
+func (d *ServiceMetaQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	if err := d.Stopped(); err != nil {
+		return nil, nil, err
+	}
+	opts := d.opts.Merge(&depext.QueryOptions{Datacenter: d.dc})
+	services, qm, err := clients.Consul().Catalog().Service(d.name, "",
+		opts.ToConsulOpts())
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", d.ID(), err)
+	}
+	meta := make(map[string]string)
+	for _, s := range services {
+		for k, v := range s.ServiceMeta {
+			meta[k] = v
+		}
+	}
+	return meta, depext.ConsulMetadata(qm), nil
+}
+
+func (d *ServiceMetaQuery) SetOptions(opts depext.QueryOptions) {
+	d.opts = opts
+}
+
+func (d *ServiceMetaQuery) ID() string {
+	if d.dc != "" {
+		return fmt.Sprintf("service.meta(%s@%s)", d.name, d.dc)
+	}
+	return fmt.Sprintf("service.meta(%s)", d.name)
+}
+
+func (d *ServiceMetaQuery) String() string {
+	return d.ID()
+}
+
+// Implements a dependency returning a service's meta data from Consul.
+func Example() {
+	d, err := NewServiceMetaQuery("web@dc2")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var _ dep.Dependency = d
+	var _ depext.QueryOptionsSetter = d
+	_, consul := interface{}(d).(depext.ConsulType)
+	_, blocking := interface{}(d).(depext.BlockingQuery)
+	fmt.Println(d.ID(), consul, blocking)
+	// Output:
+	// service.meta(web@dc2) true true
+}
@@ -0,0 +1,123 @@
+package hcat
+
+import (
+	"reflect"
+
+	"github.com/hashicorp/hcat/dep"
+)
+
+// ListDiff is the difference between the previous and new data of a list
+// dependency, eg. the instances of a health service. Each field is a slice of
+// the same type as the data (eg. []*dep.HealthService), nil if empty.
+type ListDiff struct {
+	// Added are the new items, in the new data's order
+	Added interface{}
+	// Removed are the items no longer in the list, in the previous data's
+	// order
+	Removed interface{}
+	// Changed are the new versions of the items that changed, in the new
+	// data's order
+	Changed interface{}
+}
+
+// DiffNotifier is a Notifier that also needs the changes to list data, so it
+// can push incremental updates instead of diffing the full lists itself. The
+// Watcher calls NotifyDiff in place of Notify (and NotifyDependency) for
+// notifiers that implement it.
+//
+// The diff is against the dependency's previous data, when it first has data
+// all the items are added. It is nil if the data isn't a
+// list of health services, nodes, catalog services or key pairs.
+type DiffNotifier interface {
+	Notifier
+	NotifyDiff(id string, data interface{}, diff *ListDiff) bool
+}
+
+// diffKey returns the identity of a list item, the item is "changed" if an
+// item with the same key differs. False if the item type isn't supported.
+func diffKey(item interface{}) (string, bool) {
+	switch v := item.(type) {
+	case *dep.HealthService:
+		return v.Node + "/" + v.ID, true
+	case *dep.Node:
+		return v.Node, true
+	case *dep.CatalogSnippet:
+		return v.Name, true
+	case *dep.KeyPair:
+		return v.Path, true
+	}
+	return "", false
+}
+
+// diffLists returns the difference between the lists, nil if data isn't a
+// supported list type or prev isn't the same type (eg. nil).
+func diffLists(prev, data interface{}) *ListDiff {
+	dv := reflect.ValueOf(data)
+	if dv.Kind() != reflect.Slice {
+		return nil
+	}
+	// the supported items are all pointers, check with a new one
+	elem := dv.Type().Elem()
+	if elem.Kind() != reflect.Ptr {
+		return nil
+	}
+	if _, ok := diffKey(reflect.New(elem.Elem()).Interface()); !ok {
+		return nil
+	}
+	pv := reflect.ValueOf(prev)
+	if !pv.IsValid() || pv.Type() != dv.Type() {
+		pv = reflect.MakeSlice(dv.Type(), 0, 0)
+	}
+
+	keyed := func(list reflect.Value) map[string]interface{} {
+		m := make(map[string]interface{}, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			if list.Index(i).IsNil() {
+				continue
+			}
+			item := list.Index(i).Interface()
+			key, _ := diffKey(item)
+			m[key] = item
+		}
+		return m
+	}
+	prevItems, newItems := keyed(pv), keyed(dv)
+
+	var diff ListDiff
+	empty := func() reflect.Value { return reflect.MakeSlice(dv.Type(), 0, 0) }
+	added, changed, removed := empty(), empty(), empty()
+	for i := 0; i < dv.Len(); i++ {
+		if dv.Index(i).IsNil() {
+			continue
+		}
+		key, _ := diffKey(dv.Index(i).Interface())
+		old, ok := prevItems[key]
+		switch {
+		case !ok:
+			added = reflect.Append(added, dv.Index(i))
+		case !reflect.DeepEqual(old, dv.Index(i).Interface()):
+			changed = reflect.Append(changed, dv.Index(i))
+		}
+	}
+	for i := 0; i < pv.Len(); i++ {
+		if pv.Index(i).IsNil() {
+			continue
+		}
+		key, _ := diffKey(pv.Index(i).Interface())
+		if _, ok := newItems[key]; !ok {
+			removed = reflect.Append(removed, pv.Index(i))
+		}
+	}
+	diff.Added = sliceOrNil(added)
+	diff.Changed = sliceOrNil(changed)
+	diff.Removed = sliceOrNil(removed)
+	return &diff
+}
+
+// sliceOrNil returns the slice, or nil if it is empty.
+func sliceOrNil(s reflect.Value) interface{} {
+	if s.Len() == 0 {
+		return nil
+	}
+	return s.Interface()
+}
@@ -0,0 +1,130 @@
+package hcat
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+func TestDiffLists(t *testing.T) {
+	t.Parallel()
+
+	svc := func(node, id, addr string) *dep.HealthService {
+		return &dep.HealthService{Node: node, ID: id, Address: addr}
+	}
+	a, b, c := svc("n1", "web", "10.0.0.1"), svc("n2", "web", "10.0.0.2"),
+		svc("n3", "web", "10.0.0.3")
+	b2 := svc("n2", "web", "10.0.0.22")
+
+	cases := []struct {
+		name string
+		prev interface{}
+		data interface{}
+		exp  *ListDiff
+	}{
+		{
+			"first",
+			nil,
+			[]*dep.HealthService{a, b},
+			&ListDiff{Added: []*dep.HealthService{a, b}},
+		},
+		{
+			"added-removed-changed",
+			[]*dep.HealthService{a, b},
+			[]*dep.HealthService{b2, c},
+			&ListDiff{
+				Added:   []*dep.HealthService{c},
+				Removed: []*dep.HealthService{a},
+				Changed: []*dep.HealthService{b2},
+			},
+		},
+		{
+			"no-changes",
+			[]*dep.HealthService{a, b},
+			[]*dep.HealthService{svc("n2", "web", "10.0.0.2"), a},
+			&ListDiff{},
+		},
+		{
+			"key-pairs",
+			[]*dep.KeyPair{{Path: "a", Value: "1"}},
+			[]*dep.KeyPair{{Path: "a", Value: "2"}, {Path: "b"}},
+			&ListDiff{
+				Added:   []*dep.KeyPair{{Path: "b"}},
+				Changed: []*dep.KeyPair{{Path: "a", Value: "2"}},
+			},
+		},
+		{
+			"unsupported-list",
+			nil,
+			[]string{"a"},
+			nil,
+		},
+		{
+			"not-a-list",
+			nil,
+			"a",
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			act := diffLists(tc.prev, tc.data)
+			if !reflect.DeepEqual(tc.exp, act) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.exp, act)
+			}
+		})
+	}
+
+	t.Run("data-unchanged", func(t *testing.T) {
+		data := make([]*dep.HealthService, 2, 4)
+		data[0], data[1] = a, b
+		diffLists(nil, data)
+		if full := data[:4]; full[2] != nil || full[3] != nil {
+			t.Errorf("data's backing array modified: %v", full)
+		}
+	})
+}
+
+// testDiffNotifier records the diffs it is notified with
+type testDiffNotifier struct {
+	*dummyNotifier
+	diffs []*ListDiff
+}
+
+func (n *testDiffNotifier) NotifyDiff(id string, data interface{},
+	diff *ListDiff) bool {
+	n.diffs = append(n.diffs, diff)
+	return true
+}
+
+func TestWatcherDiffNotifier(t *testing.T) {
+	t.Parallel()
+
+	w := newWatcher()
+	defer w.Stop()
+	n := &testDiffNotifier{dummyNotifier: fakeNotifier("foo")}
+	w.Register(n)
+
+	a := &dep.HealthService{Node: "n1", ID: "web"}
+	b := &dep.HealthService{Node: "n2", ID: "web"}
+	d := &idep.FakeDep{Name: "web"}
+	w.dataCh <- w.track(n, d).store([]*dep.HealthService{a})
+	w.Wait(context.Background())
+	w.dataCh <- w.track(n, d).store([]*dep.HealthService{b})
+	w.Wait(context.Background())
+
+	exp := []*ListDiff{
+		{Added: []*dep.HealthService{a}},
+		{
+			Added:   []*dep.HealthService{b},
+			Removed: []*dep.HealthService{a},
+		},
+	}
+	if !reflect.DeepEqual(exp, n.diffs) {
+		t.Errorf("\nexp: %#v\nact: %#v", exp, n.diffs)
+	}
+}
@@ -144,6 +144,7 @@ func NotifierExample(clients *hcat.ClientSet) string {
 }
 
 // Embed template to allow overridding of Notify
+// (to only notify based on the dependency's ID, see hcat.PatternNotifier)
 type KvNotifier struct {
 	*hcat.Template
 }
@@ -153,10 +154,16 @@ type KvNotifier struct {
 // assert the types and notify based on the type and/or values.
 // Note calling Template's Notify() is needed to mark it as having new data.
 func (n KvNotifier) Notify(d interface{}) (notify bool) {
-	switch d.(type) {
+	switch v := d.(type) {
 	case dep.KvValue:
 		n.Template.Notify(d)
 		return true
+	case dep.KvChange: // keys looked up with the "diff=true" option
+		if !v.Changed() {
+			return false
+		}
+		n.Template.Notify(d)
+		return true
 	default:
 		return false
 	}
@@ -65,6 +65,8 @@ type MaxRetries struct {
 type NewData struct {
 	ID   string
 	Data interface{}
+	// Labels are the labels of the templates using the dependency, sorted.
+	Labels []string
 	event
 }
 
@@ -82,9 +84,89 @@ type NoNewData struct {
 	event
 }
 
+// CacheFallback indicates that the queries to the service failed repeatedly
+// so the data is being requested from the Consul agent's cache.
+type CacheFallback struct {
+	ID       string
+	Failures int
+	Error    error
+	event
+}
+
+// BackendHealth indicates that the health of a backend (Consul or Vault)
+// changed, or was probed for the first time. Error is why it is unhealthy.
+type BackendHealth struct {
+	Backend string
+	Healthy bool
+	Error   error
+	event
+}
+
+// ClientConnected indicates that a client's server (Backend is "consul" or
+// "vault") is reachable, after connecting a lazy client or the server being
+// unreachable. Name is that of a named client, empty for the default one.
+type ClientConnected struct {
+	Backend string
+	Name    string
+	event
+}
+
+// ClientDisconnected indicates that a client's server can't be reached, or
+// isn't ready (eg. has no leader) when connecting. Error is why.
+type ClientDisconnected struct {
+	Backend string
+	Name    string
+	Error   error
+	event
+}
+
+// ClientAuthFailed indicates that a client's server refused its credentials
+// when connecting it, eg. an invalid or expired token.
+type ClientAuthFailed struct {
+	Backend string
+	Name    string
+	Error   error
+	event
+}
+
+// TemplateQuarantined indicates that a template failed to execute or render
+// too many times in a row and won't be run until it is unquarantined. Error
+// is the last failure.
+type TemplateQuarantined struct {
+	ID       string
+	Failures int
+	Error    error
+	event
+}
+
+// TemplateUnquarantined indicates that a quarantined template is run again.
+type TemplateUnquarantined struct {
+	ID string
+	event
+}
+
+// Reloaded indicates that a reload action (see the reload package) ran
+// after the templates rendered changes.
+type Reloaded struct {
+	Action    string
+	Templates []string
+	event
+}
+
+// ReloadFailed indicates that a reload action (see the reload package)
+// failed. Error is why.
+type ReloadFailed struct {
+	Action    string
+	Templates []string
+	Error     error
+	event
+}
+
 // TrackStart indicates that a new data point is being tracked.
 type TrackStart struct {
 	ID string
+	// Labels are the labels of the templates using the dependency, sorted.
+	Labels []string
 	event
 }
 
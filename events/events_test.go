@@ -15,6 +15,15 @@ var (
 	_ Event = (*NewData)(nil)
 	_ Event = (*StaleData)(nil)
 	_ Event = (*NoNewData)(nil)
+	_ Event = (*CacheFallback)(nil)
+	_ Event = (*BackendHealth)(nil)
+	_ Event = (*ClientConnected)(nil)
+	_ Event = (*ClientDisconnected)(nil)
+	_ Event = (*ClientAuthFailed)(nil)
+	_ Event = (*TemplateQuarantined)(nil)
+	_ Event = (*TemplateUnquarantined)(nil)
+	_ Event = (*Reloaded)(nil)
+	_ Event = (*ReloadFailed)(nil)
 	_ Event = (*TrackStart)(nil)
 	_ Event = (*TrackStop)(nil)
 	_ Event = (*PollingWait)(nil)
@@ -26,7 +35,10 @@ func TestEvents(t *testing.T) {
 		switch e.(type) {
 		case Trace, BlockingWait, ServerContacted, ServerError,
 			ServerTimeout, RetryAttempt, MaxRetries, NewData, StaleData,
-			NoNewData, TrackStart, TrackStop, PollingWait:
+			NoNewData, CacheFallback, BackendHealth, ClientConnected,
+			ClientDisconnected, ClientAuthFailed, TemplateQuarantined,
+			TemplateUnquarantined, Reloaded, ReloadFailed, TrackStart,
+			TrackStop, PollingWait:
 		default:
 			t.Errorf("Bad event type: %T", e)
 		}
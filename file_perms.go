@@ -4,6 +4,8 @@ package hcat
 
 import (
 	"os"
+	"os/user"
+	"strconv"
 	"syscall"
 )
 
@@ -20,3 +22,42 @@ func preserveFilePermissions(path string, fileInfo os.FileInfo) error {
 
 	return nil
 }
+
+// syncDir calls fsync on the directory to persist changes to its entries.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// setFileOwner changes the file's owner to the user and group, names or
+// numeric IDs. Either can be empty to leave it as is.
+func setFileOwner(path, username, group string) error {
+	uid, gid := -1, -1
+	if username != "" {
+		id := username
+		if _, err := strconv.Atoi(id); err != nil {
+			u, err := user.Lookup(username)
+			if err != nil {
+				return err
+			}
+			id = u.Uid
+		}
+		uid, _ = strconv.Atoi(id)
+	}
+	if group != "" {
+		id := group
+		if _, err := strconv.Atoi(id); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return err
+			}
+			id = g.Gid
+		}
+		gid, _ = strconv.Atoi(id)
+	}
+	return os.Chown(path, uid, gid)
+}
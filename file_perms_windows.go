@@ -2,8 +2,24 @@
 
 package hcat
 
-import "os"
+import (
+	"errors"
+	"os"
+)
 
 func preserveFilePermissions(path string, fileInfo os.FileInfo) error {
 	return nil
 }
+
+// syncDir is a no-op as directories can't be synced on windows.
+func syncDir(dir string) error {
+	return nil
+}
+
+// setFileOwner isn't supported on windows, errors if the owner is set.
+func setFileOwner(path, username, group string) error {
+	if username == "" && group == "" {
+		return nil
+	}
+	return errors.New("setting the file owner is not supported on windows")
+}
@@ -0,0 +1,243 @@
+package hcat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+// FixtureJSONCodec encodes the entries as a JSON object of dependency IDs to
+// {"type": "[]*dep.HealthService", "value": ...} objects, decoded back to the
+// named types (see RegisterFixtureType). Unlike JSONCodec the decoded entries
+// have the dependencies' data types and can be used with templates, while the
+// fixtures are still readable and editable by hand. Numbers in the typed
+// values' interfaces, eg. a secret's data, are decoded as json.Number like
+// the Vault API does. Entries without a type are decoded as generic JSON
+// values.
+var FixtureJSONCodec StoreCodec = fixtureJSONCodec{}
+
+var (
+	fixtureTypesLock sync.RWMutex
+	fixtureTypes     = make(map[string]reflect.Type)
+)
+
+func init() {
+	for _, v := range dataTypes {
+		RegisterFixtureType(v)
+	}
+}
+
+// RegisterFixtureType registers the type of the value for FixtureJSONCodec,
+// named as formatted by %T (eg. "[]*dep.HealthService"). The data types of
+// the built-in dependencies are registered, custom dependencies (see depext)
+// register theirs to be encoded in JSON fixtures.
+func RegisterFixtureType(v interface{}) {
+	t := reflect.TypeOf(v)
+	fixtureTypesLock.Lock()
+	defer fixtureTypesLock.Unlock()
+	fixtureTypes[t.String()] = t
+}
+
+func fixtureType(name string) (reflect.Type, bool) {
+	fixtureTypesLock.RLock()
+	defer fixtureTypesLock.RUnlock()
+	t, ok := fixtureTypes[name]
+	return t, ok
+}
+
+// fixtureEntry is an entry of a JSON fixture
+type fixtureEntry struct {
+	Type  string          `json:"type,omitempty"`
+	Value json.RawMessage `json:"value"`
+}
+
+type fixtureJSONCodec struct{}
+
+func (fixtureJSONCodec) Encode(w io.Writer, entries map[string]interface{}) error {
+	out := make(map[string]fixtureEntry, len(entries))
+	for id, v := range entries {
+		var e fixtureEntry
+		if v != nil {
+			e.Type = fmt.Sprintf("%T", v)
+			if _, ok := fixtureType(e.Type); !ok {
+				return fmt.Errorf("%s: unregistered fixture type %s", id, e.Type)
+			}
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return errors.Wrap(err, id)
+		}
+		e.Value = b
+		out[id] = e
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func (fixtureJSONCodec) Decode(r io.Reader) (map[string]interface{}, error) {
+	var in map[string]fixtureEntry
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, err
+	}
+	entries := make(map[string]interface{}, len(in))
+	for id, e := range in {
+		if e.Type == "" {
+			var v interface{}
+			if len(e.Value) > 0 {
+				if err := json.Unmarshal(e.Value, &v); err != nil {
+					return nil, errors.Wrap(err, id)
+				}
+			}
+			entries[id] = v
+			continue
+		}
+		t, ok := fixtureType(e.Type)
+		if !ok {
+			return nil, fmt.Errorf("%s: unregistered fixture type %s", id, e.Type)
+		}
+		// numbers in interface values stay json.Numbers, as the Vault API
+		// decodes them
+		v := reflect.New(t)
+		dec := json.NewDecoder(bytes.NewReader(e.Value))
+		dec.UseNumber()
+		if err := dec.Decode(v.Interface()); err != nil {
+			return nil, errors.Wrap(err, id)
+		}
+		entries[id] = v.Elem().Interface()
+	}
+	return entries, nil
+}
+
+// LoadFixture reads the fixture file, dependency data keyed by dependency ID,
+// into a new Store for a FixtureWatcher. Fixtures are snapshots of a Watcher's
+// cache (see WatcherInput's Cache) written with Store.Encode, so production
+// renders can be reproduced locally. Files with a .json extension are decoded
+// with FixtureJSONCodec, others with GobCodec.
+func LoadFixture(path string) (*Store, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "load fixture")
+	}
+	defer f.Close()
+
+	opts := StoreOptions{Codec: GobCodec}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		opts.Codec = FixtureJSONCodec
+	}
+	s := NewStoreWithOptions(opts)
+	if err := s.Decode(f); err != nil {
+		return nil, errors.Wrapf(err, "load fixture %s", path)
+	}
+	return s, nil
+}
+
+// FixtureWatcher is a Watcherer answering the templates' dependencies from a
+// Store of recorded data (see LoadFixture), for dry runs of the resolve and
+// execute pipeline without any clients. Nothing is ever fetched: dependencies
+// missing from the Store stay missing and keep the templates using them from
+// being Complete (see Missing). Templates don't need to be registered with it.
+// It is safe for concurrent use.
+type FixtureWatcher struct {
+	store *Store
+
+	sync.Mutex
+	missing map[string]map[string]struct{} // by notifier ID
+}
+
+// NewFixtureWatcher returns a FixtureWatcher recalling the data from the
+// Store.
+func NewFixtureWatcher(store *Store) *FixtureWatcher {
+	return &FixtureWatcher{
+		store:   store,
+		missing: make(map[string]map[string]struct{}),
+	}
+}
+
+// Buffering is always false, there are no changes to buffer.
+func (w *FixtureWatcher) Buffering(Notifier) bool {
+	return false
+}
+
+// Recaller returns a Recaller recalling the data from the Store, recording
+// the dependencies it doesn't have as missing for the notifier's execution.
+// The status of dependencies (see DependencyErrorQuery) is always healthy.
+func (w *FixtureWatcher) Recaller(n Notifier) Recaller {
+	missing := make(map[string]struct{})
+	w.Lock()
+	w.missing[n.ID()] = missing
+	w.Unlock()
+
+	return func(d dep.Dependency) (interface{}, bool) {
+		if q, ok := d.(*DependencyErrorQuery); ok {
+			return DependencyStatus{ID: q.Target}, true
+		}
+		data, ok := w.store.Recall(d.ID())
+		if !ok {
+			w.Lock()
+			missing[d.ID()] = struct{}{}
+			w.Unlock()
+		}
+		return data, ok
+	}
+}
+
+// Complete returns true if the notifier's last execution found all its
+// dependencies in the Store.
+func (w *FixtureWatcher) Complete(n Notifier) bool {
+	w.Lock()
+	defer w.Unlock()
+	return len(w.missing[n.ID()]) == 0
+}
+
+// Missing returns the sorted IDs of the dependencies the notifier's last
+// execution didn't find in the Store.
+func (w *FixtureWatcher) Missing(n Notifier) []string {
+	w.Lock()
+	defer w.Unlock()
+	var ids []string
+	for id := range w.missing[n.ID()] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// RunFixture renders the templates once against the FixtureWatcher's data,
+// running them through the resolver (a new one if nil, eg. pass one in
+// dry-run mode to capture the output) and calling handler with each
+// template's ResolveEvent in order of their Priority. As the data never
+// changes a single run is all there is: the events of templates using
+// dependencies missing from the fixture aren't Complete and list them in
+// their Missing. Errors executing a template or returned from the handler
+// stop the run and are returned.
+func RunFixture(r *Resolver, w *FixtureWatcher, tmpls []Templater,
+	handler func(ResolveEvent) error) error {
+	if r == nil {
+		r = NewResolver()
+	}
+	for _, tmpl := range newRunSchedule(tmpls).tmpls {
+		event, err := r.Run(tmpl, w)
+		if err != nil {
+			return err
+		}
+		if !event.Complete {
+			event.Missing = w.Missing(tmpl)
+		}
+		if err := handler(event); err != nil {
+			return err
+		}
+		r.Rendered(event)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package hcat
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"text/template"
+
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+// servicesFunc recalls the catalog services, for the fixture tests
+func servicesFunc(recall Recaller) interface{} {
+	return func() []*dep.CatalogSnippet {
+		d, _ := idep.NewCatalogServicesQuery("")
+		if value, ok := recall(d); ok {
+			return value.([]*dep.CatalogSnippet)
+		}
+		return nil
+	}
+}
+
+// fixtureStore is the store recorded by the fixture tests
+func fixtureStore(codec StoreCodec) *Store {
+	st := NewStoreWithOptions(StoreOptions{Codec: codec})
+	st.Save("catalog.services", []*dep.CatalogSnippet{
+		{Name: "web", Tags: dep.ServiceTags{"a"}}})
+	st.Save("test_dep(foo)", "bar")
+	st.Save("kv.get(foo)", dep.KvValue("baz"))
+	st.Save("kv.get(missing)", nil)
+	st.Save("vault.read(secret/foo)", &dep.Secret{
+		Data: map[string]interface{}{"password": "zap"}})
+	return st
+}
+
+// fixtureIDs are the IDs of the fixtureStore entries
+var fixtureIDs = []string{"catalog.services", "test_dep(foo)", "kv.get(foo)",
+	"kv.get(missing)", "vault.read(secret/foo)"}
+
+func TestFixtureJSONCodec(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round-trip", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := fixtureStore(FixtureJSONCodec).Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var raw map[string]fixtureEntry
+		if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+			t.Fatalf("not json: %v: %s", err, buf.String())
+		}
+		if raw["catalog.services"].Type != "[]*dep.CatalogSnippet" {
+			t.Errorf("bad json: %s", buf.String())
+		}
+
+		st := NewStoreWithOptions(StoreOptions{Codec: FixtureJSONCodec})
+		if err := st.Decode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		exp := fixtureStore(nil)
+		for _, id := range fixtureIDs {
+			act, ok := st.Recall(id)
+			if !ok {
+				t.Fatalf("missing %s", id)
+			}
+			if data, _ := exp.Recall(id); !reflect.DeepEqual(act, data) {
+				t.Errorf("bad %s: %#v", id, act)
+			}
+		}
+	})
+
+	t.Run("untyped", func(t *testing.T) {
+		entries, err := FixtureJSONCodec.Decode(bytes.NewBufferString(
+			`{"foo": {"value": {"a": [1]}}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := map[string]interface{}{"a": []interface{}{1.0}}
+		if !reflect.DeepEqual(entries["foo"], exp) {
+			t.Errorf("bad entry: %#v", entries["foo"])
+		}
+	})
+
+	t.Run("unregistered", func(t *testing.T) {
+		type custom struct{ A string }
+		err := FixtureJSONCodec.Encode(ioutil.Discard,
+			map[string]interface{}{"foo": custom{}})
+		if err == nil {
+			t.Error("expected encode error")
+		}
+		_, err = FixtureJSONCodec.Decode(bytes.NewBufferString(
+			`{"foo": {"type": "hcat.custom", "value": {}}}`))
+		if err == nil {
+			t.Error("expected decode error")
+		}
+
+		RegisterFixtureType(custom{})
+		var buf bytes.Buffer
+		err = FixtureJSONCodec.Encode(&buf,
+			map[string]interface{}{"foo": custom{A: "a"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := FixtureJSONCodec.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entries["foo"] != (custom{A: "a"}) {
+			t.Errorf("bad entry: %#v", entries["foo"])
+		}
+	})
+}
+
+func TestRunFixture(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	funcs := template.FuncMap{"echo": echoFunc, "services": servicesFunc}
+	for _, name := range []string{"fixture.json", "fixture.gob"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			codec := GobCodec
+			if filepath.Ext(name) == ".json" {
+				codec = FixtureJSONCodec
+			}
+			var buf bytes.Buffer
+			if err := fixtureStore(codec).Encode(&buf); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, name)
+			if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			st, err := LoadFixture(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exp := fixtureStore(nil)
+			for _, id := range fixtureIDs {
+				act, _ := st.Recall(id)
+				if data, _ := exp.Recall(id); !reflect.DeepEqual(act, data) {
+					t.Errorf("bad %s: %#v", id, act)
+				}
+			}
+			tmpls := []Templater{
+				NewTemplate(TemplateInput{
+					Name:         "complete",
+					Contents:     `{{ range services }}{{ .Name }}{{ end }} {{ echo "foo" }}`,
+					FuncMapMerge: funcs,
+				}),
+				NewTemplate(TemplateInput{
+					Name:         "missing",
+					Contents:     `{{ echo "other" }}`,
+					FuncMapMerge: funcs,
+				}),
+			}
+			var events []ResolveEvent
+			err = RunFixture(nil, NewFixtureWatcher(st), tmpls,
+				func(e ResolveEvent) error {
+					events = append(events, e)
+					return nil
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 2 {
+				t.Fatalf("bad events: %#v", events)
+			}
+			if !events[0].Complete || string(events[0].Contents) != "web bar" {
+				t.Errorf("bad event: %#v", events[0])
+			}
+			if events[1].Complete || !reflect.DeepEqual(events[1].Missing,
+				[]string{"test_dep(other)"}) {
+				t.Errorf("bad event: %#v", events[1])
+			}
+		})
+	}
+
+	t.Run("dry-run", func(t *testing.T) {
+		rv := NewResolver()
+		sink := NewDryRunSink()
+		rv.SetDryRun(sink)
+		tt := NewTemplate(TemplateInput{
+			Contents:     `{{ echo "foo" }}`,
+			FuncMapMerge: funcs,
+		})
+		err := RunFixture(rv, NewFixtureWatcher(fixtureStore(nil)),
+			[]Templater{tt}, func(e ResolveEvent) error {
+				if !e.DryRun {
+					t.Errorf("not a dry-run event: %#v", e)
+				}
+				return nil
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, ok := sink.Result(tt.ID())
+		if !ok || !res.Complete || string(res.Contents) != "bar" {
+			t.Errorf("bad dry-run result: %#v", res)
+		}
+	})
+
+	t.Run("no-file", func(t *testing.T) {
+		if _, err := LoadFixture(filepath.Join(dir, "none.json")); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
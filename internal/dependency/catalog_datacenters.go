@@ -53,6 +53,7 @@ func (d *CatalogDatacentersQuery) Fetch(clients dep.Clients) (interface{}, *dep.
 		case <-d.stopCh:
 			return nil, nil, ErrStopped
 		case <-time.After(CatalogDatacentersQuerySleepTime):
+		case <-opts.done():
 		}
 	}
 
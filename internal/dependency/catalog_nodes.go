@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/hashicorp/hcat/dep"
 	"github.com/pkg/errors"
@@ -27,9 +29,12 @@ type CatalogNodesQuery struct {
 	isConsul
 	stopCh chan struct{}
 
-	dc   string
-	near string
-	opts QueryOptions
+	dc        string
+	near      string
+	partition string
+	// limit caps the number of nodes returned, 0 is no limit
+	limit int
+	opts  QueryOptions
 }
 
 // NewCatalogNodesQuery parses the given string into a dependency. If the name is
@@ -47,6 +52,48 @@ func NewCatalogNodesQuery(s string) (*CatalogNodesQuery, error) {
 	}, nil
 }
 
+// NewCatalogNodesQueryV1 processes options in the format of "key=value", eg.
+// "dc=dc1". Supported options are "dc" (or "datacenter"), "near", "partition"
+// to list the nodes of an admin partition and "limit" to cap the number of
+// nodes returned (sorted by name unless near is set).
+func NewCatalogNodesQueryV1(opts []string) (*CatalogNodesQuery, error) {
+	catalogNodesQuery := CatalogNodesQuery{
+		stopCh: make(chan struct{}, 1),
+	}
+
+	for _, opt := range opts {
+		if strings.TrimSpace(opt) == "" {
+			continue
+		}
+
+		query, value, err := stringsSplit2(opt, "=")
+		if err != nil || value == "" {
+			return nil, fmt.Errorf(
+				"catalog.nodes: invalid query parameter format: %q", opt)
+		}
+		switch query {
+		case "dc", "datacenter":
+			catalogNodesQuery.dc = value
+		case "near":
+			catalogNodesQuery.near = value
+		case "partition":
+			catalogNodesQuery.partition = value
+		case "limit":
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 {
+				return nil, fmt.Errorf(
+					"catalog.nodes: invalid limit: %q", value)
+			}
+			catalogNodesQuery.limit = limit
+		default:
+			return nil, fmt.Errorf(
+				"catalog.nodes: invalid query parameter: %q", opt)
+		}
+	}
+
+	return &catalogNodesQuery, nil
+}
+
 // Fetch queries the Consul API defined by the given client and returns a slice
 // of Node objects
 func (d *CatalogNodesQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
@@ -59,6 +106,7 @@ func (d *CatalogNodesQuery) Fetch(clients dep.Clients) (interface{}, *dep.Respon
 	opts := d.opts.Merge(&QueryOptions{
 		Datacenter: d.dc,
 		Near:       d.near,
+		Partition:  d.partition,
 	})
 
 	n, qm, err := clients.Consul().Catalog().Nodes(opts.ToConsulOpts())
@@ -82,6 +130,9 @@ func (d *CatalogNodesQuery) Fetch(clients dep.Clients) (interface{}, *dep.Respon
 	if d.near == "" {
 		sort.Stable(ByNode(nodes))
 	}
+	if d.limit > 0 && len(nodes) > d.limit {
+		nodes = nodes[:d.limit]
+	}
 
 	rm := &dep.ResponseMetadata{
 		LastIndex:   qm.LastIndex,
@@ -105,6 +156,17 @@ func (d *CatalogNodesQuery) ID() string {
 	if d.near != "" {
 		name = name + "~" + d.near
 	}
+	var opts []string
+	if name != "" {
+		opts = append(opts, name)
+	}
+	if d.partition != "" {
+		opts = append(opts, "partition="+d.partition)
+	}
+	if d.limit > 0 {
+		opts = append(opts, "limit="+strconv.Itoa(d.limit))
+	}
+	name = strings.Join(opts, "&")
 
 	if name == "" {
 		return "catalog.nodes"
@@ -1,7 +1,10 @@
 package dependency
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/hashicorp/hcat/dep"
@@ -72,6 +75,109 @@ func TestNewCatalogNodesQuery(t *testing.T) {
 	}
 }
 
+func TestNewCatalogNodesQueryV1(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    []string
+		exp  *CatalogNodesQuery
+		err  bool
+	}{
+		{
+			"empty",
+			nil,
+			&CatalogNodesQuery{},
+			false,
+		},
+		{
+			"all",
+			[]string{"dc=dc1", "near=node1", "partition=part1", "limit=10"},
+			&CatalogNodesQuery{
+				dc:        "dc1",
+				near:      "node1",
+				partition: "part1",
+				limit:     10,
+			},
+			false,
+		},
+		{
+			"bad_limit",
+			[]string{"limit=0"},
+			nil,
+			true,
+		},
+		{
+			"bad_option",
+			[]string{"foo=bar"},
+			nil,
+			true,
+		},
+		{
+			"bad_format",
+			[]string{"partition"},
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewCatalogNodesQueryV1(tc.i)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestCatalogNodesQuery_FetchPartition(t *testing.T) {
+	t.Parallel()
+
+	var partition string
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v1/status/leader":
+				w.Write([]byte(`"127.0.0.1:8300"`))
+			case "/v1/catalog/nodes":
+				partition = r.URL.Query().Get("partition")
+				w.Header().Set("X-Consul-Index", "7")
+				json.NewEncoder(w).Encode([]map[string]string{
+					{"Node": "c"}, {"Node": "a"}, {"Node": "b"}})
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+	defer srv.Close()
+
+	clients := NewClientSet()
+	defer clients.Stop()
+	if err := clients.CreateConsulClient(&CreateClientInput{
+		Address: srv.Listener.Addr().String(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewCatalogNodesQueryV1([]string{"partition=part1", "limit=2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	act, rm, err := d.Fetch(clients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "part1", partition)
+	assert.Equal(t, uint64(7), rm.LastIndex)
+	assert.Equal(t, []*dep.Node{{Node: "a"}, {Node: "b"}}, act)
+}
+
 func TestCatalogNodesQuery_Fetch(t *testing.T) {
 	t.Parallel()
 
@@ -163,4 +269,14 @@ func TestCatalogNodesQuery_String(t *testing.T) {
 			assert.Equal(t, tc.exp, d.ID())
 		})
 	}
+
+	t.Run("v1", func(t *testing.T) {
+		d, err := NewCatalogNodesQueryV1([]string{
+			"dc=dc1", "near=node1", "partition=part1", "limit=10"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t,
+			"catalog.nodes(@dc1~node1&partition=part1&limit=10)", d.ID())
+	})
 }
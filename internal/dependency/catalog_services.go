@@ -29,14 +29,17 @@ type CatalogServicesQuery struct {
 	isConsul
 	stopCh chan struct{}
 
-	dc       string
-	ns       string
-	nodeMeta map[string]string
-	opts     QueryOptions
+	dc        string
+	ns        string
+	partition string
+	nodeMeta  map[string]string
+	opts      QueryOptions
 }
 
 // NewCatalogServicesQueryV1 processes options in the format of "key=value"
-// e.g. "dc=dc1"
+// e.g. "dc=dc1". Supported options are "dc" (or "datacenter"), "ns" (or
+// "namespace"), "partition" to list the services of an admin partition and
+// "node-meta".
 func NewCatalogServicesQueryV1(opts []string) (*CatalogServicesQuery, error) {
 	catalogServicesQuery := CatalogServicesQuery{
 		stopCh: make(chan struct{}, 1),
@@ -57,6 +60,8 @@ func NewCatalogServicesQueryV1(opts []string) (*CatalogServicesQuery, error) {
 			catalogServicesQuery.dc = value
 		case "ns", "namespace":
 			catalogServicesQuery.ns = value
+		case "partition":
+			catalogServicesQuery.partition = value
 		case "node-meta":
 			if catalogServicesQuery.nodeMeta == nil {
 				catalogServicesQuery.nodeMeta = make(map[string]string)
@@ -103,6 +108,7 @@ func (d *CatalogServicesQuery) Fetch(clients dep.Clients) (interface{}, *dep.Res
 	opts := d.opts.Merge(&QueryOptions{
 		Datacenter: d.dc,
 		Namespace:  d.ns,
+		Partition:  d.partition,
 	}).ToConsulOpts()
 	// node-meta is handled specifically for /v1/catalog/services endpoint since
 	// it does not support the preferred filter option.
@@ -145,6 +151,9 @@ func (d *CatalogServicesQuery) ID() string {
 	if d.ns != "" {
 		opts = append(opts, fmt.Sprintf("ns=%s", d.ns))
 	}
+	if d.partition != "" {
+		opts = append(opts, fmt.Sprintf("partition=%s", d.partition))
+	}
 	for k, v := range d.nodeMeta {
 		opts = append(opts, fmt.Sprintf("node-meta=%s:%s", k, v))
 	}
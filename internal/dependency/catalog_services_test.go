@@ -86,6 +86,14 @@ func TestNewCatalogServicesQueryV1(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"partition",
+			[]string{"partition=web"},
+			&CatalogServicesQuery{
+				partition: "web",
+			},
+			false,
+		},
 		{
 			"node-meta",
 			[]string{"node-meta=k:v", "node-meta=foo:bar"},
@@ -243,6 +251,11 @@ func TestCatalogServicesQueryV1_String(t *testing.T) {
 			[]string{"ns=namespace"},
 			"catalog.services(ns=namespace)",
 		},
+		{
+			"partition",
+			[]string{"partition=web"},
+			"catalog.services(partition=web)",
+		},
 		{
 			"node-meta",
 			[]string{"node-meta=k:v", "node-meta=foo:bar"},
@@ -4,8 +4,12 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,18 +25,38 @@ type ClientSet struct {
 
 	vault  *vaultClient
 	consul *consulClient
+
+	// additional clients registered by name
+	namedVault  map[string]*vaultClient
+	namedConsul map[string]*consulClient
+
+	// stateHandler is called on the changes of the clients' states
+	stateLock    sync.RWMutex
+	stateHandler ClientStateHandler
 }
 
 // consulClient is a wrapper around a real Consul API client.
 type consulClient struct {
 	client     *consulapi.Client
 	httpClient *http.Client
+	input      CreateClientInput // for reloading TLS
+	state      *clientState
 }
 
 // vaultClient is a wrapper around a real Vault API client.
 type vaultClient struct {
 	client     *vaultapi.Client
 	httpClient *http.Client
+	input      CreateClientInput // for reloading TLS
+	state      *clientState
+	// stopCh stops watching the Vault Agent token file and connecting
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// stop stops watching the agent token file and connecting, if either.
+func (v *vaultClient) stop() {
+	v.stopOnce.Do(func() { close(v.stopCh) })
 }
 
 // TransportDialer is an interface that allows passing a custom dialer function
@@ -49,6 +73,19 @@ type CreateClientInput struct {
 	Token     string
 	// vault only
 	UnwrapToken bool
+	// VaultAgentTokenFile is the file a Vault Agent's auto-auth sink writes
+	// the token to. The token is read from it instead of using Token, and
+	// read again whenever the agent writes a new one.
+	VaultAgentTokenFile string
+	// VaultAgentProxy is set when Address is a Vault Agent's API proxy
+	// listener, which adds its own (auto-auth) token to the requests. So the
+	// client sends no token. Address defaults to VAULT_AGENT_ADDR.
+	VaultAgentProxy bool
+	// Lazy creates the client without contacting the server, so it can be
+	// created before the server is reachable. Waiting for the Consul leader,
+	// unwrapping the token and reading the Vault Agent token file are left to
+	// the ClientSet's Connect.
+	Lazy bool
 	// consul only
 	AuthEnabled  bool
 	AuthUsername string
@@ -73,6 +110,18 @@ type CreateClientInput struct {
 	TransportMaxIdleConns        int
 	TransportMaxIdleConnsPerHost int
 	TransportTLSHandshakeTimeout time.Duration
+	// TransportFetchTimeout is a hard deadline for each request, in addition
+	// to the wait time of blocking queries. So a hung connection is detected,
+	// and the request retried, in a bounded time instead of waiting for the OS
+	// level TCP timeouts. Zero (the default) means no deadline.
+	TransportFetchTimeout time.Duration
+	// TransportProxy returns the proxy to use for a request, eg. from
+	// http.ProxyURL. Defaults to http.ProxyFromEnvironment.
+	TransportProxy func(*http.Request) (*url.URL, error)
+	// TransportRoundTripper, if set, is used instead of a transport built
+	// from the above Transport/TLS settings. The TransportFetchTimeout still
+	// applies but ReloadTLS leaves it as is.
+	TransportRoundTripper http.RoundTripper
 
 	// optional, principally for testing
 	HttpClient *http.Client
@@ -85,6 +134,43 @@ func NewClientSet() *ClientSet {
 
 // CreateConsulClient creates a new Consul API client from the given input.
 func (c *ClientSet) CreateConsulClient(i *CreateClientInput) error {
+	client, err := newConsulClient(i, c.newState(consulBackend, ""))
+	if err != nil {
+		return err
+	}
+
+	// Save the data on ourselves
+	c.Lock()
+	c.consul = client
+	c.Unlock()
+
+	return nil
+}
+
+// CreateNamedConsulClient creates a new Consul API client from the given input
+// and adds it to the set under the name. Named clients are used by
+// dependencies wrapped with NewNamedClientQuery.
+func (c *ClientSet) CreateNamedConsulClient(name string, i *CreateClientInput) error {
+	if name == "" {
+		return fmt.Errorf("client set: consul: client name required")
+	}
+	client, err := newConsulClient(i, c.newState(consulBackend, name))
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	if c.namedConsul == nil {
+		c.namedConsul = make(map[string]*consulClient)
+	}
+	c.namedConsul[name] = client
+	c.Unlock()
+
+	return nil
+}
+
+func newConsulClient(i *CreateClientInput, state *clientState) (
+	*consulClient, error) {
 	consulConfig := consulapi.DefaultConfig()
 
 	if i.Address != "" {
@@ -108,9 +194,10 @@ func (c *ClientSet) CreateConsulClient(i *CreateClientInput) error {
 
 	// set/create our HTTP client
 	if client, err := httpClient(i); err != nil {
-		return err
+		return nil, err
 	} else {
-		consulConfig.HttpClient = client
+		consulConfig.HttpClient = withQueryParams(
+			withRetryAfter(withState(client, state)))
 	}
 
 	// Setup the new transport
@@ -121,22 +208,22 @@ func (c *ClientSet) CreateConsulClient(i *CreateClientInput) error {
 	// Create the API client
 	client, err := consulapi.NewClient(consulConfig)
 	if err != nil {
-		return fmt.Errorf("client set: consul: %s", err)
+		return nil, fmt.Errorf("client set: consul: %s", err)
 	}
 
-	if err := hasLeader(client, time.Minute); err != nil {
-		return err
+	if !i.Lazy {
+		if err := hasLeader(client, time.Minute); err != nil {
+			return nil, err
+		}
+		state.setConnected()
 	}
 
-	// Save the data on ourselves
-	c.Lock()
-	c.consul = &consulClient{
+	return &consulClient{
 		client:     client,
 		httpClient: consulConfig.HttpClient,
-	}
-	c.Unlock()
-
-	return nil
+		input:      *i,
+		state:      state,
+	}, nil
 }
 
 func hasLeader(client *consulapi.Client, maxRetryWait time.Duration) error {
@@ -162,25 +249,86 @@ func hasLeader(client *consulapi.Client, maxRetryWait time.Duration) error {
 	}
 }
 
+// CreateVaultClient creates a new Vault API client from the given input.
 func (c *ClientSet) CreateVaultClient(i *CreateClientInput) error {
+	client, err := newVaultClient(i, c.newState(vaultBackend, ""))
+	if err != nil {
+		return err
+	}
+
+	// Save the data on ourselves
+	c.Lock()
+	if c.vault != nil {
+		c.vault.stop()
+	}
+	c.vault = client
+	c.Unlock()
+
+	return nil
+}
+
+// CreateNamedVaultClient creates a new Vault API client from the given input
+// and adds it to the set under the name. Named clients are used by
+// dependencies wrapped with NewNamedClientQuery.
+func (c *ClientSet) CreateNamedVaultClient(name string, i *CreateClientInput) error {
+	if name == "" {
+		return fmt.Errorf("client set: vault: client name required")
+	}
+	client, err := newVaultClient(i, c.newState(vaultBackend, name))
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	if c.namedVault == nil {
+		c.namedVault = make(map[string]*vaultClient)
+	}
+	if old, ok := c.namedVault[name]; ok {
+		old.stop()
+	}
+	c.namedVault[name] = client
+	c.Unlock()
+
+	return nil
+}
+
+func newVaultClient(i *CreateClientInput, state *clientState) (
+	*vaultClient, error) {
 	vaultConfig := vaultapi.DefaultConfig()
 
 	if i.Address != "" {
 		vaultConfig.Address = i.Address
+	} else if addr := os.Getenv(vaultAgentAddrEnv); i.VaultAgentProxy &&
+		addr != "" {
+		vaultConfig.Address = addr
 	}
 
-	// set/create our HTTP client
-	if client, err := httpClient(i); err != nil {
-		return err
-	} else {
-		vaultConfig.HttpClient = client
+	// keep the address, eg. from the environment, for the transports
+	input := *i
+	input.Address = vaultConfig.Address
+	i = &input
+
+	// The API client type asserts the transport to an *http.Transport to
+	// set up a unix socket address, so create it with a plain one and set
+	// our HTTP client, with its wrapping transports, after.
+	plain, err := newTransport(i)
+	if err != nil {
+		return nil, err
 	}
+	vaultConfig.HttpClient = &http.Client{Transport: plain}
 
 	// Create the client
 	client, err := vaultapi.NewClient(vaultConfig)
 	if err != nil {
-		return fmt.Errorf("client set: vault: %s", err)
+		return nil, fmt.Errorf("client set: vault: %s", err)
+	}
+
+	// set/create our HTTP client
+	hc, err := httpClient(i)
+	if err != nil {
+		return nil, err
 	}
+	vaultConfig.HttpClient = withState(hc, state)
 
 	// Set the namespace if given.
 	if i.Namespace != "" {
@@ -188,41 +336,62 @@ func (c *ClientSet) CreateVaultClient(i *CreateClientInput) error {
 	}
 
 	// Set the token if given
-	if i.Token != "" {
+	unwrap := i.UnwrapToken && i.VaultAgentTokenFile == ""
+	switch {
+	case unwrap && i.Lazy:
+		// the wrapping token isn't usable, it is unwrapped on Connect
+		client.ClearToken()
+	case i.Token != "":
 		client.SetToken(i.Token)
 	}
 
 	// Check if we are unwrapping
-	if i.UnwrapToken {
-		secret, err := client.Logical().Unwrap(i.Token)
+	if unwrap && !i.Lazy {
+		token, err := unwrapToken(client, i.Token)
 		if err != nil {
-			return fmt.Errorf("client set: vault unwrap: %s", err)
+			return nil, err
 		}
+		client.SetToken(token)
+	}
 
-		if secret == nil {
-			return fmt.Errorf("client set: vault unwrap: no secret")
-		}
+	// Use the Vault Agent, if configured
+	stopCh := make(chan struct{})
+	if err := configureVaultAgent(client, i, stopCh); err != nil {
+		return nil, err
+	}
+	if !i.Lazy {
+		state.setConnected()
+	}
 
-		if secret.Auth == nil {
-			return fmt.Errorf("client set: vault unwrap: no secret auth")
-		}
+	return &vaultClient{
+		client:     client,
+		httpClient: vaultConfig.HttpClient,
+		input:      *i,
+		state:      state,
+		stopCh:     stopCh,
+	}, nil
+}
 
-		if secret.Auth.ClientToken == "" {
-			return fmt.Errorf("client set: vault unwrap: no token returned")
-		}
+// unwrapToken returns the token wrapped by the wrapping token.
+func unwrapToken(client *vaultapi.Client, wrapped string) (string, error) {
+	secret, err := client.Logical().Unwrap(wrapped)
+	if err != nil {
+		return "", fmt.Errorf("client set: vault unwrap: %s", err)
+	}
 
-		client.SetToken(secret.Auth.ClientToken)
+	if secret == nil {
+		return "", fmt.Errorf("client set: vault unwrap: no secret")
 	}
 
-	// Save the data on ourselves
-	c.Lock()
-	c.vault = &vaultClient{
-		client:     client,
-		httpClient: vaultConfig.HttpClient,
+	if secret.Auth == nil {
+		return "", fmt.Errorf("client set: vault unwrap: no secret auth")
 	}
-	c.Unlock()
 
-	return nil
+	if secret.Auth.ClientToken == "" {
+		return "", fmt.Errorf("client set: vault unwrap: no token returned")
+	}
+
+	return secret.Auth.ClientToken, nil
 }
 
 // Consul returns the Consul client for this set.
@@ -245,6 +414,26 @@ func (c *ClientSet) Vault() *vaultapi.Client {
 	return c.vault.client
 }
 
+// ConsulNamed returns the named Consul client, or nil if there isn't one.
+func (c *ClientSet) ConsulNamed(name string) *consulapi.Client {
+	c.RLock()
+	defer c.RUnlock()
+	if cc, ok := c.namedConsul[name]; ok {
+		return cc.client
+	}
+	return nil
+}
+
+// VaultNamed returns the named Vault client, or nil if there isn't one.
+func (c *ClientSet) VaultNamed(name string) *vaultapi.Client {
+	c.RLock()
+	defer c.RUnlock()
+	if vc, ok := c.namedVault[name]; ok {
+		return vc.client
+	}
+	return nil
+}
+
 // Stop closes all idle connections for any attached clients.
 func (c *ClientSet) Stop() {
 	c.Lock()
@@ -257,33 +446,287 @@ func (c *ClientSet) Stop() {
 		c.consul.httpClient.CloseIdleConnections()
 	}
 
+	if c.vault != nil {
+		c.vault.stop()
+	}
 	switch {
 	case c.vault == nil:
 	case c.vault.httpClient == nil:
 	default:
 		c.vault.httpClient.CloseIdleConnections()
 	}
+
+	for _, cc := range c.namedConsul {
+		if cc.httpClient != nil {
+			cc.httpClient.CloseIdleConnections()
+		}
+	}
+	for _, vc := range c.namedVault {
+		vc.stop()
+		if vc.httpClient != nil {
+			vc.httpClient.CloseIdleConnections()
+		}
+	}
+}
+
+// ReloadTLS reloads the TLS certificates, keys and CAs of all the clients in
+// the set, including the named ones, from their configured files. Use it to
+// pick up rotated TLS material without recreating the clients. Requests in
+// flight finish using the previous configuration.
+//
+// Clients without SSL enabled, or created with their own HttpClient, are left
+// as is. If loading any of the TLS material fails an error is returned and
+// none of the clients are changed.
+func (c *ClientSet) ReloadTLS() error {
+	c.RLock()
+	defer c.RUnlock()
+
+	type reload struct {
+		rt        *reloadableTransport
+		transport *http.Transport
+	}
+	var reloads []reload
+	add := func(hc *http.Client, i *CreateClientInput) error {
+		if hc == nil {
+			return nil
+		}
+		current := hc.Transport
+		if pt, ok := current.(*queryParamsTransport); ok {
+			current = pt.transport
+		}
+		if st, ok := current.(*stateTransport); ok {
+			current = st.transport
+		}
+		if dt, ok := current.(*deadlineTransport); ok {
+			current = dt.transport
+		}
+		rt, ok := current.(*reloadableTransport)
+		if !ok {
+			return nil
+		}
+		transport, err := newTransport(i)
+		if err != nil {
+			return err
+		}
+		reloads = append(reloads, reload{rt: rt, transport: transport})
+		return nil
+	}
+
+	if c.consul != nil {
+		if err := add(c.consul.httpClient, &c.consul.input); err != nil {
+			return err
+		}
+	}
+	if c.vault != nil {
+		if err := add(c.vault.httpClient, &c.vault.input); err != nil {
+			return err
+		}
+	}
+	for _, cc := range c.namedConsul {
+		if err := add(cc.httpClient, &cc.input); err != nil {
+			return err
+		}
+	}
+	for _, vc := range c.namedVault {
+		if err := add(vc.httpClient, &vc.input); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range reloads {
+		r.rt.swap(r.transport)
+	}
+	return nil
 }
 
 // httpClient returns the http.Client to use with the API client.
 // Returns the test one if given, otherwise creates one with default transport.
-func httpClient(i *CreateClientInput) (client *http.Client, err error) {
+// With SSL enabled the transport is wrapped so its TLS can be reloaded and
+// with a fetch timeout it is wrapped to enforce the deadline.
+func httpClient(i *CreateClientInput) (*http.Client, error) {
 	if i.HttpClient != nil {
 		return i.HttpClient, nil
 	}
-	var transport *http.Transport
-	if transport, err = newTransport(i); err == nil {
-		client = &http.Client{
-			Transport: transport,
+	client := &http.Client{Transport: i.TransportRoundTripper}
+	if client.Transport == nil {
+		transport, err := newTransport(i)
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = transport
+		if i.SSLEnabled {
+			client.Transport = &reloadableTransport{transport: transport}
+		}
+	}
+	if i.TransportFetchTimeout > 0 {
+		client.Transport = &deadlineTransport{
+			transport: client.Transport,
+			timeout:   i.TransportFetchTimeout,
+		}
+	}
+	return client, nil
+}
+
+// deadlineTransport is an http.RoundTripper that gives each request a hard
+// deadline, the timeout plus the wait time if it is a blocking query. The
+// deadline covers reading the response body.
+type deadlineTransport struct {
+	transport http.RoundTripper
+	timeout   time.Duration
+}
+
+func (t *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(),
+		t.timeout+blockingWait(req))
+	resp, err := t.transport.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport,
+// called by the http.Client's method of the same name.
+func (t *deadlineTransport) CloseIdleConnections() {
+	type closeIdler interface{ CloseIdleConnections() }
+	if c, ok := t.transport.(closeIdler); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// queryParamKey is the context key of the query parameters added to the
+// requests by the queryParamsTransport.
+type queryParamKey struct{}
+
+// withQueryParam returns a copy of the context with the query parameter
+// added to the requests made with it. Used for parameters the Consul API
+// client doesn't support, eg. partition.
+func withQueryParam(ctx context.Context, key, value string) context.Context {
+	params := url.Values{}
+	if p, ok := ctx.Value(queryParamKey{}).(url.Values); ok {
+		for k, v := range p {
+			params[k] = v
 		}
 	}
-	return client, err
+	params.Set(key, value)
+	return context.WithValue(ctx, queryParamKey{}, params)
+}
+
+// queryParamsTransport is an http.RoundTripper that adds the query
+// parameters set on the request's context by withQueryParam.
+type queryParamsTransport struct {
+	transport http.RoundTripper
+}
+
+// withQueryParams returns a copy of the client using the
+// queryParamsTransport, the client itself is left as is.
+func withQueryParams(client *http.Client) *http.Client {
+	c := *client
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	c.Transport = &queryParamsTransport{transport: transport}
+	return &c
+}
+
+func (t *queryParamsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	params, ok := req.Context().Value(queryParamKey{}).(url.Values)
+	if !ok {
+		return t.transport.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	q := req.URL.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	req.URL.RawQuery = q.Encode()
+	return t.transport.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport,
+// called by the http.Client's method of the same name.
+func (t *queryParamsTransport) CloseIdleConnections() {
+	type closeIdler interface{ CloseIdleConnections() }
+	if c, ok := t.transport.(closeIdler); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// consulDefaultWait is the wait time Consul uses for blocking queries that
+// don't set one.
+const consulDefaultWait = 5 * time.Minute
+
+// blockingWait returns how long the request can block on the server. Consul
+// blocking queries have an index and wait for up to their wait time plus a
+// random jitter of up to 1/16th of it.
+func blockingWait(req *http.Request) time.Duration {
+	q := req.URL.Query()
+	if q.Get("index") == "" {
+		return 0
+	}
+	wait := consulDefaultWait
+	if d, err := time.ParseDuration(q.Get("wait")); err == nil {
+		wait = d
+	}
+	return wait + wait/16
+}
+
+// cancelOnClose is a response body that cancels the request's context when
+// closed, releasing the deadline's resources.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// reloadableTransport is an http.RoundTripper that passes requests to its
+// transport, which can be swapped for one with a new TLS configuration.
+type reloadableTransport struct {
+	sync.RWMutex
+	transport *http.Transport
+}
+
+func (t *reloadableTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.RLock()
+	transport := t.transport
+	t.RUnlock()
+	return transport.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of the current transport,
+// called by the http.Client's method of the same name.
+func (t *reloadableTransport) CloseIdleConnections() {
+	t.RLock()
+	defer t.RUnlock()
+	t.transport.CloseIdleConnections()
+}
+
+// swap replaces the transport, closing the old one's idle connections so
+// they aren't left open.
+func (t *reloadableTransport) swap(transport *http.Transport) {
+	t.Lock()
+	old := t.transport
+	t.transport = transport
+	t.Unlock()
+	old.CloseIdleConnections()
 }
 
 func newTransport(i *CreateClientInput) (*http.Transport, error) {
 	// This transport will attempt to keep connections open to the server.
+	proxy := http.ProxyFromEnvironment
+	if i.TransportProxy != nil {
+		proxy = i.TransportProxy
+	}
 	transport := &http.Transport{
-		Proxy:               http.ProxyFromEnvironment,
+		Proxy:               proxy,
 		DialContext:         newDialer(i).DialContext,
 		DisableKeepAlives:   i.TransportDisableKeepAlives,
 		ForceAttemptHTTP2:   true,
@@ -292,6 +735,13 @@ func newTransport(i *CreateClientInput) (*http.Transport, error) {
 		MaxIdleConnsPerHost: i.TransportMaxIdleConnsPerHost,
 		TLSHandshakeTimeout: i.TransportTLSHandshakeTimeout,
 	}
+	if socket := strings.TrimPrefix(i.Address, "unix://"); socket != i.Address {
+		dialer := newDialer(i)
+		transport.DialContext = func(ctx context.Context, _, _ string) (
+			net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	}
 
 	// Configure SSL
 	if i.SSLEnabled {
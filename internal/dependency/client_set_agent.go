@@ -0,0 +1,103 @@
+package dependency
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// VaultAgentTokenFileSleepTime is the amount of time between checks of a
+// Vault client's agent token file (see CreateClientInput) for a new token.
+var VaultAgentTokenFileSleepTime = VaultAgentTokenSleepTime
+
+// vaultAgentAddrEnv is the environment variable of the Vault Agent's address,
+// used for the agent proxy when the client has no address.
+const vaultAgentAddrEnv = "VAULT_AGENT_ADDR"
+
+// configureVaultAgent sets up the client to use the Vault Agent configured by
+// the input, if any. Either the agent proxies the requests (adding its own
+// token) or the token is read from the agent's auto-auth sink file, see
+// startAgentToken. For lazy clients the token file is left to Connect.
+func configureVaultAgent(client *vaultapi.Client, i *CreateClientInput,
+	stopCh <-chan struct{}) error {
+	switch {
+	case i.VaultAgentProxy && (i.Token != "" || i.VaultAgentTokenFile != ""):
+		return fmt.Errorf("client set: vault agent: the agent proxy " +
+			"adds the token, a token or token file can't be set")
+	case i.VaultAgentProxy:
+		// drop any token from the environment, so the agent's is used
+		client.ClearToken()
+		return nil
+	case i.VaultAgentTokenFile == "":
+		return nil
+	case i.Token != "":
+		return fmt.Errorf("client set: vault agent: a token and a " +
+			"token file can't both be set")
+	case i.Lazy:
+		return nil
+	}
+	return startAgentToken(client, i, stopCh)
+}
+
+// startAgentToken sets the client's token from the agent's token file, which
+// is then watched for new tokens until the stop channel is closed.
+func startAgentToken(client *vaultapi.Client, i *CreateClientInput,
+	stopCh <-chan struct{}) error {
+	stat, err := os.Stat(i.VaultAgentTokenFile)
+	if err != nil {
+		return fmt.Errorf("client set: vault agent: %s", err)
+	}
+	if err := setAgentToken(client, i); err != nil {
+		return err
+	}
+	go watchAgentToken(client, i, stat, stopCh)
+	return nil
+}
+
+// watchAgentToken checks the token file for changes, setting the client's
+// token to the new one, until stopped. Errors keep the current token, the
+// agent writes a new one whenever it re-authenticates.
+func watchAgentToken(client *vaultapi.Client, i *CreateClientInput,
+	last os.FileInfo, stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(VaultAgentTokenFileSleepTime):
+		}
+
+		stat, err := os.Stat(i.VaultAgentTokenFile)
+		if err != nil || (stat.Size() == last.Size() &&
+			stat.ModTime() == last.ModTime()) {
+			continue
+		}
+		if err := setAgentToken(client, i); err == nil {
+			last = stat
+		}
+	}
+}
+
+// setAgentToken reads the token from the agent's sink file and sets it as
+// the client's, unwrapping it first if configured to.
+func setAgentToken(client *vaultapi.Client, i *CreateClientInput) error {
+	raw, err := ioutil.ReadFile(i.VaultAgentTokenFile)
+	if err != nil {
+		return fmt.Errorf("client set: vault agent: %s", err)
+	}
+	token := strings.TrimSpace(string(raw))
+	if token == "" {
+		return fmt.Errorf("client set: vault agent: empty token file: %q",
+			i.VaultAgentTokenFile)
+	}
+	if i.UnwrapToken {
+		if token, err = unwrapToken(client, token); err != nil {
+			return err
+		}
+	}
+	client.SetToken(token)
+	return nil
+}
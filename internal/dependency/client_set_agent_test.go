@@ -0,0 +1,99 @@
+package dependency
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func init() {
+	VaultAgentTokenFileSleepTime = 20 * time.Millisecond
+}
+
+func TestClientSet_vaultAgent(t *testing.T) {
+	t.Parallel()
+
+	tokens := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			tokens <- r.Header.Get("X-Vault-Token")
+			w.Write([]byte(`{"data": {}}`))
+		}))
+	defer srv.Close()
+
+	read := func(t *testing.T, clients *ClientSet) string {
+		if _, err := clients.Vault().Logical().Read("secret/foo"); err != nil {
+			t.Fatal(err)
+		}
+		return <-tokens
+	}
+
+	t.Run("token-file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "sink")
+		if err := ioutil.WriteFile(path, []byte("token-1\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		clients := NewClientSet()
+		defer clients.Stop()
+		err = clients.CreateVaultClient(&CreateClientInput{
+			Address:             srv.URL,
+			VaultAgentTokenFile: path,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token := read(t, clients); token != "token-1" {
+			t.Fatalf("bad token: %q", token)
+		}
+
+		// the agent re-authenticates, writing a new token
+		if err := ioutil.WriteFile(path, []byte("token-22\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 50; i++ {
+			if clients.Vault().Token() == "token-22" {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("token not reloaded: %q", clients.Vault().Token())
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		clients := NewClientSet()
+		defer clients.Stop()
+		err := clients.CreateVaultClient(&CreateClientInput{
+			Address:         srv.URL,
+			VaultAgentProxy: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token := read(t, clients); token != "" {
+			t.Fatalf("token sent to the agent proxy: %q", token)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		cases := map[string]*CreateClientInput{
+			"missing-file": {VaultAgentTokenFile: "/no/such/sink"},
+			"proxy-token":  {VaultAgentProxy: true, Token: "token"},
+			"file-token":   {VaultAgentTokenFile: "sink", Token: "token"},
+		}
+		for name, i := range cases {
+			i.Address = srv.URL
+			if err := NewClientSet().CreateVaultClient(i); err == nil {
+				t.Errorf("%s: expected error", name)
+			}
+		}
+	})
+}
@@ -0,0 +1,275 @@
+package dependency
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// ClientConnectRetryTime is the amount of time Connect waits before retrying
+// to connect a client, doubled on each retry up to clientConnectMaxRetryTime.
+var ClientConnectRetryTime = time.Second
+
+const clientConnectMaxRetryTime = time.Minute
+
+// names of the backends of the clients, as passed to the ClientStateHandler
+const (
+	consulBackend = "consul"
+	vaultBackend  = "vault"
+)
+
+// ClientState is the state of a client's connection to its server.
+type ClientState int
+
+const (
+	// ClientDisconnected is a client whose server can't be reached.
+	ClientDisconnected ClientState = iota
+	// ClientConnected is a client whose server is reachable, since Connect
+	// (or the creation of a client that isn't lazy) succeeded or, after
+	// being disconnected, a request got a response again.
+	ClientConnected
+	// ClientAuthFailed is a client whose server refused its credentials on
+	// Connect, eg. an invalid token.
+	ClientAuthFailed
+)
+
+// ClientStateHandler is called when the state of one of the set's clients
+// changes. The backend is "consul" or "vault" and the name is that of a named
+// client, empty for the default ones. Err is why the client is disconnected
+// or failed to authenticate. It is called synchronously as the changes happen
+// so it must not block or use the clients.
+type ClientStateHandler func(backend, name string, state ClientState,
+	err error)
+
+// SetStateHandler sets the handler called on changes of the clients' states.
+func (c *ClientSet) SetStateHandler(handler ClientStateHandler) {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	c.stateHandler = handler
+}
+
+func (c *ClientSet) getStateHandler() ClientStateHandler {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+	return c.stateHandler
+}
+
+// Connect connects the set's lazy clients (see CreateClientInput) that aren't
+// yet, retrying each until it connects or the context is done. So the set
+// (and a watcher using it) can be started before the servers are reachable,
+// with Connect waiting for them. A client whose server refuses its
+// credentials isn't retried, the error is returned right away.
+func (c *ClientSet) Connect(ctx context.Context) error {
+	c.RLock()
+	var consuls []*consulClient
+	var vaults []*vaultClient
+	if c.consul != nil {
+		consuls = append(consuls, c.consul)
+	}
+	for _, cc := range c.namedConsul {
+		consuls = append(consuls, cc)
+	}
+	if c.vault != nil {
+		vaults = append(vaults, c.vault)
+	}
+	for _, vc := range c.namedVault {
+		vaults = append(vaults, vc)
+	}
+	c.RUnlock()
+
+	for _, cc := range consuls {
+		if err := cc.connect(ctx); err != nil {
+			return err
+		}
+	}
+	for _, vc := range vaults {
+		if err := vc.connect(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// connect waits for the Consul cluster to have a leader.
+func (cc *consulClient) connect(ctx context.Context) error {
+	return cc.state.connect(ctx, nil, func() error {
+		leader, err := cc.client.Status().Leader()
+		switch {
+		case err != nil:
+			return err
+		case leader == "":
+			return fmt.Errorf("no consul leader detected")
+		}
+		return nil
+	})
+}
+
+// connect sets the client's token, from the Vault Agent's token file or
+// unwrapping it, and checks it with a token lookup. Without a token it
+// checks that Vault responds.
+func (vc *vaultClient) connect(ctx context.Context) error {
+	i := &vc.input
+	tokenSet := false
+	return vc.state.connect(ctx, vc.stopCh, func() error {
+		switch {
+		case tokenSet:
+		case i.VaultAgentTokenFile != "":
+			if err := startAgentToken(vc.client, i, vc.stopCh); err != nil {
+				return err
+			}
+		case i.UnwrapToken:
+			token, err := unwrapToken(vc.client, i.Token)
+			if err != nil {
+				return err
+			}
+			vc.client.SetToken(token)
+		}
+		tokenSet = true
+
+		if vc.client.Token() == "" && !i.VaultAgentProxy {
+			_, err := vc.client.Sys().Health()
+			return err
+		}
+		_, err := vc.client.Auth().Token().LookupSelf()
+		return err
+	})
+}
+
+// clientState tracks the state of a client, reporting its changes to the
+// set's ClientStateHandler.
+type clientState struct {
+	sync.Mutex
+	set     *ClientSet
+	backend string
+	name    string
+	current ClientState
+	known   bool // current is unset until the first report
+	// ready is set once the client is connected, created or by Connect
+	ready       bool
+	connectLock sync.Mutex
+}
+
+func (c *ClientSet) newState(backend, name string) *clientState {
+	return &clientState{set: c, backend: backend, name: name}
+}
+
+// report sets the state, calling the handler if it changed.
+func (s *clientState) report(state ClientState, err error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.known && s.current == state {
+		return
+	}
+	s.current, s.known = state, true
+	if handler := s.set.getStateHandler(); handler != nil {
+		handler(s.backend, s.name, state, err)
+	}
+}
+
+// setConnected marks the client connected on creation, not reported as
+// there is no change for the creator.
+func (s *clientState) setConnected() {
+	s.Lock()
+	defer s.Unlock()
+	s.current, s.known, s.ready = ClientConnected, true, true
+}
+
+func (s *clientState) isReady() bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.ready
+}
+
+// authErrorRe matches the status codes of the Consul ("Unexpected response
+// code: 403") and Vault ("Code: 403.") API errors for refused credentials,
+// including Vault's 400 for an invalid wrapping token.
+var authErrorRe = regexp.MustCompile(`[Cc]ode: 40[013]\b`)
+
+// connect calls attempt until it succeeds, the context is done or the stop
+// channel closed, waiting longer between each attempt.
+func (s *clientState) connect(ctx context.Context, stopCh <-chan struct{},
+	attempt func() error) error {
+	s.connectLock.Lock()
+	defer s.connectLock.Unlock()
+	if s.isReady() {
+		return nil
+	}
+
+	wait := ClientConnectRetryTime
+	for {
+		err := attempt()
+		switch {
+		case err == nil:
+			s.report(ClientConnected, nil)
+			s.setConnected()
+			return nil
+		case authErrorRe.MatchString(err.Error()):
+			s.report(ClientAuthFailed, err)
+			return fmt.Errorf("client set: %s: connect: %s", s.backend, err)
+		}
+		s.report(ClientDisconnected, err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("client set: %s: connect: %s, last error: %s",
+				s.backend, ctx.Err(), err)
+		case <-stopCh:
+			return fmt.Errorf("client set: %s: connect: client stopped",
+				s.backend)
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > clientConnectMaxRetryTime {
+			wait = clientConnectMaxRetryTime
+		}
+	}
+}
+
+// stateTransport is an http.RoundTripper that reports the client's state
+// from its requests once connected, disconnected when a request fails and
+// connected when one gets a response again. Refused credentials don't change
+// the state, they could be a policy denying the request.
+type stateTransport struct {
+	transport http.RoundTripper
+	state     *clientState
+}
+
+// withState returns a copy of the client using the stateTransport, the
+// client itself is left as is.
+func withState(client *http.Client, state *clientState) *http.Client {
+	c := *client
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	c.Transport = &stateTransport{transport: transport, state: state}
+	return &c
+}
+
+func (t *stateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	switch {
+	case !t.state.isReady():
+		// connecting, the state is reported by Connect
+	case err == nil && resp.StatusCode != http.StatusUnauthorized &&
+		resp.StatusCode != http.StatusForbidden:
+		t.state.report(ClientConnected, nil)
+	case err == nil:
+	case req.Context().Err() == context.Canceled:
+		// canceled by the caller, eg. the watcher stopping
+	default:
+		t.state.report(ClientDisconnected, err)
+	}
+	return resp, err
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport,
+// called by the http.Client's method of the same name.
+func (t *stateTransport) CloseIdleConnections() {
+	type closeIdler interface{ CloseIdleConnections() }
+	if c, ok := t.transport.(closeIdler); ok {
+		c.CloseIdleConnections()
+	}
+}
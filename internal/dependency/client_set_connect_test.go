@@ -0,0 +1,127 @@
+package dependency
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func init() {
+	ClientConnectRetryTime = 10 * time.Millisecond
+}
+
+func TestClientSet_Connect(t *testing.T) {
+	t.Parallel()
+
+	// record returns a client set recording its clients' state changes
+	record := func() (*ClientSet, func() []string) {
+		var mu sync.Mutex
+		var states []string
+		clients := NewClientSet()
+		clients.SetStateHandler(func(backend, name string, state ClientState,
+			err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			states = append(states, fmt.Sprintf("%s:%d", backend, state))
+		})
+		return clients, func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string{}, states...)
+		}
+	}
+
+	t.Run("consul", func(t *testing.T) {
+		var leader atomic.Value
+		leader.Store("")
+		srv := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, "%q", leader.Load())
+			}))
+		defer srv.Close()
+
+		clients, states := record()
+		defer clients.Stop()
+		err := clients.CreateConsulClient(&CreateClientInput{
+			Address: srv.URL,
+			Lazy:    true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := states(); len(s) != 0 {
+			t.Fatalf("lazy client contacted consul: %v", s)
+		}
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			leader.Store("127.0.0.1:8300")
+		}()
+		if err := clients.Connect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		exp := []string{"consul:0", "consul:1"}
+		if s := states(); strings.Join(s, ",") != strings.Join(exp, ",") {
+			t.Fatalf("bad states, expected %v, got %v", exp, s)
+		}
+
+		// losing the server is reported by the requests
+		srv.Close()
+		clients.Consul().Status().Leader()
+		exp = append(exp, "consul:0")
+		if s := states(); strings.Join(s, ",") != strings.Join(exp, ",") {
+			t.Fatalf("bad states, expected %v, got %v", exp, s)
+		}
+	})
+
+	t.Run("vault-auth-failed", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors": ["permission denied"]}`))
+			}))
+		defer srv.Close()
+
+		clients, states := record()
+		defer clients.Stop()
+		err := clients.CreateNamedVaultClient("dc2", &CreateClientInput{
+			Address: srv.URL,
+			Token:   "bad-token",
+			Lazy:    true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := clients.Connect(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+		if s := states(); len(s) != 1 || s[0] != "vault:2" {
+			t.Fatalf("bad states: %v", s)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		clients, _ := record()
+		defer clients.Stop()
+		err := clients.CreateVaultClient(&CreateClientInput{
+			Address:             "http://127.0.0.1:0",
+			VaultAgentTokenFile: "/no/such/sink",
+			Lazy:                true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(),
+			50*time.Millisecond)
+		defer cancel()
+		if err := clients.Connect(ctx); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
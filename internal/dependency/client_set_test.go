@@ -1,8 +1,19 @@
 package dependency
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -106,3 +117,265 @@ func TestClientSet_hasLeader(t *testing.T) {
 		}
 	})
 }
+
+func TestClientSet_ReloadTLS(t *testing.T) {
+	t.Parallel()
+
+	cert, err := tls.LoadX509KeyPair("testdata/cert.pem", "testdata/key.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`"127.0.0.1:8300"`))
+		}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.StartTLS()
+	defer srv.Close()
+
+	// a CA that didn't sign the server's certificate
+	other := httptest.NewTLSServer(http.NotFoundHandler())
+	other.Close()
+	otherCA := pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: other.Certificate().Raw})
+	goodCA, err := ioutil.ReadFile("testdata/cert.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	writeCA := func(ca []byte) {
+		if err := ioutil.WriteFile(caFile, ca, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCA(goodCA)
+
+	clients := NewClientSet()
+	defer clients.Stop()
+	input := &CreateClientInput{
+		Address:    srv.Listener.Addr().String(),
+		SSLEnabled: true,
+		SSLVerify:  true,
+		SSLCACert:  caFile,
+	}
+	if err := clients.CreateConsulClient(input); err != nil {
+		t.Fatal(err)
+	}
+	leader := func() error {
+		_, err := clients.Consul().Status().Leader()
+		return err
+	}
+
+	writeCA(otherCA)
+	if err := leader(); err != nil {
+		t.Fatal("CA reloaded before ReloadTLS:", err)
+	}
+	if err := clients.ReloadTLS(); err != nil {
+		t.Fatal(err)
+	}
+	if err := leader(); err == nil {
+		t.Fatal("expected error with the wrong CA")
+	}
+
+	writeCA(goodCA)
+	if err := clients.ReloadTLS(); err != nil {
+		t.Fatal(err)
+	}
+	if err := leader(); err != nil {
+		t.Fatal(err)
+	}
+
+	// bad TLS material fails the reload, leaving the client as is
+	writeCA([]byte("bad"))
+	if err := clients.ReloadTLS(); err == nil {
+		t.Fatal("expected error reloading a bad CA")
+	}
+	if err := leader(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientSet_FetchTimeout(t *testing.T) {
+	t.Parallel()
+
+	// the leader check responds, all other requests hang like a dead
+	// connection
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/v1/status/leader" {
+				w.Write([]byte(`"127.0.0.1:8300"`))
+				return
+			}
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}))
+	defer srv.Close()
+
+	clients := NewClientSet()
+	defer clients.Stop()
+	if err := clients.CreateConsulClient(&CreateClientInput{
+		Address:               srv.Listener.Addr().String(),
+		TransportFetchTimeout: 50 * time.Millisecond,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		opts *capi.QueryOptions
+		min  time.Duration
+	}{
+		{"non-blocking", nil, 50 * time.Millisecond},
+		{
+			"blocking",
+			&capi.QueryOptions{WaitIndex: 1, WaitTime: 160 * time.Millisecond},
+			(50 + 160 + 10) * time.Millisecond,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			_, _, err := clients.Consul().KV().Get("foo", tc.opts)
+			elapsed := time.Since(start)
+			if err == nil || !strings.Contains(err.Error(),
+				context.DeadlineExceeded.Error()) {
+				t.Fatalf("expected deadline exceeded error, got: %v", err)
+			}
+			if elapsed < tc.min || elapsed > 2*time.Second {
+				t.Errorf("bad elapsed time: %v", elapsed)
+			}
+		})
+	}
+}
+
+// countingTransport is a RoundTripper counting the requests it passes on
+type countingTransport struct {
+	count int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.count, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClientSet_customTransport(t *testing.T) {
+	t.Parallel()
+
+	// leader responds to the leader check, recording the requested hosts
+	var mu sync.Mutex
+	var hosts []string
+	leader := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hosts = append(hosts, r.Host)
+			mu.Unlock()
+			w.Write([]byte(`"127.0.0.1:8300"`))
+		}))
+	defer leader.Close()
+
+	t.Run("round-tripper", func(t *testing.T) {
+		rt := &countingTransport{}
+		clients := NewClientSet()
+		defer clients.Stop()
+		if err := clients.CreateConsulClient(&CreateClientInput{
+			Address:               leader.Listener.Addr().String(),
+			TransportRoundTripper: rt,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if atomic.LoadInt32(&rt.count) == 0 {
+			t.Error("round tripper not used")
+		}
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		proxyURL, err := url.Parse(leader.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clients := NewClientSet()
+		defer clients.Stop()
+		// the address doesn't resolve, requests only succeed via the proxy
+		if err := clients.CreateConsulClient(&CreateClientInput{
+			Address:        "consul.invalid:8500",
+			TransportProxy: http.ProxyURL(proxyURL),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(hosts) == 0 || hosts[len(hosts)-1] != "consul.invalid:8500" {
+			t.Errorf("proxy not used, hosts: %v", hosts)
+		}
+	})
+}
+
+// roundTripperFunc is a RoundTripper calling the function
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestClientSet_vaultUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "vault.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"data": {"path": "` + r.URL.Path + `"}}`))
+		})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	// the custom round tripper dials the socket itself
+	unixTransport := &http.Transport{DialContext: func(ctx context.Context,
+		_, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+	}}
+	cases := []struct {
+		name  string
+		input CreateClientInput
+	}{
+		{"plain", CreateClientInput{}},
+		{"ssl", CreateClientInput{SSLEnabled: true}},
+		{"fetch-timeout", CreateClientInput{TransportFetchTimeout: time.Second}},
+		{"round-tripper", CreateClientInput{
+			TransportRoundTripper: roundTripperFunc(unixTransport.RoundTrip)}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			clients := NewClientSet()
+			defer clients.Stop()
+			tc.input.Address = "unix://" + socket
+			if err := clients.CreateVaultClient(&tc.input); err != nil {
+				t.Fatal(err)
+			}
+			secret, err := clients.Vault().Logical().Read("secret/foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if secret == nil || secret.Data["path"] != "/v1/secret/foo" {
+				t.Errorf("bad secret: %#v", secret)
+			}
+		})
+	}
+}
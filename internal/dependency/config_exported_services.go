@@ -0,0 +1,151 @@
+package dependency
+
+import (
+	"encoding/gob"
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*ExportedServicesQuery)(nil)
+
+	// ExportedServicesQueryRe is the regular expression to use.
+	ExportedServicesQueryRe = regexp.MustCompile(`\A` + dcRe + `\z`)
+)
+
+func init() {
+	gob.Register(&dep.ExportedServices{})
+}
+
+// ExportedServicesQuery is the dependency to query the exported-services
+// config entry of the partition, listing the services exported to other
+// partitions and cluster peers.
+type ExportedServicesQuery struct {
+	isConsul
+	stopCh chan struct{}
+
+	dc   string
+	opts QueryOptions
+}
+
+// NewExportedServicesQuery parses the given string into a dependency. If the
+// datacenter is empty then the agent's datacenter is used.
+func NewExportedServicesQuery(s string) (*ExportedServicesQuery, error) {
+	if !ExportedServicesQueryRe.MatchString(s) {
+		return nil, fmt.Errorf("config.exported-services: invalid format: %q", s)
+	}
+
+	m := regexpMatch(ExportedServicesQueryRe, s)
+	return &ExportedServicesQuery{
+		dc:     m["dc"],
+		stopCh: make(chan struct{}, 1),
+	}, nil
+}
+
+// exportedServicesEntry is the exported-services config entry as returned by
+// the API. The Consul API client doesn't support the kind, so it is queried
+// and decoded directly.
+type exportedServicesEntry struct {
+	Name     string
+	Services []struct {
+		Name      string
+		Namespace string
+		Consumers []struct {
+			Partition     string
+			Peer          string
+			PeerName      string // older name for Peer
+			SamenessGroup string
+		}
+	}
+	Meta map[string]string
+}
+
+// Fetch queries the Consul API defined by the given client and returns the
+// *ExportedServices config entry, or nil if there isn't one.
+func (d *ExportedServicesQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	opts := d.opts.Merge(&QueryOptions{
+		Datacenter: d.dc,
+	})
+
+	// the list is used as it is empty, not an error, when there's no entry
+	var entries []exportedServicesEntry
+	qm, err := clients.Consul().Raw().Query("/v1/config/exported-services",
+		&entries, opts.ToConsulOpts())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.ID())
+	}
+
+	rm := &dep.ResponseMetadata{
+		LastIndex:   qm.LastIndex,
+		LastContact: qm.LastContact,
+	}
+
+	// there is at most one entry per partition, named for it
+	if len(entries) == 0 {
+		return nil, rm, nil
+	}
+	entry := entries[0]
+	result := &dep.ExportedServices{
+		Name:     entry.Name,
+		Services: make([]dep.ExportedService, 0, len(entry.Services)),
+		Meta:     entry.Meta,
+	}
+	for _, s := range entry.Services {
+		svc := dep.ExportedService{
+			Name:      s.Name,
+			Namespace: s.Namespace,
+			Consumers: make([]dep.ServiceConsumer, 0, len(s.Consumers)),
+		}
+		for _, c := range s.Consumers {
+			peer := c.Peer
+			if peer == "" {
+				peer = c.PeerName
+			}
+			svc.Consumers = append(svc.Consumers, dep.ServiceConsumer{
+				Partition:     c.Partition,
+				Peer:          peer,
+				SamenessGroup: c.SamenessGroup,
+			})
+		}
+		result.Services = append(result.Services, svc)
+	}
+
+	return result, rm, nil
+}
+
+// CanShare returns if this dependency is shareable.
+func (d *ExportedServicesQuery) CanShare() bool {
+	return true
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *ExportedServicesQuery) ID() string {
+	if d.dc != "" {
+		return fmt.Sprintf("config.exported-services(@%s)", d.dc)
+	}
+	return "config.exported-services"
+}
+
+// Stringer interface reuses ID
+func (d *ExportedServicesQuery) String() string {
+	return d.ID()
+}
+
+// Stop terminates this dependency's fetch.
+func (d *ExportedServicesQuery) Stop() {
+	close(d.stopCh)
+}
+
+func (d *ExportedServicesQuery) SetOptions(opts QueryOptions) {
+	d.opts = opts
+}
@@ -0,0 +1,98 @@
+package dependency
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewExportedServicesQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    string
+		exp  *ExportedServicesQuery
+		err  bool
+	}{
+		{
+			"empty",
+			"",
+			&ExportedServicesQuery{},
+			false,
+		},
+		{
+			"dc",
+			"@dc1",
+			&ExportedServicesQuery{
+				dc: "dc1",
+			},
+			false,
+		},
+		{
+			"invalid",
+			"default",
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewExportedServicesQuery(tc.i)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestExportedServicesQuery_Fetch(t *testing.T) {
+	t.Parallel()
+
+	// the test server predates the exported-services config entry
+	d, err := NewExportedServicesQuery("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = d.Fetch(testClients)
+	assert.Error(t, err)
+}
+
+func TestExportedServicesQuery_String(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    string
+		exp  string
+	}{
+		{
+			"empty",
+			"",
+			"config.exported-services",
+		},
+		{
+			"datacenter",
+			"@dc1",
+			"config.exported-services(@dc1)",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewExportedServicesQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, d.ID())
+		})
+	}
+}
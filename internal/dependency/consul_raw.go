@@ -0,0 +1,112 @@
+package dependency
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*ConsulRawQuery)(nil)
+)
+
+// ConsulRawQuery is a generic dependency on a Consul HTTP API endpoint, for
+// endpoints not covered by the other dependencies. The JSON response is
+// decoded into generic values (maps, slices, strings, float64s, etc.).
+//
+// Blocking queries are used if the endpoint supports them, detected by the
+// response having an index. Endpoints that don't are polled, sleeping
+// OperatorQuerySleepTime between queries.
+type ConsulRawQuery struct {
+	isConsul
+	stopCh chan struct{}
+
+	path string
+	opts QueryOptions
+
+	// polling is set once the endpoint is found to not support blocking
+	polling bool
+}
+
+// NewConsulRawQuery creates the dependency for the method and the endpoint's
+// path (eg. "/v1/operator/raft/configuration"). Only GET is supported, and
+// the path can't have query parameters.
+func NewConsulRawQuery(method, path string) (*ConsulRawQuery, error) {
+	if !strings.EqualFold(method, "GET") {
+		return nil, fmt.Errorf("consul.raw: unsupported method: %q", method)
+	}
+	if !strings.HasPrefix(path, "/v1/") || strings.ContainsAny(path, "?#") {
+		return nil, fmt.Errorf("consul.raw: invalid path: %q", path)
+	}
+
+	return &ConsulRawQuery{
+		stopCh: make(chan struct{}, 1),
+		path:   path,
+	}, nil
+}
+
+// Fetch queries the Consul API defined by the given client.
+func (d *ConsulRawQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	opts := d.opts.Merge(&QueryOptions{})
+	if d.polling && opts.WaitIndex != 0 {
+		select {
+		case <-d.stopCh:
+			return nil, nil, ErrStopped
+		case <-time.After(OperatorQuerySleepTime):
+		case <-opts.done():
+		}
+		opts.WaitIndex = 0
+	}
+
+	var data interface{}
+	qm, err := clients.Consul().Raw().Query(d.path, &data, opts.ToConsulOpts())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.ID())
+	}
+
+	if qm.LastIndex == 0 {
+		d.polling = true
+		return respWithMetadata(data)
+	}
+
+	rm := &dep.ResponseMetadata{
+		LastIndex:   qm.LastIndex,
+		LastContact: qm.LastContact,
+	}
+
+	return data, rm, nil
+}
+
+// CanShare returns a boolean if this dependency is shareable.
+func (d *ConsulRawQuery) CanShare() bool {
+	return true
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *ConsulRawQuery) ID() string {
+	return fmt.Sprintf("consul.raw(GET %s)", d.path)
+}
+
+// Stringer interface reuses ID
+func (d *ConsulRawQuery) String() string {
+	return d.ID()
+}
+
+// Stop halts the dependency's fetch function.
+func (d *ConsulRawQuery) Stop() {
+	close(d.stopCh)
+}
+
+func (d *ConsulRawQuery) SetOptions(opts QueryOptions) {
+	d.opts = opts
+}
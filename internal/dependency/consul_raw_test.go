@@ -0,0 +1,112 @@
+package dependency
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewConsulRawQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		exp    *ConsulRawQuery
+		err    bool
+	}{
+		{
+			"get",
+			"GET",
+			"/v1/operator/raft/configuration",
+			&ConsulRawQuery{path: "/v1/operator/raft/configuration"},
+			false,
+		},
+		{
+			"lowercase_method",
+			"get",
+			"/v1/agent/self",
+			&ConsulRawQuery{path: "/v1/agent/self"},
+			false,
+		},
+		{
+			"put",
+			"PUT",
+			"/v1/kv/foo",
+			nil,
+			true,
+		},
+		{
+			"not_v1",
+			"GET",
+			"/ui/",
+			nil,
+			true,
+		},
+		{
+			"query_params",
+			"GET",
+			"/v1/catalog/services?dc=dc1",
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewConsulRawQuery(tc.method, tc.path)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestConsulRawQuery_Fetch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("blocking", func(t *testing.T) {
+		d, err := NewConsulRawQuery("GET", "/v1/catalog/services")
+		if err != nil {
+			t.Fatal(err)
+		}
+		act, rm, err := d.Fetch(testClients)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Contains(t, act, "consul")
+		assert.NotZero(t, rm.LastIndex)
+		assert.False(t, d.polling)
+	})
+
+	t.Run("polling", func(t *testing.T) {
+		d, err := NewConsulRawQuery("GET", "/v1/status/leader")
+		if err != nil {
+			t.Fatal(err)
+		}
+		act, _, err := d.Fetch(testClients)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.IsType(t, "", act)
+		assert.True(t, d.polling)
+	})
+}
+
+func TestConsulRawQuery_String(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewConsulRawQuery("get", "/v1/agent/self")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "consul.raw(GET /v1/agent/self)", d.ID())
+}
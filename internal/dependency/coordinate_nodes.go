@@ -0,0 +1,134 @@
+package dependency
+
+import (
+	"encoding/gob"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ isDependency  = (*CoordinateNodesQuery)(nil)
+	_ BlockingQuery = (*CoordinateNodesQuery)(nil)
+
+	// CoordinateNodesQueryRe is the regular expression to use.
+	CoordinateNodesQueryRe = regexp.MustCompile(`\A` + dcRe + `\z`)
+)
+
+func init() {
+	gob.Register([]*dep.NodeCoordinate{})
+}
+
+// CoordinateNodesQuery is the representation of the network coordinates of
+// all the nodes in a Consul datacenter.
+type CoordinateNodesQuery struct {
+	isConsul
+	isBlocking
+	stopCh chan struct{}
+
+	dc   string
+	opts QueryOptions
+}
+
+// NewCoordinateNodesQuery parses the given string into a dependency. If the
+// datacenter is empty then the agent's datacenter is used.
+func NewCoordinateNodesQuery(s string) (*CoordinateNodesQuery, error) {
+	if !CoordinateNodesQueryRe.MatchString(s) {
+		return nil, fmt.Errorf("coordinate.nodes: invalid format: %q", s)
+	}
+
+	m := regexpMatch(CoordinateNodesQueryRe, s)
+	return &CoordinateNodesQuery{
+		dc:     m["dc"],
+		stopCh: make(chan struct{}, 1),
+	}, nil
+}
+
+// Fetch queries the Consul API defined by the given client and returns a slice
+// of NodeCoordinate objects
+func (d *CoordinateNodesQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	opts := d.opts.Merge(&QueryOptions{
+		Datacenter: d.dc,
+	})
+
+	entries, qm, err := clients.Consul().Coordinate().Nodes(opts.ToConsulOpts())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.ID())
+	}
+
+	coords := make([]*dep.NodeCoordinate, 0, len(entries))
+	for _, entry := range entries {
+		nc := &dep.NodeCoordinate{
+			Node:    entry.Node,
+			Segment: entry.Segment,
+		}
+		if c := entry.Coord; c != nil {
+			nc.Coord = &dep.Coordinate{
+				Vec:        c.Vec,
+				Error:      c.Error,
+				Adjustment: c.Adjustment,
+				Height:     c.Height,
+			}
+		}
+		coords = append(coords, nc)
+	}
+
+	sort.Stable(ByNodeCoordinate(coords))
+
+	rm := &dep.ResponseMetadata{
+		LastIndex:   qm.LastIndex,
+		LastContact: qm.LastContact,
+	}
+
+	return coords, rm, nil
+}
+
+// CanShare returns a boolean if this dependency is shareable.
+func (d *CoordinateNodesQuery) CanShare() bool {
+	return true
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *CoordinateNodesQuery) ID() string {
+	if d.dc != "" {
+		return fmt.Sprintf("coordinate.nodes(@%s)", d.dc)
+	}
+	return "coordinate.nodes"
+}
+
+// Stringer interface reuses ID
+func (d *CoordinateNodesQuery) String() string {
+	return d.ID()
+}
+
+// Stop halts the dependency's fetch function.
+func (d *CoordinateNodesQuery) Stop() {
+	close(d.stopCh)
+}
+
+func (d *CoordinateNodesQuery) SetOptions(opts QueryOptions) {
+	d.opts = opts
+}
+
+// ByNodeCoordinate is a sortable list of node coordinates by node name and
+// then network segment.
+type ByNodeCoordinate []*dep.NodeCoordinate
+
+func (s ByNodeCoordinate) Len() int      { return len(s) }
+func (s ByNodeCoordinate) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s ByNodeCoordinate) Less(i, j int) bool {
+	if s[i].Node == s[j].Node {
+		return s[i].Segment < s[j].Segment
+	}
+	return s[i].Node < s[j].Node
+}
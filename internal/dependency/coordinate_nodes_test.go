@@ -0,0 +1,114 @@
+package dependency
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCoordinateNodesQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    string
+		exp  *CoordinateNodesQuery
+		err  bool
+	}{
+		{
+			"empty",
+			"",
+			&CoordinateNodesQuery{},
+			false,
+		},
+		{
+			"node",
+			"node",
+			nil,
+			true,
+		},
+		{
+			"dc",
+			"@dc1",
+			&CoordinateNodesQuery{
+				dc: "dc1",
+			},
+			false,
+		},
+		{
+			"near",
+			"~node1",
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewCoordinateNodesQuery(tc.i)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestCoordinateNodesQuery_Fetch(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewCoordinateNodesQuery("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	act, _, err := d.Fetch(testClients)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// coordinates are computed asynchronously by the agent, so the test
+	// server may not have any yet
+	for _, c := range act.([]*dep.NodeCoordinate) {
+		assert.Equal(t, testConsul.Config.NodeName, c.Node)
+		assert.NotNil(t, c.Coord)
+	}
+}
+
+func TestCoordinateNodesQuery_String(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    string
+		exp  string
+	}{
+		{
+			"empty",
+			"",
+			"coordinate.nodes",
+		},
+		{
+			"datacenter",
+			"@dc1",
+			"coordinate.nodes(@dc1)",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewCoordinateNodesQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, d.ID())
+		})
+	}
+}
@@ -2,10 +2,12 @@ package dependency
 
 import (
 	"context"
+	"fmt"
 	"net/url"
 	"regexp"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	consulapi "github.com/hashicorp/consul/api"
@@ -23,6 +25,30 @@ const (
 	tagRe         = `((?P<tag>[[:word:]=:\.\-\_]+)\.)?`
 )
 
+// The sentinel near values Consul supports, sorting by the estimated round
+// trip time to the agent (queried) or to the requester's IP address.
+const (
+	NearAgent = "_agent"
+	NearIP    = "_ip"
+)
+
+var nearNodeRe = regexp.MustCompile(`\A[[:word:]\.\-]+\z`)
+
+// validNear returns an error if the near value isn't a node name or one of
+// the sentinel values (NearAgent or NearIP). Empty is valid, for no sorting.
+func validNear(near string) error {
+	switch {
+	case near == "", near == NearAgent, near == NearIP:
+		return nil
+	case strings.HasPrefix(near, "_"):
+		return fmt.Errorf("invalid near: %q, expected a node name, %q or %q",
+			near, NearAgent, NearIP)
+	case !nearNodeRe.MatchString(near):
+		return fmt.Errorf("invalid near: %q", near)
+	}
+	return nil
+}
+
 // Type aliases to simplify things as we refactor
 //type QueryOptions = dep.QueryOptions
 type ResponseMetadata = dep.ResponseMetadata
@@ -38,14 +64,25 @@ type VaultType interface {
 type ConsulType interface {
 	Consul()
 }
+type ExecType interface {
+	Exec()
+}
 type isConsul struct{}
 type isVault struct{}
+type isExec struct{}
 type isBlocking struct{}
 
 func (isConsul) Consul()          {}
 func (isVault) Vault()            {}
+func (isExec) Exec()              {}
 func (isBlocking) blockingQuery() {}
 
+// VaultPathQuery is implemented by the Vault dependencies on a secret path,
+// VaultPath returns the path (without the leading slash).
+type VaultPathQuery interface {
+	VaultPath() string
+}
+
 // This specifies all the fields internally required by dependencies.
 // The public ones + private ones used internally by hashicat.
 // Used to validate interface implementations in each dependency file.
@@ -70,13 +107,22 @@ type QueryOptions struct {
 	Filter            string
 	Namespace         string
 	Near              string
+	Partition         string
 	RequireConsistent bool
 	VaultGrace        time.Duration
 	WaitIndex         uint64
 	WaitTime          time.Duration
 	DefaultLease      time.Duration
-
-	ctx context.Context
+	// MaxAge caps the time the Vault dependencies wait (for a lease to be
+	// renewed or expire) before reading their secret again. Zero is no cap.
+	// For Consul queries with UseCache it is the maximum age of the cached
+	// data.
+	MaxAge time.Duration
+	// UseCache has Consul queries served from the agent's cache
+	UseCache bool
+
+	ctx           context.Context
+	leaseObserver func(dep.LeaseEvent)
 }
 
 func (q *QueryOptions) Merge(o *QueryOptions) *QueryOptions {
@@ -116,6 +162,10 @@ func (q *QueryOptions) Merge(o *QueryOptions) *QueryOptions {
 		r.Near = o.Near
 	}
 
+	if o.Partition != "" {
+		r.Partition = o.Partition
+	}
+
 	if o.RequireConsistent != false {
 		r.RequireConsistent = o.RequireConsistent
 	}
@@ -140,6 +190,34 @@ func (q *QueryOptions) SetContext(ctx context.Context) QueryOptions {
 	return q2
 }
 
+// SetLeaseObserver returns a copy of the options with the function to call
+// with the lease events of Vault secrets.
+func (q *QueryOptions) SetLeaseObserver(f func(dep.LeaseEvent)) QueryOptions {
+	var q2 QueryOptions
+	if q != nil {
+		q2 = *q
+	}
+	q2.leaseObserver = f
+	return q2
+}
+
+// done returns the Done channel of the options' context, nil (never ready)
+// if not set. It is closed when the fetch is canceled, eg. to refresh it, and
+// is used to cut short the waits between polled queries.
+func (q *QueryOptions) done() <-chan struct{} {
+	if q == nil || q.ctx == nil {
+		return nil
+	}
+	return q.ctx.Done()
+}
+
+// observeLease passes the lease event to the lease observer, if set.
+func (q *QueryOptions) observeLease(e dep.LeaseEvent) {
+	if q != nil && q.leaseObserver != nil {
+		q.leaseObserver(e)
+	}
+}
+
 func (q *QueryOptions) ToConsulOpts() *consulapi.QueryOptions {
 	cq := consulapi.QueryOptions{
 		AllowStale:        q.AllowStale,
@@ -150,10 +228,19 @@ func (q *QueryOptions) ToConsulOpts() *consulapi.QueryOptions {
 		RequireConsistent: q.RequireConsistent,
 		WaitIndex:         q.WaitIndex,
 		WaitTime:          q.WaitTime,
+		UseCache:          q.UseCache,
+		MaxAge:            q.MaxAge,
 	}
 
-	if q.ctx != nil {
-		return cq.WithContext(q.ctx)
+	ctx := q.ctx
+	if q.Partition != "" {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx = withQueryParam(ctx, "partition", q.Partition)
+	}
+	if ctx != nil {
+		return cq.WithContext(ctx)
 	}
 	return &cq
 }
@@ -181,6 +268,10 @@ func (q *QueryOptions) String() string {
 		u.Add("near", q.Near)
 	}
 
+	if q.Partition != "" {
+		u.Add("partition", q.Partition)
+	}
+
 	if q.RequireConsistent {
 		u.Add("consistent", strconv.FormatBool(q.RequireConsistent))
 	}
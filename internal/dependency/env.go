@@ -0,0 +1,160 @@
+package dependency
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*EnvQuery)(nil)
+
+	// EnvQuerySleepTime is the amount of time to sleep between checks of the
+	// environment variable for changes.
+	EnvQuerySleepTime = 2 * time.Second
+)
+
+// EnvQuery is the dependency on an environment variable, of the process or
+// of an env file (eg. one rewritten by a supervisor). It is polled and has
+// new data when the variable's value changes, unset variables have the empty
+// value.
+type EnvQuery struct {
+	stopCh chan struct{}
+
+	name    string
+	path    string // of the env file, empty for the process environment
+	value   string
+	fetched bool
+	index   uint64 // incremented on each change
+}
+
+// NewEnvQuery creates a dependency on the process' environment variable.
+func NewEnvQuery(name string) (*EnvQuery, error) {
+	return newEnvQuery(name, "")
+}
+
+// NewEnvFileQuery creates a dependency on the environment variable set in the
+// env file at the path. The file has a `NAME=value` line per variable,
+// optionally prefixed with `export ` and with the value quoted. Blank lines
+// and lines starting with `#` are ignored.
+func NewEnvFileQuery(path, name string) (*EnvQuery, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("env: invalid file: %q", path)
+	}
+	return newEnvQuery(name, path)
+}
+
+func newEnvQuery(name, path string) (*EnvQuery, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.Contains(name, "=") {
+		return nil, fmt.Errorf("env: invalid name: %q", name)
+	}
+	return &EnvQuery{
+		stopCh: make(chan struct{}, 1),
+		name:   name,
+		path:   path,
+	}, nil
+}
+
+// Fetch returns the variable's value right away the first time, then polls
+// it and returns it when it changes.
+func (d *EnvQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	for {
+		select {
+		case <-d.stopCh:
+			return "", nil, ErrStopped
+		default:
+		}
+
+		value, err := d.lookup()
+		if err != nil {
+			return "", nil, errors.Wrap(err, d.ID())
+		}
+		if !d.fetched || value != d.value {
+			d.fetched, d.value = true, value
+			d.index++
+			return value, &dep.ResponseMetadata{LastIndex: d.index}, nil
+		}
+
+		select {
+		case <-d.stopCh:
+			return "", nil, ErrStopped
+		case <-time.After(EnvQuerySleepTime):
+		}
+	}
+}
+
+// lookup returns the variable's current value
+func (d *EnvQuery) lookup() (string, error) {
+	if d.path == "" {
+		return os.Getenv(d.name), nil
+	}
+
+	f, err := os.Open(d.path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var value string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		split := strings.SplitN(line, "=", 2)
+		if len(split) != 2 || strings.TrimSpace(split[0]) != d.name {
+			continue
+		}
+		// the last assignment wins, as when sourced by a shell
+		value = unquoteEnv(strings.TrimSpace(split[1]))
+	}
+	return value, scanner.Err()
+}
+
+// unquoteEnv strips the matching single or double quotes around the value
+func unquoteEnv(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// File returns the path of the env file, empty for the process environment.
+func (d *EnvQuery) File() string {
+	return d.path
+}
+
+// CanShare returns a boolean if this dependency is shareable.
+func (d *EnvQuery) CanShare() bool {
+	return false
+}
+
+// Stop halts the dependency's fetch function.
+func (d *EnvQuery) Stop() {
+	close(d.stopCh)
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *EnvQuery) ID() string {
+	if d.path != "" {
+		return fmt.Sprintf("env(%s@%s)", d.name, d.path)
+	}
+	return fmt.Sprintf("env(%s)", d.name)
+}
+
+// Stringer interface reuses ID
+func (d *EnvQuery) String() string {
+	return d.ID()
+}
+
+func (d *EnvQuery) SetOptions(opts QueryOptions) {}
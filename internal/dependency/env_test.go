@@ -0,0 +1,157 @@
+package dependency
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	EnvQuerySleepTime = 50 * time.Millisecond
+}
+
+func TestNewEnvQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		path string
+		i    string
+		exp  string
+		err  bool
+	}{
+		{"process", "", "FOO", "env(FOO)", false},
+		{"file", "/etc/app.env", " FOO ", "env(FOO@/etc/app.env)", false},
+		{"empty", "", "", "", true},
+		{"equals", "", "FOO=bar", "", true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			var d *EnvQuery
+			var err error
+			if tc.path == "" {
+				d, err = NewEnvQuery(tc.i)
+			} else {
+				d, err = NewEnvFileQuery(tc.path, tc.i)
+			}
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if d != nil {
+				assert.Equal(t, tc.exp, d.ID())
+			}
+		})
+	}
+
+	if _, err := NewEnvFileQuery(" ", "FOO"); err == nil {
+		t.Error("expected error for empty file")
+	}
+}
+
+func TestEnvQuery_Fetch(t *testing.T) {
+	// fetch returns the changed value, failing if it takes too long
+	fetch := func(t *testing.T, d *EnvQuery) (string, uint64) {
+		type result struct {
+			v   interface{}
+			idx uint64
+			err error
+		}
+		ch := make(chan result, 1)
+		go func() {
+			v, rm, err := d.Fetch(nil)
+			var idx uint64
+			if rm != nil {
+				idx = rm.LastIndex
+			}
+			ch <- result{v, idx, err}
+		}()
+		select {
+		case r := <-ch:
+			if r.err != nil {
+				t.Fatal(r.err)
+			}
+			return r.v.(string), r.idx
+		case <-time.After(time.Second):
+			d.Stop()
+			t.Fatal("fetch didn't return")
+		}
+		return "", 0
+	}
+
+	t.Run("process", func(t *testing.T) {
+		os.Setenv("HCAT_ENV_QUERY_TEST", "foo")
+		defer os.Unsetenv("HCAT_ENV_QUERY_TEST")
+
+		d, err := NewEnvQuery("HCAT_ENV_QUERY_TEST")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, idx := fetch(t, d); v != "foo" || idx != 1 {
+			t.Errorf("bad first fetch: %q, %d", v, idx)
+		}
+		os.Setenv("HCAT_ENV_QUERY_TEST", "bar")
+		if v, idx := fetch(t, d); v != "bar" || idx != 2 {
+			t.Errorf("bad changed fetch: %q, %d", v, idx)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "app.env")
+		write := func(contents string) {
+			if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		write("# comment\nOTHER=1\nexport FOO=\"foo bar\"\n")
+
+		d, err := NewEnvFileQuery(path, "FOO")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := fetch(t, d); v != "foo bar" {
+			t.Errorf("bad first fetch: %q", v)
+		}
+		// other variables changing doesn't return
+		write("OTHER=2\nFOO='foo bar'\n")
+		go func() {
+			time.Sleep(3 * EnvQuerySleepTime)
+			write("OTHER=2\nFOO=baz\n")
+		}()
+		if v, _ := fetch(t, d); v != "baz" {
+			t.Errorf("bad changed fetch: %q", v)
+		}
+	})
+
+	t.Run("missing_file", func(t *testing.T) {
+		d, err := NewEnvFileQuery("/nope/app.env", "FOO")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := d.Fetch(nil); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		d, err := NewEnvQuery("HCAT_ENV_QUERY_UNSET")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fetch(t, d)
+		d.Stop()
+		if _, _, err := d.Fetch(nil); err != ErrStopped {
+			t.Errorf("expected stopped: %v", err)
+		}
+	})
+}
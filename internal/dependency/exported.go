@@ -0,0 +1,27 @@
+package dependency
+
+import "regexp"
+
+// Exported versions of the type annotations, regular expressions and helpers
+// for implementing dependencies. These are made public by the hcat/depext
+// package, see it for their documentation.
+
+type IsConsul = isConsul
+type IsVault = isVault
+type IsExec = isExec
+type IsBlocking = isBlocking
+
+const (
+	DatacenterRe  = dcRe
+	KeyRe         = keyRe
+	FilterRe      = filterRe
+	ServiceNameRe = serviceNameRe
+	NodeNameRe    = nodeNameRe
+	NearRe        = nearRe
+	PrefixRe      = prefixRe
+	TagRe         = tagRe
+)
+
+func RegexpMatch(re *regexp.Regexp, q string) map[string]string {
+	return regexpMatch(re, q)
+}
@@ -211,16 +211,20 @@ type FakeDepBlockingQuery struct {
 	Data          interface{}
 	BlockDuration time.Duration
 	Ctx           context.Context
-	stop          chan struct{}
+
+	// stop is created once, by the first of Fetch or Stop
+	stopOnce sync.Once
+	stop     chan struct{}
 }
 
-func (d *FakeDepBlockingQuery) Fetch(dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
-	if d.stop == nil {
-		d.stop = make(chan struct{})
-	}
+func (d *FakeDepBlockingQuery) stopCh() chan struct{} {
+	d.stopOnce.Do(func() { d.stop = make(chan struct{}) })
+	return d.stop
+}
 
+func (d *FakeDepBlockingQuery) Fetch(dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
 	select {
-	case <-d.stop:
+	case <-d.stopCh():
 		return nil, nil, dep.ErrStopped
 	case <-time.After(d.BlockDuration):
 		return d.Data, &dep.ResponseMetadata{LastIndex: 1}, nil
@@ -237,7 +241,158 @@ func (d *FakeDepBlockingQuery) String() string {
 }
 
 func (d *FakeDepBlockingQuery) Stop() {
-	if d.stop != nil {
-		close(d.stop)
+	close(d.stopCh())
+}
+
+// FakeDepWaitIndex is a fake blocking query dependency that honors the
+// WaitIndex. With a WaitIndex of 0 it returns the next of its fetch count
+// (as "name_N") at index 10, otherwise it blocks until its context is
+// canceled. It records the WaitIndexes it was fetched with.
+type FakeDepWaitIndex struct {
+	FakeDep
+	Name string
+
+	sync.Mutex
+	fetches int
+	indexes []uint64
+}
+
+func (d *FakeDepWaitIndex) Fetch(dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	d.Lock()
+	opts := d.Opts
+	d.indexes = append(d.indexes, opts.WaitIndex)
+	d.Unlock()
+
+	if opts.WaitIndex != 0 {
+		<-opts.ctx.Done()
+		return nil, nil, opts.ctx.Err()
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	d.fetches++
+	data := fmt.Sprintf("%s_%d", d.Name, d.fetches)
+	return data, &dep.ResponseMetadata{LastIndex: 10}, nil
+}
+
+func (d *FakeDepWaitIndex) SetOptions(opts QueryOptions) {
+	d.Lock()
+	defer d.Unlock()
+	d.Opts = opts
+}
+
+// Indexes returns the WaitIndexes it was fetched with.
+func (d *FakeDepWaitIndex) Indexes() []uint64 {
+	d.Lock()
+	defer d.Unlock()
+	return append([]uint64{}, d.indexes...)
+}
+
+func (d *FakeDepWaitIndex) ID() string {
+	return fmt.Sprintf("test_dep_wait_index(%s)", d.Name)
+}
+func (d *FakeDepWaitIndex) String() string {
+	return d.ID()
+}
+
+// FakeDepLease is a fake dependency that returns a secret with a lease,
+// sending the acquired lease event to the lease observer.
+type FakeDepLease struct {
+	FakeDep
+	Name string
+}
+
+func (d *FakeDepLease) Fetch(dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	secret := &dep.Secret{LeaseID: d.Name + "/lease", LeaseDuration: 60}
+	d.Opts.observeLease(leaseEvent(dep.LeaseAcquired, d.ID(), secret))
+	return secret, &dep.ResponseMetadata{LastIndex: 1}, nil
+}
+
+func (d *FakeDepLease) ID() string {
+	return fmt.Sprintf("test_dep_lease(%s)", d.Name)
+}
+func (d *FakeDepLease) String() string {
+	return d.ID()
+}
+
+////////////
+// FakeVaultDep is a fake Vault dependency on a secret path, it returns the
+// path as its data.
+type FakeVaultDep struct {
+	isVault
+	Path string
+
+	mu   sync.Mutex
+	opts QueryOptions
+}
+
+func (d *FakeVaultDep) Fetch(dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	time.Sleep(time.Microsecond)
+	return d.Path, &dep.ResponseMetadata{LastIndex: 1}, nil
+}
+
+func (d *FakeVaultDep) ID() string {
+	return fmt.Sprintf("test_vault_dep(%s)", d.Path)
+}
+func (d *FakeVaultDep) String() string {
+	return d.ID()
+}
+
+func (d *FakeVaultDep) CanShare() bool {
+	return true
+}
+func (d *FakeVaultDep) Stop() {}
+func (d *FakeVaultDep) VaultPath() string {
+	return d.Path
+}
+func (d *FakeVaultDep) SetOptions(opts QueryOptions) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.opts = opts
+}
+func (d *FakeVaultDep) GetOptions() QueryOptions {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.opts
+}
+
+////////////
+// FakeDepOutage is a fake Consul dependency during a server outage, its
+// fetches fail unless served from the agent's cache (UseCache).
+type FakeDepOutage struct {
+	isConsul
+	Name string
+
+	mu        sync.Mutex
+	opts      QueryOptions
+	cacheOpts QueryOptions // of the last fetch from the cache
+}
+
+func (d *FakeDepOutage) Fetch(dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	time.Sleep(time.Microsecond)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.opts.UseCache {
+		return nil, nil, fmt.Errorf("no cluster leader")
 	}
+	d.cacheOpts = d.opts
+	return d.Name, &dep.ResponseMetadata{LastIndex: 1}, nil
+}
+
+func (d *FakeDepOutage) ID() string {
+	return fmt.Sprintf("test_dep_outage(%s)", d.Name)
+}
+func (d *FakeDepOutage) String() string {
+	return d.ID()
+}
+func (d *FakeDepOutage) Stop() {}
+func (d *FakeDepOutage) SetOptions(opts QueryOptions) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.opts = opts
+}
+func (d *FakeDepOutage) CacheOptions() QueryOptions {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cacheOpts
 }
@@ -2,11 +2,14 @@ package dependency
 
 import (
 	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
+	consulapi "github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-bexpr"
 	"github.com/hashicorp/hcat/dep"
 	"github.com/pkg/errors"
@@ -65,6 +68,13 @@ type HealthServiceQuery struct {
 	// passingOnly filters for services that have an overall aggregated status
 	// of passing. When true, sdk adds ?passing=1 to api request
 	passingOnly bool
+
+	// sortByWeight sorts the results by their effective weight, heaviest
+	// first, instead of by node.
+	sortByWeight bool
+
+	// nonZeroWeight filters out instances with an effective weight of 0.
+	nonZeroWeight bool
 }
 
 // NewHealthServiceQueryV1 processes the strings to build a service dependency.
@@ -119,8 +129,28 @@ func healthServiceQueryV1(service string, connect bool, opts []string) (*HealthS
 				healthServiceQuery.ns = value
 				continue
 			case "near":
+				if err := validNear(value); err != nil {
+					return nil, fmt.Errorf("health.service: %s for %q", err,
+						service)
+				}
 				healthServiceQuery.near = value
 				continue
+			case "sort":
+				if value != "weight" {
+					return nil, fmt.Errorf(
+						"health.service: invalid sort: %q for %q", value, service)
+				}
+				healthServiceQuery.sortByWeight = true
+				continue
+			case "nonzero_weight":
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, fmt.Errorf(
+						"health.service: invalid nonzero_weight: %q for %q",
+						value, service)
+				}
+				healthServiceQuery.nonZeroWeight = b
+				continue
 			}
 		}
 
@@ -152,6 +182,9 @@ func healthServiceQuery(s string, connect bool) (*HealthServiceQuery, error) {
 	}
 
 	m := regexpMatch(HealthServiceQueryRe, s)
+	if err := validNear(m["near"]); err != nil {
+		return nil, fmt.Errorf("health.service: %s in %q", err, s)
+	}
 
 	var filters []string
 	if filter := m["filter"]; filter != "" {
@@ -204,11 +237,22 @@ func (d *HealthServiceQuery) Fetch(clients dep.Clients) (interface{}, *dep.Respo
 		Near:       d.near,
 	})
 
-	nodes := clients.Consul().Health().Service
+	// queried raw, like the API client's Health().Service (or Connect), to
+	// decode the localities it doesn't have
+	path := "/v1/health/service/" + d.name
 	if d.connect {
-		nodes = clients.Consul().Health().Connect
+		path = "/v1/health/connect/" + d.name
+	}
+	q := opts.ToConsulOpts()
+	ctx := q.Context()
+	if d.deprecatedTag != "" {
+		ctx = withQueryParam(ctx, "tag", d.deprecatedTag)
 	}
-	entries, qm, err := nodes(d.name, d.deprecatedTag, d.passingOnly, opts.ToConsulOpts())
+	if d.passingOnly {
+		ctx = withQueryParam(ctx, HealthPassing, "1")
+	}
+	var entries []*serviceEntry
+	qm, err := clients.Consul().Raw().Query(path, &entries, q.WithContext(ctx))
 	if err != nil {
 		return nil, nil, errors.Wrap(err, d.ID())
 	}
@@ -230,7 +274,7 @@ func (d *HealthServiceQuery) Fetch(clients dep.Clients) (interface{}, *dep.Respo
 			address = entry.Node.Address
 		}
 
-		list = append(list, &dep.HealthService{
+		hs := &dep.HealthService{
 			Node:                   entry.Node.Node,
 			NodeID:                 entry.Node.ID,
 			Kind:                   string(entry.Service.Kind),
@@ -250,11 +294,19 @@ func (d *HealthServiceQuery) Fetch(clients dep.Clients) (interface{}, *dep.Respo
 			Port:      entry.Service.Port,
 			Weights:   entry.Service.Weights,
 			Namespace: entry.Service.Namespace,
-		})
+			Locality:  entry.Locality,
+		}
+		if d.nonZeroWeight && hs.EffectiveWeight() == 0 {
+			continue
+		}
+		list = append(list, hs)
 	}
 
 	// Sort unless the user explicitly asked for nearness
-	if d.near == "" {
+	switch {
+	case d.sortByWeight:
+		sort.Stable(ByWeight(list))
+	case d.near == "":
 		sort.Stable(ByNodeThenID(list))
 	}
 
@@ -266,6 +318,32 @@ func (d *HealthServiceQuery) Fetch(clients dep.Clients) (interface{}, *dep.Respo
 	return list, rm, nil
 }
 
+// serviceEntry is a health service entry with its locality, the service's
+// or else its node's, which the Consul API client doesn't decode.
+type serviceEntry struct {
+	*consulapi.ServiceEntry
+	Locality *dep.Locality
+}
+
+func (e *serviceEntry) UnmarshalJSON(b []byte) error {
+	e.ServiceEntry = new(consulapi.ServiceEntry)
+	if err := json.Unmarshal(b, e.ServiceEntry); err != nil {
+		return err
+	}
+	var localities struct {
+		Node    struct{ Locality *dep.Locality }
+		Service struct{ Locality *dep.Locality }
+	}
+	if err := json.Unmarshal(b, &localities); err != nil {
+		return err
+	}
+	e.Locality = localities.Service.Locality
+	if e.Locality == nil {
+		e.Locality = localities.Node.Locality
+	}
+	return nil
+}
+
 // CanShare returns a boolean if this dependency is shareable.
 func (d *HealthServiceQuery) CanShare() bool {
 	return true
@@ -299,6 +377,12 @@ func (d *HealthServiceQuery) ID() string {
 	if d.filter != "" {
 		opts = append(opts, fmt.Sprintf("filter=%s", d.filter))
 	}
+	if d.sortByWeight {
+		opts = append(opts, "sort=weight")
+	}
+	if d.nonZeroWeight {
+		opts = append(opts, "nonzero_weight=true")
+	}
 	if len(opts) > 0 {
 		name = fmt.Sprintf("%s?%s", name, strings.Join(opts, "&"))
 	}
@@ -342,3 +426,18 @@ func (s ByNodeThenID) Less(i, j int) bool {
 	}
 	return false
 }
+
+// ByWeight is a sortable slice of Service, sorted by their effective weight
+// (heaviest first), then by node and ID.
+type ByWeight []*dep.HealthService
+
+// Len, Swap, and Less are used to implement the sort.Sort interface.
+func (s ByWeight) Len() int      { return len(s) }
+func (s ByWeight) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s ByWeight) Less(i, j int) bool {
+	wi, wj := s[i].EffectiveWeight(), s[j].EffectiveWeight()
+	if wi != wj {
+		return wi > wj
+	}
+	return ByNodeThenID(s).Less(i, j)
+}
@@ -1,6 +1,7 @@
 package dependency
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -42,6 +43,23 @@ func TestNewHealthServiceQuery(t *testing.T) {
 			nil,
 			true,
 		},
+		{
+			"near_bad_sentinel",
+			"name~_nearest",
+			nil,
+			true,
+		},
+		{
+			"name_near_agent",
+			"name~_agent",
+			&HealthServiceQuery{
+				deprecatedStatusFilters: []string{"passing"},
+				name:                    "name",
+				near:                    NearAgent,
+				passingOnly:             true,
+			},
+			false,
+		},
 		{
 			"name",
 			"name",
@@ -639,6 +657,40 @@ func TestNewHealthServiceQueryV1(t *testing.T) {
 				passingOnly: true,
 			},
 			false,
+		}, {
+			"weights",
+			[]string{"sort=weight", "nonzero_weight=true"},
+			&HealthServiceQuery{
+				name:          "name",
+				passingOnly:   true,
+				sortByWeight:  true,
+				nonZeroWeight: true,
+			},
+			false,
+		}, {
+			"near ip",
+			[]string{"near=_ip"},
+			&HealthServiceQuery{
+				name:        "name",
+				near:        NearIP,
+				passingOnly: true,
+			},
+			false,
+		}, {
+			"invalid near sentinel",
+			[]string{"near=_nearest"},
+			nil,
+			true,
+		}, {
+			"invalid near",
+			[]string{"near=node name"},
+			nil,
+			true,
+		}, {
+			"invalid sort",
+			[]string{"sort=name"},
+			nil,
+			true,
 		}, {
 			"invalid query",
 			[]string{"dne=dne"},
@@ -720,6 +772,10 @@ func TestHealthServiceQueryV1_String(t *testing.T) {
 			"multifilter",
 			[]string{"Checks.Status != passing", "mytag in Service.Tags"},
 			`health.service(name?filter=Checks.Status != passing and mytag in Service.Tags)`,
+		}, {
+			"weights",
+			[]string{"sort=weight", "nonzero_weight=true"},
+			`health.service(name?sort=weight&nonzero_weight=true)`,
 		},
 	}
 
@@ -888,3 +944,43 @@ func Test_acceptStatus(t *testing.T) {
 		}
 	}
 }
+
+func TestServiceEntryLocality(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		json string
+		exp  *dep.Locality
+	}{
+		{
+			"none",
+			`{"Node": {"Node": "n1"}, "Service": {"ID": "web"}}`,
+			nil,
+		},
+		{
+			"service",
+			`{"Node": {"Node": "n1", "Locality": {"Region": "west"}},
+			  "Service": {"ID": "web",
+			    "Locality": {"Region": "east", "Zone": "east-a"}}}`,
+			&dep.Locality{Region: "east", Zone: "east-a"},
+		},
+		{
+			"node",
+			`{"Node": {"Node": "n1", "Locality": {"Region": "west"}},
+			  "Service": {"ID": "web"}}`,
+			&dep.Locality{Region: "west"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var entry serviceEntry
+			if err := json.Unmarshal([]byte(tc.json), &entry); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "n1", entry.Node.Node)
+			assert.Equal(t, "web", entry.Service.ID)
+			assert.Equal(t, tc.exp, entry.Locality)
+		})
+	}
+}
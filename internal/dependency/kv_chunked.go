@@ -0,0 +1,165 @@
+package dependency
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*KVChunkedQuery)(nil)
+
+	// KVChunkedQueryRe is the regular expression to use.
+	KVChunkedQueryRe = regexp.MustCompile(`\A` + keyRe + dcRe + `\z`)
+
+	// kvChunkRe matches the name of a chunk key, under the value's key
+	kvChunkRe = regexp.MustCompile(`\Apart-([0-9]+)\z`)
+)
+
+// KVChunkedQuery queries the KV store for a value too large for a single key,
+// stored in chunks under the key (`key/part-0000`, `key/part-0001`, ...). The
+// chunks are joined in numeric order into the value. Until they are numbered
+// from 0 without gaps, eg. while the value is being written, the query waits
+// for the next change instead of returning.
+type KVChunkedQuery struct {
+	isConsul
+	stopCh chan struct{}
+
+	dc   string
+	key  string
+	opts QueryOptions
+}
+
+// NewKVChunkedQuery parses a string into a dependency.
+func NewKVChunkedQuery(s string) (*KVChunkedQuery, error) {
+	if !KVChunkedQueryRe.MatchString(s) {
+		return nil, fmt.Errorf("kv.chunked: invalid format: %q", s)
+	}
+
+	m := regexpMatch(KVChunkedQueryRe, s)
+	return &KVChunkedQuery{
+		stopCh: make(chan struct{}, 1),
+		dc:     m["dc"],
+		key:    strings.TrimSuffix(m["key"], "/"),
+	}, nil
+}
+
+// Fetch queries the Consul API defined by the given client. It returns the
+// joined value as a string or nil if there are no chunks.
+func (d *KVChunkedQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	opts := d.opts.Merge(&QueryOptions{
+		Datacenter: d.dc,
+	})
+
+	for {
+		list, qm, err := clients.Consul().KV().List(d.key+"/part-",
+			opts.ToConsulOpts())
+		if err != nil {
+			return nil, nil, errors.Wrap(err, d.ID())
+		}
+
+		rm := &dep.ResponseMetadata{
+			LastIndex:   qm.LastIndex,
+			LastContact: qm.LastContact,
+		}
+
+		value, ok, err := joinChunks(d.key, list)
+		switch {
+		case err == errChunksIncomplete:
+			// not ready, the value is being written. Wait for the next
+			// change rather than returning a partial value.
+			select {
+			case <-d.stopCh:
+				return nil, nil, ErrStopped
+			default:
+			}
+			opts = opts.Merge(&QueryOptions{WaitIndex: qm.LastIndex})
+			continue
+		case err != nil:
+			return nil, nil, errors.Wrap(err, d.ID())
+		case !ok:
+			return nil, rm, nil
+		}
+		return value, rm, nil
+	}
+}
+
+// errChunksIncomplete is returned by joinChunks when the chunks aren't
+// numbered part-0000 to part-N without gaps or duplicates.
+var errChunksIncomplete = fmt.Errorf("chunks incomplete")
+
+// joinChunks joins the values of the key's chunks in order. Returns false if
+// there are no chunks and errChunksIncomplete unless they are numbered from 0
+// without gaps or duplicates, eg. when the value is being written.
+func joinChunks(key string, pairs consulapi.KVPairs) (string, bool, error) {
+	type chunk struct {
+		n     int
+		value []byte
+	}
+	chunks := make([]chunk, 0, len(pairs))
+	for _, pair := range pairs {
+		m := kvChunkRe.FindStringSubmatch(strings.TrimPrefix(pair.Key, key+"/"))
+		if m == nil {
+			continue
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return "", false, err
+		}
+		chunks = append(chunks, chunk{n: n, value: pair.Value})
+	}
+	if len(chunks) == 0 {
+		return "", false, nil
+	}
+
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].n < chunks[j].n })
+	var b strings.Builder
+	for i, c := range chunks {
+		if c.n != i {
+			return "", false, errChunksIncomplete
+		}
+		b.Write(c.value)
+	}
+	return b.String(), true, nil
+}
+
+// CanShare returns a boolean if this dependency is shareable.
+func (d *KVChunkedQuery) CanShare() bool {
+	return true
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *KVChunkedQuery) ID() string {
+	key := d.key
+	if d.dc != "" {
+		key = key + "@" + d.dc
+	}
+	return fmt.Sprintf("kv.chunked(%s)", key)
+}
+
+// Stringer interface reuses ID
+func (d *KVChunkedQuery) String() string {
+	return d.ID()
+}
+
+// Stop halts the dependency's fetch function.
+func (d *KVChunkedQuery) Stop() {
+	close(d.stopCh)
+}
+
+func (d *KVChunkedQuery) SetOptions(opts QueryOptions) {
+	d.opts = opts
+}
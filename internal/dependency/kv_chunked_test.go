@@ -0,0 +1,260 @@
+package dependency
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKVChunkedQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    string
+		exp  *KVChunkedQuery
+		err  bool
+	}{
+		{
+			"empty",
+			"",
+			nil,
+			true,
+		},
+		{
+			"key",
+			"key",
+			&KVChunkedQuery{
+				key: "key",
+			},
+			false,
+		},
+		{
+			"slashes",
+			"/path/to/key/",
+			&KVChunkedQuery{
+				key: "path/to/key",
+			},
+			false,
+		},
+		{
+			"dc",
+			"key@dc1",
+			&KVChunkedQuery{
+				key: "key",
+				dc:  "dc1",
+			},
+			false,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewKVChunkedQuery(tc.i)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestKVChunkedQuery_Fetch(t *testing.T) {
+	t.Parallel()
+
+	testConsul.SetKVString(t, "test-kv-chunked/blob/part-0001", "wor")
+	testConsul.SetKVString(t, "test-kv-chunked/blob/part-0000", "hello ")
+	testConsul.SetKVString(t, "test-kv-chunked/blob/part-0002", "ld")
+
+	cases := []struct {
+		name string
+		i    string
+		exp  interface{}
+		err  bool
+	}{
+		{
+			"exists",
+			"test-kv-chunked/blob",
+			"hello world",
+			false,
+		},
+		{
+			"no_exist",
+			"test-kv-chunked/not/a/real/key/like/ever",
+			nil,
+			false,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewKVChunkedQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			act, _, err := d.Fetch(testClients)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+
+	t.Run("waits_for_missing_chunk", func(t *testing.T) {
+		testConsul.SetKVString(t, "test-kv-chunked/gap/part-0000", "a")
+		testConsul.SetKVString(t, "test-kv-chunked/gap/part-0002", "c")
+
+		d, err := NewKVChunkedQuery("test-kv-chunked/gap")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go func() {
+			time.Sleep(250 * time.Millisecond)
+			testConsul.SetKVString(t, "test-kv-chunked/gap/part-0001", "b")
+		}()
+
+		act, _, err := d.Fetch(testClients)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "abc", act)
+	})
+}
+
+func TestJoinChunks(t *testing.T) {
+	t.Parallel()
+
+	pair := func(key, value string) *consulapi.KVPair {
+		return &consulapi.KVPair{Key: key, Value: []byte(value)}
+	}
+
+	cases := []struct {
+		name  string
+		pairs consulapi.KVPairs
+		exp   string
+		ok    bool
+		err   bool
+	}{
+		{
+			"none",
+			nil,
+			"",
+			false,
+			false,
+		},
+		{
+			"numeric_order",
+			consulapi.KVPairs{
+				pair("key/part-10", "k"),
+				pair("key/part-9", "j"),
+				pair("key/part-0", "a"),
+				pair("key/part-1", "b"),
+				pair("key/part-2", "c"),
+				pair("key/part-3", "d"),
+				pair("key/part-4", "e"),
+				pair("key/part-5", "f"),
+				pair("key/part-6", "g"),
+				pair("key/part-7", "h"),
+				pair("key/part-8", "i"),
+			},
+			"abcdefghijk",
+			true,
+			false,
+		},
+		{
+			"other_keys_ignored",
+			consulapi.KVPairs{
+				pair("key/part-0000", "a"),
+				pair("key/part-0000.bak", "x"),
+				pair("key/part-0001/nested", "x"),
+			},
+			"a",
+			true,
+			false,
+		},
+		{
+			"gap",
+			consulapi.KVPairs{
+				pair("key/part-0000", "a"),
+				pair("key/part-0002", "c"),
+			},
+			"",
+			false,
+			true,
+		},
+		{
+			"not_from_zero",
+			consulapi.KVPairs{
+				pair("key/part-0001", "b"),
+				pair("key/part-0002", "c"),
+			},
+			"",
+			false,
+			true,
+		},
+		{
+			"duplicate",
+			consulapi.KVPairs{
+				pair("key/part-0000", "a"),
+				pair("key/part-0001", "b"),
+				pair("key/part-1", "b"),
+			},
+			"",
+			false,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, ok, err := joinChunks("key", tc.pairs)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, act)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
+
+func TestKVChunkedQuery_String(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    string
+		exp  string
+	}{
+		{
+			"key",
+			"key",
+			"kv.chunked(key)",
+		},
+		{
+			"dc",
+			"key@dc1",
+			"kv.chunked(key@dc1)",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewKVChunkedQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, d.ID())
+		})
+	}
+}
@@ -3,7 +3,9 @@ package dependency
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 
+	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/hcat/dep"
 	"github.com/pkg/errors"
 )
@@ -21,20 +23,64 @@ var (
 type KVGetQuery struct {
 	KVExistsQuery
 	isBlocking
+
+	// diff returns a dep.KvChange with the previous value instead of the
+	// plain dep.KvValue
+	diff bool
+	prev *dep.KeyPair
+	// meta returns the key's *dep.KeyPair instead of the plain dep.KvValue
+	meta bool
 }
 
 // NewKVGetQueryV1 processes options in the format of "key key=value"
 // e.g. "my/key dc=dc1"
+//
+// The "diff=true" option changes the returned value to a dep.KvChange
+// containing both the previous and current key pairs.
 func NewKVGetQueryV1(key string, opts []string) (*KVGetQuery, error) {
 	if key == "" || key == "/" {
 		return nil, fmt.Errorf("kv.get: key required")
 	}
 
-	q, err := NewKVExistsQueryV1(key, opts)
+	var diff bool
+	existsOpts := make([]string, 0, len(opts))
+	for _, opt := range opts {
+		query, value, err := stringsSplit2(opt, "=")
+		if err != nil || query != "diff" {
+			existsOpts = append(existsOpts, opt)
+			continue
+		}
+		diff, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"kv.get: invalid query parameter: %q", opt)
+		}
+	}
+
+	q, err := NewKVExistsQueryV1(key, existsOpts)
 	if err != nil {
 		return nil, err
 	}
-	return &KVGetQuery{KVExistsQuery: *q}, nil
+	return &KVGetQuery{KVExistsQuery: *q, diff: diff}, nil
+}
+
+// NewKVMetaQueryV1 is NewKVGetQueryV1 returning the key's *dep.KeyPair, with
+// its flags, session and indexes, instead of only its value. It doesn't take
+// the "diff=true" option.
+func NewKVMetaQueryV1(key string, opts []string) (*KVGetQuery, error) {
+	if key == "" || key == "/" {
+		return nil, fmt.Errorf("kv.meta: key required")
+	}
+	d, err := NewKVGetQueryV1(key, opts)
+	if err != nil {
+		return nil, err
+	}
+	if d.diff {
+		return nil, fmt.Errorf("kv.meta: invalid query parameter: %q",
+			"diff=true")
+	}
+	d.meta = true
+	return d, nil
 }
 
 // NewKVGetQuery parses a string into a (non-blocking) KV lookup.
@@ -73,14 +119,65 @@ func (d *KVGetQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetad
 		LastContact: qm.LastContact,
 	}
 
+	if d.diff {
+		return d.change(pair), rm, nil
+	}
+
 	if pair == nil {
 		return nil, rm, nil
 	}
 
+	if d.meta {
+		return keyPair(pair), rm, nil
+	}
+
 	value := dep.KvValue(pair.Value)
 	return value, rm, nil
 }
 
+// change returns the KvChange from the previous pair to the current one and
+// tracks the current one for the next call. Returns nil if the key has never
+// been seen to keep the blocking behavior of missing keys.
+func (d *KVGetQuery) change(pair *api.KVPair) interface{} {
+	var current *dep.KeyPair
+	if pair != nil {
+		current = keyPair(pair)
+	}
+	prev := d.prev
+	d.prev = current
+	if prev == nil && current == nil {
+		return nil
+	}
+	return dep.KvChange{Old: prev, New: current}
+}
+
+// keyPair converts the Consul API's pair of an existing key.
+func keyPair(pair *api.KVPair) *dep.KeyPair {
+	return &dep.KeyPair{
+		Path:        pair.Key,
+		Key:         pair.Key,
+		Value:       string(pair.Value),
+		Exists:      true,
+		CreateIndex: pair.CreateIndex,
+		ModifyIndex: pair.ModifyIndex,
+		LockIndex:   pair.LockIndex,
+		Flags:       pair.Flags,
+		Session:     pair.Session,
+	}
+}
+
+// Meta returns true if the query returns the key's *dep.KeyPair, see
+// NewKVMetaQueryV1.
+func (d *KVGetQuery) Meta() bool {
+	return d.meta
+}
+
+// Diff returns true if the query returns a dep.KvChange, see the "diff=true"
+// option of NewKVGetQueryV1.
+func (d *KVGetQuery) Diff() bool {
+	return d.diff
+}
+
 // CanShare returns a boolean if this dependency is shareable.
 func (d *KVGetQuery) CanShare() bool {
 	return true
@@ -92,6 +189,12 @@ func (d *KVGetQuery) ID() string {
 	if d.dc != "" {
 		key = key + "@" + d.dc
 	}
+	if d.diff {
+		key = key + "?diff=true"
+	}
+	if d.meta {
+		return fmt.Sprintf("kv.meta(%s)", key)
+	}
 
 	return fmt.Sprintf("kv.get(%s)", key)
 }
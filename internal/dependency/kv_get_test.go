@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/hcat/dep"
 	"github.com/stretchr/testify/assert"
 )
@@ -239,6 +240,50 @@ func TestNewKVGetQueryV1WithParameters(t *testing.T) {
 	}
 }
 
+func TestNewKVGetQueryV1_Diff(t *testing.T) {
+	t.Parallel()
+
+	t.Run("enabled", func(t *testing.T) {
+		d, err := NewKVGetQueryV1("key", []string{"dc=dc1", "diff=true"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, d.diff)
+		assert.Equal(t, "dc1", d.dc)
+		assert.Equal(t, "kv.get(key@dc1?diff=true)", d.ID())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		d, err := NewKVGetQueryV1("key", []string{"diff=false"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, d.diff)
+		assert.Equal(t, "kv.get(key)", d.ID())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewKVGetQueryV1("key", []string{"diff=maybe"})
+		assert.Error(t, err)
+	})
+}
+
+func TestNewKVMetaQueryV1(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewKVMetaQueryV1("key", []string{"dc=dc1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, d.meta)
+	assert.Equal(t, "kv.meta(key@dc1)", d.ID())
+
+	_, err = NewKVMetaQueryV1("key", []string{"diff=true"})
+	assert.Error(t, err)
+	_, err = NewKVMetaQueryV1("/", nil)
+	assert.Error(t, err)
+}
+
 func TestKVGetQuery_Fetch(t *testing.T) {
 	t.Parallel()
 
@@ -355,6 +400,64 @@ func TestKVGetQuery_Fetch(t *testing.T) {
 			assert.Equal(t, data, dep.KvValue("new-value"))
 		}
 	})
+
+	t.Run("diff", func(t *testing.T) {
+		testConsul.SetKVString(t, "test-kv-get/diff", "old-value")
+
+		d, err := NewKVGetQueryV1("test-kv-get/diff", []string{"diff=true"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, qm, err := d.Fetch(testClients)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first := data.(dep.KvChange)
+		assert.Nil(t, first.Old)
+		assert.Equal(t, "old-value", first.New.Value)
+		assert.True(t, first.Changed())
+
+		testConsul.SetKVString(t, "test-kv-get/diff", "new-value")
+
+		d.SetOptions(QueryOptions{WaitIndex: qm.LastIndex})
+		data, _, err = d.Fetch(testClients)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second := data.(dep.KvChange)
+		assert.Equal(t, first.New, second.Old)
+		assert.Equal(t, "new-value", second.New.Value)
+		assert.True(t, second.New.ModifyIndex > second.Old.ModifyIndex)
+		assert.True(t, second.Changed())
+	})
+
+	t.Run("meta", func(t *testing.T) {
+		_, err := testClients.Consul().KV().Put(&api.KVPair{
+			Key:   "test-kv-get/meta",
+			Value: []byte("value"),
+			Flags: 42,
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := NewKVMetaQueryV1("test-kv-get/meta", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _, err := d.Fetch(testClients)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pair := data.(*dep.KeyPair)
+		assert.Equal(t, "value", pair.Value)
+		assert.Equal(t, uint64(42), pair.Flags)
+		assert.True(t, pair.HasFlag(2))
+		assert.False(t, pair.HasFlag(1))
+		assert.False(t, pair.Locked())
+		assert.True(t, pair.ModifyIndex > 0)
+	})
 }
 
 func TestKVGetQuery_String(t *testing.T) {
@@ -3,7 +3,10 @@ package dependency
 import (
 	"encoding/gob"
 	"fmt"
+	"path"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/hashicorp/hcat/dep"
@@ -31,10 +34,16 @@ type KVListQuery struct {
 	prefix string
 	ns     string
 	opts   QueryOptions
+
+	// filters applied to the keys right after fetching, see kvListFilters
+	glob  string
+	regex *regexp.Regexp
+	limit int
 }
 
 // NewKVListQuery processes options in the format of "prefix key=value"
-// e.g. "key_prefix dc=dc1"
+// e.g. "key_prefix dc=dc1". Besides "dc" (or "datacenter") and "ns" (or
+// "namespace") it takes the filter options, see NewKVListQueryFiltered.
 func NewKVListQueryV1(prefix string, opts []string) (*KVListQuery, error) {
 	if prefix == "" || prefix == "/" {
 		return nil, fmt.Errorf("kv.list: prefix required")
@@ -50,7 +59,7 @@ func NewKVListQueryV1(prefix string, opts []string) (*KVListQuery, error) {
 			continue
 		}
 
-		queryParam := strings.Split(opt, "=")
+		queryParam := strings.SplitN(opt, "=", 2)
 		if len(queryParam) != 2 {
 			return nil, fmt.Errorf(
 				"kv.list: invalid query parameter format: %q", opt)
@@ -63,14 +72,96 @@ func NewKVListQueryV1(prefix string, opts []string) (*KVListQuery, error) {
 		case "ns", "namespace":
 			q.ns = value
 		default:
-			return nil, fmt.Errorf(
-				"kv.list: invalid query parameter: %q", opt)
+			if err := q.setFilter(query, value); err != nil {
+				return nil, err
+			}
 		}
 	}
 
 	return &q, nil
 }
 
+// NewKVListQueryFiltered parses a string into a dependency, like
+// NewKVListQuery, whose keys are filtered by the "key=value" filter options
+// right after they are fetched. So changes to the filtered out keys don't
+// change the dependency's data. The filters are "glob" (a path.Match pattern)
+// and "regex" matching the keys, relative to the prefix, and "limit" keeping
+// at most that many of the matching keys (sorted).
+func NewKVListQueryFiltered(s string, filters []string) (*KVListQuery, error) {
+	q, err := NewKVListQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range filters {
+		if strings.TrimSpace(opt) == "" {
+			continue
+		}
+		filter := strings.SplitN(opt, "=", 2)
+		if len(filter) != 2 {
+			return nil, fmt.Errorf("kv.list: invalid filter format: %q", opt)
+		}
+		err := q.setFilter(strings.TrimSpace(filter[0]),
+			strings.TrimSpace(filter[1]))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// setFilter sets the filter of the name to the value.
+func (d *KVListQuery) setFilter(name, value string) error {
+	switch name {
+	case "glob":
+		if _, err := path.Match(value, ""); err != nil {
+			return fmt.Errorf("kv.list: invalid glob: %q", value)
+		}
+		d.glob = value
+	case "regex":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return fmt.Errorf("kv.list: invalid regex: %q: %s", value, err)
+		}
+		d.regex = re
+	case "limit":
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return fmt.Errorf("kv.list: invalid limit: %q", value)
+		}
+		d.limit = limit
+	default:
+		return fmt.Errorf("kv.list: invalid query parameter: %q",
+			name+"="+value)
+	}
+	return nil
+}
+
+// filter returns the pairs matching the filters, at most limit of them.
+func (d *KVListQuery) filter(pairs []*dep.KeyPair) []*dep.KeyPair {
+	if d.glob == "" && d.regex == nil && d.limit == 0 {
+		return pairs
+	}
+	filtered := pairs[:0]
+	for _, pair := range pairs {
+		if d.glob != "" {
+			if ok, _ := path.Match(d.glob, pair.Key); !ok {
+				continue
+			}
+		}
+		if d.regex != nil && !d.regex.MatchString(pair.Key) {
+			continue
+		}
+		filtered = append(filtered, pair)
+	}
+	if d.limit > 0 && len(filtered) > d.limit {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return filtered[i].Key < filtered[j].Key
+		})
+		filtered = filtered[:d.limit]
+	}
+	return filtered
+}
+
 // NewKVListQuery parses a string into a dependency.
 func NewKVListQuery(s string) (*KVListQuery, error) {
 	if s != "" && !KVListQueryRe.MatchString(s) {
@@ -127,7 +218,7 @@ func (d *KVListQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMeta
 		LastContact: qm.LastContact,
 	}
 
-	return pairs, rm, nil
+	return d.filter(pairs), rm, nil
 }
 
 // CanShare returns a boolean if this dependency is shareable.
@@ -141,6 +232,19 @@ func (d *KVListQuery) ID() string {
 	if d.dc != "" {
 		prefix = prefix + "@" + d.dc
 	}
+	var filters []string
+	if d.glob != "" {
+		filters = append(filters, "glob="+d.glob)
+	}
+	if d.regex != nil {
+		filters = append(filters, "regex="+d.regex.String())
+	}
+	if d.limit > 0 {
+		filters = append(filters, "limit="+strconv.Itoa(d.limit))
+	}
+	if len(filters) > 0 {
+		prefix = prefix + "?" + strings.Join(filters, "&")
+	}
 	return fmt.Sprintf("kv.list(%s)", prefix)
 }
 
@@ -530,3 +530,83 @@ func TestKVListQuery_String(t *testing.T) {
 		})
 	}
 }
+
+func TestNewKVListQueryFiltered(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		filters []string
+		id      string
+		err     bool
+	}{
+		{
+			"none",
+			nil,
+			"kv.list(prefix@dc1)",
+			false,
+		},
+		{
+			"all",
+			[]string{"limit=2", "glob=*.conf", "regex=^[a-z]+\\.conf$"},
+			"kv.list(prefix@dc1?glob=*.conf&regex=^[a-z]+\\.conf$&limit=2)",
+			false,
+		},
+		{
+			"bad_glob",
+			[]string{"glob=[a"},
+			"",
+			true,
+		},
+		{
+			"bad_regex",
+			[]string{"regex=(a"},
+			"",
+			true,
+		},
+		{
+			"bad_limit",
+			[]string{"limit=0"},
+			"",
+			true,
+		},
+		{
+			"bad_filter",
+			[]string{"dc=dc2"},
+			"",
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewKVListQueryFiltered("prefix@dc1", tc.filters)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if err == nil {
+				assert.Equal(t, tc.id, d.ID())
+			}
+		})
+	}
+}
+
+func TestKVListQuery_filter(t *testing.T) {
+	t.Parallel()
+
+	pairs := func(keys ...string) []*dep.KeyPair {
+		var pairs []*dep.KeyPair
+		for _, k := range keys {
+			pairs = append(pairs, &dep.KeyPair{Key: k})
+		}
+		return pairs
+	}
+	d, err := NewKVListQueryFiltered("prefix",
+		[]string{"glob=*.conf", "regex=^[a-m]", "limit=2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	act := d.filter(pairs("zz.conf", "c.conf", "a.conf", "b.txt",
+		"sub/a.conf", "b.conf"))
+	assert.Equal(t, pairs("a.conf", "b.conf"), act)
+}
@@ -0,0 +1,191 @@
+package dependency
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*KVTxnQuery)(nil)
+
+	// KVTxnQuerySleepTime is the amount of time to sleep between queries,
+	// since the transaction endpoint does not support blocking queries.
+	KVTxnQuerySleepTime = 5 * time.Second
+)
+
+// kvTxnMaxKeys is Consul's limit of operations per transaction
+const kvTxnMaxKeys = 64
+
+// KVTxnQuery reads a set of keys from the KV store atomically, in a single
+// transaction, so their values are a consistent snapshot. Separate queries
+// of the keys can return values from before and after a change of several
+// of them, rendering a torn configuration.
+//
+// The keys are read with the transaction's get-tree operation, which unlike
+// get doesn't fail the transaction when a key is missing, keeping only the
+// exact keys from its results. The cost is that get-tree is a prefix read:
+// each key returns every key it is a prefix of (eg. "app/config" returns
+// "app/config/..." and "app/configs" too), all sent by Consul and decoded to
+// be dropped. Keep to keys that aren't the prefix of large trees, the
+// response can otherwise be many times the size of the values read.
+type KVTxnQuery struct {
+	isConsul
+	stopCh chan struct{}
+
+	keys []string // sorted, without duplicates
+	dc   string
+	ns   string
+	opts QueryOptions
+}
+
+// NewKVTxnQuery creates the dependency reading the keys, with the options in
+// the format of "key=value" e.g. "dc=dc1" (dc and ns are supported).
+func NewKVTxnQuery(keys []string, opts []string) (*KVTxnQuery, error) {
+	q := KVTxnQuery{stopCh: make(chan struct{}, 1)}
+
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		key = strings.TrimPrefix(key, "/")
+		if key == "" {
+			return nil, fmt.Errorf("kv.txn: key required")
+		}
+		if !seen[key] {
+			seen[key] = true
+			q.keys = append(q.keys, key)
+		}
+	}
+	switch {
+	case len(q.keys) == 0:
+		return nil, fmt.Errorf("kv.txn: key required")
+	case len(q.keys) > kvTxnMaxKeys:
+		return nil, fmt.Errorf("kv.txn: too many keys, the maximum is %d",
+			kvTxnMaxKeys)
+	}
+	sort.Strings(q.keys)
+
+	for _, opt := range opts {
+		if strings.TrimSpace(opt) == "" {
+			continue
+		}
+		query, value, err := stringsSplit2(opt, "=")
+		if err != nil {
+			return nil, fmt.Errorf(
+				"kv.txn: invalid query parameter format: %q", opt)
+		}
+		switch query {
+		case "dc", "datacenter":
+			q.dc = value
+		case "ns", "namespace":
+			q.ns = value
+		default:
+			return nil, fmt.Errorf(
+				"kv.txn: invalid query parameter: %q", opt)
+		}
+	}
+
+	return &q, nil
+}
+
+// Fetch queries the Consul API defined by the given client and returns the
+// values of the keys that exist, a map[string]dep.KvValue by key.
+func (d *KVTxnQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	opts := d.opts.Merge(&QueryOptions{
+		Datacenter: d.dc,
+		Namespace:  d.ns,
+	})
+
+	// If this is not the first query, poll to simulate blocking-queries.
+	if opts.WaitIndex != 0 {
+		select {
+		case <-d.stopCh:
+			return nil, nil, ErrStopped
+		case <-time.After(KVTxnQuerySleepTime):
+		case <-opts.done():
+		}
+		opts.WaitIndex = 0
+	}
+
+	ops := make(api.TxnOps, 0, len(d.keys))
+	wanted := make(map[string]bool, len(d.keys))
+	for _, key := range d.keys {
+		ops = append(ops, &api.TxnOp{KV: &api.KVTxnOp{
+			Verb:      api.KVGetTree,
+			Key:       key,
+			Namespace: d.ns,
+		}})
+		wanted[key] = true
+	}
+
+	ok, resp, qm, err := clients.Consul().Txn().Txn(ops, opts.ToConsulOpts())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.ID())
+	}
+	if !ok {
+		msgs := make([]string, 0, len(resp.Errors))
+		for _, e := range resp.Errors {
+			msgs = append(msgs, e.What)
+		}
+		return nil, nil, fmt.Errorf("%s: transaction rolled back: %s",
+			d.ID(), strings.Join(msgs, ", "))
+	}
+
+	values := make(map[string]dep.KvValue, len(d.keys))
+	for _, r := range resp.Results {
+		if r.KV != nil && wanted[r.KV.Key] {
+			values[r.KV.Key] = dep.KvValue(r.KV.Value)
+		}
+	}
+
+	if qm.LastIndex == 0 {
+		return respWithMetadata(values)
+	}
+	rm := &dep.ResponseMetadata{
+		LastIndex:   qm.LastIndex,
+		LastContact: qm.LastContact,
+	}
+	return values, rm, nil
+}
+
+// CanShare returns a boolean if this dependency is shareable.
+func (d *KVTxnQuery) CanShare() bool {
+	return true
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *KVTxnQuery) ID() string {
+	keys := strings.Join(d.keys, ",")
+	if d.dc != "" {
+		keys = keys + "@" + d.dc
+	}
+	if d.ns != "" {
+		keys = keys + "?ns=" + d.ns
+	}
+	return fmt.Sprintf("kv.txn(%s)", keys)
+}
+
+// Stringer interface reuses ID
+func (d *KVTxnQuery) String() string {
+	return d.ID()
+}
+
+// Stop halts the dependency's fetch function.
+func (d *KVTxnQuery) Stop() {
+	close(d.stopCh)
+}
+
+func (d *KVTxnQuery) SetOptions(opts QueryOptions) {
+	d.opts = opts
+}
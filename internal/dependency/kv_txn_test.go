@@ -0,0 +1,143 @@
+package dependency
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKVTxnQuery(t *testing.T) {
+	t.Parallel()
+
+	tooMany := make([]string, kvTxnMaxKeys+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("k%d", i)
+	}
+
+	cases := []struct {
+		name string
+		keys []string
+		opts []string
+		exp  *KVTxnQuery
+		err  bool
+	}{
+		{
+			"keys",
+			[]string{"b", "/a", "b"},
+			nil,
+			&KVTxnQuery{keys: []string{"a", "b"}},
+			false,
+		},
+		{
+			"dc_ns",
+			[]string{"a"},
+			[]string{"dc=dc1", "ns=team"},
+			&KVTxnQuery{keys: []string{"a"}, dc: "dc1", ns: "team"},
+			false,
+		},
+		{
+			"no_keys",
+			nil,
+			nil,
+			nil,
+			true,
+		},
+		{
+			"empty_key",
+			[]string{"a", "/"},
+			nil,
+			nil,
+			true,
+		},
+		{
+			"too_many_keys",
+			tooMany,
+			nil,
+			nil,
+			true,
+		},
+		{
+			"invalid_opt",
+			[]string{"a"},
+			[]string{"recurse=true"},
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewKVTxnQuery(tc.keys, tc.opts)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestKVTxnQuery_Fetch(t *testing.T) {
+	t.Parallel()
+
+	testConsul.SetKVString(t, "test-kv-txn/a", "1")
+	testConsul.SetKVString(t, "test-kv-txn/a/nested", "2")
+	testConsul.SetKVString(t, "test-kv-txn/b", "")
+
+	d, err := NewKVTxnQuery([]string{
+		"test-kv-txn/a", "test-kv-txn/b", "test-kv-txn/missing"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	act, _, err := d.Fetch(testClients)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := map[string]dep.KvValue{
+		"test-kv-txn/a": "1",
+		"test-kv-txn/b": "",
+	}
+	assert.Equal(t, exp, act)
+}
+
+func TestKVTxnQuery_String(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		keys []string
+		opts []string
+		exp  string
+	}{
+		{
+			"keys",
+			[]string{"b", "a"},
+			nil,
+			"kv.txn(a,b)",
+		},
+		{
+			"dc_ns",
+			[]string{"a"},
+			[]string{"dc=dc1", "ns=team"},
+			"kv.txn(a@dc1?ns=team)",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewKVTxnQuery(tc.keys, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, d.ID())
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package dependency
+
+import (
+	"fmt"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/hcat/dep"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*namedConsulQuery)(nil)
+	_ isDependency = (*namedBlockingConsulQuery)(nil)
+	_ isDependency = (*namedVaultQuery)(nil)
+)
+
+// NamedClients is the interface for looking up clients registered by name.
+// It is implemented by ClientSet.
+type NamedClients interface {
+	ConsulNamed(name string) *consulapi.Client
+	VaultNamed(name string) *vaultapi.Client
+}
+
+// NewNamedClientQuery wraps the dependency so it fetches its data using the
+// named Consul or Vault client instead of the default ones. Used for
+// templates that aggregate data across clusters that aren't federated.
+func NewNamedClientQuery(name string, d dep.Dependency) (dep.Dependency, error) {
+	if name == "" {
+		return nil, fmt.Errorf("named.client: client name required")
+	}
+	id, ok := d.(isDependency)
+	if !ok {
+		return nil, fmt.Errorf("named.client: unsupported dependency: %s", d)
+	}
+	q := namedClientQuery{isDependency: id, name: name}
+	switch d.(type) {
+	case VaultType:
+		return &namedVaultQuery{namedClientQuery: q}, nil
+	case ConsulType:
+		if _, ok := d.(BlockingQuery); ok {
+			return &namedBlockingConsulQuery{namedClientQuery: q}, nil
+		}
+		return &namedConsulQuery{namedClientQuery: q}, nil
+	}
+	return nil, fmt.Errorf("named.client: not a consul or vault dependency: %s", d)
+}
+
+// namedClientQuery is the common wrapper for the typed versions below.
+type namedClientQuery struct {
+	isDependency
+	name string
+}
+
+// Fetch calls the wrapped dependency's Fetch with the named clients.
+func (d *namedClientQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	nc, ok := clients.(NamedClients)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: clients don't support named clients",
+			d.ID())
+	}
+	var missing bool
+	switch d.isDependency.(type) {
+	case VaultType:
+		missing = nc.VaultNamed(d.name) == nil
+	case ConsulType:
+		missing = nc.ConsulNamed(d.name) == nil
+	}
+	if missing {
+		return nil, nil, fmt.Errorf("%s: no client named %q", d.ID(), d.name)
+	}
+	return d.isDependency.Fetch(namedClientSet{named: nc, name: d.name})
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *namedClientQuery) ID() string {
+	return fmt.Sprintf("client(%s).%s", d.name, d.isDependency.ID())
+}
+
+// Stringer interface reuses ID
+func (d *namedClientQuery) String() string {
+	return d.ID()
+}
+
+type namedConsulQuery struct {
+	isConsul
+	namedClientQuery
+}
+
+type namedBlockingConsulQuery struct {
+	isConsul
+	isBlocking
+	namedClientQuery
+}
+
+type namedVaultQuery struct {
+	isVault
+	namedClientQuery
+}
+
+// namedClientSet meets the dep.Clients interface returning the named clients.
+type namedClientSet struct {
+	named NamedClients
+	name  string
+}
+
+func (c namedClientSet) Consul() *consulapi.Client {
+	return c.named.ConsulNamed(c.name)
+}
+
+func (c namedClientSet) Vault() *vaultapi.Client {
+	return c.named.VaultNamed(c.name)
+}
@@ -0,0 +1,116 @@
+package dependency
+
+import (
+	"encoding/gob"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*NamespaceListQuery)(nil)
+
+	// NamespaceListQueryRe is the regular expression to use.
+	NamespaceListQueryRe = regexp.MustCompile(`\A` + dcRe + `\z`)
+)
+
+func init() {
+	gob.Register([]*dep.Namespace{})
+}
+
+// NamespaceListQuery is the dependency to query all the namespaces of a
+// Consul Enterprise cluster.
+type NamespaceListQuery struct {
+	isConsul
+	stopCh chan struct{}
+
+	dc   string
+	opts QueryOptions
+}
+
+// NewNamespaceListQuery parses the given string into a dependency. If the
+// datacenter is empty then the agent's datacenter is used.
+func NewNamespaceListQuery(s string) (*NamespaceListQuery, error) {
+	if !NamespaceListQueryRe.MatchString(s) {
+		return nil, fmt.Errorf("namespaces: invalid format: %q", s)
+	}
+
+	m := regexpMatch(NamespaceListQueryRe, s)
+	return &NamespaceListQuery{
+		dc:     m["dc"],
+		stopCh: make(chan struct{}, 1),
+	}, nil
+}
+
+// Fetch queries the Consul API defined by the given client and returns a slice
+// of Namespace objects, sorted by name. Namespaces marked for deletion are
+// left out. Errors when used with a non-enterprise Consul.
+func (d *NamespaceListQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	opts := d.opts.Merge(&QueryOptions{
+		Datacenter: d.dc,
+	})
+
+	entries, qm, err := clients.Consul().Namespaces().List(opts.ToConsulOpts())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.ID())
+	}
+
+	namespaces := make([]*dep.Namespace, 0, len(entries))
+	for _, ns := range entries {
+		if ns.DeletedAt != nil {
+			continue
+		}
+		namespaces = append(namespaces, &dep.Namespace{
+			Name:        ns.Name,
+			Description: ns.Description,
+			Meta:        ns.Meta,
+		})
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].Name < namespaces[j].Name
+	})
+
+	rm := &dep.ResponseMetadata{
+		LastIndex:   qm.LastIndex,
+		LastContact: qm.LastContact,
+	}
+
+	return namespaces, rm, nil
+}
+
+// CanShare returns if this dependency is shareable.
+func (d *NamespaceListQuery) CanShare() bool {
+	return true
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *NamespaceListQuery) ID() string {
+	if d.dc != "" {
+		return fmt.Sprintf("namespaces(@%s)", d.dc)
+	}
+	return "namespaces"
+}
+
+// Stringer interface reuses ID
+func (d *NamespaceListQuery) String() string {
+	return d.ID()
+}
+
+// Stop terminates this dependency's fetch.
+func (d *NamespaceListQuery) Stop() {
+	close(d.stopCh)
+}
+
+func (d *NamespaceListQuery) SetOptions(opts QueryOptions) {
+	d.opts = opts
+}
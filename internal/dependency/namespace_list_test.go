@@ -0,0 +1,98 @@
+package dependency
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewNamespaceListQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    string
+		exp  *NamespaceListQuery
+		err  bool
+	}{
+		{
+			"empty",
+			"",
+			&NamespaceListQuery{},
+			false,
+		},
+		{
+			"dc",
+			"@dc1",
+			&NamespaceListQuery{
+				dc: "dc1",
+			},
+			false,
+		},
+		{
+			"invalid",
+			"namespace",
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewNamespaceListQuery(tc.i)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestNamespaceListQuery_Fetch(t *testing.T) {
+	t.Parallel()
+
+	// the test server is not Consul Enterprise
+	d, err := NewNamespaceListQuery("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = d.Fetch(testClients)
+	assert.Error(t, err)
+}
+
+func TestNamespaceListQuery_String(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    string
+		exp  string
+	}{
+		{
+			"empty",
+			"",
+			"namespaces",
+		},
+		{
+			"datacenter",
+			"@dc1",
+			"namespaces(@dc1)",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewNamespaceListQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, d.ID())
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package dependency
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*OperatorAutopilotHealthQuery)(nil)
+
+	// OperatorAutopilotHealthQueryRe is the regular expression to use.
+	OperatorAutopilotHealthQueryRe = regexp.MustCompile(`\A` + dcRe + `\z`)
+
+	// OperatorQuerySleepTime is the amount of time to sleep between operator
+	// queries, since the operator endpoints do not support blocking queries.
+	OperatorQuerySleepTime = 15 * time.Second
+)
+
+// OperatorAutopilotHealthQuery is the dependency to query the health of the
+// Consul servers as reported by autopilot.
+type OperatorAutopilotHealthQuery struct {
+	isConsul
+	stopCh chan struct{}
+
+	dc   string
+	opts QueryOptions
+}
+
+// NewOperatorAutopilotHealthQuery parses the given string into a dependency.
+// If the datacenter is empty then the agent's datacenter is used.
+func NewOperatorAutopilotHealthQuery(s string) (*OperatorAutopilotHealthQuery, error) {
+	if !OperatorAutopilotHealthQueryRe.MatchString(s) {
+		return nil, fmt.Errorf("operator.autopilot.health: invalid format: %q", s)
+	}
+
+	m := regexpMatch(OperatorAutopilotHealthQueryRe, s)
+	return &OperatorAutopilotHealthQuery{
+		dc:     m["dc"],
+		stopCh: make(chan struct{}, 1),
+	}, nil
+}
+
+// Fetch queries the Consul API defined by the given client and returns the
+// *api.OperatorHealthReply
+func (d *OperatorAutopilotHealthQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	opts := d.opts.Merge(&QueryOptions{
+		Datacenter: d.dc,
+	})
+
+	// The endpoint does not support blocking queries so, like the catalog
+	// datacenters, poll it after the initial query.
+	if opts.WaitIndex != 0 {
+		select {
+		case <-d.stopCh:
+			return nil, nil, ErrStopped
+		case <-time.After(OperatorQuerySleepTime):
+		case <-opts.done():
+		}
+	}
+
+	reply, err := clients.Consul().Operator().AutopilotServerHealth(
+		opts.ToConsulOpts())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.ID())
+	}
+
+	return respWithMetadata(reply)
+}
+
+// CanShare returns if this dependency is shareable.
+func (d *OperatorAutopilotHealthQuery) CanShare() bool {
+	return true
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *OperatorAutopilotHealthQuery) ID() string {
+	if d.dc != "" {
+		return fmt.Sprintf("operator.autopilot.health(@%s)", d.dc)
+	}
+	return "operator.autopilot.health"
+}
+
+// Stringer interface reuses ID
+func (d *OperatorAutopilotHealthQuery) String() string {
+	return d.ID()
+}
+
+// Stop terminates this dependency's fetch.
+func (d *OperatorAutopilotHealthQuery) Stop() {
+	close(d.stopCh)
+}
+
+func (d *OperatorAutopilotHealthQuery) SetOptions(opts QueryOptions) {
+	d.opts = opts
+}
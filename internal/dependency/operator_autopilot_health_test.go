@@ -0,0 +1,125 @@
+package dependency
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	OperatorQuerySleepTime = 50 * time.Millisecond
+}
+
+func TestNewOperatorAutopilotHealthQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    string
+		exp  *OperatorAutopilotHealthQuery
+		err  bool
+	}{
+		{
+			"empty",
+			"",
+			&OperatorAutopilotHealthQuery{},
+			false,
+		},
+		{
+			"dc",
+			"@dc1",
+			&OperatorAutopilotHealthQuery{
+				dc: "dc1",
+			},
+			false,
+		},
+		{
+			"invalid",
+			"server",
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewOperatorAutopilotHealthQuery(tc.i)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestOperatorAutopilotHealthQuery_Fetch(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewOperatorAutopilotHealthQuery("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	act, _, err := d.Fetch(testClients)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply := act.(*api.OperatorHealthReply)
+	if assert.Len(t, reply.Servers, 1) {
+		assert.Equal(t, testConsul.Config.NodeName, reply.Servers[0].Name)
+	}
+
+	t.Run("stops", func(t *testing.T) {
+		d, err := NewOperatorAutopilotHealthQuery("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.SetOptions(QueryOptions{WaitIndex: 1})
+		d.Stop()
+
+		_, _, err = d.Fetch(testClients)
+		if err != ErrStopped {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestOperatorAutopilotHealthQuery_String(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    string
+		exp  string
+	}{
+		{
+			"empty",
+			"",
+			"operator.autopilot.health",
+		},
+		{
+			"datacenter",
+			"@dc1",
+			"operator.autopilot.health(@dc1)",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewOperatorAutopilotHealthQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, d.ID())
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package dependency
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*OperatorLicenseQuery)(nil)
+
+	// OperatorLicenseQueryRe is the regular expression to use.
+	OperatorLicenseQueryRe = regexp.MustCompile(`\A` + dcRe + `\z`)
+)
+
+// OperatorLicenseQuery is the dependency to query the license status of a
+// Consul Enterprise cluster.
+type OperatorLicenseQuery struct {
+	isConsul
+	stopCh chan struct{}
+
+	dc   string
+	opts QueryOptions
+}
+
+// NewOperatorLicenseQuery parses the given string into a dependency. If the
+// datacenter is empty then the agent's datacenter is used.
+func NewOperatorLicenseQuery(s string) (*OperatorLicenseQuery, error) {
+	if !OperatorLicenseQueryRe.MatchString(s) {
+		return nil, fmt.Errorf("operator.license: invalid format: %q", s)
+	}
+
+	m := regexpMatch(OperatorLicenseQueryRe, s)
+	return &OperatorLicenseQuery{
+		dc:     m["dc"],
+		stopCh: make(chan struct{}, 1),
+	}, nil
+}
+
+// Fetch queries the Consul API defined by the given client and returns the
+// *api.LicenseReply. Errors when used with a non-enterprise Consul.
+func (d *OperatorLicenseQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	opts := d.opts.Merge(&QueryOptions{
+		Datacenter: d.dc,
+	})
+
+	// The endpoint does not support blocking queries, see
+	// OperatorAutopilotHealthQuery.
+	if opts.WaitIndex != 0 {
+		select {
+		case <-d.stopCh:
+			return nil, nil, ErrStopped
+		case <-time.After(OperatorQuerySleepTime):
+		case <-opts.done():
+		}
+	}
+
+	reply, err := clients.Consul().Operator().LicenseGet(opts.ToConsulOpts())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.ID())
+	}
+
+	return respWithMetadata(reply)
+}
+
+// CanShare returns if this dependency is shareable.
+func (d *OperatorLicenseQuery) CanShare() bool {
+	return true
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *OperatorLicenseQuery) ID() string {
+	if d.dc != "" {
+		return fmt.Sprintf("operator.license(@%s)", d.dc)
+	}
+	return "operator.license"
+}
+
+// Stringer interface reuses ID
+func (d *OperatorLicenseQuery) String() string {
+	return d.ID()
+}
+
+// Stop terminates this dependency's fetch.
+func (d *OperatorLicenseQuery) Stop() {
+	close(d.stopCh)
+}
+
+func (d *OperatorLicenseQuery) SetOptions(opts QueryOptions) {
+	d.opts = opts
+}
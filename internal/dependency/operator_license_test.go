@@ -0,0 +1,98 @@
+package dependency
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOperatorLicenseQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    string
+		exp  *OperatorLicenseQuery
+		err  bool
+	}{
+		{
+			"empty",
+			"",
+			&OperatorLicenseQuery{},
+			false,
+		},
+		{
+			"dc",
+			"@dc1",
+			&OperatorLicenseQuery{
+				dc: "dc1",
+			},
+			false,
+		},
+		{
+			"invalid",
+			"license",
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewOperatorLicenseQuery(tc.i)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestOperatorLicenseQuery_Fetch(t *testing.T) {
+	t.Parallel()
+
+	// the test server is not Consul Enterprise
+	d, err := NewOperatorLicenseQuery("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = d.Fetch(testClients)
+	assert.Error(t, err)
+}
+
+func TestOperatorLicenseQuery_String(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    string
+		exp  string
+	}{
+		{
+			"empty",
+			"",
+			"operator.license",
+		},
+		{
+			"datacenter",
+			"@dc1",
+			"operator.license(@dc1)",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewOperatorLicenseQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, d.ID())
+		})
+	}
+}
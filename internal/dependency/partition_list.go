@@ -0,0 +1,126 @@
+package dependency
+
+import (
+	"encoding/gob"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*PartitionListQuery)(nil)
+
+	// PartitionListQueryRe is the regular expression to use.
+	PartitionListQueryRe = regexp.MustCompile(`\A` + dcRe + `\z`)
+)
+
+func init() {
+	gob.Register([]*dep.Partition{})
+}
+
+// PartitionListQuery is the dependency to query all the admin partitions of
+// a Consul Enterprise cluster.
+type PartitionListQuery struct {
+	isConsul
+	stopCh chan struct{}
+
+	dc   string
+	opts QueryOptions
+}
+
+// NewPartitionListQuery parses the given string into a dependency. If the
+// datacenter is empty then the agent's datacenter is used.
+func NewPartitionListQuery(s string) (*PartitionListQuery, error) {
+	if !PartitionListQueryRe.MatchString(s) {
+		return nil, fmt.Errorf("partitions: invalid format: %q", s)
+	}
+
+	m := regexpMatch(PartitionListQueryRe, s)
+	return &PartitionListQuery{
+		dc:     m["dc"],
+		stopCh: make(chan struct{}, 1),
+	}, nil
+}
+
+// partitionEntry is a partition as returned by the API. The Consul API client
+// doesn't support partitions, so they are queried and decoded directly.
+type partitionEntry struct {
+	Name        string
+	Description string
+	DeletedAt   *time.Time
+}
+
+// Fetch queries the Consul API defined by the given client and returns a slice
+// of Partition objects, sorted by name. Partitions marked for deletion are
+// left out. Errors when used with a non-enterprise Consul.
+func (d *PartitionListQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	opts := d.opts.Merge(&QueryOptions{
+		Datacenter: d.dc,
+	})
+
+	var entries []partitionEntry
+	qm, err := clients.Consul().Raw().Query("/v1/partitions", &entries,
+		opts.ToConsulOpts())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.ID())
+	}
+
+	partitions := make([]*dep.Partition, 0, len(entries))
+	for _, p := range entries {
+		if p.DeletedAt != nil {
+			continue
+		}
+		partitions = append(partitions, &dep.Partition{
+			Name:        p.Name,
+			Description: p.Description,
+		})
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].Name < partitions[j].Name
+	})
+
+	rm := &dep.ResponseMetadata{
+		LastIndex:   qm.LastIndex,
+		LastContact: qm.LastContact,
+	}
+
+	return partitions, rm, nil
+}
+
+// CanShare returns if this dependency is shareable.
+func (d *PartitionListQuery) CanShare() bool {
+	return true
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *PartitionListQuery) ID() string {
+	if d.dc != "" {
+		return fmt.Sprintf("partitions(@%s)", d.dc)
+	}
+	return "partitions"
+}
+
+// Stringer interface reuses ID
+func (d *PartitionListQuery) String() string {
+	return d.ID()
+}
+
+// Stop terminates this dependency's fetch.
+func (d *PartitionListQuery) Stop() {
+	close(d.stopCh)
+}
+
+func (d *PartitionListQuery) SetOptions(opts QueryOptions) {
+	d.opts = opts
+}
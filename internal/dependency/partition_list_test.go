@@ -0,0 +1,98 @@
+package dependency
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPartitionListQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    string
+		exp  *PartitionListQuery
+		err  bool
+	}{
+		{
+			"empty",
+			"",
+			&PartitionListQuery{},
+			false,
+		},
+		{
+			"dc",
+			"@dc1",
+			&PartitionListQuery{
+				dc: "dc1",
+			},
+			false,
+		},
+		{
+			"invalid",
+			"partition",
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewPartitionListQuery(tc.i)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestPartitionListQuery_Fetch(t *testing.T) {
+	t.Parallel()
+
+	// the test server is not Consul Enterprise
+	d, err := NewPartitionListQuery("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = d.Fetch(testClients)
+	assert.Error(t, err)
+}
+
+func TestPartitionListQuery_String(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		i    string
+		exp  string
+	}{
+		{
+			"empty",
+			"",
+			"partitions",
+		},
+		{
+			"datacenter",
+			"@dc1",
+			"partitions(@dc1)",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewPartitionListQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, d.ID())
+		})
+	}
+}
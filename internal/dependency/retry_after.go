@@ -0,0 +1,101 @@
+package dependency
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryAfter records the Retry-After of the rate limited (429) and
+// unavailable (503) responses to the requests made with a context from
+// WithRetryAfter. The Consul dependencies make their requests with the
+// fetch's context, the Vault API client doesn't take one so its responses
+// aren't recorded.
+type RetryAfter struct {
+	mu    sync.Mutex
+	after time.Duration
+}
+
+// After returns the last Retry-After recorded, 0 if none was.
+func (r *RetryAfter) After() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.after
+}
+
+func (r *RetryAfter) set(after time.Duration) {
+	r.mu.Lock()
+	r.after = after
+	r.mu.Unlock()
+}
+
+// retryAfterKey is the context key of the RetryAfter the retryAfterTransport
+// records to.
+type retryAfterKey struct{}
+
+// WithRetryAfter returns a copy of the context recording the Retry-After of
+// the responses to the requests made with it in r.
+func WithRetryAfter(ctx context.Context, r *RetryAfter) context.Context {
+	return context.WithValue(ctx, retryAfterKey{}, r)
+}
+
+// retryAfterTransport is an http.RoundTripper that records the Retry-After
+// of the responses in the RetryAfter set on the request's context by
+// WithRetryAfter.
+type retryAfterTransport struct {
+	transport http.RoundTripper
+}
+
+// withRetryAfter returns a copy of the client using the retryAfterTransport,
+// the client itself is left as is.
+func withRetryAfter(client *http.Client) *http.Client {
+	c := *client
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	c.Transport = &retryAfterTransport{transport: transport}
+	return &c
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	r, ok := req.Context().Value(retryAfterKey{}).(*RetryAfter)
+	if !ok || err != nil {
+		return resp, err
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		if after, ok := parseRetryAfter(resp.Header.Get("Retry-After"),
+			time.Now()); ok {
+			r.set(after)
+		}
+	}
+	return resp, err
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport,
+// called by the http.Client's method of the same name.
+func (t *retryAfterTransport) CloseIdleConnections() {
+	type closeIdler interface{ CloseIdleConnections() }
+	if c, ok := t.transport.(closeIdler); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// parseRetryAfter parses the value of a Retry-After header, either a number
+// of seconds or an HTTP date. Returns false if it is neither or not in the
+// future.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second, secs > 0
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	after := t.Sub(now)
+	return after, after > 0
+}
@@ -0,0 +1,81 @@
+package dependency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name  string
+		value string
+		exp   time.Duration
+		ok    bool
+	}{
+		{"empty", "", 0, false},
+		{"seconds", "120", 2 * time.Minute, true},
+		{"zero", "0", 0, false},
+		{"negative", "-1", -time.Second, false},
+		{"date", "Wed, 01 Jan 2020 00:00:30 GMT", 30 * time.Second, true},
+		{"past_date", "Tue, 31 Dec 2019 23:59:30 GMT", -30 * time.Second, false},
+		{"invalid", "soon", 0, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			act, ok := parseRetryAfter(tc.value, now)
+			if ok != tc.ok || (ok && act != tc.exp) {
+				t.Errorf("exp: %s %t, act: %s %t", tc.exp, tc.ok, act, ok)
+			}
+		})
+	}
+}
+
+func TestRetryAfterTransport(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "3")
+			switch r.URL.Path {
+			case "/limited":
+				w.WriteHeader(http.StatusTooManyRequests)
+			case "/unavailable":
+				w.WriteHeader(http.StatusServiceUnavailable)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+	defer ts.Close()
+
+	client := withRetryAfter(ts.Client())
+	get := func(path string) time.Duration {
+		var r RetryAfter
+		req, err := http.NewRequestWithContext(
+			WithRetryAfter(context.Background(), &r), "GET", ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return r.After()
+	}
+
+	if act := get("/limited"); act != 3*time.Second {
+		t.Errorf("limited: exp: 3s, act: %s", act)
+	}
+	if act := get("/unavailable"); act != 3*time.Second {
+		t.Errorf("unavailable: exp: 3s, act: %s", act)
+	}
+	if act := get("/error"); act != 0 {
+		t.Errorf("error: exp: 0s, act: %s", act)
+	}
+}
@@ -0,0 +1,246 @@
+package dependency
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*TimerQuery)(nil)
+)
+
+// TimerQuery is a pseudo-dependency that has new data, the time, on a
+// schedule. Templates use it to be re-rendered periodically in addition to
+// when their other dependencies change. The first fetch returns right away,
+// later ones wait for the next scheduled time.
+type TimerQuery struct {
+	stopCh chan struct{}
+
+	id       string
+	schedule schedule
+	last     time.Time
+}
+
+// schedule returns the next scheduled time after the given time.
+type schedule interface {
+	next(time.Time) time.Time
+}
+
+// NewEveryQuery creates a timer dependency that fires at the interval, a
+// duration string (eg. "5m").
+func NewEveryQuery(s string) (*TimerQuery, error) {
+	s = strings.TrimSpace(s)
+	interval, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, fmt.Errorf("every: invalid format: %q", s)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("every: interval must be positive: %q", s)
+	}
+	return &TimerQuery{
+		stopCh:   make(chan struct{}, 1),
+		id:       fmt.Sprintf("every(%s)", s),
+		schedule: intervalSchedule(interval),
+	}, nil
+}
+
+// NewCronQuery creates a timer dependency that fires on the cron schedule,
+// in the standard 5 field format (minute hour day-of-month month day-of-week)
+// using the local time zone. Fields support `*`, values, ranges (`1-5`),
+// steps (`*/15`, `0-30/10`) and lists of them (`0,30`).
+func NewCronQuery(s string) (*TimerQuery, error) {
+	s = strings.Join(strings.Fields(s), " ")
+	sched, err := parseCron(s)
+	if err != nil {
+		return nil, fmt.Errorf("cron: invalid format: %q: %s", s, err)
+	}
+	if sched.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron: schedule never fires: %q", s)
+	}
+	return &TimerQuery{
+		stopCh:   make(chan struct{}, 1),
+		id:       fmt.Sprintf("cron(%s)", s),
+		schedule: sched,
+	}, nil
+}
+
+// Fetch returns the current time on the first call, then waits for and
+// returns the next scheduled time.
+func (d *TimerQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	if d.last.IsZero() {
+		d.last = time.Now()
+	} else {
+		next := d.schedule.next(d.last)
+		select {
+		case <-d.stopCh:
+			return nil, nil, ErrStopped
+		case <-time.After(time.Until(next)):
+		}
+		d.last = next
+	}
+
+	// nanoseconds so ticks less than a second apart have different indexes
+	return d.last, &dep.ResponseMetadata{
+		LastIndex: uint64(d.last.UnixNano()),
+	}, nil
+}
+
+// CanShare returns a boolean if this dependency is shareable.
+func (d *TimerQuery) CanShare() bool {
+	return true
+}
+
+// Stop halts the dependency's fetch function.
+func (d *TimerQuery) Stop() {
+	close(d.stopCh)
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *TimerQuery) ID() string {
+	return d.id
+}
+
+// Stringer interface reuses ID
+func (d *TimerQuery) String() string {
+	return d.ID()
+}
+
+func (d *TimerQuery) SetOptions(opts QueryOptions) {}
+
+// intervalSchedule fires every interval
+type intervalSchedule time.Duration
+
+func (s intervalSchedule) next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// cronSchedule is a parsed cron schedule, each field is the set of matching
+// values
+type cronSchedule struct {
+	minute, hour, dom, month, dow map[int]bool
+	// domAny and dowAny are set if the day fields are `*`, if both are
+	// restricted a day matches either (as in cron)
+	domAny, dowAny bool
+}
+
+// cronFields are the names and value ranges of the cron fields
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+func parseCron(s string) (*cronSchedule, error) {
+	fields := strings.Fields(s)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("expected %d fields, got %d",
+			len(cronFields), len(fields))
+	}
+	sets := make([]map[int]bool, len(fields))
+	for i, f := range fields {
+		set, err := parseCronField(f, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", cronFields[i].name, err)
+		}
+		sets[i] = set
+	}
+	return &cronSchedule{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3],
+		dow:    sets[4],
+		domAny: fields[2] == "*", dowAny: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField parses a comma separated list of `*`, values and ranges,
+// each with an optional `/step`, into the set of matching values.
+func parseCronField(f string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(f, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rng = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step: %q", part)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value: %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value: %q", part)
+				}
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("out of range %d-%d: %q", min, max, part)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// dayMatches returns if the day matches the day of month and week fields
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := s.dom[t.Day()], s.dow[int(t.Weekday())]
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// next returns the first matching minute after t. Zero if there is none in
+// the next 5 years (eg. Feb 30th).
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0,
+		t.Location())
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		switch {
+		case !s.month[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0,
+				t.Location())
+		case !s.hour[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0,
+				t.Location())
+		case !s.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
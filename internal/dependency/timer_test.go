@@ -0,0 +1,152 @@
+package dependency
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTimerQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		new  func(string) (*TimerQuery, error)
+		i    string
+		exp  string
+		err  bool
+	}{
+		{"every", NewEveryQuery, "5m", "every(5m)", false},
+		{"every_invalid", NewEveryQuery, "often", "", true},
+		{"every_negative", NewEveryQuery, "-1s", "", true},
+		{"cron", NewCronQuery, "0  *  * * 1-5", "cron(0 * * * 1-5)", false},
+		{"cron_fields", NewCronQuery, "0 * * *", "", true},
+		{"cron_range", NewCronQuery, "60 * * * *", "", true},
+		{"cron_step", NewCronQuery, "*/0 * * * *", "", true},
+		{"cron_never", NewCronQuery, "0 0 30 2 *", "", true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := tc.new(tc.i)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if d != nil {
+				assert.Equal(t, tc.exp, d.ID())
+			}
+		})
+	}
+}
+
+func TestCronSchedule_next(t *testing.T) {
+	t.Parallel()
+
+	// a Wednesday
+	start := time.Date(2021, time.March, 3, 10, 17, 30, 0, time.UTC)
+
+	cases := []struct {
+		name string
+		i    string
+		exp  time.Time
+	}{
+		{
+			"every_minute",
+			"* * * * *",
+			time.Date(2021, time.March, 3, 10, 18, 0, 0, time.UTC),
+		},
+		{
+			"hourly",
+			"0 * * * *",
+			time.Date(2021, time.March, 3, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			"step",
+			"*/15 * * * *",
+			time.Date(2021, time.March, 3, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			"list",
+			"5,20 9,10 * * *",
+			time.Date(2021, time.March, 3, 10, 20, 0, 0, time.UTC),
+		},
+		{
+			"next_day",
+			"0 9 * * *",
+			time.Date(2021, time.March, 4, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			"day_of_week",
+			"0 0 * * 1",
+			time.Date(2021, time.March, 8, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"day_of_month_or_week",
+			"0 0 5 * 1",
+			time.Date(2021, time.March, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"next_year",
+			"30 6 1 1 *",
+			time.Date(2022, time.January, 1, 6, 30, 0, 0, time.UTC),
+		},
+		{
+			"leap_day",
+			"0 0 29 2 *",
+			time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			s, err := parseCron(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, s.next(start))
+		})
+	}
+}
+
+func TestTimerQuery_Fetch(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewEveryQuery("10ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first fetch returns right away, the next after the interval
+	start := time.Now()
+	first, rm1, err := d.Fetch(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, rm2, err := d.Fetch(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("fetched too soon: %v", elapsed)
+	}
+	if !second.(time.Time).After(first.(time.Time)) ||
+		rm2.LastIndex <= rm1.LastIndex {
+		t.Errorf("bad fetches: %v (%d), %v (%d)", first, rm1.LastIndex,
+			second, rm2.LastIndex)
+	}
+
+	errCh := make(chan error)
+	go func() {
+		_, _, err := d.Fetch(nil)
+		errCh <- err
+	}()
+	d.Stop()
+	select {
+	case err := <-errCh:
+		assert.Equal(t, ErrStopped, err)
+	case <-time.After(time.Second):
+		t.Fatal("fetch not stopped")
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/hashicorp/hcat/dep"
 	"github.com/hashicorp/vault/api"
+	"golang.org/x/crypto/ssh"
 )
 
 //
@@ -21,7 +22,9 @@ type renewer interface {
 	secrets() (*dep.Secret, *api.Secret)
 }
 
-func renewSecret(clients dep.Clients, d renewer) error {
+// renewSecret renews the secret's lease until it can't be renewed any more,
+// sending the lease events to the options' lease observer (opts can be nil).
+func renewSecret(clients dep.Clients, d renewer, opts *QueryOptions) error {
 	secret, vaultSecret := d.secrets()
 	renewer, err := clients.Vault().NewRenewer(&api.RenewerInput{
 		Secret: vaultSecret,
@@ -32,18 +35,71 @@ func renewSecret(clients dep.Clients, d renewer) error {
 	go renewer.Renew()
 	defer renewer.Stop()
 
+	var maxAgeCh <-chan time.Time
+	if opts.MaxAge > 0 {
+		t := time.NewTimer(opts.MaxAge)
+		defer t.Stop()
+		maxAgeCh = t.C
+	}
+
 	for {
 		select {
-		case <-renewer.DoneCh():
+		case <-maxAgeCh:
+			// stop renewing so the secret is read again
+			return nil
+		case <-opts.done():
+			// refreshed, stop renewing so the secret is read again now
+			return nil
+		case err := <-renewer.DoneCh():
+			if err != nil {
+				e := leaseEvent(dep.LeaseRevoked, d.ID(), secret)
+				e.Err = err
+				opts.observeLease(e)
+			} else {
+				opts.observeLease(leaseEvent(dep.LeaseExpiring, d.ID(), secret))
+			}
 			return nil
 		case renewal := <-renewer.RenewCh():
 			updateSecret(secret, renewal.Secret)
+			opts.observeLease(leaseEvent(dep.LeaseRenewed, d.ID(), secret))
 		case <-d.stopChan():
 			return ErrStopped
 		}
 	}
 }
 
+// hasLease returns true if the secret (or its auth token) has a lease.
+func hasLease(s *dep.Secret) bool {
+	if s.Auth != nil && s.Auth.LeaseDuration > 0 {
+		return true
+	}
+	return s.LeaseID != ""
+}
+
+// leaseEvent returns the lease event of the type for the dependency's secret.
+func leaseEvent(t dep.LeaseEventType, id string, s *dep.Secret) dep.LeaseEvent {
+	e := dep.LeaseEvent{
+		Type:          t,
+		ID:            id,
+		LeaseID:       s.LeaseID,
+		LeaseDuration: time.Duration(s.LeaseDuration) * time.Second,
+		Renewable:     s.Renewable,
+	}
+	if s.Auth != nil && s.Auth.LeaseDuration > 0 {
+		e.LeaseDuration = time.Duration(s.Auth.LeaseDuration) * time.Second
+		e.Renewable = s.Auth.Renewable
+	}
+	return e
+}
+
+// capWait returns the wait duration capped by the max age, if set.
+func capWait(dur, maxAge time.Duration) time.Duration {
+	if maxAge > 0 && dur > maxAge {
+		return maxAge
+	}
+	return dur
+}
+
 // leaseCheckWait accepts a secret and returns the recommended amount of
 // time to sleep.
 func leaseCheckWait(s *dep.Secret) time.Duration {
@@ -64,6 +120,13 @@ func leaseCheckWait(s *dep.Secret) time.Duration {
 		}
 	}
 
+	// Handle if this is a signed SSH certificate with no lease
+	if signed, ok := s.Data["signed_key"]; ok && s.LeaseID == "" {
+		if validBefore, ok := sshCertValidBefore(signed); ok {
+			base = int(validBefore.Unix() - time.Now().Unix())
+		}
+	}
+
 	// Handle if this is an AppRole secret_id with no lease
 	if _, ok := s.Data["secret_id"]; ok && s.LeaseID == "" {
 		if ttlInterface, ok := s.Data["secret_id_ttl"]; ok {
@@ -75,6 +138,15 @@ func leaseCheckWait(s *dep.Secret) time.Duration {
 		}
 	}
 
+	// Handle if this is a GCP OAuth2 access token with no lease
+	if _, ok := s.Data["token"]; ok && s.LeaseID == "" {
+		if expInterface, ok := s.Data["expires_at_seconds"]; ok {
+			if expData, err := expInterface.(json.Number).Int64(); err == nil {
+				base = int(expData - time.Now().Unix())
+			}
+		}
+	}
+
 	// Handle if this is a secret with a rotation period.  If this is a
 	// rotating secret, the rotating secret's TTL will be the duration to sleep
 	// before rendering the new secret.
@@ -112,6 +184,24 @@ func leaseCheckWait(s *dep.Secret) time.Duration {
 	return time.Duration(sleep)
 }
 
+// sshCertValidBefore parses the signed SSH certificate returned by Vault's SSH
+// secrets engine and returns the time it expires.
+func sshCertValidBefore(signed interface{}) (time.Time, bool) {
+	str, ok := signed.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(str))
+	if err != nil {
+		return time.Time{}, false
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok || cert.ValidBefore == ssh.CertTimeInfinity {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(cert.ValidBefore), 0), true
+}
+
 // vaultSecretRenewable determines if the given secret is renewable.
 func vaultSecretRenewable(s *dep.Secret) bool {
 	if s.Auth != nil {
@@ -1,6 +1,8 @@
 package dependency
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/json"
 	"strconv"
 	"testing"
@@ -8,6 +10,7 @@ import (
 
 	"github.com/hashicorp/hcat/dep"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestVaultRenewDuration(t *testing.T) {
@@ -112,6 +115,103 @@ func TestVaultRenewDuration(t *testing.T) {
 		})
 
 	})
+
+	t.Run("ssh signed key", func(t *testing.T) {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer, err := ssh.NewSignerFromKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert := &ssh.Certificate{
+			Key:         signer.PublicKey(),
+			CertType:    ssh.UserCert,
+			ValidAfter:  uint64(time.Now().Unix()),
+			ValidBefore: uint64(time.Now().Unix() + 200),
+		}
+		if err := cert.SignCert(rand.Reader, signer); err != nil {
+			t.Fatal(err)
+		}
+		data := map[string]interface{}{
+			"signed_key":    string(ssh.MarshalAuthorizedKey(cert)),
+			"serial_number": "abc",
+		}
+
+		secret := dep.Secret{LeaseDuration: 1000, Data: data}
+		secretDur := leaseCheckWait(&secret).Seconds()
+		if secretDur < 0.85*199 || secretDur > 0.95*200 {
+			t.Fatalf("ssh certificate duration is not within 85%% to 95%%: %f",
+				secretDur)
+		}
+	})
+
+	t.Run("gcp-token", func(t *testing.T) {
+		exp := time.Now().Add(200 * time.Second).Unix()
+		data := map[string]interface{}{
+			"token":              "ya29",
+			"expires_at_seconds": json.Number(strconv.FormatInt(exp, 10)),
+			"token_ttl":          json.Number("200"),
+		}
+
+		secret := dep.Secret{LeaseDuration: 1000, Data: data}
+		secretDur := leaseCheckWait(&secret).Seconds()
+		if secretDur < 0.85*199 || secretDur > 0.95*200 {
+			t.Fatalf("gcp token duration is not within 85%% to 95%%: %f",
+				secretDur)
+		}
+	})
+}
+
+func TestLeaseEvent(t *testing.T) {
+	cases := []struct {
+		name   string
+		secret *dep.Secret
+		lease  bool
+		exp    dep.LeaseEvent
+	}{
+		{
+			"no-lease",
+			&dep.Secret{LeaseDuration: 300},
+			false,
+			dep.LeaseEvent{LeaseDuration: 5 * time.Minute},
+		},
+		{
+			"lease",
+			&dep.Secret{LeaseID: "db/creds/1", LeaseDuration: 60,
+				Renewable: true},
+			true,
+			dep.LeaseEvent{LeaseID: "db/creds/1", LeaseDuration: time.Minute,
+				Renewable: true},
+		},
+		{
+			"auth",
+			&dep.Secret{Auth: &dep.SecretAuth{LeaseDuration: 120,
+				Renewable: true}},
+			true,
+			dep.LeaseEvent{LeaseDuration: 2 * time.Minute, Renewable: true},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.lease, hasLease(tc.secret))
+			tc.exp.Type, tc.exp.ID = dep.LeaseRenewed, "vault.read(foo)"
+			act := leaseEvent(dep.LeaseRenewed, "vault.read(foo)", tc.secret)
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+
+	// observing without an observer is a no-op
+	var opts *QueryOptions
+	opts.observeLease(dep.LeaseEvent{})
+	(&QueryOptions{}).observeLease(dep.LeaseEvent{})
+}
+
+func TestCapWait(t *testing.T) {
+	assert.Equal(t, time.Hour, capWait(time.Hour, 0))
+	assert.Equal(t, time.Minute, capWait(time.Hour, time.Minute))
+	assert.Equal(t, time.Second, capWait(time.Second, time.Minute))
 }
 
 func TestShimKVv2Path(t *testing.T) {
@@ -0,0 +1,103 @@
+package dependency
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// kvMountTTL is how long a mount's KV version is cached, after that it is
+// detected again in case the mount was upgraded or remounted.
+const kvMountTTL = 10 * time.Minute
+
+// kvMounts caches the KV version of the Vault mounts so the detection
+// (sys/internal/ui/mounts) is done once per mount, every kvMountTTL, instead
+// of on every secret read, list and write.
+var kvMounts = newKVMountCache(kvMountTTL)
+
+// kvMount is the cached version information of a single mount.
+type kvMount struct {
+	path    string // with trailing slash, eg. "secret/"
+	isKVv2  bool
+	expires time.Time
+}
+
+// kvMountCache is a cache of mounts per Vault cluster and namespace.
+type kvMountCache struct {
+	sync.RWMutex
+	ttl    time.Duration
+	mounts map[string][]kvMount
+}
+
+func newKVMountCache(ttl time.Duration) *kvMountCache {
+	return &kvMountCache{ttl: ttl, mounts: make(map[string][]kvMount)}
+}
+
+// kvMountInfo returns the mount path of the secret path and if it is a KV v2
+// mount. The result of the lookup is cached per mount, see isKVv2.
+func kvMountInfo(client *api.Client, path string) (string, bool, error) {
+	key := kvMountCacheKey(client)
+	if m, ok := kvMounts.lookup(key, path); ok {
+		return m.path, m.isKVv2, nil
+	}
+	mountPath, isV2, err := isKVv2(client, path)
+	if err == nil && mountPath != "" {
+		kvMounts.add(key, kvMount{path: mountPath, isKVv2: isV2})
+	}
+	return mountPath, isV2, err
+}
+
+// kvMountCacheKey identifies the Vault cluster and namespace of the client.
+func kvMountCacheKey(client *api.Client) string {
+	return client.Address() + "|" + client.Headers().Get("X-Vault-Namespace")
+}
+
+// lookup returns the cached mount containing the path, the longest one if
+// mounts are nested. Expired mounts aren't returned.
+func (c *kvMountCache) lookup(key, path string) (kvMount, bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	var found kvMount
+	var ok bool
+	now := time.Now()
+	path = strings.TrimPrefix(path, "/")
+	for _, m := range c.mounts[key] {
+		if !strings.HasPrefix(path+"/", m.path) || now.After(m.expires) {
+			continue
+		}
+		if !ok || len(m.path) > len(found.path) {
+			found, ok = m, true
+		}
+	}
+	return found, ok
+}
+
+// add caches the mount for the cache's TTL, replacing any existing entry for
+// the same path.
+func (c *kvMountCache) add(key string, m kvMount) {
+	c.Lock()
+	defer c.Unlock()
+
+	if !strings.HasSuffix(m.path, "/") {
+		m.path += "/"
+	}
+	m.expires = time.Now().Add(c.ttl)
+	mounts := c.mounts[key]
+	for i := range mounts {
+		if mounts[i].path == m.path {
+			mounts[i] = m
+			return
+		}
+	}
+	c.mounts[key] = append(mounts, m)
+}
+
+// reset clears the cache, for testing.
+func (c *kvMountCache) reset() {
+	c.Lock()
+	defer c.Unlock()
+	c.mounts = make(map[string][]kvMount)
+}
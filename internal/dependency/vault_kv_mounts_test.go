@@ -0,0 +1,100 @@
+package dependency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKVMountCache(t *testing.T) {
+	t.Parallel()
+
+	c := newKVMountCache(time.Minute)
+	c.add("a", kvMount{path: "secret/", isKVv2: true})
+	c.add("a", kvMount{path: "kv", isKVv2: false})
+	c.add("a", kvMount{path: "kv/nested/", isKVv2: true})
+	c.add("b", kvMount{path: "other/", isKVv2: true})
+
+	cases := []struct {
+		name  string
+		key   string
+		path  string
+		mount string
+		isV2  bool
+		found bool
+	}{
+		{"secret", "a", "secret/foo/bar", "secret/", true, true},
+		{"mount_only", "a", "secret", "secret/", true, true},
+		{"leading_slash", "a", "/secret/foo", "secret/", true, true},
+		{"no_trailing_slash", "a", "kv/foo", "kv/", false, true},
+		{"nested", "a", "kv/nested/foo", "kv/nested/", true, true},
+		{"prefix_not_mount", "a", "secretive/foo", "", false, false},
+		{"other_key", "a", "other/foo", "", false, false},
+		{"other_cluster", "b", "other/foo", "other/", true, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, ok := c.lookup(tc.key, tc.path)
+			assert.Equal(t, tc.found, ok)
+			assert.Equal(t, tc.mount, m.path)
+			assert.Equal(t, tc.isV2, m.isKVv2)
+		})
+	}
+
+	t.Run("replace", func(t *testing.T) {
+		c.add("a", kvMount{path: "secret/", isKVv2: false})
+		m, ok := c.lookup("a", "secret/foo")
+		assert.True(t, ok)
+		assert.False(t, m.isKVv2)
+		assert.Len(t, c.mounts["a"], 3)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		c := newKVMountCache(0)
+		c.add("a", kvMount{path: "secret/", isKVv2: true})
+		time.Sleep(time.Millisecond)
+		_, ok := c.lookup("a", "secret/foo")
+		assert.False(t, ok)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		c.reset()
+		_, ok := c.lookup("a", "secret/foo")
+		assert.False(t, ok)
+	})
+}
+
+func TestVaultReadQuery_kvVersionOverride(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		i          string
+		mountPath  string
+		isV2       bool
+		secretPath string
+	}{
+		{"v2", "secret/foo/bar?kv_version=2", "secret/", true,
+			"secret/data/foo/bar"},
+		{"v2_data", "secret/data/foo?kv_version=2", "secret/", true,
+			"secret/data/foo"},
+		{"v1", "secret/foo/bar?kv_version=1", "", false, "secret/foo/bar"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewVaultReadQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// no client needed when overridden
+			mountPath, isV2, err := d.kvMountInfo(nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.mountPath, mountPath)
+			assert.Equal(t, tc.isV2, isV2)
+			if isV2 {
+				assert.Equal(t, tc.secretPath, shimKVv2Path(d.rawPath, mountPath))
+			}
+		})
+	}
+}
@@ -51,18 +51,19 @@ func (d *VaultListQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseM
 
 	// If this is not the first query, poll to simulate blocking-queries.
 	if opts.WaitIndex != 0 {
-		dur := opts.DefaultLease
+		dur := capWait(opts.DefaultLease, opts.MaxAge)
 		select {
 		case <-d.stopCh:
 			return nil, nil, ErrStopped
 		case <-time.After(dur):
+		case <-opts.done():
 		}
 	}
 
 	path := d.path
 	// Checking secret engine version. If it's v2, we should shim /metadata/
 	// to secret path if necessary.
-	mountPath, isV2, _ := isKVv2(clients.Vault(), path)
+	mountPath, isV2, _ := kvMountInfo(clients.Vault(), path)
 	if isV2 {
 		path = shimKv2ListPath(path, mountPath)
 	}
@@ -123,6 +124,11 @@ func (d *VaultListQuery) String() string {
 	return d.ID()
 }
 
+// VaultPath returns the path of the listed secrets.
+func (d *VaultListQuery) VaultPath() string {
+	return d.path
+}
+
 func (d *VaultListQuery) SetOptions(opts QueryOptions) {
 	d.opts = opts
 }
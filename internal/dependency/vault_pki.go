@@ -0,0 +1,141 @@
+package dependency
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*VaultPKIQuery)(nil)
+
+	// VaultPKIQuerySleepTime is the default amount of time to sleep between
+	// queries of a PKI mount's CA chain or CRL.
+	VaultPKIQuerySleepTime = 5 * time.Minute
+)
+
+// The PKI endpoints supported by VaultPKIQuery.
+const (
+	// PKICAChain is the mount's PEM encoded CA certificate chain.
+	PKICAChain = "ca_chain"
+	// PKICRL is the mount's PEM encoded certificate revocation list.
+	PKICRL = "crl"
+)
+
+// pkiEndpoints maps the supported kinds to their endpoint under the mount.
+var pkiEndpoints = map[string]string{
+	PKICAChain: "ca_chain",
+	PKICRL:     "crl/pem",
+}
+
+// VaultPKIQuery is the dependency to Vault for a PKI mount's CA certificate
+// chain or CRL. These endpoints have no leases or blocking queries so they
+// are polled, sleeping the interval between queries. The result is the PEM
+// encoded string, changes are detected by comparing it with the previous one.
+type VaultPKIQuery struct {
+	isVault
+	stopCh chan struct{}
+
+	mount    string
+	kind     string
+	interval time.Duration
+	opts     QueryOptions
+}
+
+// NewVaultPKIQuery creates a new dependency on the kind (PKICAChain or
+// PKICRL) of the PKI mount, polled every interval. A zero interval uses
+// VaultPKIQuerySleepTime.
+func NewVaultPKIQuery(mount, kind string, interval time.Duration) (*VaultPKIQuery, error) {
+	mount = strings.Trim(strings.TrimSpace(mount), "/")
+	if mount == "" {
+		return nil, fmt.Errorf("vault.pki: invalid mount: %q", mount)
+	}
+	if _, ok := pkiEndpoints[kind]; !ok {
+		return nil, fmt.Errorf("vault.pki: invalid kind: %q", kind)
+	}
+	if interval < 0 {
+		return nil, fmt.Errorf("vault.pki: invalid interval: %s", interval)
+	}
+
+	return &VaultPKIQuery{
+		stopCh:   make(chan struct{}, 1),
+		mount:    mount,
+		kind:     kind,
+		interval: interval,
+	}, nil
+}
+
+// Fetch queries the Vault API
+func (d *VaultPKIQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	opts := d.opts.Merge(&QueryOptions{})
+
+	// If this is not the first query, poll to simulate blocking-queries.
+	if opts.WaitIndex != 0 {
+		dur := d.interval
+		if dur == 0 {
+			dur = VaultPKIQuerySleepTime
+		}
+		select {
+		case <-d.stopCh:
+			return nil, nil, ErrStopped
+		case <-time.After(dur):
+		case <-opts.done():
+		}
+	}
+
+	client := clients.Vault()
+	req := client.NewRequest("GET",
+		"/v1/"+d.mount+"/"+pkiEndpoints[d.kind])
+	resp, err := client.RawRequest(req)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.ID())
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.ID())
+	}
+
+	return respWithMetadata(string(body))
+}
+
+// CanShare returns if this dependency is shareable.
+func (d *VaultPKIQuery) CanShare() bool {
+	return true
+}
+
+// Stop halts the given dependency's fetch.
+func (d *VaultPKIQuery) Stop() {
+	close(d.stopCh)
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *VaultPKIQuery) ID() string {
+	if d.interval != 0 {
+		return fmt.Sprintf("vault.pki.%s(%s|%s)", d.kind, d.mount, d.interval)
+	}
+	return fmt.Sprintf("vault.pki.%s(%s)", d.kind, d.mount)
+}
+
+// Stringer interface reuses ID
+func (d *VaultPKIQuery) String() string {
+	return d.ID()
+}
+
+func (d *VaultPKIQuery) SetOptions(opts QueryOptions) {
+	d.opts = opts
+}
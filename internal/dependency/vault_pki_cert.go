@@ -0,0 +1,307 @@
+package dependency
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*VaultPKICertQuery)(nil)
+)
+
+// The certificate flows supported by VaultPKICertQuery.
+const (
+	// PKIIssue has Vault generate the private key and issue the certificate.
+	PKIIssue = "issue"
+	// PKISign generates the private key locally and has Vault sign a CSR for
+	// it, the key is never sent to Vault.
+	PKISign = "sign"
+)
+
+// The local key options of the PKISign flow, they aren't sent to Vault.
+const (
+	pkiKeyTypeOpt = "key_type"
+	pkiKeyBitsOpt = "key_bits"
+)
+
+// VaultPKICertQuery is the dependency to Vault for a certificate from a PKI
+// mount's role, either issued (Vault generates the private key) or signed
+// (the private key is generated locally and only the CSR is sent). The result
+// is the secret returned by Vault, in the sign flow with the local key added
+// as its "private_key" (PEM encoded) and "private_key_type" data. A new
+// certificate, with a new key, is fetched before the current one expires.
+type VaultPKICertQuery struct {
+	isVault
+	stopCh  chan struct{}
+	sleepCh chan time.Duration
+
+	mode     string
+	path     string
+	data     map[string]interface{}
+	dataHash string
+	keyType  string
+	keyBits  int
+	secret   *dep.Secret
+	opts     QueryOptions
+}
+
+// NewVaultPKICertQuery creates a new dependency on a certificate of the role
+// of the PKI mount, using the mode's flow (PKIIssue or PKISign). The data is
+// sent with the request (eg. "common_name", "alt_names", "ttl"). In the sign
+// flow "key_type" ("ec", the default, or "rsa") and "key_bits" set the local
+// key's type and size (defaults to 256 for ec and 2048 for rsa).
+func NewVaultPKICertQuery(mode, mount, role string, data map[string]interface{}) (*VaultPKICertQuery, error) {
+	if mode != PKIIssue && mode != PKISign {
+		return nil, fmt.Errorf("vault.pki: invalid mode: %q", mode)
+	}
+	mount = strings.Trim(strings.TrimSpace(mount), "/")
+	role = strings.Trim(strings.TrimSpace(role), "/")
+	if mount == "" || role == "" {
+		return nil, fmt.Errorf("vault.pki.%s: invalid role: %q", mode,
+			mount+"/"+role)
+	}
+
+	d := &VaultPKICertQuery{
+		stopCh:   make(chan struct{}, 1),
+		sleepCh:  make(chan time.Duration, 1),
+		mode:     mode,
+		path:     strings.Join([]string{mount, mode, role}, "/"),
+		data:     make(map[string]interface{}, len(data)),
+		dataHash: sha1Map(data),
+	}
+	for k, v := range data {
+		d.data[k] = v
+	}
+	if mode == PKISign {
+		if err := d.keyOptions(); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// keyOptions moves the local key options out of the request's data.
+func (d *VaultPKICertQuery) keyOptions() error {
+	d.keyType, d.keyBits = "ec", 256
+	if v, ok := d.data[pkiKeyTypeOpt]; ok {
+		d.keyType = fmt.Sprint(v)
+		delete(d.data, pkiKeyTypeOpt)
+	}
+	switch d.keyType {
+	case "ec":
+	case "rsa":
+		d.keyBits = 2048
+	default:
+		return fmt.Errorf("vault.pki.sign: invalid key type: %q", d.keyType)
+	}
+	if v, ok := d.data[pkiKeyBitsOpt]; ok {
+		bits, err := strconv.Atoi(fmt.Sprint(v))
+		if err != nil {
+			return fmt.Errorf("vault.pki.sign: invalid key bits: %q", v)
+		}
+		d.keyBits = bits
+		delete(d.data, pkiKeyBitsOpt)
+	}
+	if _, err := d.curve(); d.keyType == "ec" && err != nil {
+		return err
+	}
+	if d.keyType == "rsa" && d.keyBits < 2048 {
+		return fmt.Errorf("vault.pki.sign: invalid rsa key bits: %d",
+			d.keyBits)
+	}
+	return nil
+}
+
+// Fetch queries the Vault API
+func (d *VaultPKICertQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+	select {
+	case dur := <-d.sleepCh:
+		select {
+		case <-d.stopCh:
+			return nil, nil, ErrStopped
+		case <-time.After(dur):
+			if hasLease(d.secret) {
+				d.opts.observeLease(leaseEvent(dep.LeaseExpiring, d.ID(), d.secret))
+			}
+		case <-d.opts.done():
+			// refreshed, fetch a new certificate now
+		}
+	default:
+	}
+
+	data := d.data
+	var keyPEM string
+	if d.mode == PKISign {
+		var csrPEM string
+		var err error
+		keyPEM, csrPEM, err = d.newCSR()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, d.ID())
+		}
+		data = make(map[string]interface{}, len(d.data)+1)
+		for k, v := range d.data {
+			data[k] = v
+		}
+		data["csr"] = csrPEM
+	}
+
+	vaultSecret, err := clients.Vault().Logical().Write(d.path, data)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.ID())
+	}
+	if vaultSecret == nil {
+		return nil, nil, fmt.Errorf("%s: no certificate returned", d.ID())
+	}
+
+	opts := d.opts.Merge(&QueryOptions{})
+	d.secret = transformSecret(vaultSecret, opts.DefaultLease)
+	if keyPEM != "" {
+		d.secret.Data["private_key"] = keyPEM
+		d.secret.Data["private_key_type"] = d.keyType
+	}
+	if hasLease(d.secret) {
+		d.opts.observeLease(leaseEvent(dep.LeaseAcquired, d.ID(), d.secret))
+	}
+
+	d.sleepCh <- capWait(leaseCheckWait(d.secret), d.opts.MaxAge)
+
+	return respWithMetadata(d.secret)
+}
+
+// newKey generates a new private key of the query's type and size.
+func (d *VaultPKICertQuery) newKey() (crypto.Signer, error) {
+	if d.keyType == "rsa" {
+		return rsa.GenerateKey(rand.Reader, d.keyBits)
+	}
+	curve, err := d.curve()
+	if err != nil {
+		return nil, err
+	}
+	return ecdsa.GenerateKey(curve, rand.Reader)
+}
+
+// curve returns the elliptic curve of the ec key size.
+func (d *VaultPKICertQuery) curve() (elliptic.Curve, error) {
+	switch d.keyBits {
+	case 224:
+		return elliptic.P224(), nil
+	case 256:
+		return elliptic.P256(), nil
+	case 384:
+		return elliptic.P384(), nil
+	case 521:
+		return elliptic.P521(), nil
+	}
+	return nil, fmt.Errorf("vault.pki.sign: invalid ec key bits: %d",
+		d.keyBits)
+}
+
+// newCSR generates a new private key and a CSR for it with the request's
+// common name and SANs, returning both PEM encoded.
+func (d *VaultPKICertQuery) newCSR() (keyPEM, csrPEM string, err error) {
+	key, err := d.newKey()
+	if err != nil {
+		return "", "", err
+	}
+
+	var block *pem.Block
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		block = &pem.Block{Type: "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(k)}
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return "", "", err
+		}
+		block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	}
+
+	tmpl := &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: d.dataString("common_name")},
+		DNSNames: splitSANs(d.dataString("alt_names")),
+	}
+	for _, ip := range splitSANs(d.dataString("ip_sans")) {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return "", "", fmt.Errorf("invalid ip_sans: %q", ip)
+		}
+		tmpl.IPAddresses = append(tmpl.IPAddresses, parsed)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(pem.EncodeToMemory(block)),
+		string(pem.EncodeToMemory(&pem.Block{
+			Type: "CERTIFICATE REQUEST", Bytes: der})), nil
+}
+
+// dataString returns the request's data for the key as a string
+func (d *VaultPKICertQuery) dataString(k string) string {
+	if v, ok := d.data[k]; ok {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// splitSANs splits the comma separated list, dropping empty entries
+func splitSANs(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// CanShare returns if this dependency is shareable.
+func (d *VaultPKICertQuery) CanShare() bool {
+	return false
+}
+
+// Stop halts the given dependency's fetch.
+func (d *VaultPKICertQuery) Stop() {
+	close(d.stopCh)
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *VaultPKICertQuery) ID() string {
+	return fmt.Sprintf("vault.pki.%s(%s -> %s)", d.mode, d.path, d.dataHash)
+}
+
+// Stringer interface reuses ID
+func (d *VaultPKICertQuery) String() string {
+	return d.ID()
+}
+
+// VaultPath returns the certificate's endpoint path.
+func (d *VaultPKICertQuery) VaultPath() string {
+	return d.path
+}
+
+func (d *VaultPKICertQuery) SetOptions(opts QueryOptions) {
+	d.opts = opts
+}
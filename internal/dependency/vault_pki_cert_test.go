@@ -0,0 +1,145 @@
+package dependency
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewVaultPKICertQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		mode string
+		role string
+		data map[string]interface{}
+		exp  string
+		err  bool
+	}{
+		{"issue", PKIIssue, "web", nil,
+			"vault.pki.issue(pki/issue/web -> " + sha1Map(nil) + ")", false},
+		{"sign", PKISign, "/web/", map[string]interface{}{"key_type": "rsa"},
+			"vault.pki.sign(pki/sign/web -> " +
+				sha1Map(map[string]interface{}{"key_type": "rsa"}) + ")", false},
+		{"bad_mode", "generate", "web", nil, "", true},
+		{"no_role", PKIIssue, "", nil, "", true},
+		{"bad_key_type", PKISign, "web",
+			map[string]interface{}{"key_type": "dsa"}, "", true},
+		{"bad_ec_bits", PKISign, "web",
+			map[string]interface{}{"key_bits": "512"}, "", true},
+		{"bad_rsa_bits", PKISign, "web",
+			map[string]interface{}{"key_type": "rsa", "key_bits": "1024"},
+			"", true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewVaultPKICertQuery(tc.mode, "pki", tc.role, tc.data)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if d != nil {
+				assert.Equal(t, tc.exp, d.ID())
+			}
+		})
+	}
+}
+
+func TestVaultPKICertQuery_Fetch(t *testing.T) {
+	t.Parallel()
+
+	var path string
+	var req map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			req = nil
+			json.NewDecoder(r.Body).Decode(&req)
+			data := map[string]interface{}{
+				"certificate": "cert",
+				"expiration":  4102444800,
+			}
+			if _, ok := req["csr"]; !ok {
+				data["private_key"] = "vault-key"
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+		}))
+	defer srv.Close()
+
+	clients := NewClientSet()
+	defer clients.Stop()
+	if err := clients.CreateVaultClient(&CreateClientInput{
+		Address: srv.URL,
+		Token:   "token",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("issue", func(t *testing.T) {
+		d, err := NewVaultPKICertQuery(PKIIssue, "pki", "web",
+			map[string]interface{}{"common_name": "web.example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		act, _, err := d.Fetch(clients)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "/v1/pki/issue/web", path)
+		assert.Equal(t, "web.example.com", req["common_name"])
+		assert.Equal(t, "vault-key", act.(*dep.Secret).Data["private_key"])
+	})
+
+	t.Run("sign", func(t *testing.T) {
+		d, err := NewVaultPKICertQuery(PKISign, "pki", "web",
+			map[string]interface{}{
+				"common_name": "web.example.com",
+				"alt_names":   "a.example.com, b.example.com",
+				"ip_sans":     "10.0.0.1",
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		act, _, err := d.Fetch(clients)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "/v1/pki/sign/web", path)
+
+		// the CSR is for the local key, which isn't sent
+		block, _ := pem.Decode([]byte(req["csr"].(string)))
+		if block == nil {
+			t.Fatalf("bad csr: %v", req["csr"])
+		}
+		csr, err := x509.ParseCertificateRequest(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "web.example.com", csr.Subject.CommonName)
+		assert.Equal(t, []string{"a.example.com", "b.example.com"}, csr.DNSNames)
+		assert.Equal(t, "10.0.0.1", csr.IPAddresses[0].String())
+		_, ok := req["key_type"]
+		assert.False(t, ok)
+
+		secret := act.(*dep.Secret)
+		assert.Equal(t, "cert", secret.Data["certificate"])
+		assert.Equal(t, "ec", secret.Data["private_key_type"])
+		block, _ = pem.Decode([]byte(secret.Data["private_key"].(string)))
+		if block == nil {
+			t.Fatalf("bad private key: %v", secret.Data["private_key"])
+		}
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, key.Public(), csr.PublicKey)
+	})
+}
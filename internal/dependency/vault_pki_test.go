@@ -0,0 +1,201 @@
+package dependency
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	vapi "github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewVaultPKIQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		mount    string
+		kind     string
+		interval time.Duration
+		exp      *VaultPKIQuery
+		err      bool
+	}{
+		{
+			"ca_chain",
+			"pki",
+			PKICAChain,
+			0,
+			&VaultPKIQuery{mount: "pki", kind: PKICAChain},
+			false,
+		},
+		{
+			"crl_interval",
+			"/pki_int/",
+			PKICRL,
+			time.Minute,
+			&VaultPKIQuery{mount: "pki_int", kind: PKICRL, interval: time.Minute},
+			false,
+		},
+		{
+			"empty_mount",
+			"",
+			PKICRL,
+			0,
+			nil,
+			true,
+		},
+		{
+			"bad_kind",
+			"pki",
+			"cert",
+			0,
+			nil,
+			true,
+		},
+		{
+			"negative_interval",
+			"pki",
+			PKICRL,
+			-time.Second,
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewVaultPKIQuery(tc.mount, tc.kind, tc.interval)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestVaultPKIQuery_Fetch(t *testing.T) {
+	t.Parallel()
+
+	vc := testClients.Vault()
+	if err := vc.Sys().Mount("pkifetch", &vapi.MountInput{
+		Type: "pki",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vc.Logical().Write("pkifetch/root/generate/internal",
+		map[string]interface{}{
+			"common_name": "example.com",
+			"ttl":         "1h",
+		}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kind := range []string{PKICAChain, PKICRL} {
+		t.Run(kind, func(t *testing.T) {
+			d, err := NewVaultPKIQuery("pkifetch", kind, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			act, _, err := d.Fetch(testClients)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pem, _ := act.(string)
+			if !strings.Contains(pem, "-----BEGIN") {
+				t.Errorf("bad pem: %q", pem)
+			}
+		})
+	}
+
+	t.Run("stops", func(t *testing.T) {
+		d, err := NewVaultPKIQuery("pkifetch", PKICRL, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.SetOptions(QueryOptions{WaitIndex: 1})
+
+		errCh := make(chan error, 1)
+		go func() {
+			_, _, err := d.Fetch(testClients)
+			errCh <- err
+		}()
+		d.Stop()
+
+		select {
+		case err := <-errCh:
+			if err != ErrStopped {
+				t.Errorf("bad error: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("did not stop")
+		}
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		d, err := NewVaultPKIQuery("pkifetch", PKICRL, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		opts := QueryOptions{WaitIndex: 1}
+		d.SetOptions(opts.SetContext(ctx))
+
+		errCh := make(chan error, 1)
+		go func() {
+			_, _, err := d.Fetch(testClients)
+			errCh <- err
+		}()
+		// canceling cuts the wait short, fetching it now
+		cancel()
+
+		select {
+		case err := <-errCh:
+			if err != nil {
+				t.Errorf("bad error: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("did not refresh")
+		}
+	})
+}
+
+func TestVaultPKIQuery_String(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		kind     string
+		interval time.Duration
+		exp      string
+	}{
+		{
+			"ca_chain",
+			PKICAChain,
+			0,
+			"vault.pki.ca_chain(pki)",
+		},
+		{
+			"crl_interval",
+			PKICRL,
+			time.Minute,
+			"vault.pki.crl(pki|1m0s)",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewVaultPKIQuery("pki", tc.kind, tc.interval)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, d.ID())
+		})
+	}
+}
@@ -29,11 +29,20 @@ type VaultReadQuery struct {
 	secretPath  string
 	opts        QueryOptions
 
+	// kvVersion overrides the KV version detection, see NewVaultReadQuery
+	kvVersion string
+
 	// vaultSecret is the actual Vault secret which we are renewing
 	vaultSecret *api.Secret
 }
 
 // NewVaultReadQuery creates a new datacenter dependency.
+//
+// The KV version of the secret's mount is detected using the
+// sys/internal/ui/mounts endpoint. For environments where it is ACL
+// restricted the version can be set with the "kv_version" parameter, eg.
+// "secret/foo?kv_version=2". The mount is then assumed to be the first
+// element of the path.
 func NewVaultReadQuery(s string) (*VaultReadQuery, error) {
 	s = strings.TrimSpace(s)
 	s = strings.Trim(s, "/")
@@ -46,11 +55,21 @@ func NewVaultReadQuery(s string) (*VaultReadQuery, error) {
 		return nil, err
 	}
 
+	queryValues := secretURL.Query()
+	kvVersion := queryValues.Get("kv_version")
+	switch kvVersion {
+	case "", "1", "2":
+	default:
+		return nil, fmt.Errorf("vault.read: invalid kv_version: %q", kvVersion)
+	}
+	queryValues.Del("kv_version") // not a vault parameter
+
 	return &VaultReadQuery{
 		stopCh:      make(chan struct{}, 1),
 		sleepCh:     make(chan time.Duration, 1),
 		rawPath:     secretURL.Path,
-		queryValues: secretURL.Query(),
+		queryValues: queryValues,
+		kvVersion:   kvVersion,
 	}, nil
 }
 
@@ -63,14 +82,21 @@ func (d *VaultReadQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseM
 	}
 	select {
 	case dur := <-d.sleepCh:
-		time.Sleep(dur)
+		select {
+		case <-time.After(dur):
+			if hasLease(d.secret) {
+				d.opts.observeLease(leaseEvent(dep.LeaseExpiring, d.ID(), d.secret))
+			}
+		case <-d.opts.done():
+			// refreshed, read it again now
+		}
 	default:
 	}
 
 	firstRun := d.secret == nil
 
 	if !firstRun && vaultSecretRenewable(d.secret) {
-		err := renewSecret(clients, d)
+		err := renewSecret(clients, d, &d.opts)
 		if err != nil {
 			return nil, nil, errors.Wrap(err, d.ID())
 		}
@@ -80,9 +106,12 @@ func (d *VaultReadQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseM
 	if err != nil {
 		return nil, nil, errors.Wrap(err, d.ID())
 	}
+	if hasLease(d.secret) {
+		d.opts.observeLease(leaseEvent(dep.LeaseAcquired, d.ID(), d.secret))
+	}
 
 	if !vaultSecretRenewable(d.secret) {
-		dur := leaseCheckWait(d.secret)
+		dur := capWait(leaseCheckWait(d.secret), d.opts.MaxAge)
 		d.sleepCh <- dur
 	}
 
@@ -118,12 +147,18 @@ func (d *VaultReadQuery) Stop() {
 	close(d.stopCh)
 }
 
-// ID returns the human-friendly version of this dependency.
+// ID returns the human-friendly version of this dependency. The kv_version
+// is part of it, reads of the same path as different versions return
+// different data.
 func (d *VaultReadQuery) ID() string {
+	id := d.rawPath
 	if v := d.queryValues["version"]; len(v) > 0 {
-		return fmt.Sprintf("vault.read(%s.v%s)", d.rawPath, v[0])
+		id += ".v" + v[0]
 	}
-	return fmt.Sprintf("vault.read(%s)", d.rawPath)
+	if d.kvVersion != "" {
+		id += "?kv_version=" + d.kvVersion
+	}
+	return fmt.Sprintf("vault.read(%s)", id)
 }
 
 // Stringer interface reuses ID
@@ -131,12 +166,17 @@ func (d *VaultReadQuery) String() string {
 	return d.ID()
 }
 
+// VaultPath returns the secret's path.
+func (d *VaultReadQuery) VaultPath() string {
+	return d.rawPath
+}
+
 func (d *VaultReadQuery) readSecret(clients dep.Clients, opts *QueryOptions) (*api.Secret, error) {
 	vaultClient := clients.Vault()
 
 	// Check whether this secret refers to a KV v2 entry if we haven't yet.
 	if d.isKVv2 == nil {
-		mountPath, isKVv2, err := isKVv2(vaultClient, d.rawPath)
+		mountPath, isKVv2, err := d.kvMountInfo(vaultClient)
 		if err != nil {
 			isKVv2 = false
 			d.secretPath = d.rawPath
@@ -160,6 +200,19 @@ func (d *VaultReadQuery) readSecret(clients dep.Clients, opts *QueryOptions) (*a
 	return vaultSecret, nil
 }
 
+// kvMountInfo returns the mount path and KV version of the secret, using the
+// kv_version override if set.
+func (d *VaultReadQuery) kvMountInfo(client *api.Client) (string, bool, error) {
+	switch d.kvVersion {
+	case "1":
+		return "", false, nil
+	case "2":
+		mountPath := strings.SplitN(d.rawPath, "/", 2)[0] + "/"
+		return mountPath, true, nil
+	}
+	return kvMountInfo(client, d.rawPath)
+}
+
 func (d *VaultReadQuery) SetOptions(opts QueryOptions) {
 	d.opts = opts
 }
@@ -0,0 +1,155 @@
+package dependency
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*VaultReadBatchQuery)(nil)
+)
+
+// VaultReadBatchQuery is the dependency to Vault for several secrets read as
+// one, so templates reading many secrets don't need a dependency (and view)
+// per secret. The secrets are read in parallel and returned in a map keyed by
+// path. They are all read again when the first one needs to be (going by its
+// lease), their leases aren't renewed, so it is meant for static (eg. KV)
+// secrets and not dynamic credentials.
+type VaultReadBatchQuery struct {
+	isVault
+	stopCh chan struct{}
+
+	reads   []batchRead   // sorted by path
+	fetched bool          // the secrets were read
+	wait    time.Duration // before reading them again
+	opts    QueryOptions
+}
+
+// batchRead is the read of one of the batch's secrets.
+type batchRead struct {
+	path string
+	*VaultReadQuery
+}
+
+// NewVaultReadBatchQuery creates a new dependency on the secrets at the paths,
+// which take the same form as with NewVaultReadQuery. The secrets are keyed by
+// the paths without surrounding slashes, duplicates are read once.
+func NewVaultReadBatchQuery(paths []string) (*VaultReadBatchQuery, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("vault.read.batch: no paths")
+	}
+
+	seen := make(map[string]struct{}, len(paths))
+	reads := make([]batchRead, 0, len(paths))
+	for _, p := range paths {
+		r, err := NewVaultReadQuery(p)
+		if err != nil {
+			return nil, errors.Wrap(err, "vault.read.batch")
+		}
+		p = strings.Trim(strings.TrimSpace(p), "/")
+		if _, ok := seen[p]; ok {
+			continue
+		}
+		seen[p] = struct{}{}
+		reads = append(reads, batchRead{path: p, VaultReadQuery: r})
+	}
+	sort.Slice(reads, func(i, j int) bool {
+		return reads[i].path < reads[j].path
+	})
+
+	return &VaultReadBatchQuery{
+		stopCh: make(chan struct{}, 1),
+		reads:  reads,
+	}, nil
+}
+
+// Fetch queries the Vault API
+func (d *VaultReadBatchQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	if d.fetched {
+		select {
+		case <-d.stopCh:
+			return nil, nil, ErrStopped
+		case <-time.After(d.wait):
+		case <-d.opts.done():
+		}
+	}
+
+	secrets := make(map[string]*dep.Secret, len(d.reads))
+	errs := make([]error, len(d.reads))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, r := range d.reads {
+		wg.Add(1)
+		go func(i int, r batchRead) {
+			defer wg.Done()
+			r.SetOptions(d.opts)
+			if errs[i] = r.fetchSecret(clients); errs[i] != nil {
+				return
+			}
+			mu.Lock()
+			secrets[r.path] = r.secret
+			mu.Unlock()
+		}(i, r)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, nil, errors.Wrap(err, d.ID())
+		}
+	}
+
+	// read them all again when the first one needs to be
+	d.fetched, d.wait = true, 0
+	for _, s := range secrets {
+		if w := leaseCheckWait(s); w > 0 && (d.wait == 0 || w < d.wait) {
+			d.wait = w
+		}
+	}
+	if d.wait == 0 {
+		d.wait = d.opts.DefaultLease
+	}
+	d.wait = capWait(d.wait, d.opts.MaxAge)
+
+	return respWithMetadata(secrets)
+}
+
+// CanShare returns if this dependency is shareable.
+func (d *VaultReadBatchQuery) CanShare() bool {
+	return false
+}
+
+// Stop halts the given dependency's fetch.
+func (d *VaultReadBatchQuery) Stop() {
+	close(d.stopCh)
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *VaultReadBatchQuery) ID() string {
+	paths := make([]string, len(d.reads))
+	for i, r := range d.reads {
+		paths[i] = r.path
+	}
+	return fmt.Sprintf("vault.read.batch(%s)", strings.Join(paths, ","))
+}
+
+// Stringer interface reuses ID
+func (d *VaultReadBatchQuery) String() string {
+	return d.ID()
+}
+
+func (d *VaultReadBatchQuery) SetOptions(opts QueryOptions) {
+	d.opts = opts
+}
@@ -0,0 +1,111 @@
+package dependency
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewVaultReadBatchQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		paths []string
+		exp   []string
+		err   bool
+	}{
+		{
+			"empty",
+			nil,
+			nil,
+			true,
+		},
+		{
+			"bad_path",
+			[]string{"secret/foo", ""},
+			nil,
+			true,
+		},
+		{
+			"sorted_deduped",
+			[]string{"secret/foo", "/secret/bar/", "secret/foo"},
+			[]string{"secret/bar", "secret/foo"},
+			false,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewVaultReadBatchQuery(tc.paths)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if act == nil {
+				return
+			}
+			var paths []string
+			for _, r := range act.reads {
+				paths = append(paths, r.path)
+			}
+			assert.Equal(t, tc.exp, paths)
+		})
+	}
+}
+
+func TestVaultReadBatchQuery_Fetch(t *testing.T) {
+	t.Parallel()
+
+	clients, vault := testVaultServer(t, "read_batch_fetch", "1")
+	secretsPath := vault.secretsPath
+	for _, k := range []string{"foo", "bar"} {
+		err := vault.CreateSecret(k, map[string]interface{}{"zip": k})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("exists", func(t *testing.T) {
+		d, err := NewVaultReadBatchQuery([]string{
+			secretsPath + "/foo", secretsPath + "/bar"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		act, _, err := d.Fetch(clients)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secrets := act.(map[string]*dep.Secret)
+		assert.Len(t, secrets, 2)
+		for _, k := range []string{"foo", "bar"} {
+			s, ok := secrets[secretsPath+"/"+k]
+			if !ok {
+				t.Fatalf("missing secret: %s", k)
+			}
+			assert.Equal(t, k, s.Data["zip"])
+		}
+	})
+
+	t.Run("no_exist", func(t *testing.T) {
+		d, err := NewVaultReadBatchQuery([]string{
+			secretsPath + "/foo", secretsPath + "/nope"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := d.Fetch(clients); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestVaultReadBatchQuery_String(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewVaultReadBatchQuery([]string{"secret/foo", "secret/bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "vault.read.batch(secret/bar,secret/foo)", d.ID())
+}
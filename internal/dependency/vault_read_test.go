@@ -68,6 +68,24 @@ func TestNewVaultReadQuery(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"kv_version",
+			"path?version=3&kv_version=2",
+			&VaultReadQuery{
+				rawPath: "path",
+				queryValues: url.Values{
+					"version": []string{"3"},
+				},
+				kvVersion: "2",
+			},
+			false,
+		},
+		{
+			"invalid_kv_version",
+			"path?kv_version=3",
+			nil,
+			true,
+		},
 	}
 
 	for i, tc := range cases {
@@ -658,6 +676,16 @@ func TestVaultReadQuery_String(t *testing.T) {
 			"path",
 			"vault.read(path)",
 		},
+		{
+			"version",
+			"path?version=3",
+			"vault.read(path.v3)",
+		},
+		{
+			"kv_version",
+			"path?version=3&kv_version=2",
+			"vault.read(path.v3?kv_version=2)",
+		},
 	}
 
 	for i, tc := range cases {
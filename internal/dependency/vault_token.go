@@ -47,7 +47,7 @@ func (d *VaultTokenQuery) Fetch(clients dep.Clients) (interface{}, *dep.Response
 	}
 
 	if vaultSecretRenewable(d.secret) {
-		err := renewSecret(clients, d)
+		err := renewSecret(clients, d, nil)
 		if err != nil {
 			return nil, nil, errors.Wrap(err, d.ID())
 		}
@@ -0,0 +1,144 @@
+package dependency
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ isDependency = (*VaultTOTPQuery)(nil)
+)
+
+const (
+	// VaultTOTPDefaultMount is the default mount path of the TOTP engine.
+	VaultTOTPDefaultMount = "totp"
+	// VaultTOTPDefaultPeriod is the default period of a TOTP key's codes,
+	// that of Vault's keys unless created with another.
+	VaultTOTPDefaultPeriod = 30 * time.Second
+
+	// totpBoundaryDelay is how long after a period's boundary the code is
+	// fetched, so clock skew with Vault doesn't return the previous code.
+	totpBoundaryDelay = time.Second
+)
+
+// VaultTOTPQuery is the dependency to Vault for the current code of a key of
+// the TOTP secrets engine. The code changes every period, so it is fetched
+// again at each period boundary (TOTP periods start at multiples of the
+// period since the Unix epoch).
+type VaultTOTPQuery struct {
+	isVault
+	stopCh chan struct{}
+
+	mount  string
+	name   string
+	period time.Duration
+	opts   QueryOptions
+}
+
+// NewVaultTOTPQuery creates a new dependency on the code of the key of the
+// TOTP engine's mount, whose codes are valid for the period. An empty mount
+// uses VaultTOTPDefaultMount and a zero period VaultTOTPDefaultPeriod.
+func NewVaultTOTPQuery(mount, name string, period time.Duration) (
+	*VaultTOTPQuery, error) {
+	mount = strings.Trim(strings.TrimSpace(mount), "/")
+	if mount == "" {
+		mount = VaultTOTPDefaultMount
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("vault.totp: invalid key name: %q", name)
+	}
+	if period < 0 || (period > 0 && period < time.Second) {
+		return nil, fmt.Errorf("vault.totp: invalid period: %s", period)
+	}
+
+	return &VaultTOTPQuery{
+		stopCh: make(chan struct{}, 1),
+		mount:  mount,
+		name:   name,
+		period: period,
+	}, nil
+}
+
+// Fetch queries the Vault API
+func (d *VaultTOTPQuery) Fetch(clients dep.Clients) (interface{}, *dep.ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	opts := d.opts.Merge(&QueryOptions{})
+
+	// If this is not the first query, wait for the next code.
+	if opts.WaitIndex != 0 {
+		select {
+		case <-d.stopCh:
+			return nil, nil, ErrStopped
+		case <-time.After(d.untilNextPeriod(time.Now())):
+		case <-opts.done():
+		}
+	}
+
+	secret, err := clients.Vault().Logical().Read(d.mount + "/code/" + d.name)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.ID())
+	}
+	if secret == nil {
+		return nil, nil, fmt.Errorf("%s: no key %q", d.ID(), d.name)
+	}
+	code, ok := secret.Data["code"].(string)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: no code returned", d.ID())
+	}
+
+	return respWithMetadata(code)
+}
+
+// untilNextPeriod returns the time from now until the next period starts,
+// plus the totpBoundaryDelay.
+func (d *VaultTOTPQuery) untilNextPeriod(now time.Time) time.Duration {
+	period := d.period
+	if period == 0 {
+		period = VaultTOTPDefaultPeriod
+	}
+	elapsed := time.Duration(now.UnixNano()) % period
+	return period - elapsed + totpBoundaryDelay
+}
+
+// CanShare returns if this dependency is shareable.
+func (d *VaultTOTPQuery) CanShare() bool {
+	return true
+}
+
+// Stop halts the given dependency's fetch.
+func (d *VaultTOTPQuery) Stop() {
+	close(d.stopCh)
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *VaultTOTPQuery) ID() string {
+	if d.period != 0 {
+		return fmt.Sprintf("vault.totp(%s/%s|%s)", d.mount, d.name, d.period)
+	}
+	return fmt.Sprintf("vault.totp(%s/%s)", d.mount, d.name)
+}
+
+// Stringer interface reuses ID
+func (d *VaultTOTPQuery) String() string {
+	return d.ID()
+}
+
+// VaultPath returns the path of the key's code.
+func (d *VaultTOTPQuery) VaultPath() string {
+	return d.mount + "/code/" + d.name
+}
+
+func (d *VaultTOTPQuery) SetOptions(opts QueryOptions) {
+	d.opts = opts
+}
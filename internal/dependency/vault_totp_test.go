@@ -0,0 +1,149 @@
+package dependency
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewVaultTOTPQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		mount  string
+		key    string
+		period time.Duration
+		exp    *VaultTOTPQuery
+		err    bool
+	}{
+		{
+			"default_mount",
+			"",
+			"vpn",
+			0,
+			&VaultTOTPQuery{mount: "totp", name: "vpn"},
+			false,
+		},
+		{
+			"mount_period",
+			"/otp/",
+			"vpn",
+			time.Minute,
+			&VaultTOTPQuery{mount: "otp", name: "vpn", period: time.Minute},
+			false,
+		},
+		{
+			"empty_name",
+			"",
+			"",
+			0,
+			nil,
+			true,
+		},
+		{
+			"bad_name",
+			"",
+			"a/b",
+			0,
+			nil,
+			true,
+		},
+		{
+			"bad_period",
+			"",
+			"vpn",
+			time.Millisecond,
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewVaultTOTPQuery(tc.mount, tc.key, tc.period)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestVaultTOTPQuery_untilNextPeriod(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewVaultTOTPQuery("", "vpn", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1000*30+12, 0) // 12s into a 30s period
+	assert.Equal(t, 18*time.Second+totpBoundaryDelay, d.untilNextPeriod(now))
+}
+
+func TestVaultTOTPQuery_Fetch(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/totp/code/vpn" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(`{"data": {"code": "123456"}}`))
+		}))
+	defer srv.Close()
+
+	clients := NewClientSet()
+	defer clients.Stop()
+	err := clients.CreateVaultClient(&CreateClientInput{
+		Address: srv.URL,
+		Token:   "token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewVaultTOTPQuery("", "vpn", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	act, _, err := d.Fetch(clients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "123456", act)
+
+	d, err = NewVaultTOTPQuery("", "missing", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := d.Fetch(clients); err == nil {
+		t.Fatal("expected error for a missing key")
+	}
+}
+
+func TestVaultTOTPQuery_String(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewVaultTOTPQuery("", "vpn", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "vault.totp(totp/vpn)", d.ID())
+
+	d, err = NewVaultTOTPQuery("otp", "vpn", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "vault.totp(otp/vpn|1m0s)", d.ID())
+}
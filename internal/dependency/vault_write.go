@@ -60,14 +60,21 @@ func (d *VaultWriteQuery) Fetch(clients dep.Clients) (interface{}, *dep.Response
 	}
 	select {
 	case dur := <-d.sleepCh:
-		time.Sleep(dur)
+		select {
+		case <-time.After(dur):
+			if hasLease(d.secret) {
+				d.opts.observeLease(leaseEvent(dep.LeaseExpiring, d.ID(), d.secret))
+			}
+		case <-d.opts.done():
+			// refreshed, read it again now
+		}
 	default:
 	}
 
 	firstRun := d.secret == nil
 
 	if !firstRun && vaultSecretRenewable(d.secret) {
-		err := renewSecret(clients, d)
+		err := renewSecret(clients, d, &d.opts)
 		if err != nil {
 			return nil, nil, errors.Wrap(err, d.ID())
 		}
@@ -87,9 +94,12 @@ func (d *VaultWriteQuery) Fetch(clients dep.Clients) (interface{}, *dep.Response
 	d.vaultSecret = vaultSecret
 	// cloned secret which will be exposed to the template
 	d.secret = transformSecret(vaultSecret, opts.DefaultLease)
+	if hasLease(d.secret) {
+		d.opts.observeLease(leaseEvent(dep.LeaseAcquired, d.ID(), d.secret))
+	}
 
 	if !vaultSecretRenewable(d.secret) {
-		dur := leaseCheckWait(d.secret)
+		dur := capWait(leaseCheckWait(d.secret), d.opts.MaxAge)
 		d.sleepCh <- dur
 	}
 
@@ -125,6 +135,11 @@ func (d *VaultWriteQuery) String() string {
 	return d.ID()
 }
 
+// VaultPath returns the secret's path.
+func (d *VaultWriteQuery) VaultPath() string {
+	return d.path
+}
+
 // sha1Map returns the sha1 hash of the data in the map. The reason this data is
 // hashed is because it appears in the output and could contain sensitive
 // information.
@@ -147,7 +162,7 @@ func (d *VaultWriteQuery) writeSecret(clients dep.Clients, opts *QueryOptions) (
 	path := d.path
 	data := d.data
 
-	mountPath, isv2, _ := isKVv2(clients.Vault(), path)
+	mountPath, isv2, _ := kvMountInfo(clients.Vault(), path)
 	if isv2 {
 		path = shimKVv2Path(path, mountPath)
 		data = map[string]interface{}{"data": d.data}
@@ -2,14 +2,22 @@ package hcat
 
 import (
 	"net/http"
+	"net/url"
 	"os"
 	"sync"
 	"time"
 
+	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/hcat/dep"
+	"github.com/hashicorp/hcat/events"
 	idep "github.com/hashicorp/hcat/internal/dependency"
+	"github.com/pkg/errors"
 )
 
+// ErrKVCASConflict is returned by KVCAS when the key was modified since the
+// index it was read at.
+var ErrKVCASConflict = errors.New("kv cas conflict")
+
 // Looker is an interface for looking up data from Consul, Vault and the
 // Environment.
 type Looker interface {
@@ -52,6 +60,43 @@ func (cs *ClientSet) AddVault(i VaultInput) error {
 	return cs.CreateVaultClient(i.toInternal())
 }
 
+// AddConsulNamed creates a Consul client and adds it to the client set under
+// the given name. Named clients are in addition to the default client added
+// with AddConsul and are referenced by name from template functions (eg.
+// serviceFrom) to query clusters that aren't federated.
+func (cs *ClientSet) AddConsulNamed(name string, i ConsulInput) error {
+	return cs.CreateNamedConsulClient(name, i.toInternal())
+}
+
+// AddVaultNamed creates a Vault client and adds it to the client set under the
+// given name. See AddConsulNamed.
+func (cs *ClientSet) AddVaultNamed(name string, i VaultInput) error {
+	return cs.CreateNamedVaultClient(name, i.toInternal())
+}
+
+// SetEventHandler sets the handler of the clients' connection events,
+// ClientConnected, ClientDisconnected and ClientAuthFailed. Use with Lazy
+// clients and Connect to follow the backends becoming reachable.
+func (cs *ClientSet) SetEventHandler(handler events.EventHandler) {
+	if handler == nil {
+		cs.SetStateHandler(nil)
+		return
+	}
+	cs.SetStateHandler(func(backend, name string, state idep.ClientState,
+		err error) {
+		switch state {
+		case idep.ClientConnected:
+			handler(events.ClientConnected{Backend: backend, Name: name})
+		case idep.ClientDisconnected:
+			handler(events.ClientDisconnected{
+				Backend: backend, Name: name, Error: err})
+		case idep.ClientAuthFailed:
+			handler(events.ClientAuthFailed{
+				Backend: backend, Name: name, Error: err})
+		}
+	})
+}
+
 // Stop closes all idle connections for any attached clients and clears
 // the list of injected environment variables.
 func (cs *ClientSet) Stop() {
@@ -79,6 +124,43 @@ func (cs *ClientSet) Env() []string {
 	return append(os.Environ(), cs.injectedEnv...)
 }
 
+// KVWrite writes the value to the Consul KV key. It is for notifier and
+// callback code recording render state (eg. "rendered at index X") back into
+// KV, it isn't available to templates. Use KVCAS when there can be concurrent
+// writers.
+func (cs *ClientSet) KVWrite(key string, value []byte) error {
+	client := cs.Consul()
+	if client == nil {
+		return errors.New("kv write: no consul client")
+	}
+	pair := &api.KVPair{Key: key, Value: value}
+	if _, err := client.KV().Put(pair, nil); err != nil {
+		return errors.Wrapf(err, "kv write: %s", key)
+	}
+	return nil
+}
+
+// KVCAS writes the value to the Consul KV key with check-and-set semantics,
+// only if the key's ModifyIndex is still index (eg. from its KeyPair). Index 0
+// only writes the key if it doesn't exist. It returns ErrKVCASConflict if the
+// key was modified in between, read it again and retry.
+func (cs *ClientSet) KVCAS(key string, value []byte, index uint64) error {
+	client := cs.Consul()
+	if client == nil {
+		return errors.New("kv cas: no consul client")
+	}
+	pair := &api.KVPair{Key: key, Value: value, ModifyIndex: index}
+	ok, _, err := client.KV().CAS(pair, nil)
+	if err != nil {
+		return errors.Wrapf(err, "kv cas: %s", key)
+	}
+	if !ok {
+		return errors.Wrapf(ErrKVCASConflict, "kv cas: %s at index %d", key,
+			index)
+	}
+	return nil
+}
+
 // Input wrappers around internal structure. Going to rework the internal
 // structure, so this abstracts that away to make that workable.
 
@@ -88,7 +170,18 @@ type VaultInput struct {
 	Namespace   string
 	Token       string
 	UnwrapToken bool
-	Transport   TransportInput
+	// AgentTokenFile is the file a Vault Agent's auto-auth sink writes the
+	// token to, used instead of Token. It is read again whenever the agent
+	// writes a new token, so the agent handles all the authentication.
+	AgentTokenFile string
+	// AgentProxy is set when Address is a Vault Agent's API proxy listener,
+	// which adds its own auto-auth token to the requests. Address defaults to
+	// the VAULT_AGENT_ADDR environment variable.
+	AgentProxy bool
+	// Lazy creates the client without contacting Vault, token unwrapping and
+	// reading the AgentTokenFile are left to the ClientSet's Connect.
+	Lazy      bool
+	Transport TransportInput
 	// optional, principally for testing
 	HttpClient *http.Client
 }
@@ -99,6 +192,10 @@ func (i VaultInput) toInternal() *idep.CreateClientInput {
 		Namespace:   i.Namespace,
 		Token:       i.Token,
 		UnwrapToken: i.UnwrapToken,
+
+		VaultAgentTokenFile: i.AgentTokenFile,
+		VaultAgentProxy:     i.AgentProxy,
+		Lazy:                i.Lazy,
 	}
 	return i.Transport.toInternal(cci)
 }
@@ -111,7 +208,10 @@ type ConsulInput struct {
 	AuthEnabled  bool
 	AuthUsername string
 	AuthPassword string
-	Transport    TransportInput
+	// Lazy creates the client without waiting for the Consul cluster to have
+	// a leader, which is left to the ClientSet's Connect.
+	Lazy      bool
+	Transport TransportInput
 	// optional, principally for testing
 	HttpClient *http.Client
 }
@@ -124,6 +224,7 @@ func (i ConsulInput) toInternal() *idep.CreateClientInput {
 		AuthEnabled:  i.AuthEnabled,
 		AuthUsername: i.AuthUsername,
 		AuthPassword: i.AuthPassword,
+		Lazy:         i.Lazy,
 	}
 	return i.Transport.toInternal(cci)
 }
@@ -145,8 +246,27 @@ type TransportInput struct {
 	MaxIdleConns        int
 	MaxIdleConnsPerHost int
 	TLSHandshakeTimeout time.Duration
+	// FetchTimeout is a hard deadline for each request, in addition to the
+	// wait time of Consul's blocking queries, so hung connections are
+	// detected and retried. Zero (the default) disables it.
+	FetchTimeout time.Duration
+
+	// Proxy returns the proxy to use for a request (eg. http.ProxyURL with an
+	// http(s) or socks5 URL). Defaults to http.ProxyFromEnvironment.
+	Proxy func(*http.Request) (*url.URL, error)
+	// CustomDialer replaces the dialer, and so the DialKeepAlive and
+	// DialTimeout settings, used to open connections.
+	CustomDialer TransportDialer
+	// RoundTripper, if set, is used instead of a transport built from the
+	// above settings (FetchTimeout excepted), eg. to add mTLS or middleware.
+	// ReloadTLS leaves it as is.
+	RoundTripper http.RoundTripper
 }
 
+// TransportDialer is a custom dialer for the TransportInput, its DialContext
+// matches that of net.Dialer.
+type TransportDialer = idep.TransportDialer
+
 func (i TransportInput) toInternal(cci *idep.CreateClientInput) *idep.CreateClientInput {
 	cci.SSLEnabled = i.SSLEnabled
 	cci.SSLVerify = i.SSLVerify
@@ -162,5 +282,9 @@ func (i TransportInput) toInternal(cci *idep.CreateClientInput) *idep.CreateClie
 	cci.TransportMaxIdleConns = i.MaxIdleConns
 	cci.TransportMaxIdleConnsPerHost = i.MaxIdleConnsPerHost
 	cci.TransportTLSHandshakeTimeout = i.TLSHandshakeTimeout
+	cci.TransportFetchTimeout = i.FetchTimeout
+	cci.TransportProxy = i.Proxy
+	cci.TransportCustomDialer = i.CustomDialer
+	cci.TransportRoundTripper = i.RoundTripper
 	return cci
 }
@@ -1,12 +1,18 @@
 package hcat
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/hashicorp/hcat/events"
+	"github.com/pkg/errors"
 )
 
 func TestClientSet(t *testing.T) {
@@ -37,6 +43,101 @@ func TestClientSet(t *testing.T) {
 		}
 	})
 
+	t.Run("kv-write", func(t *testing.T) {
+		// fake consul KV with the key "foo" at ModifyIndex 5
+		var value string
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/kv/foo" || r.Method != http.MethodPut {
+					fmt.Fprint(w, `"test"`)
+					return
+				}
+				if cas := r.URL.Query().Get("cas"); cas != "" && cas != "5" {
+					fmt.Fprint(w, "false")
+					return
+				}
+				body, _ := ioutil.ReadAll(r.Body)
+				value = string(body)
+				fmt.Fprint(w, "true")
+			}))
+		defer ts.Close()
+
+		cs := NewClientSet()
+		defer cs.Stop()
+		if err := cs.KVWrite("foo", []byte("bar")); err == nil {
+			t.Fatal("expected error without a consul client")
+		}
+		err := cs.AddConsul(ConsulInput{Address: ts.Listener.Addr().String()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := cs.KVWrite("foo", []byte("bar")); err != nil {
+			t.Fatal(err)
+		}
+		if value != "bar" {
+			t.Errorf("bad value: %q", value)
+		}
+		if err := cs.KVCAS("foo", []byte("baz"), 5); err != nil {
+			t.Fatal(err)
+		}
+		if value != "baz" {
+			t.Errorf("bad value: %q", value)
+		}
+		err = cs.KVCAS("foo", []byte("zip"), 4)
+		if errors.Cause(err) != ErrKVCASConflict {
+			t.Fatalf("bad error: %v", err)
+		}
+		if value != "baz" {
+			t.Errorf("bad value: %q", value)
+		}
+	})
+
+	t.Run("lazy-connect", func(t *testing.T) {
+		cs := NewClientSet()
+		defer cs.Stop()
+		var evs []events.Event
+		cs.SetEventHandler(func(e events.Event) { evs = append(evs, e) })
+
+		// the server isn't up yet, the lazy client doesn't need it
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := ln.Addr().String()
+		ln.Close()
+		err = cs.AddConsul(ConsulInput{Address: addr, Lazy: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(),
+			100*time.Millisecond)
+		defer cancel()
+		if err := cs.Connect(ctx); err == nil {
+			t.Fatal("expected error connecting to a down server")
+		}
+
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `"leader"`)
+			}))
+		ts.Listener, _ = net.Listen("tcp", addr)
+		ts.Start()
+		defer ts.Close()
+		if err := cs.Connect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(evs) != 2 {
+			t.Fatalf("bad events: %#v", evs)
+		}
+		if e, ok := evs[0].(events.ClientDisconnected); !ok || e.Error == nil {
+			t.Errorf("bad event: %#v", evs[0])
+		}
+		if evs[1] != (events.ClientConnected{Backend: ConsulBackend}) {
+			t.Errorf("bad event: %#v", evs[1])
+		}
+	})
+
 	t.Run("env", func(t *testing.T) {
 		cs := NewClientSet()
 		defer cs.Stop()
@@ -0,0 +1,205 @@
+package hcat
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// check for interface compliance
+var (
+	_ DependencyNotifier = (*PatternNotifier)(nil)
+	_ DependencyNotifier = (*IgnoreFieldsNotifier)(nil)
+)
+
+// PatternNotifier is a Template that is only notified of new data, and so
+// only re-rendered, when a dependency whose ID matches one of its patterns
+// delivers data. Data from the other dependencies is still tracked and used,
+// it just doesn't trigger a render on its own.
+//
+// It covers the common gating patterns, eg. only render when a KV key used
+// as a trigger changes, without writing a custom Notifier.
+type PatternNotifier struct {
+	*Template
+	patterns []*regexp.Regexp
+}
+
+// PatternNotifierInput is the input for NewPatternNotifier.
+type PatternNotifierInput struct {
+	// Template is the template to notify.
+	Template *Template
+
+	// Patterns are glob patterns matched against the dependency IDs, `*`
+	// matches any sequence of characters and `?` any single character. Eg.
+	// `kv.block(notify*)`.
+	Patterns []string
+
+	// Regexps are regular expressions matched against the dependency IDs.
+	Regexps []string
+}
+
+// NewPatternNotifier returns a new PatternNotifier. It errors if there are no
+// patterns or one of them is invalid.
+func NewPatternNotifier(i PatternNotifierInput) (*PatternNotifier, error) {
+	if i.Template == nil {
+		return nil, fmt.Errorf("pattern notifier: template required")
+	}
+	if len(i.Patterns) == 0 && len(i.Regexps) == 0 {
+		return nil, fmt.Errorf("pattern notifier: no patterns")
+	}
+
+	n := &PatternNotifier{Template: i.Template}
+	for _, p := range i.Patterns {
+		n.patterns = append(n.patterns, globRegexp(p))
+	}
+	for _, p := range i.Regexps {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("pattern notifier: %s", err)
+		}
+		n.patterns = append(n.patterns, re)
+	}
+	return n, nil
+}
+
+// NotifyDependency notifies the template if the dependency's ID matches one
+// of the patterns.
+func (n *PatternNotifier) NotifyDependency(id string, data interface{}) bool {
+	if !n.Matches(id) {
+		return false
+	}
+	return n.Template.Notify(data)
+}
+
+// Matches returns true if the dependency ID matches one of the patterns.
+func (n *PatternNotifier) Matches(id string) bool {
+	for _, re := range n.patterns {
+		if re.MatchString(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// globRegexp converts the glob pattern to an anchored regular expression.
+func globRegexp(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString(`\A`)
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString(`\z`)
+	return regexp.MustCompile(b.String())
+}
+
+// IgnoreFieldsNotifier is a Template that isn't notified of new data when the
+// only changes are to fields it ignores. Use it to avoid no-op re-renders
+// caused by noisy fields the template doesn't use, eg. the ModifyIndex of
+// KV pairs or the Output of health checks.
+//
+// The data is compared as JSON, with the ignored fields (at any depth)
+// removed. Data that can't be encoded as JSON always notifies. Ignored
+// changes are still stored and used the next time the template is rendered.
+type IgnoreFieldsNotifier struct {
+	*Template
+	fields map[string]bool
+
+	mu   sync.Mutex
+	last map[string]interface{} // by dependency ID
+}
+
+// IgnoreFieldsNotifierInput is the input for NewIgnoreFieldsNotifier.
+type IgnoreFieldsNotifierInput struct {
+	// Template is the template to notify.
+	Template *Template
+
+	// Fields are the names of the fields to ignore. They are matched against
+	// the JSON names of the fields, which are the Go field names for the
+	// dependency types.
+	Fields []string
+}
+
+// NewIgnoreFieldsNotifier returns a new IgnoreFieldsNotifier. It errors if
+// there are no fields to ignore.
+func NewIgnoreFieldsNotifier(i IgnoreFieldsNotifierInput) (
+	*IgnoreFieldsNotifier, error) {
+	if i.Template == nil {
+		return nil, fmt.Errorf("ignore fields notifier: template required")
+	}
+	if len(i.Fields) == 0 {
+		return nil, fmt.Errorf("ignore fields notifier: no fields")
+	}
+
+	n := &IgnoreFieldsNotifier{
+		Template: i.Template,
+		fields:   make(map[string]bool, len(i.Fields)),
+		last:     make(map[string]interface{}),
+	}
+	for _, f := range i.Fields {
+		n.fields[f] = true
+	}
+	return n, nil
+}
+
+// NotifyDependency notifies the template unless the data is the same as the
+// dependency's previous data, apart from the ignored fields.
+func (n *IgnoreFieldsNotifier) NotifyDependency(id string, data interface{}) bool {
+	filtered, ok := n.filter(data)
+
+	n.mu.Lock()
+	last, seen := n.last[id]
+	if ok {
+		n.last[id] = filtered
+	} else {
+		delete(n.last, id)
+	}
+	n.mu.Unlock()
+
+	if ok && seen && reflect.DeepEqual(last, filtered) {
+		return false
+	}
+	return n.Template.Notify(data)
+}
+
+// filter returns the data as generic JSON values with the ignored fields
+// removed. Returns false if it can't be encoded.
+func (n *IgnoreFieldsNotifier) filter(data interface{}) (interface{}, bool) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, false
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, false
+	}
+	n.strip(v)
+	return v, true
+}
+
+// strip removes the ignored fields from the generic JSON value, in place.
+func (n *IgnoreFieldsNotifier) strip(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if n.fields[k] {
+				delete(v, k)
+				continue
+			}
+			n.strip(e)
+		}
+	case []interface{}:
+		for _, e := range v {
+			n.strip(e)
+		}
+	}
+}
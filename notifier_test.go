@@ -0,0 +1,146 @@
+package hcat
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+func TestPatternNotifier(t *testing.T) {
+	t.Parallel()
+
+	t.Run("matches", func(t *testing.T) {
+		n, err := NewPatternNotifier(PatternNotifierInput{
+			Template: NewTemplate(TemplateInput{}),
+			Patterns: []string{"kv.block(notify*)"},
+			Regexps:  []string{`^health\.service\(web`},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for id, exp := range map[string]bool{
+			"kv.block(notify)":                true,
+			"kv.block(notify/app)":            true,
+			"kv.block(other)":                 false,
+			"xkv.block(notify)":               false,
+			"health.service(web|passing)":     true,
+			"health.service(db|passing)":      false,
+			"catalog.services(notify)":        false,
+			"kv.block(notify)@dc1 not really": false,
+		} {
+			if act := n.Matches(id); act != exp {
+				t.Errorf("%s: exp: %v, act: %v", id, exp, act)
+			}
+		}
+	})
+
+	t.Run("bad-input", func(t *testing.T) {
+		_, err := NewPatternNotifier(PatternNotifierInput{
+			Template: NewTemplate(TemplateInput{}),
+		})
+		if err == nil {
+			t.Error("expected error for no patterns")
+		}
+		_, err = NewPatternNotifier(PatternNotifierInput{
+			Template: NewTemplate(TemplateInput{}),
+			Regexps:  []string{"("},
+		})
+		if err == nil {
+			t.Error("expected error for bad regexp")
+		}
+		_, err = NewPatternNotifier(PatternNotifierInput{
+			Patterns: []string{"*"},
+		})
+		if err == nil {
+			t.Error("expected error for missing template")
+		}
+	})
+
+	t.Run("watcher-notify", func(t *testing.T) {
+		w := NewWatcher(WatcherInput{Cache: NewStore()})
+		defer w.Stop()
+		tmpl := NewTemplate(TemplateInput{})
+		n, err := NewPatternNotifier(PatternNotifierInput{
+			Template: tmpl,
+			Patterns: []string{"test_dep(trigger)"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Register(n)
+		tmpl.isDirty() // clear initial dirty flag
+
+		other := w.track(n, &idep.FakeDep{Name: "other"})
+		if w.notify(n, other, nil) || tmpl.isDirty() {
+			t.Error("non-matching dependency shouldn't notify")
+		}
+		trigger := w.track(n, &idep.FakeDep{Name: "trigger"})
+		if !w.notify(n, trigger, nil) || !tmpl.isDirty() {
+			t.Error("matching dependency should notify")
+		}
+	})
+}
+
+func TestIgnoreFieldsNotifier(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ignores-fields", func(t *testing.T) {
+		tmpl := NewTemplate(TemplateInput{})
+		n, err := NewIgnoreFieldsNotifier(IgnoreFieldsNotifierInput{
+			Template: tmpl,
+			Fields:   []string{"ModifyIndex", "Output"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl.isDirty() // clear initial dirty flag
+
+		pair := func(value string, index uint64) []*dep.KeyPair {
+			return []*dep.KeyPair{{Key: "foo", Value: value, ModifyIndex: index}}
+		}
+		checks := func(status, output string) *dep.HealthService {
+			return &dep.HealthService{Name: "web", Checks: api.HealthChecks{
+				{CheckID: "check", Status: status, Output: output}}}
+		}
+		cases := []struct {
+			name string
+			id   string
+			data interface{}
+			exp  bool
+		}{
+			{"first", "kv", pair("bar", 1), true},
+			{"ignored-change", "kv", pair("bar", 2), false},
+			{"change", "kv", pair("baz", 3), true},
+			{"other-dependency", "health", checks("passing", "ok"), true},
+			{"nested-ignored-change", "health", checks("passing", "ok 2"), false},
+			{"nested-change", "health", checks("critical", "ok 2"), true},
+			{"not-json", "func", func() {}, true},
+			{"not-json-again", "func", func() {}, true},
+		}
+		for _, tc := range cases {
+			if act := n.NotifyDependency(tc.id, tc.data); act != tc.exp {
+				t.Errorf("%s: exp: %v, act: %v", tc.name, tc.exp, act)
+			}
+			if dirty := tmpl.isDirty(); dirty != tc.exp {
+				t.Errorf("%s: dirty exp: %v, act: %v", tc.name, tc.exp, dirty)
+			}
+		}
+	})
+
+	t.Run("bad-input", func(t *testing.T) {
+		_, err := NewIgnoreFieldsNotifier(IgnoreFieldsNotifierInput{
+			Template: NewTemplate(TemplateInput{}),
+		})
+		if err == nil {
+			t.Error("expected error for no fields")
+		}
+		_, err = NewIgnoreFieldsNotifier(IgnoreFieldsNotifierInput{
+			Fields: []string{"ModifyIndex"},
+		})
+		if err == nil {
+			t.Error("expected error for missing template")
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package hcat
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+// redacted replaces the redacted Vault data, see RecorderInput's RedactVault
+const redacted = "<redacted>"
+
+// Recorder captures the result of every dependency fetched by a Watcher (see
+// WatcherInput's Recorder), keyed by dependency ID, for debugging production
+// renders. Its snapshots are the fixtures replayed with a FixtureWatcher (see
+// LoadFixture). Unlike the Watcher's cache it also records the data kept out
+// of the cache by a VaultCachePolicy and isn't subject to its eviction
+// policies. It is safe for concurrent use.
+type Recorder struct {
+	store       *Store
+	redactVault bool
+}
+
+// RecorderInput is the input structure for NewRecorder.
+type RecorderInput struct {
+	// RedactVault replaces the values of the Vault secrets' Data, and their
+	// tokens, with "<redacted>" so the snapshots can be shared. The keys and
+	// structure of the secrets are kept so the templates still render. Vault
+	// data of other types, except lists of keys, isn't recorded.
+	RedactVault bool
+}
+
+// NewRecorder returns a new, empty Recorder.
+func NewRecorder(i RecorderInput) *Recorder {
+	return &Recorder{
+		store:       NewStore(),
+		redactVault: i.RedactVault,
+	}
+}
+
+// record saves the dependency's data, redacted if it is a Vault dependency
+// and RedactVault is set.
+func (r *Recorder) record(d dep.Dependency, data interface{}) {
+	if r.redactVault && dependencyClass(d) == VaultDependencies {
+		var ok bool
+		if data, ok = redactVault(data); !ok {
+			return
+		}
+	}
+	r.store.Save(d.ID(), data)
+}
+
+// Encode writes the recorded data to w with the codec, GobCodec if nil.
+func (r *Recorder) Encode(w io.Writer, codec StoreCodec) error {
+	s := NewStoreWithOptions(StoreOptions{Codec: codec})
+	r.store.RLock()
+	for id, data := range r.store.data {
+		s.data[id] = data
+	}
+	r.store.RUnlock()
+	return s.Encode(w)
+}
+
+// WriteFile atomically writes a snapshot of the recorded data to the file, a
+// fixture for LoadFixture. Files with a .json extension are encoded with
+// FixtureJSONCodec, others with GobCodec. The file is only readable by its
+// owner as it can hold secrets.
+func (r *Recorder) WriteFile(path string) error {
+	codec := GobCodec
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		codec = FixtureJSONCodec
+	}
+	var buf bytes.Buffer
+	if err := r.Encode(&buf, codec); err != nil {
+		return errors.Wrap(err, "recorder")
+	}
+	err := atomicWrite(path, buf.Bytes(), 0600, true,
+		writeOptions{sensitive: true})
+	return errors.Wrap(err, "recorder")
+}
+
+// redactVault returns the redacted copy of the Vault dependency's data, false
+// if it can't be redacted and shouldn't be recorded.
+func redactVault(data interface{}) (interface{}, bool) {
+	switch v := data.(type) {
+	case nil, []string:
+		return v, true
+	case *dep.Secret:
+		return redactSecret(v), true
+	case map[string]*dep.Secret:
+		secrets := make(map[string]*dep.Secret, len(v))
+		for k, s := range v {
+			secrets[k] = redactSecret(s)
+		}
+		return secrets, true
+	}
+	return nil, false
+}
+
+// redactSecret returns a copy of the secret with its data and tokens redacted
+func redactSecret(s *dep.Secret) *dep.Secret {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	if c.LeaseID != "" {
+		c.LeaseID = redacted
+	}
+	if s.Data != nil {
+		c.Data = redactValue(s.Data).(map[string]interface{})
+	}
+	if s.Auth != nil {
+		auth := *s.Auth
+		auth.ClientToken, auth.Accessor = redacted, redacted
+		c.Auth = &auth
+	}
+	if s.WrapInfo != nil {
+		info := *s.WrapInfo
+		info.Token, info.WrappedAccessor = redacted, redacted
+		c.WrapInfo = &info
+	}
+	return &c
+}
+
+// redactValue returns the value with its maps' keys, and the length of its
+// slices, kept and everything else redacted.
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = redactValue(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = redactValue(e)
+		}
+		return l
+	case nil:
+		return nil
+	}
+	return redacted
+}
@@ -0,0 +1,166 @@
+package hcat
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	foodep := &idep.FakeDep{Name: "foo"}
+	vaultdep := &idep.FakeVaultDep{Path: "secret/foo"}
+	kvdep := &idep.FakeDep{Name: "kv"}
+	secretdep := &idep.FakeVaultDep{Path: "secret/bar"}
+	secret := testVaultSecret(t)
+	every, _ := testTimerData(t)
+	record := func(i RecorderInput) *Recorder {
+		rec := NewRecorder(i)
+		w := NewWatcher(WatcherInput{
+			Clients:  NewClientSet(),
+			Cache:    NewStore(),
+			Recorder: rec,
+		})
+		defer w.Stop()
+		n := fakeNotifier("foo")
+		w.Register(n)
+		w.dataCh <- w.track(n, foodep).store("foo")
+		w.Wait(context.Background())
+		w.dataCh <- w.track(n, vaultdep).store("secret/foo")
+		w.Wait(context.Background())
+		w.dataCh <- w.track(n, kvdep).store(dep.KvValue("bar"))
+		w.Wait(context.Background())
+		w.dataCh <- w.track(n, secretdep).store(secret)
+		w.Wait(context.Background())
+		// the watcher stops the timer, a new one for each recording
+		everydep, _ := idep.NewEveryQuery("1m")
+		w.dataCh <- w.track(n, everydep).store(every)
+		w.Wait(context.Background())
+		return rec
+	}
+
+	for _, name := range []string{"snapshot.json", "snapshot.gob"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := record(RecorderInput{}).WriteFile(path); err != nil {
+				t.Fatal(err)
+			}
+			st, err := LoadFixture(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := st.Recall(foodep.ID()); data != "foo" {
+				t.Errorf("bad data: %#v", data)
+			}
+			if data, _ := st.Recall(vaultdep.ID()); data != "secret/foo" {
+				t.Errorf("bad vault data: %#v", data)
+			}
+			if data, _ := st.Recall(kvdep.ID()); data != dep.KvValue("bar") {
+				t.Errorf("bad kv data: %#v", data)
+			}
+			if data, _ := st.Recall(secretdep.ID()); !reflect.DeepEqual(data,
+				secret) {
+				t.Errorf("bad secret: %#v", data)
+			}
+			data, _ := st.Recall("every(1m)")
+			if tm, ok := data.(time.Time); !ok || !tm.Equal(every) {
+				t.Errorf("bad timer data: %#v", data)
+			}
+		})
+	}
+
+	for _, name := range []string{"redacted.json", "redacted.gob"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			rec := record(RecorderInput{RedactVault: true})
+			if err := rec.WriteFile(path); err != nil {
+				t.Fatal(err)
+			}
+			st, err := LoadFixture(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := st.Recall(foodep.ID()); data != "foo" {
+				t.Errorf("bad data: %#v", data)
+			}
+			// a string can't be redacted, it isn't recorded
+			if data, ok := st.Recall(vaultdep.ID()); ok {
+				t.Errorf("unexpected vault data: %#v", data)
+			}
+			data, _ := st.Recall(secretdep.ID())
+			s, _ := data.(*dep.Secret)
+			if s == nil {
+				t.Fatalf("bad secret: %#v", data)
+			}
+			kv, _ := s.Data["data"].(map[string]interface{})
+			if kv["password"] != redacted || kv["port"] != redacted {
+				t.Errorf("bad secret: %#v", data)
+			}
+		})
+	}
+}
+
+func TestRedactVault(t *testing.T) {
+	t.Parallel()
+
+	secret := &dep.Secret{
+		LeaseID:       "lease",
+		LeaseDuration: 60,
+		Data: map[string]interface{}{
+			"password": "hunter2",
+			"nested":   map[string]interface{}{"list": []interface{}{1, 2}},
+			"empty":    nil,
+		},
+		Auth: &dep.SecretAuth{ClientToken: "token", Accessor: "accessor",
+			Policies: []string{"default"}},
+	}
+	exp := &dep.Secret{
+		LeaseID:       redacted,
+		LeaseDuration: 60,
+		Data: map[string]interface{}{
+			"password": redacted,
+			"nested": map[string]interface{}{
+				"list": []interface{}{redacted, redacted}},
+			"empty": nil,
+		},
+		Auth: &dep.SecretAuth{ClientToken: redacted, Accessor: redacted,
+			Policies: []string{"default"}},
+	}
+
+	act, ok := redactVault(secret)
+	if !ok || !reflect.DeepEqual(act, exp) {
+		t.Errorf("bad secret: %#v", act)
+	}
+	if secret.Data["password"] != "hunter2" || secret.Auth.ClientToken != "token" {
+		t.Error("the secret was modified")
+	}
+
+	act, ok = redactVault(map[string]*dep.Secret{"a": secret})
+	if !ok || !reflect.DeepEqual(act, map[string]*dep.Secret{"a": exp}) {
+		t.Errorf("bad secrets: %#v", act)
+	}
+
+	if act, ok := redactVault([]string{"a", "b"}); !ok ||
+		!reflect.DeepEqual(act, []string{"a", "b"}) {
+		t.Errorf("bad list: %#v", act)
+	}
+	if _, ok := redactVault("code"); ok {
+		t.Error("expected a string not to be recorded")
+	}
+}
@@ -0,0 +1,98 @@
+package reload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// systemctl is the command Systemd runs, a variable for the tests
+var systemctl = "systemctl"
+
+// Signal returns the action sending the signal to the process with the PID.
+// A nil signal is SIGHUP, which isn't supported on windows.
+func Signal(pid int, sig os.Signal) *Action {
+	if sig == nil {
+		sig = defaultSignal
+	}
+	name := fmt.Sprintf("signal(%d, %v)", pid, sig)
+	return NewAction(name, func(context.Context) error {
+		return signal(pid, sig)
+	})
+}
+
+// PidFileSignal returns the action sending the signal to the process whose
+// PID is in the file, read each time it runs so it follows restarts. A nil
+// signal is SIGHUP, which isn't supported on windows.
+func PidFileSignal(path string, sig os.Signal) *Action {
+	if sig == nil {
+		sig = defaultSignal
+	}
+	name := fmt.Sprintf("signal(%s, %v)", path, sig)
+	return NewAction(name, func(context.Context) error {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrap(err, "reading pid file")
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil {
+			return errors.Wrapf(err, "invalid pid file %s", path)
+		}
+		return signal(pid, sig)
+	})
+}
+
+// signal sends the signal to the process
+func signal(pid int, sig os.Signal) error {
+	if sig == nil {
+		return fmt.Errorf("no signal set")
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Signal(sig)
+}
+
+// Systemd returns the action reloading the systemd unit, with "systemctl
+// reload".
+func Systemd(unit string) *Action {
+	name := fmt.Sprintf("systemd(%s)", unit)
+	return NewAction(name, func(ctx context.Context) error {
+		cmd := exec.CommandContext(ctx, systemctl, "reload", unit)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return errors.Wrapf(err, "systemctl reload %s: %s", unit,
+				bytes.TrimSpace(out))
+		}
+		return nil
+	})
+}
+
+// HTTPPost returns the action sending an empty POST request to the URL, eg.
+// a service's reload endpoint. Responses other than 2xx are errors.
+func HTTPPost(url string) *Action {
+	name := fmt.Sprintf("post(%s)", url)
+	return NewAction(name, func(ctx context.Context) error {
+		req, err := http.NewRequest(http.MethodPost, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("unexpected response: %s", resp.Status)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,86 @@
+//+build !windows
+
+package reload
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	ossignal "os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestActions(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("signal", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		pidFile := filepath.Join(dir, "pid")
+		err = ioutil.WriteFile(pidFile, []byte(fmt.Sprintf("%d\n",
+			os.Getpid())), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sigCh := make(chan os.Signal, 2)
+		ossignal.Notify(sigCh, syscall.SIGHUP)
+		defer ossignal.Stop(sigCh)
+		for _, a := range []*Action{
+			Signal(os.Getpid(), nil),
+			PidFileSignal(pidFile, syscall.SIGHUP),
+		} {
+			if err := a.run(ctx); err != nil {
+				t.Fatal(a, err)
+			}
+			select {
+			case <-sigCh:
+			case <-time.After(time.Second):
+				t.Fatal(a, "no signal received")
+			}
+		}
+
+		missing := PidFileSignal(filepath.Join(dir, "missing"), nil)
+		if err := missing.run(ctx); err == nil {
+			t.Error("expected error for a missing pid file")
+		}
+	})
+
+	t.Run("systemd", func(t *testing.T) {
+		defer func(cmd string) { systemctl = cmd }(systemctl)
+		systemctl = "true"
+		if err := Systemd("nginx.service").run(ctx); err != nil {
+			t.Error(err)
+		}
+		systemctl = "false"
+		if err := Systemd("nginx.service").run(ctx); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("http-post", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/reload" {
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+		defer srv.Close()
+
+		if err := HTTPPost(srv.URL + "/reload").run(ctx); err != nil {
+			t.Error(err)
+		}
+		if err := HTTPPost(srv.URL + "/other").run(ctx); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
@@ -0,0 +1,220 @@
+/*
+Package reload runs reload actions when templates render changed contents,
+eg. signaling the service using the rendered files or reloading its systemd
+unit.
+
+Actions are added to a Reloader by template ID and triggered with the
+templates' ResolveEvents (Handle) or explicitly (Trigger), eg. when a
+Renderer's RenderResult DidRender. Each action waits for the Reloader's
+Debounce after its last trigger before it runs, so a service using several
+templates that change together is reloaded once.
+
+	nginx := reload.PidFileSignal("/run/nginx.pid", syscall.SIGHUP)
+	r := reload.NewReloader(reload.ReloaderInput{Debounce: time.Second})
+	defer r.Stop()
+	r.Add(upstreams.ID(), nginx)
+	r.Add(certs.ID(), nginx)
+*/
+package reload
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/hcat"
+	"github.com/hashicorp/hcat/events"
+)
+
+// defaultTimeout is the default time limit of an action's run
+const defaultTimeout = 30 * time.Second
+
+// Action is a named reload action, see Signal, PidFileSignal, Systemd and
+// HTTPPost for the common ones. Actions are compared by pointer, templates
+// added with the same Action share its debounce.
+type Action struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// NewAction returns a custom action that calls run, with a context canceled
+// when the Reloader's Timeout passes or it is stopped.
+func NewAction(name string, run func(ctx context.Context) error) *Action {
+	return &Action{name: name, run: run}
+}
+
+// Name returns the name of the action, used in the events.
+func (a *Action) Name() string {
+	return a.name
+}
+
+// String returns the name of the action.
+func (a *Action) String() string {
+	return a.name
+}
+
+// Reloader runs the actions of the templates that rendered changes. It is
+// safe for concurrent use.
+type Reloader struct {
+	sync.Mutex
+	debounce time.Duration
+	timeout  time.Duration
+	event    events.EventHandler
+
+	actions map[string][]*Action // by template ID
+	pending map[*Action]*pending
+	stopped bool
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+// pending is a triggered action waiting for its debounce, and the templates
+// that triggered it.
+type pending struct {
+	timer     *time.Timer
+	templates map[string]struct{}
+}
+
+// ReloaderInput is the input structure for NewReloader.
+type ReloaderInput struct {
+	// Debounce is how long a triggered action waits before running, reset
+	// by each new trigger. Zero runs it right away.
+	Debounce time.Duration
+	// Timeout limits how long an action runs. Defaults to 30 seconds.
+	Timeout time.Duration
+	// EventHandler receives the Reloaded and ReloadFailed events (optional)
+	EventHandler events.EventHandler
+}
+
+// NewReloader returns a new Reloader with no actions.
+func NewReloader(i ReloaderInput) *Reloader {
+	timeout := i.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	eventHandler := i.EventHandler
+	if eventHandler == nil {
+		eventHandler = func(events.Event) {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Reloader{
+		debounce: i.Debounce,
+		timeout:  timeout,
+		event:    eventHandler,
+		actions:  make(map[string][]*Action),
+		pending:  make(map[*Action]*pending),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Add adds actions run when the template with the ID renders changes.
+func (r *Reloader) Add(id string, actions ...*Action) {
+	r.Lock()
+	defer r.Unlock()
+	r.actions[id] = append(r.actions[id], actions...)
+}
+
+// Handle triggers the actions of the event's template if the event has
+// changes to render: it is Complete, not NoChange and not a DryRun.
+func (r *Reloader) Handle(e hcat.ResolveEvent) {
+	if e.Complete && !e.NoChange && !e.DryRun {
+		r.Trigger(e.ID)
+	}
+}
+
+// Trigger triggers the actions of the template with the ID, each runs once
+// the Debounce passes without it being triggered again.
+func (r *Reloader) Trigger(id string) {
+	r.Lock()
+	defer r.Unlock()
+	if r.stopped {
+		return
+	}
+	for _, a := range r.actions[id] {
+		p, ok := r.pending[a]
+		if ok {
+			p.timer.Reset(r.debounce)
+		} else {
+			p = &pending{templates: make(map[string]struct{})}
+			a := a
+			p.timer = time.AfterFunc(r.debounce, func() { r.fire(a, p) })
+			r.pending[a] = p
+		}
+		p.templates[id] = struct{}{}
+	}
+}
+
+// fire runs the action when its debounce passes, unless it already ran.
+func (r *Reloader) fire(a *Action, p *pending) {
+	r.Lock()
+	if r.stopped || r.pending[a] != p {
+		r.Unlock()
+		return
+	}
+	delete(r.pending, a)
+	r.running.Add(1)
+	r.Unlock()
+
+	defer r.running.Done()
+	r.run(a, p)
+}
+
+// Flush runs the pending actions right away, without waiting for their
+// debounce, and returns when they and those already running are done. Eg.
+// before exiting after rendering the templates once.
+func (r *Reloader) Flush() {
+	r.Lock()
+	ps := make(map[*Action]*pending, len(r.pending))
+	for a, p := range r.pending {
+		p.timer.Stop()
+		ps[a] = p
+	}
+	r.pending = make(map[*Action]*pending)
+	r.running.Add(len(ps))
+	r.Unlock()
+
+	for a, p := range ps {
+		go func(a *Action, p *pending) {
+			defer r.running.Done()
+			r.run(a, p)
+		}(a, p)
+	}
+	r.running.Wait()
+}
+
+// Stop drops the pending actions and cancels those running, returning once
+// they are done. Triggers are ignored after Stop.
+func (r *Reloader) Stop() {
+	r.Lock()
+	r.stopped = true
+	for _, p := range r.pending {
+		p.timer.Stop()
+	}
+	r.pending = make(map[*Action]*pending)
+	r.Unlock()
+
+	r.cancel()
+	r.running.Wait()
+}
+
+// run runs the action, sending the event of its result.
+func (r *Reloader) run(a *Action, p *pending) {
+	templates := make([]string, 0, len(p.templates))
+	for id := range p.templates {
+		templates = append(templates, id)
+	}
+	sort.Strings(templates)
+
+	ctx, cancel := context.WithTimeout(r.ctx, r.timeout)
+	defer cancel()
+	if err := a.run(ctx); err != nil {
+		r.event(events.ReloadFailed{Action: a.name, Templates: templates,
+			Error: err})
+		return
+	}
+	r.event(events.Reloaded{Action: a.name, Templates: templates})
+}
@@ -0,0 +1,149 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcat"
+	"github.com/hashicorp/hcat/events"
+)
+
+// recorder records the events of a Reloader
+type recorder struct {
+	sync.Mutex
+	events []events.Event
+}
+
+func (r *recorder) handle(e events.Event) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) all() []events.Event {
+	r.Lock()
+	defer r.Unlock()
+	return append([]events.Event{}, r.events...)
+}
+
+// counter returns an action counting its runs
+func counter(name string, err error) (*Action, func() int) {
+	var mu sync.Mutex
+	var runs int
+	a := NewAction(name, func(context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		runs++
+		return err
+	})
+	return a, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return runs
+	}
+}
+
+func TestReloader(t *testing.T) {
+	t.Parallel()
+
+	t.Run("debounce", func(t *testing.T) {
+		rec := &recorder{}
+		r := NewReloader(ReloaderInput{
+			Debounce:     50 * time.Millisecond,
+			EventHandler: rec.handle,
+		})
+		defer r.Stop()
+		shared, sharedRuns := counter("shared", nil)
+		other, otherRuns := counter("other", nil)
+		r.Add("a", shared)
+		r.Add("b", shared, other)
+
+		r.Trigger("a")
+		r.Trigger("b")
+		r.Trigger("unknown")
+		time.Sleep(10 * time.Millisecond)
+		if sharedRuns() != 0 {
+			t.Fatal("action ran before the debounce")
+		}
+		r.Flush()
+		if sharedRuns() != 1 || otherRuns() != 1 {
+			t.Fatalf("bad runs, shared: %d, other: %d", sharedRuns(),
+				otherRuns())
+		}
+		exp := events.Reloaded{Action: "shared", Templates: []string{"a", "b"}}
+		found := false
+		for _, e := range rec.all() {
+			if reflect.DeepEqual(e, exp) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("missing event %#v in %#v", exp, rec.all())
+		}
+
+		// triggered again after running
+		r.Trigger("a")
+		deadline := time.Now().Add(time.Second)
+		for sharedRuns() != 2 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if sharedRuns() != 2 || otherRuns() != 1 {
+			t.Errorf("bad runs, shared: %d, other: %d", sharedRuns(),
+				otherRuns())
+		}
+	})
+
+	t.Run("handle", func(t *testing.T) {
+		r := NewReloader(ReloaderInput{})
+		defer r.Stop()
+		a, runs := counter("a", nil)
+		r.Add("tmpl", a)
+
+		r.Handle(hcat.ResolveEvent{ID: "tmpl"})
+		r.Handle(hcat.ResolveEvent{ID: "tmpl", Complete: true, NoChange: true})
+		r.Handle(hcat.ResolveEvent{ID: "tmpl", Complete: true, DryRun: true})
+		r.Flush()
+		if runs() != 0 {
+			t.Fatal("unexpected run")
+		}
+		r.Handle(hcat.ResolveEvent{ID: "tmpl", Complete: true})
+		r.Flush()
+		if runs() != 1 {
+			t.Errorf("expected 1 run, got %d", runs())
+		}
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		rec := &recorder{}
+		r := NewReloader(ReloaderInput{EventHandler: rec.handle})
+		defer r.Stop()
+		failure := errors.New("failure")
+		a, _ := counter("a", failure)
+		r.Add("tmpl", a)
+		r.Trigger("tmpl")
+		r.Flush()
+
+		exp := []events.Event{events.ReloadFailed{Action: "a",
+			Templates: []string{"tmpl"}, Error: failure}}
+		if act := rec.all(); !reflect.DeepEqual(act, exp) {
+			t.Errorf("bad events: %#v", act)
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		r := NewReloader(ReloaderInput{Debounce: time.Hour})
+		a, runs := counter("a", nil)
+		r.Add("tmpl", a)
+		r.Trigger("tmpl")
+		r.Stop()
+		r.Trigger("tmpl")
+		r.Flush()
+		if runs() != 0 {
+			t.Errorf("unexpected run after stop")
+		}
+	})
+}
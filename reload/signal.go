@@ -0,0 +1,11 @@
+//+build !windows
+
+package reload
+
+import (
+	"os"
+	"syscall"
+)
+
+// defaultSignal is the signal of the signal actions when none is set
+var defaultSignal os.Signal = syscall.SIGHUP
@@ -0,0 +1,8 @@
+//+build windows
+
+package reload
+
+import "os"
+
+// defaultSignal is unset, windows has no SIGHUP
+var defaultSignal os.Signal
@@ -0,0 +1,190 @@
+package hcat
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// RenderTransaction renders a set of templates to files as a unit, all of
+// them or none. Use it for sets of files that need to be consistent with each
+// other (eg. a config file and the certificate it references) so a failure
+// part way through doesn't leave a mix of old and new files.
+//
+// Rendering is done in two phases. First the output of every template is
+// written to a temporary file (and validated, see FileRendererInput's
+// Validate), if any template isn't complete or any write or validation fails
+// the temporary files are removed and nothing is rendered. Then the
+// temporary files are renamed into place, if a rename fails the files already
+// renamed are restored to their previous contents.
+type RenderTransaction struct {
+	resolver *Resolver
+	watcher  Watcherer
+	entries  []transactionEntry
+}
+
+// transactionEntry is a template and the file it renders to.
+type transactionEntry struct {
+	tmpl     Templater
+	renderer FileRenderer
+}
+
+// TransactionEvent is returned from running a RenderTransaction.
+type TransactionEvent struct {
+	// Complete is true if all the templates were complete and so rendered.
+	// If false nothing was rendered.
+	Complete bool
+
+	// Results are the render results for each template, in the order they
+	// were added. Only returned when Complete is true.
+	Results []RenderResult
+
+	// DryRun is true if the resolver is in dry-run mode. The templates were
+	// resolved but nothing was rendered.
+	DryRun bool
+}
+
+// NewRenderTransaction returns a new, empty, RenderTransaction that uses the
+// resolver and watcher to run its templates. The templates need to be
+// registered with the watcher as usual.
+func NewRenderTransaction(r *Resolver, w Watcherer) *RenderTransaction {
+	return &RenderTransaction{resolver: r, watcher: w}
+}
+
+// Add the template, rendered by the file renderer, to the transaction.
+func (tx *RenderTransaction) Add(tmpl Templater, r FileRenderer) {
+	tx.entries = append(tx.entries, transactionEntry{tmpl: tmpl, renderer: r})
+}
+
+// Run resolves all the templates and, if they are all complete, renders them
+// together. Like Resolver.Run it should be repeated until the returned event
+// is Complete. On an error nothing is rendered. The files of sensitive
+// templates without new values (see TemplateInput's Sensitive) are left as
+// they are, their contents aren't kept to render them again.
+func (tx *RenderTransaction) Run() (TransactionEvent, error) {
+	events := make([]ResolveEvent, len(tx.entries))
+	complete, dryRun := true, false
+	for i, e := range tx.entries {
+		event, err := tx.resolver.Run(e.tmpl, tx.watcher)
+		if err != nil {
+			return TransactionEvent{}, err
+		}
+		complete = complete && event.Complete
+		dryRun = dryRun || event.DryRun
+		events[i] = event
+	}
+	switch {
+	case !complete:
+		return TransactionEvent{}, nil
+	case dryRun:
+		return TransactionEvent{Complete: true, DryRun: true}, nil
+	}
+
+	results, err := tx.render(events)
+	if err != nil {
+		return TransactionEvent{}, err
+	}
+	for _, event := range events {
+		tx.resolver.Rendered(event)
+	}
+	return TransactionEvent{Complete: true, Results: results}, nil
+}
+
+// stagedFile is a template output written to its temporary file, along with
+// what is needed to restore the previous file on a rollback.
+type stagedFile struct {
+	renderer FileRenderer
+	tempName string
+	existed  bool
+	previous []byte
+	perms    os.FileMode
+}
+
+// render writes the events' contents to the entries' files, all or none.
+func (tx *RenderTransaction) render(events []ResolveEvent) (
+	[]RenderResult, error) {
+	results := make([]RenderResult, len(tx.entries))
+
+	// stage all the changed files
+	staged := make([]*stagedFile, 0, len(tx.entries))
+	cleanup := func() {
+		for _, s := range staged {
+			os.Remove(s.tempName)
+		}
+	}
+	for i, e := range tx.entries {
+		if events[i].NoChange && events[i].Contents == nil {
+			// a sensitive (or quarantined) template without new values,
+			// its file already has the contents
+			results[i].WouldRender = true
+			continue
+		}
+		r := e.renderer
+		p, err := r.prepare(events[i].Contents)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		results[i].WouldRender = true
+		if bytes.Equal(p.existing, p.contents) && p.exists {
+			p.zero()
+			continue
+		}
+		results[i].DidRender = true
+
+		s := &stagedFile{renderer: r, existed: p.exists, previous: p.existing}
+		if p.exists {
+			info, err := os.Stat(r.path)
+			if err != nil {
+				p.zero()
+				cleanup()
+				return nil, errors.Wrap(err, "failed reading file")
+			}
+			s.perms = info.Mode()
+		}
+		s.tempName, err = stageWrite(r.path, p.contents, r.perms,
+			r.createDestDirs, r.writeOpts)
+		p.zero()
+		if err != nil {
+			cleanup()
+			return nil, errors.Wrap(err, "failed writing file")
+		}
+		staged = append(staged, s)
+		if err := r.validate(s.tempName); err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+
+	// commit them
+	for i, s := range staged {
+		r := s.renderer
+		r.backup(r.path)
+		if err := commitWrite(s.tempName, r.path, r.writeOpts); err != nil {
+			// the failed rename might have happened, roll it back too
+			rollback(staged[:i+1])
+			cleanup()
+			return nil, errors.Wrap(err, "failed writing file")
+		}
+	}
+	for _, s := range staged {
+		if s.renderer.writeOpts.sensitive {
+			zeroBytes(s.previous)
+		}
+	}
+	return results, nil
+}
+
+// rollback restores the files to their contents from before the commit,
+// removing those that didn't exist.
+func rollback(staged []*stagedFile) {
+	for _, s := range staged {
+		r := s.renderer
+		if !s.existed {
+			os.Remove(r.path)
+			continue
+		}
+		atomicWrite(r.path, s.previous, s.perms, false, r.writeOpts)
+	}
+}
@@ -0,0 +1,219 @@
+package hcat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+func TestRenderTransaction(t *testing.T) {
+	t.Parallel()
+
+	// setup returns a temp dir with the existing "a" file and a transaction
+	// rendering "new-a" to "a" and "new-b" to the b path
+	setup := func(t *testing.T, complete bool, bPath string) (
+		string, *RenderTransaction) {
+		dir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		a := filepath.Join(dir, "a")
+		if err := ioutil.WriteFile(a, []byte("old-a"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		st := NewStore()
+		w := txWatcher{Store: st, complete: complete}
+		tx := NewRenderTransaction(NewResolver(), w)
+		for _, path := range []string{"a", bPath} {
+			d := &idep.FakeDep{Name: path}
+			st.Save(d.ID(), "new-"+filepath.Base(path))
+			tmpl := NewTemplate(TemplateInput{
+				Name:         path,
+				Contents:     `{{ echo "` + path + `" }}`,
+				FuncMapMerge: template.FuncMap{"echo": echoFunc},
+			})
+			tx.Add(tmpl, NewFileRenderer(FileRendererInput{
+				Path: filepath.Join(dir, path),
+			}))
+		}
+		return dir, tx
+	}
+
+	// checkFiles compares the dir's contents with the expected files
+	checkFiles := func(t *testing.T, dir string, exp map[string]string) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		act := make(map[string]string, len(files))
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			b, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			act[f.Name()] = string(b)
+		}
+		if len(act) != len(exp) {
+			t.Fatalf("bad files, exp: %v, act: %v", exp, act)
+		}
+		for name, contents := range exp {
+			if act[name] != contents {
+				t.Errorf("bad %s, exp: %q, act: %q", name, contents, act[name])
+			}
+		}
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		dir, tx := setup(t, true, "b")
+		event, err := tx.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !event.Complete || len(event.Results) != 2 {
+			t.Fatalf("bad event: %#v", event)
+		}
+		for _, r := range event.Results {
+			if !r.DidRender || !r.WouldRender {
+				t.Errorf("bad result: %#v", r)
+			}
+		}
+		checkFiles(t, dir, map[string]string{"a": "new-a", "b": "new-b"})
+
+		// no changes, nothing re-rendered
+		event, err = tx.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range event.Results {
+			if r.DidRender || !r.WouldRender {
+				t.Errorf("bad result: %#v", r)
+			}
+		}
+	})
+
+	t.Run("sensitive-no-new-values", func(t *testing.T) {
+		dir, tx := setup(t, true, "b")
+		tx.entries[0].tmpl = NewTemplate(TemplateInput{
+			Name:         "a",
+			Contents:     `{{ echo "a" }}`,
+			FuncMapMerge: template.FuncMap{"echo": echoFunc},
+			Sensitive:    true,
+		})
+		for i := 0; i < 2; i++ {
+			event, err := tx.Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !event.Complete || len(event.Results) != 2 ||
+				!event.Results[0].WouldRender {
+				t.Fatalf("%d: bad event: %#v", i, event)
+			}
+			checkFiles(t, dir, map[string]string{"a": "new-a", "b": "new-b"})
+		}
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		dir, tx := setup(t, true, "b")
+		tx.entries[0].renderer.mode = WriteBlock
+		if _, err := tx.Run(); err != nil {
+			t.Fatal(err)
+		}
+		checkFiles(t, dir, map[string]string{
+			"a": "old-a\n# BEGIN HCAT MANAGED BLOCK\nnew-a\n" +
+				"# END HCAT MANAGED BLOCK\n",
+			"b": "new-b",
+		})
+	})
+
+	t.Run("normalize", func(t *testing.T) {
+		dir, tx := setup(t, true, "b")
+		tx.entries[0].renderer = NewFileRenderer(FileRendererInput{
+			Path:            filepath.Join(dir, "a"),
+			LineEnding:      LineEndingCRLF,
+			TrailingNewline: true,
+		})
+		if _, err := tx.Run(); err != nil {
+			t.Fatal(err)
+		}
+		checkFiles(t, dir, map[string]string{"a": "new-a\r\n", "b": "new-b"})
+	})
+
+	t.Run("incomplete", func(t *testing.T) {
+		dir, tx := setup(t, false, "b")
+		event, err := tx.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Complete || event.Results != nil {
+			t.Fatalf("bad event: %#v", event)
+		}
+		checkFiles(t, dir, map[string]string{"a": "old-a"})
+	})
+
+	t.Run("stage-error", func(t *testing.T) {
+		// b's parent directory is missing and won't be created
+		dir, tx := setup(t, true, filepath.Join("missing", "b"))
+		_, err := tx.Run()
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		checkFiles(t, dir, map[string]string{"a": "old-a"})
+	})
+
+	t.Run("commit-error", func(t *testing.T) {
+		// b is a non-empty directory so renaming over it fails
+		dir, tx := setup(t, true, "b")
+		if err := os.MkdirAll(filepath.Join(dir, "b", "c"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		_, err := tx.Run()
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		checkFiles(t, dir, map[string]string{"a": "old-a"})
+		info, err := os.Stat(filepath.Join(dir, "a"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode() != 0600 {
+			t.Errorf("bad permissions: %v", info.Mode())
+		}
+	})
+
+	t.Run("dry-run", func(t *testing.T) {
+		dir, tx := setup(t, true, "b")
+		tx.resolver.SetDryRun(NewDryRunSink())
+		event, err := tx.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !event.Complete || !event.DryRun {
+			t.Fatalf("bad event: %#v", event)
+		}
+		checkFiles(t, dir, map[string]string{"a": "old-a"})
+	})
+}
+
+// txWatcher is a Watcherer that recalls values from the store by name
+type txWatcher struct {
+	*Store
+	complete bool
+}
+
+func (txWatcher) Buffering(Notifier) bool  { return false }
+func (w txWatcher) Complete(Notifier) bool { return w.complete }
+func (w txWatcher) Recaller(Notifier) Recaller {
+	return func(d dep.Dependency) (interface{}, bool) {
+		return w.Store.Recall(d.ID())
+	}
+}
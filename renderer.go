@@ -2,9 +2,12 @@ package hcat
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -13,6 +16,10 @@ const (
 	// DefaultFilePerms are the default file permissions for files rendered onto
 	// disk when a specific file permission has not already been specified.
 	defaultFilePerms = 0644
+
+	// sensitiveFilePerms are the default permissions for files rendered with
+	// sensitive contents.
+	sensitiveFilePerms = 0600
 )
 
 var (
@@ -30,6 +37,19 @@ type FileRenderer struct {
 	path           string
 	perms          os.FileMode
 	backup         BackupFunc
+	writeOpts      writeOptions
+	gzip           bool
+	base64         bool
+	mode           WriteMode
+	marker         string
+
+	lineEnding      LineEnding
+	trailingNewline bool
+	bom             BOMMode
+
+	validateFunc    ValidateFunc
+	validateCommand []string
+	validateTimeout time.Duration
 }
 
 // check for innterface compliance
@@ -46,6 +66,25 @@ func NewFileRenderer(i FileRendererInput) FileRenderer {
 		path:           i.Path,
 		perms:          i.Perms,
 		backup:         backup,
+		writeOpts: writeOptions{
+			tempDir:        i.TempDir,
+			tempPrefix:     i.TempPrefix,
+			skipFsync:      i.SkipFsync,
+			fsyncParentDir: i.FsyncParentDir,
+			user:           i.User,
+			group:          i.Group,
+			sensitive:      i.Sensitive,
+		},
+		gzip:            i.Gzip,
+		base64:          i.Base64,
+		mode:            i.Mode,
+		marker:          i.Marker,
+		lineEnding:      i.LineEnding,
+		trailingNewline: i.TrailingNewline,
+		bom:             i.BOM,
+		validateFunc:    i.Validate,
+		validateCommand: i.ValidateCommand,
+		validateTimeout: i.ValidateTimeout,
 	}
 }
 
@@ -59,6 +98,68 @@ type FileRendererInput struct {
 	Perms os.FileMode
 	// Backup causes a backup of the rendered file to be made
 	Backup BackupFunc
+	// User and Group set the owner of the file, names or numeric IDs. Empty
+	// (the default) leaves them as is, inheriting those of the existing file
+	// if Perms isn't set. Not supported on windows.
+	User  string
+	Group string
+
+	// TempDir is the directory the temporary file is written to before being
+	// renamed to Path. Defaults to the directory of Path. It needs to be on the
+	// same filesystem as Path for the rename to work (and be atomic).
+	TempDir string
+	// TempPrefix is the prefix used for the temporary file's name.
+	TempPrefix string
+	// SkipFsync disables calling fsync on the temporary file before it is
+	// renamed. Faster, but the file contents might not be durable on a crash.
+	SkipFsync bool
+	// FsyncParentDir calls fsync on Path's parent directory after the rename
+	// so the rename itself is durable.
+	FsyncParentDir bool
+
+	// Gzip writes the contents gzip-compressed.
+	Gzip bool
+	// Base64 writes the contents base64-encoded (standard encoding, with
+	// padding). With Gzip the compressed contents are encoded, eg. for
+	// cloud-init user data.
+	Base64 bool
+
+	// Mode is how the contents are written, replacing the whole file (the
+	// default), appended or merged into a managed block, see WriteMode. The
+	// merging modes don't support Gzip or Base64.
+	Mode WriteMode
+	// Marker is the marker line of the merging modes, in which "%s" is
+	// replaced by BEGIN or END for the block and by the contents' hash when
+	// appending. Templates managing different blocks of a file need
+	// different markers. Defaults to "# %s HCAT MANAGED BLOCK" for the block
+	// and "# HCAT APPENDED %s" when appending.
+	Marker string
+
+	// LineEnding normalizes the line endings of the rendered contents, to LF
+	// or CRLF, regardless of those the template was authored with.
+	// TrailingNewline ends non-empty contents with a newline if they don't
+	// (CRLF if the contents use CRLF line endings). BOM adds or strips the
+	// UTF-8 byte order mark. They are applied before the contents are encoded
+	// or merged. The defaults leave the contents as rendered.
+	LineEnding      LineEnding
+	TrailingNewline bool
+	BOM             BOMMode
+
+	// Sensitive is set for contents that are sensitive (eg. secrets). New
+	// files are created with 0600 permissions when Perms isn't set and the
+	// renderer's copies of the contents are zeroed once written. Set for
+	// Sensitive templates rendered with RenderFor.
+	Sensitive bool
+
+	// Validate and ValidateCommand validate the staged temporary file before
+	// it is renamed to Path, a failure aborts the write with a
+	// ValidationError. ValidateCommand is the command and its arguments, in
+	// which "%s" is replaced by the temporary file's path, eg.
+	// []string{"nginx", "-t", "-c", "%s"}. It fails if it exits non-zero or
+	// runs longer than ValidateTimeout (default 30s), when it is killed.
+	Validate        ValidateFunc
+	ValidateCommand []string
+	ValidateTimeout time.Duration
 }
 
 // BackupFunc defines the function type passed in to make backups if previously
@@ -82,23 +183,36 @@ type RenderResult struct {
 // Render atomically renders a file contents to disk, returning a result of
 // whether it would have rendered and actually did render.
 func (r FileRenderer) Render(contents []byte) (RenderResult, error) {
-	existing, err := ioutil.ReadFile(r.path)
-	fileExists := !os.IsNotExist(err)
-	if err != nil && fileExists {
-		return RenderResult{}, errors.Wrap(err, "failed reading file")
+	p, err := r.prepare(contents)
+	if err != nil {
+		return RenderResult{}, err
+	}
+	defer p.zero()
+	if r.writeOpts.sensitive {
+		defer zeroBytes(p.existing)
 	}
 
-	if bytes.Equal(existing, contents) && fileExists {
+	if bytes.Equal(p.existing, p.contents) && p.exists {
 		return RenderResult{
 			DidRender:   false,
 			WouldRender: true,
 		}, nil
 	}
 
+	tempName, err := stageWrite(r.path, p.contents, r.perms, r.createDestDirs,
+		r.writeOpts)
+	if err != nil {
+		return RenderResult{}, errors.Wrap(err, "failed writing file")
+	}
+	defer os.Remove(tempName)
+
+	if err := r.validate(tempName); err != nil {
+		return RenderResult{}, err
+	}
+
 	r.backup(r.path)
 
-	err = atomicWrite(r.path, contents, r.perms, r.createDestDirs)
-	if err != nil {
+	if err := commitWrite(tempName, r.path, r.writeOpts); err != nil {
 		return RenderResult{}, errors.Wrap(err, "failed writing file")
 	}
 
@@ -108,6 +222,115 @@ func (r FileRenderer) Render(contents []byte) (RenderResult, error) {
 	}, nil
 }
 
+// RenderFor renders the template's contents to the file described by its
+// TemplateInput's Destination. Errors if the template has no Destination. The
+// contents are zeroed after rendering if the template is Sensitive.
+func RenderFor(tmpl *Template, contents []byte) (RenderResult, error) {
+	if tmpl.destination == nil {
+		return RenderResult{}, errors.Errorf(
+			"template %s has no destination", tmpl.ID())
+	}
+	i := *tmpl.destination
+	if tmpl.sensitive {
+		i.Sensitive = true
+		defer zeroBytes(contents)
+	}
+	return NewFileRenderer(i).Render(contents)
+}
+
+// encode returns the contents as they are written to the file, compressed
+// and/or encoded as set by the Gzip and Base64 options. The gzip header has no
+// name or timestamp so unchanged contents encode to the same bytes and don't
+// trigger a re-render.
+func (r FileRenderer) encode(contents []byte) ([]byte, error) {
+	if r.gzip {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		if _, err := zw.Write(contents); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		contents = b.Bytes()
+	}
+	if r.base64 {
+		encoded := make([]byte, base64.StdEncoding.EncodedLen(len(contents)))
+		base64.StdEncoding.Encode(encoded, contents)
+		contents = encoded
+	}
+	return contents, nil
+}
+
+// preparedFile is the file's new contents, prepared from the rendered ones,
+// along with its current contents.
+type preparedFile struct {
+	contents []byte
+	existing []byte
+	exists   bool
+	// buffers are the sensitive buffers allocated preparing the contents
+	buffers [][]byte
+}
+
+// zero zeroes the buffers allocated preparing the contents of a sensitive
+// file, once the contents are written.
+func (p preparedFile) zero() {
+	for _, b := range p.buffers {
+		zeroBytes(b)
+	}
+}
+
+// prepare returns the file's new contents: the rendered contents normalized,
+// encoded and merged into the existing file as set by the options. Render
+// and RenderTransaction write the same contents this way.
+func (r FileRenderer) prepare(contents []byte) (preparedFile, error) {
+	var p preparedFile
+	owned := func(b []byte) {
+		if r.writeOpts.sensitive {
+			p.buffers = append(p.buffers, b)
+		}
+	}
+	fail := func(err error, msg string) (preparedFile, error) {
+		p.zero()
+		return preparedFile{}, errors.Wrap(err, msg)
+	}
+
+	if r.normalizes() {
+		normalized, err := r.normalize(contents)
+		if err != nil {
+			return fail(err, "failed normalizing contents")
+		}
+		owned(normalized)
+		contents = normalized
+	}
+
+	contents, err := r.encode(contents)
+	if err != nil {
+		return fail(err, "failed encoding contents")
+	}
+	if r.gzip || r.base64 {
+		owned(contents)
+	}
+
+	p.existing, err = ioutil.ReadFile(r.path)
+	p.exists = !os.IsNotExist(err)
+	if err != nil && p.exists {
+		return fail(err, "failed reading file")
+	}
+
+	if r.mode != WriteReplace {
+		if contents, err = r.merge(p.existing, contents); err != nil {
+			if r.writeOpts.sensitive {
+				zeroBytes(p.existing)
+			}
+			return fail(err, "failed merging contents")
+		}
+		owned(contents)
+	}
+	p.contents = contents
+	return p, nil
+}
+
 // Backup creates a [filename].bak copy, preserving the Mode
 // Provided for convenience (to use as the BackupFunc) and an example.
 func Backup(path string) {
@@ -136,40 +359,73 @@ func Backup(path string) {
 //
 // If no errors occur, the Tempfile is "renamed" (moved) to the destination
 // path.
+//
+// The writeOptions control where the Tempfile is created and how it is synced
+// to disk. The zero value creates it in the destination's parent directory and
+// only syncs the Tempfile.
 func atomicWrite(
 	path string, contents []byte, perms os.FileMode, createDestDirs bool,
+	opts writeOptions,
 ) error {
+	tempName, err := stageWrite(path, contents, perms, createDestDirs, opts)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempName)
+
+	return commitWrite(tempName, path, opts)
+}
+
+// stageWrite does the first half of atomicWrite, writing the contents to the
+// Tempfile with the final permissions. It returns the Tempfile's name, which
+// the caller is responsible for removing if it isn't committed.
+func stageWrite(
+	path string, contents []byte, perms os.FileMode, createDestDirs bool,
+	opts writeOptions,
+) (string, error) {
 	if path == "" {
-		return errMissingDest
+		return "", errMissingDest
 	}
 
 	parent := filepath.Dir(path)
 	if _, err := os.Stat(parent); os.IsNotExist(err) {
 		if createDestDirs {
 			if err := os.MkdirAll(parent, 0755); err != nil {
-				return err
+				return "", err
 			}
 		} else {
-			return errNoParentDir
+			return "", errNoParentDir
 		}
 	}
 
-	f, err := ioutil.TempFile(parent, "")
+	tempDir := parent
+	if opts.tempDir != "" {
+		tempDir = opts.tempDir
+	}
+	f, err := ioutil.TempFile(tempDir, opts.tempPrefix)
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer os.Remove(f.Name())
+	staged := false
+	defer func() {
+		if !staged {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
 
 	if _, err := f.Write(contents); err != nil {
-		return err
+		return "", err
 	}
 
-	if err := f.Sync(); err != nil {
-		return err
+	if !opts.skipFsync {
+		if err := f.Sync(); err != nil {
+			return "", err
+		}
 	}
 
 	if err := f.Close(); err != nil {
-		return err
+		return "", err
 	}
 
 	// If the user did not explicitly set permissions, attempt to lookup the
@@ -178,10 +434,13 @@ func atomicWrite(
 	if perms == 0 {
 		currentInfo, err := os.Stat(path)
 		if err != nil {
-			if os.IsNotExist(err) {
+			switch {
+			case os.IsNotExist(err) && opts.sensitive:
+				perms = sensitiveFilePerms
+			case os.IsNotExist(err):
 				perms = defaultFilePerms
-			} else {
-				return err
+			default:
+				return "", err
 			}
 		} else {
 			perms = currentInfo.Mode()
@@ -192,12 +451,47 @@ func atomicWrite(
 	}
 
 	if err := os.Chmod(f.Name(), perms); err != nil {
-		return err
+		return "", err
+	}
+
+	if opts.user != "" || opts.group != "" {
+		if err := setFileOwner(f.Name(), opts.user, opts.group); err != nil {
+			return "", err
+		}
 	}
 
-	if err := os.Rename(f.Name(), path); err != nil {
+	staged = true
+	return f.Name(), nil
+}
+
+// commitWrite does the second half of atomicWrite, renaming the Tempfile
+// written by stageWrite to the destination path.
+func commitWrite(tempName, path string, opts writeOptions) error {
+	if err := os.Rename(tempName, path); err != nil {
 		return err
 	}
 
+	if opts.fsyncParentDir {
+		return syncDir(filepath.Dir(path))
+	}
+
 	return nil
 }
+
+// writeOptions are the options for atomicWrite.
+type writeOptions struct {
+	tempDir        string
+	tempPrefix     string
+	skipFsync      bool
+	fsyncParentDir bool
+	user, group    string
+	sensitive      bool
+}
+
+// zeroBytes overwrites the buffer with zeros, so sensitive contents don't
+// linger in memory.
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,253 @@
+package hcat
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultMemoryHistory is the default number of renders a MemoryRenderer
+// keeps.
+const defaultMemoryHistory = 10
+
+// check for interface compliance
+var _ Renderer = (*MemoryRenderer)(nil)
+
+// MemoryRenderer keeps the rendered contents in memory instead of writing them
+// to disk, for services that serve them (eg. over HTTP). It keeps a history of
+// the last renders to compare them and roll back to an earlier one. It is safe
+// for concurrent use.
+type MemoryRenderer struct {
+	sync.RWMutex
+	size    int
+	history []Rendition // oldest first
+	version uint64      // of the last render
+	now     func() time.Time
+}
+
+// MemoryRendererInput is the input structure for NewMemoryRenderer.
+type MemoryRendererInput struct {
+	// History is the number of renders kept, including the current one.
+	// Defaults to 10.
+	History int
+}
+
+// Rendition is a render kept by the MemoryRenderer.
+type Rendition struct {
+	// Version numbers the renders, starting at 1 and increasing with each
+	// render (rollbacks included).
+	Version uint64
+	// Contents are the rendered contents
+	Contents []byte
+	// Time is when it was rendered
+	Time time.Time
+}
+
+// NewMemoryRenderer returns a new, empty MemoryRenderer.
+func NewMemoryRenderer(i MemoryRendererInput) *MemoryRenderer {
+	size := i.History
+	if size < 1 {
+		size = defaultMemoryHistory
+	}
+	return &MemoryRenderer{size: size, now: time.Now}
+}
+
+// Render keeps the contents as the current render. Contents that are the same
+// as the current ones aren't kept again and don't render.
+func (r *MemoryRenderer) Render(contents []byte) (RenderResult, error) {
+	r.Lock()
+	defer r.Unlock()
+	if n := len(r.history); n > 0 && bytes.Equal(r.history[n-1].Contents, contents) {
+		return RenderResult{DidRender: false, WouldRender: true}, nil
+	}
+	r.add(append([]byte(nil), contents...))
+	return RenderResult{DidRender: true, WouldRender: true}, nil
+}
+
+// add appends the contents to the history, dropping the oldest render if it
+// is full.
+func (r *MemoryRenderer) add(contents []byte) Rendition {
+	r.version++
+	rn := Rendition{Version: r.version, Contents: contents, Time: r.now()}
+	r.history = append(r.history, rn)
+	if len(r.history) > r.size {
+		r.history = append(r.history[:0], r.history[1:]...)
+	}
+	return rn
+}
+
+// Current returns the current (last) render, false if nothing has rendered.
+func (r *MemoryRenderer) Current() (Rendition, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	if len(r.history) == 0 {
+		return Rendition{}, false
+	}
+	return r.history[len(r.history)-1].copy(), true
+}
+
+// Get returns the render with the version, false if it isn't (or no longer)
+// kept.
+func (r *MemoryRenderer) Get(version uint64) (Rendition, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	rn, ok := r.get(version)
+	return rn.copy(), ok
+}
+
+func (r *MemoryRenderer) get(version uint64) (Rendition, bool) {
+	for _, rn := range r.history {
+		if rn.Version == version {
+			return rn, true
+		}
+	}
+	return Rendition{}, false
+}
+
+// History returns the kept renders, oldest first.
+func (r *MemoryRenderer) History() []Rendition {
+	r.RLock()
+	defer r.RUnlock()
+	history := make([]Rendition, len(r.history))
+	for i, rn := range r.history {
+		history[i] = rn.copy()
+	}
+	return history
+}
+
+// Compare returns the line differences between the renders with the
+// versions, the lines only in from prefixed with "-" and those only in to
+// with "+", in order. Empty if they are the same. Errors if either version
+// isn't kept.
+func (r *MemoryRenderer) Compare(from, to uint64) ([]string, error) {
+	r.RLock()
+	defer r.RUnlock()
+	a, ok := r.get(from)
+	if !ok {
+		return nil, errors.Errorf("memory renderer: no version %d", from)
+	}
+	b, ok := r.get(to)
+	if !ok {
+		return nil, errors.Errorf("memory renderer: no version %d", to)
+	}
+	return diffLines(splitLines(a.Contents), splitLines(b.Contents)), nil
+}
+
+// Rollback renders the contents of the earlier render with the version again,
+// as a new render, and returns it. Errors if the version isn't kept.
+func (r *MemoryRenderer) Rollback(version uint64) (Rendition, error) {
+	r.Lock()
+	defer r.Unlock()
+	rn, ok := r.get(version)
+	if !ok {
+		return Rendition{}, errors.Errorf("memory renderer: no version %d",
+			version)
+	}
+	return r.add(rn.Contents).copy(), nil
+}
+
+// copy returns the rendition with a copy of its contents, so they can't be
+// modified by callers.
+func (rn Rendition) copy() Rendition {
+	if rn.Contents != nil {
+		rn.Contents = append([]byte(nil), rn.Contents...)
+	}
+	return rn
+}
+
+// splitLines splits the contents into lines, without the line endings.
+func splitLines(contents []byte) []string {
+	if len(contents) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")
+}
+
+// diffLines returns the lines removed from a ("-") and added in b ("+"),
+// a shortest edit script found with Myers' diff algorithm in linear space.
+func diffLines(a, b []string) []string {
+	var diff []string
+	var walk func(a, b []string)
+	walk = func(a, b []string) {
+		for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+			a, b = a[1:], b[1:]
+		}
+		for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+			a, b = a[:len(a)-1], b[:len(b)-1]
+		}
+		switch {
+		case len(a) == 0:
+			for _, line := range b {
+				diff = append(diff, "+"+line)
+			}
+		case len(b) == 0:
+			for _, line := range a {
+				diff = append(diff, "-"+line)
+			}
+		default:
+			// edit both sides of the middle snake, the snake is common
+			x, y, u, v := middleSnake(a, b)
+			walk(a[:x], b[:y])
+			walk(a[u:], b[v:])
+		}
+	}
+	walk(a, b)
+	return diff
+}
+
+// middleSnake returns the start (x, y) and end (u, v) of the middle snake of
+// a shortest edit script of a into b, the run of common lines halfway along
+// it. Found by searching from both ends at once, keeping only the furthest
+// reaching path on each diagonal.
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	max := (n + m + 1) / 2
+	// fwd[k] and rev[k] are the furthest x reached on diagonal k (x-y) from
+	// the start, and from the end of the reversed a and b
+	off := max + 1
+	fwd := make([]int, 2*max+3)
+	rev := make([]int, 2*max+3)
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			if k == -d || (k != d && fwd[off+k-1] < fwd[off+k+1]) {
+				x = fwd[off+k+1]
+			} else {
+				x = fwd[off+k-1] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && a[u] == b[v] {
+				u, v = u+1, v+1
+			}
+			fwd[off+k] = u
+			if kr := delta - k; odd && kr >= -(d-1) && kr <= d-1 &&
+				u+rev[off+kr] >= n {
+				return x, y, u, v
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			if k == -d || (k != d && rev[off+k-1] < rev[off+k+1]) {
+				x = rev[off+k+1]
+			} else {
+				x = rev[off+k-1] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && a[n-1-u] == b[m-1-v] {
+				u, v = u+1, v+1
+			}
+			rev[off+k] = u
+			if kf := delta - k; !odd && kf >= -d && kf <= d &&
+				u+fwd[off+kf] >= n {
+				return n - u, m - v, n - x, m - y
+			}
+		}
+	}
+	// unreachable, the paths meet by d = max
+	return n, m, n, m
+}
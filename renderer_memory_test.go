@@ -0,0 +1,220 @@
+package hcat
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryRenderer(t *testing.T) {
+	t.Parallel()
+
+	newRenderer := func(history int) *MemoryRenderer {
+		r := NewMemoryRenderer(MemoryRendererInput{History: history})
+		now := time.Unix(0, 0)
+		r.now = func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		}
+		return r
+	}
+	render := func(t *testing.T, r *MemoryRenderer, contents string) RenderResult {
+		res, err := r.Render([]byte(contents))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		r := NewMemoryRenderer(MemoryRendererInput{})
+		if _, ok := r.Current(); ok {
+			t.Error("expected no current render")
+		}
+		if r.size != defaultMemoryHistory {
+			t.Errorf("bad default history: %d", r.size)
+		}
+	})
+
+	t.Run("render", func(t *testing.T) {
+		r := newRenderer(2)
+		if res := render(t, r, "foo"); !res.DidRender || !res.WouldRender {
+			t.Errorf("bad result: %#v", res)
+		}
+		if res := render(t, r, "foo"); res.DidRender || !res.WouldRender {
+			t.Errorf("bad unchanged result: %#v", res)
+		}
+		render(t, r, "bar")
+		render(t, r, "baz")
+
+		cur, ok := r.Current()
+		if !ok || string(cur.Contents) != "baz" || cur.Version != 3 {
+			t.Fatalf("bad current: %#v", cur)
+		}
+		if !cur.Time.Equal(time.Unix(3, 0)) {
+			t.Errorf("bad time: %v", cur.Time)
+		}
+
+		// only the last 2 are kept
+		var versions []uint64
+		for _, rn := range r.History() {
+			versions = append(versions, rn.Version)
+		}
+		if !reflect.DeepEqual(versions, []uint64{2, 3}) {
+			t.Errorf("bad history: %v", versions)
+		}
+		if _, ok := r.Get(1); ok {
+			t.Error("expected version 1 to be dropped")
+		}
+		if rn, ok := r.Get(2); !ok || string(rn.Contents) != "bar" {
+			t.Errorf("bad version 2: %#v", rn)
+		}
+
+		// returned contents are copies
+		cur.Contents[0] = 'x'
+		if cur, _ := r.Current(); string(cur.Contents) != "baz" {
+			t.Errorf("contents modified: %q", cur.Contents)
+		}
+	})
+
+	t.Run("compare", func(t *testing.T) {
+		r := newRenderer(0)
+		render(t, r, "a\nb\nc\n")
+		render(t, r, "a\nc\nd\n")
+
+		diff, err := r.Compare(1, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp := []string{"-b", "+d"}; !reflect.DeepEqual(diff, exp) {
+			t.Errorf("bad diff, exp: %v, act: %v", exp, diff)
+		}
+		if diff, _ := r.Compare(2, 2); len(diff) != 0 {
+			t.Errorf("expected no diff: %v", diff)
+		}
+		if _, err := r.Compare(1, 3); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		r := newRenderer(0)
+		render(t, r, "foo")
+		render(t, r, "bar")
+
+		rn, err := r.Rollback(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rn.Version != 3 || string(rn.Contents) != "foo" {
+			t.Errorf("bad rollback: %#v", rn)
+		}
+		if cur, _ := r.Current(); cur.Version != 3 {
+			t.Errorf("bad current: %#v", cur)
+		}
+		if _, err := r.Rollback(5); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestDiffLines(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		a, b string
+		exp  []string
+	}{
+		{"same", "abc", "abc", nil},
+		{"empty", "", "", nil},
+		{"added", "", "ab", []string{"+a", "+b"}},
+		{"removed", "ab", "", []string{"-a", "-b"}},
+		{"changed", "abc", "adc", []string{"-b", "+d"}},
+		{"moved", "abcd", "bcda", []string{"-a", "+a"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			act := diffLines(strings.Split(tc.a, ""), strings.Split(tc.b, ""))
+			if !reflect.DeepEqual(act, tc.exp) {
+				t.Errorf("exp: %v, act: %v", tc.exp, act)
+			}
+		})
+	}
+
+	t.Run("shortest", func(t *testing.T) {
+		// the diff removes and adds the lines not in the longest common
+		// subsequence, and keeps the order of those it does
+		rnd := rand.New(rand.NewSource(1))
+		lines := func() []string {
+			l := make([]string, rnd.Intn(12))
+			for i := range l {
+				l[i] = string(rune('a' + rnd.Intn(3)))
+			}
+			return l
+		}
+		for i := 0; i < 1000; i++ {
+			a, b := lines(), lines()
+			var removed, added []string
+			for _, line := range diffLines(a, b) {
+				if line[0] == '-' {
+					removed = append(removed, line[1:])
+				} else {
+					added = append(added, line[1:])
+				}
+			}
+			lcs := lcsLength(a, b)
+			if len(removed) != len(a)-lcs || len(added) != len(b)-lcs ||
+				!subsequence(removed, a) || !subsequence(added, b) {
+				t.Fatalf("bad diff of %v and %v: %v", a, b, diffLines(a, b))
+			}
+		}
+	})
+
+	t.Run("large", func(t *testing.T) {
+		a := make([]string, 100000)
+		for i := range a {
+			a[i] = fmt.Sprint(i)
+		}
+		b := append([]string(nil), a...)
+		b[50000] = "changed"
+		exp := []string{"-50000", "+changed"}
+		if act := diffLines(a, b); !reflect.DeepEqual(act, exp) {
+			t.Errorf("exp: %v, act: %v", exp, act)
+		}
+	})
+}
+
+// lcsLength returns the length of the longest common subsequence of a and b.
+func lcsLength(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	return lcs[0][0]
+}
+
+// subsequence returns true if the lines of sub are in lines, in order.
+func subsequence(sub, lines []string) bool {
+	for _, line := range lines {
+		if len(sub) > 0 && sub[0] == line {
+			sub = sub[1:]
+		}
+	}
+	return len(sub) == 0
+}
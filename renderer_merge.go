@@ -0,0 +1,128 @@
+package hcat
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// WriteMode is how a FileRenderer writes the contents to the file, see
+// FileRendererInput's Mode.
+type WriteMode string
+
+const (
+	// WriteReplace replaces the whole file with the contents, the default.
+	WriteReplace WriteMode = ""
+	// WriteAppend appends the contents to the file, after a marker line with
+	// their hash. Contents whose marker is already in the file aren't
+	// appended again, so re-rendering the same contents doesn't duplicate
+	// them.
+	WriteAppend WriteMode = "append"
+	// WriteBlock replaces only the managed block of the file, the lines
+	// between its BEGIN and END marker lines, preserving the rest. The block
+	// is appended if the file doesn't have it yet. For files partially owned
+	// by other tooling.
+	WriteBlock WriteMode = "block"
+)
+
+// The default markers of the merging write modes, markerArg is replaced by
+// BEGIN or END for the block and the contents' hash when appending.
+const (
+	markerArg           = "%s"
+	defaultBlockMarker  = "# " + markerArg + " HCAT MANAGED BLOCK"
+	defaultAppendMarker = "# HCAT APPENDED " + markerArg
+)
+
+// merge returns the file's new contents with the rendered contents merged
+// into the existing ones as set by the write mode.
+func (r FileRenderer) merge(existing, contents []byte) ([]byte, error) {
+	if r.gzip || r.base64 {
+		return nil, errors.Errorf(
+			"write mode %q doesn't support gzip or base64", r.mode)
+	}
+	marker := r.marker
+	switch {
+	case marker == "" && r.mode == WriteAppend:
+		marker = defaultAppendMarker
+	case marker == "":
+		marker = defaultBlockMarker
+	case strings.Count(marker, markerArg) != 1:
+		return nil, errors.Errorf("marker %q must contain %s once", marker,
+			markerArg)
+	}
+
+	switch r.mode {
+	case WriteAppend:
+		sum := sha256.Sum256(contents)
+		line := strings.Replace(marker, markerArg, hex.EncodeToString(sum[:]), 1)
+		if markerIndex(existing, line, 0) >= 0 {
+			return existing, nil
+		}
+		return appendLines(existing, []byte(line+"\n"), contents), nil
+	case WriteBlock:
+		return mergeBlock(existing, contents,
+			strings.Replace(marker, markerArg, "BEGIN", 1),
+			strings.Replace(marker, markerArg, "END", 1))
+	}
+	return nil, errors.Errorf("invalid write mode: %q", r.mode)
+}
+
+// mergeBlock replaces the lines from begin to end, inclusive, with the block
+// of the contents. Appending the block if there is no begin line.
+func mergeBlock(existing, contents []byte, begin, end string) ([]byte, error) {
+	block := appendLines([]byte(begin+"\n"), contents, []byte(end+"\n"))
+	start := markerIndex(existing, begin, 0)
+	if start < 0 {
+		return appendLines(existing, block), nil
+	}
+	stop := markerIndex(existing, end, start)
+	if stop < 0 {
+		return nil, errors.Errorf("managed block %q has no end marker", begin)
+	}
+	if i := bytes.IndexByte(existing[stop:], '\n'); i >= 0 {
+		stop += i + 1
+	} else {
+		stop = len(existing)
+	}
+
+	merged := make([]byte, 0, start+len(block)+len(existing)-stop)
+	merged = append(merged, existing[:start]...)
+	merged = append(merged, block...)
+	return append(merged, existing[stop:]...), nil
+}
+
+// markerIndex returns the offset of the first line, at or after from, that is
+// the marker (ignoring a trailing carriage return), or -1.
+func markerIndex(data []byte, marker string, from int) int {
+	for start := from; start < len(data); {
+		end := bytes.IndexByte(data[start:], '\n')
+		if end < 0 {
+			end = len(data)
+		} else {
+			end += start
+		}
+		if string(bytes.TrimSuffix(data[start:end], []byte("\r"))) == marker {
+			return start
+		}
+		start = end + 1
+	}
+	return -1
+}
+
+// appendLines appends the chunks to the data, each starting on a new line.
+func appendLines(data []byte, chunks ...[]byte) []byte {
+	out := append([]byte{}, data...)
+	for _, c := range chunks {
+		if len(c) == 0 {
+			continue
+		}
+		if len(out) > 0 && out[len(out)-1] != '\n' {
+			out = append(out, '\n')
+		}
+		out = append(out, c...)
+	}
+	return out
+}
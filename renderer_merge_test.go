@@ -0,0 +1,97 @@
+package hcat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderMerge(t *testing.T) {
+	t.Parallel()
+
+	outDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	// render renders the contents to the file, which starts with the
+	// existing contents, returning the file's contents after each render
+	render := func(t *testing.T, i FileRendererInput, existing string,
+		contents ...string) []string {
+		i.Path = filepath.Join(outDir, t.Name())
+		os.MkdirAll(filepath.Dir(i.Path), 0755)
+		if err := ioutil.WriteFile(i.Path, []byte(existing), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var files []string
+		for _, c := range contents {
+			if _, err := NewFileRenderer(i).Render([]byte(c)); err != nil {
+				t.Fatal(err)
+			}
+			b, err := ioutil.ReadFile(i.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, string(b))
+		}
+		return files
+	}
+
+	t.Run("block", func(t *testing.T) {
+		files := render(t, FileRendererInput{Mode: WriteBlock},
+			"user: root\n", "a: 1", "a: 2\n")
+		exp := []string{
+			"user: root\n# BEGIN HCAT MANAGED BLOCK\na: 1\n# END HCAT MANAGED BLOCK\n",
+			"user: root\n# BEGIN HCAT MANAGED BLOCK\na: 2\n# END HCAT MANAGED BLOCK\n",
+		}
+		for i := range exp {
+			if files[i] != exp[i] {
+				t.Errorf("bad render %d\nexp: %q\nact: %q", i, exp[i], files[i])
+			}
+		}
+	})
+
+	t.Run("block-preserves", func(t *testing.T) {
+		existing := "top\r\n// BEGIN web\r\nold\r\n// END web\r\nbottom"
+		files := render(t, FileRendererInput{Mode: WriteBlock,
+			Marker: "// %s web"}, existing, "new")
+		exp := "top\r\n// BEGIN web\nnew\n// END web\nbottom"
+		if files[0] != exp {
+			t.Errorf("bad render\nexp: %q\nact: %q", exp, files[0])
+		}
+	})
+
+	t.Run("append", func(t *testing.T) {
+		files := render(t, FileRendererInput{Mode: WriteAppend},
+			"start", "one\n", "one\n", "two\n")
+		if files[0] != files[1] {
+			t.Errorf("same contents appended twice: %q", files[1])
+		}
+		lines := strings.Split(strings.TrimSpace(files[2]), "\n")
+		if len(lines) != 5 || lines[0] != "start" || lines[2] != "one" ||
+			lines[4] != "two" ||
+			!strings.HasPrefix(lines[1], "# HCAT APPENDED ") {
+			t.Errorf("bad appended file: %q", files[2])
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		cases := map[string]FileRendererInput{
+			"unclosed-block": {Mode: WriteBlock},
+			"bad-marker":     {Mode: WriteBlock, Marker: "# block"},
+			"bad-mode":       {Mode: "prepend"},
+			"gzip":           {Mode: WriteAppend, Gzip: true},
+		}
+		for name, i := range cases {
+			i.Path = filepath.Join(outDir, name)
+			ioutil.WriteFile(i.Path,
+				[]byte("# BEGIN HCAT MANAGED BLOCK\nopen\n"), 0644)
+			if _, err := NewFileRenderer(i).Render([]byte("x")); err == nil {
+				t.Errorf("%s: expected error", name)
+			}
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package hcat
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// LineEnding is the line ending a FileRenderer normalizes the contents to,
+// see FileRendererInput's LineEnding.
+type LineEnding string
+
+const (
+	// LineEndingAsIs leaves the line endings as rendered, the default.
+	LineEndingAsIs LineEnding = ""
+	// LineEndingLF converts CRLF line endings to LF.
+	LineEndingLF LineEnding = "lf"
+	// LineEndingCRLF converts LF line endings to CRLF, eg. for Windows
+	// targets.
+	LineEndingCRLF LineEnding = "crlf"
+)
+
+// BOMMode is how a FileRenderer handles the UTF-8 byte order mark at the
+// start of the contents, see FileRendererInput's BOM.
+type BOMMode string
+
+const (
+	// BOMAsIs leaves the byte order mark as rendered, the default.
+	BOMAsIs BOMMode = ""
+	// BOMAdd adds the byte order mark if the contents don't start with it.
+	BOMAdd BOMMode = "add"
+	// BOMStrip removes the byte order mark, eg. for parsers that don't
+	// expect it.
+	BOMStrip BOMMode = "strip"
+)
+
+// utf8BOM is the UTF-8 byte order mark
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// normalizes returns if the renderer normalizes the contents.
+func (r FileRenderer) normalizes() bool {
+	return r.lineEnding != LineEndingAsIs || r.trailingNewline ||
+		r.bom != BOMAsIs
+}
+
+// normalize returns a copy of the contents with the line endings, trailing
+// newline and byte order mark normalized as set by the options.
+func (r FileRenderer) normalize(contents []byte) ([]byte, error) {
+	body := bytes.TrimPrefix(contents, utf8BOM)
+	hasBOM := len(body) < len(contents)
+
+	var bom bool
+	switch r.bom {
+	case BOMAsIs:
+		bom = hasBOM
+	case BOMAdd:
+		bom = true
+	case BOMStrip:
+	default:
+		return nil, errors.Errorf("unknown byte order mark mode %q", r.bom)
+	}
+
+	crlf := []byte("\r\n")
+	eol := []byte("\n")
+	switch r.lineEnding {
+	case LineEndingAsIs:
+		if bytes.Contains(body, crlf) {
+			eol = crlf // for the trailing newline
+		}
+	case LineEndingLF:
+		body = bytes.ReplaceAll(body, crlf, eol)
+	case LineEndingCRLF:
+		body = bytes.ReplaceAll(bytes.ReplaceAll(body, crlf, eol), eol, crlf)
+		eol = crlf
+	default:
+		return nil, errors.Errorf("unknown line ending %q", r.lineEnding)
+	}
+
+	out := make([]byte, 0, len(utf8BOM)+len(body)+len(eol))
+	if bom {
+		out = append(out, utf8BOM...)
+	}
+	out = append(out, body...)
+	if r.trailingNewline && len(body) > 0 &&
+		!bytes.HasSuffix(body, []byte("\n")) {
+		out = append(out, eol...)
+	}
+	return out, nil
+}
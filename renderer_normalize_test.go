@@ -0,0 +1,136 @@
+package hcat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderNormalize(t *testing.T) {
+	t.Parallel()
+
+	outDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	const bom = "\xEF\xBB\xBF"
+	cases := []struct {
+		name     string
+		input    FileRendererInput
+		contents string
+		exp      string
+	}{
+		{
+			"as-is",
+			FileRendererInput{},
+			bom + "a\r\nb",
+			bom + "a\r\nb",
+		},
+		{
+			"lf",
+			FileRendererInput{LineEnding: LineEndingLF},
+			"a\r\nb\nc\r\n",
+			"a\nb\nc\n",
+		},
+		{
+			"crlf",
+			FileRendererInput{LineEnding: LineEndingCRLF},
+			"a\r\nb\nc\n",
+			"a\r\nb\r\nc\r\n",
+		},
+		{
+			"trailing-newline",
+			FileRendererInput{TrailingNewline: true},
+			"a\nb",
+			"a\nb\n",
+		},
+		{
+			"trailing-newline-present",
+			FileRendererInput{TrailingNewline: true},
+			"a\nb\n",
+			"a\nb\n",
+		},
+		{
+			"trailing-newline-crlf",
+			FileRendererInput{TrailingNewline: true},
+			"a\r\nb",
+			"a\r\nb\r\n",
+		},
+		{
+			"trailing-newline-empty",
+			FileRendererInput{TrailingNewline: true},
+			"",
+			"",
+		},
+		{
+			"crlf-trailing-newline",
+			FileRendererInput{LineEnding: LineEndingCRLF,
+				TrailingNewline: true},
+			"a\nb",
+			"a\r\nb\r\n",
+		},
+		{
+			"bom-add",
+			FileRendererInput{BOM: BOMAdd},
+			"a",
+			bom + "a",
+		},
+		{
+			"bom-add-present",
+			FileRendererInput{BOM: BOMAdd},
+			bom + "a",
+			bom + "a",
+		},
+		{
+			"bom-strip",
+			FileRendererInput{BOM: BOMStrip, LineEnding: LineEndingLF},
+			bom + "a\r\n",
+			"a\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			i := tc.input
+			i.Path = filepath.Join(outDir, tc.name)
+			contents := []byte(tc.contents)
+			if _, err := NewFileRenderer(i).Render(contents); err != nil {
+				t.Fatal(err)
+			}
+			if string(contents) != tc.contents {
+				t.Error("the rendered contents were modified")
+			}
+			b, err := ioutil.ReadFile(i.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tc.exp {
+				t.Errorf("bad contents, expected %q, got %q", tc.exp, b)
+			}
+
+			// the normalized contents are unchanged, no re-render
+			rr, err := NewFileRenderer(i).Render(contents)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rr.DidRender {
+				t.Error("unexpected re-render")
+			}
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		for _, i := range []FileRendererInput{
+			{LineEnding: "cr"},
+			{BOM: "keep"},
+		} {
+			i.Path = filepath.Join(outDir, "unknown")
+			if _, err := NewFileRenderer(i).Render([]byte("a")); err == nil {
+				t.Errorf("expected error for %#v", i)
+			}
+		}
+	})
+}
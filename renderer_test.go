@@ -2,10 +2,14 @@ package hcat
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"testing"
 )
 
@@ -26,7 +30,7 @@ func TestAtomicWrite(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		if err := atomicWrite(outFile.Name(), nil, 0644, true); err != nil {
+		if err := atomicWrite(outFile.Name(), nil, 0644, true, writeOptions{}); err != nil {
 			t.Fatal(err)
 		}
 
@@ -47,7 +51,7 @@ func TestAtomicWrite(t *testing.T) {
 		}
 		os.Chmod(outFile.Name(), 0600)
 
-		if err := atomicWrite(outFile.Name(), nil, 0, true); err != nil {
+		if err := atomicWrite(outFile.Name(), nil, 0, true, writeOptions{}); err != nil {
 			t.Fatal(err)
 		}
 
@@ -72,7 +76,7 @@ func TestAtomicWrite(t *testing.T) {
 
 		// Try atomicWrite to a file that doesn't exist yet
 		file := filepath.Join(outDir, "nope/not/it/create")
-		if err := atomicWrite(file, nil, 0644, true); err != nil {
+		if err := atomicWrite(file, nil, 0644, true, writeOptions{}); err != nil {
 			t.Fatal(err)
 		}
 
@@ -81,6 +85,39 @@ func TestAtomicWrite(t *testing.T) {
 		}
 	})
 
+	t.Run("temp_dir_and_prefix", func(t *testing.T) {
+		outDir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(outDir)
+		tempDir := filepath.Join(outDir, "tmp")
+		if err := os.Mkdir(tempDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		file := filepath.Join(outDir, "out")
+		opts := writeOptions{
+			tempDir:        tempDir,
+			tempPrefix:     "hcat-",
+			skipFsync:      true,
+			fsyncParentDir: true,
+		}
+		if err := atomicWrite(file, []byte("foo"), 0644, false, opts); err != nil {
+			t.Fatal(err)
+		}
+
+		if b, err := ioutil.ReadFile(file); err != nil {
+			t.Fatal(err)
+		} else if string(b) != "foo" {
+			t.Fatalf("bad contents: %q", b)
+		}
+		// temp file was renamed out of the temp dir
+		if files, _ := ioutil.ReadDir(tempDir); len(files) != 0 {
+			t.Fatalf("temp dir not empty: %v", files)
+		}
+	})
+
 	t.Run("non_existent_no_create", func(t *testing.T) {
 		outDir, err := ioutil.TempDir("", "")
 		if err != nil {
@@ -91,7 +128,7 @@ func TestAtomicWrite(t *testing.T) {
 
 		// Try atomicWrite to a file that doesn't exist yet
 		file := filepath.Join(outDir, "nope/not/it/nope-no-create")
-		if err := atomicWrite(file, nil, 0644, false); err != errNoParentDir {
+		if err := atomicWrite(file, nil, 0644, false, writeOptions{}); err != errNoParentDir {
 			t.Fatalf("expected %q to be %q", err, errNoParentDir)
 		}
 	})
@@ -185,7 +222,8 @@ func TestBackup(t *testing.T) {
 		}
 
 		Backup(outFile.Name())
-		err = atomicWrite(outFile.Name(), []byte("second"), 0644, true)
+		err = atomicWrite(outFile.Name(), []byte("second"), 0644, true,
+			writeOptions{})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -302,4 +340,143 @@ func TestRender(t *testing.T) {
 				rr.WouldRender, rr.DidRender)
 		}
 	})
+	t.Run("encoded", func(t *testing.T) {
+		outDir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(outDir)
+		contents := []byte("first")
+
+		// decode reverses the encodings, in order, on the rendered file
+		decode := func(t *testing.T, path string, gz, b64 bool) []byte {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b64 {
+				if b, err = base64.StdEncoding.DecodeString(string(b)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if gz {
+				zr, err := gzip.NewReader(bytes.NewReader(b))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if b, err = ioutil.ReadAll(zr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return b
+		}
+
+		cases := []struct {
+			name    string
+			gz, b64 bool
+		}{
+			{"gzip", true, false},
+			{"base64", false, true},
+			{"gzip-base64", true, true},
+		}
+		for _, tc := range cases {
+			path := path.Join(outDir, tc.name)
+			fr := NewFileRenderer(FileRendererInput{
+				Path:   path,
+				Gzip:   tc.gz,
+				Base64: tc.b64,
+			})
+			rr, err := fr.Render(contents)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !rr.WouldRender || !rr.DidRender {
+				t.Fatalf("%s: bad render results; would: %v, did: %v",
+					tc.name, rr.WouldRender, rr.DidRender)
+			}
+			if act := decode(t, path, tc.gz, tc.b64); !bytes.Equal(act, contents) {
+				t.Errorf("%s: bad contents: %q", tc.name, act)
+			}
+
+			// same contents encode the same, no re-render
+			rr, err = fr.Render(contents)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !rr.WouldRender || rr.DidRender {
+				t.Errorf("%s: bad re-render results; would: %v, did: %v",
+					tc.name, rr.WouldRender, rr.DidRender)
+			}
+		}
+	})
+	t.Run("render-for", func(t *testing.T) {
+		outDir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(outDir)
+		path := path.Join(outDir, "dest")
+
+		var backups []string
+		dest := &FileRendererInput{
+			Path:   path,
+			Perms:  0600,
+			Backup: func(p string) { backups = append(backups, p) },
+		}
+		if runtime.GOOS != "windows" {
+			// the current user, so it works unprivileged
+			dest.User = strconv.Itoa(os.Getuid())
+		}
+		tmpl := NewTemplate(TemplateInput{Contents: "x", Destination: dest})
+		dest.Path = "" // changes to the input don't affect the template
+
+		for _, contents := range []string{"first", "second"} {
+			rr, err := RenderFor(tmpl, []byte(contents))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !rr.WouldRender || !rr.DidRender {
+				t.Fatalf("bad render results; would: %v, did: %v",
+					rr.WouldRender, rr.DidRender)
+			}
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != contents {
+				t.Errorf("bad contents: %q", b)
+			}
+		}
+		if info, err := os.Stat(path); err != nil || info.Mode() != 0600 {
+			t.Errorf("bad file: %v, %v", info, err)
+		}
+		if len(backups) != 2 || backups[0] != path {
+			t.Errorf("bad backups: %v", backups)
+		}
+	})
+	t.Run("render-for-no-destination", func(t *testing.T) {
+		tmpl := NewTemplate(TemplateInput{Contents: "x"})
+		if _, err := RenderFor(tmpl, []byte("x")); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+	t.Run("unknown-owner", func(t *testing.T) {
+		outDir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(outDir)
+		path := path.Join(outDir, "owned")
+
+		fr := NewFileRenderer(FileRendererInput{
+			Path: path,
+			User: "hcat-no-such-user",
+		})
+		if _, err := fr.Render([]byte("first")); err == nil {
+			t.Fatal("expected error")
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("file should not exist: %v", err)
+		}
+	})
 }
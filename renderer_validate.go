@@ -0,0 +1,80 @@
+package hcat
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ValidateFunc validates a rendered file before it replaces the destination,
+// eg. checking its syntax. It is passed the path of the staged temporary file
+// and returning an error aborts the write.
+type ValidateFunc func(path string) error
+
+// ValidationError is the error returned when validating a rendered file fails
+// (see FileRendererInput's Validate and ValidateCommand), the destination is
+// left as is. Use errors.As to check for it.
+type ValidationError struct {
+	// Path is the destination of the file
+	Path string
+	// Output is the combined output of the ValidateCommand, if it was run
+	Output []byte
+	// Err is the error returned by the ValidateFunc or command
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	msg := fmt.Sprintf("validation of %s failed: %v", e.Path, e.Err)
+	if out := strings.TrimSpace(string(e.Output)); out != "" {
+		msg += ": " + out
+	}
+	return msg
+}
+
+// Unwrap returns the validation error
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// defaultValidateTimeout is how long the ValidateCommand can run when the
+// FileRendererInput's ValidateTimeout isn't set.
+const defaultValidateTimeout = 30 * time.Second
+
+// validatePathArg is replaced in the ValidateCommand's arguments by the path
+// of the file being validated.
+const validatePathArg = "%s"
+
+// validate runs the renderer's validation, if any, on the staged file.
+func (r FileRenderer) validate(tempName string) error {
+	if r.validateFunc != nil {
+		if err := r.validateFunc(tempName); err != nil {
+			return &ValidationError{Path: r.path, Err: err}
+		}
+	}
+	if len(r.validateCommand) > 0 {
+		args := make([]string, len(r.validateCommand))
+		for i, arg := range r.validateCommand {
+			args[i] = strings.ReplaceAll(arg, validatePathArg, tempName)
+		}
+		timeout := r.validateTimeout
+		if timeout <= 0 {
+			timeout = defaultValidateTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		var out bytes.Buffer
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdout, cmd.Stderr = &out, &out
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("timed out after %s", timeout)
+			}
+			return &ValidationError{Path: r.path, Output: out.Bytes(),
+				Err: err}
+		}
+	}
+	return nil
+}
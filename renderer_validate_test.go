@@ -0,0 +1,138 @@
+package hcat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	idep "github.com/hashicorp/hcat/internal/dependency"
+	"github.com/pkg/errors"
+)
+
+func TestFileRendererValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// validFunc accepts the contents starting with "ok"
+	validFunc := func(path string) error {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(string(b), "ok") {
+			return errors.New("invalid")
+		}
+		return nil
+	}
+
+	t.Run("func", func(t *testing.T) {
+		path := filepath.Join(dir, "func")
+		r := NewFileRenderer(FileRendererInput{Path: path, Validate: validFunc})
+
+		if _, err := r.Render([]byte("ok 1")); err != nil {
+			t.Fatal(err)
+		}
+		_, err := r.Render([]byte("bad"))
+		var verr *ValidationError
+		if !errors.As(err, &verr) || verr.Path != path {
+			t.Fatalf("bad error: %v", err)
+		}
+		if b, _ := ioutil.ReadFile(path); string(b) != "ok 1" {
+			t.Errorf("invalid contents written: %q", b)
+		}
+		if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+			t.Errorf("temporary file left behind: %d files", len(files))
+		}
+	})
+
+	t.Run("command", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("no grep on windows")
+		}
+		path := filepath.Join(dir, "command")
+		r := NewFileRenderer(FileRendererInput{
+			Path:            path,
+			ValidateCommand: []string{"grep", "-q", "^ok", "%s"},
+		})
+
+		if _, err := r.Render([]byte("ok 1")); err != nil {
+			t.Fatal(err)
+		}
+		_, err := r.Render([]byte("bad"))
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("bad error: %v", err)
+		}
+		if b, _ := ioutil.ReadFile(path); string(b) != "ok 1" {
+			t.Errorf("invalid contents written: %q", b)
+		}
+	})
+
+	t.Run("command-timeout", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("no sleep on windows")
+		}
+		path := filepath.Join(dir, "command-timeout")
+		r := NewFileRenderer(FileRendererInput{
+			Path:            path,
+			ValidateCommand: []string{"sleep", "10"},
+			ValidateTimeout: 50 * time.Millisecond,
+		})
+
+		start := time.Now()
+		_, err := r.Render([]byte("ok"))
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("bad error: %v", err)
+		}
+		if !strings.Contains(err.Error(), "timed out after 50ms") {
+			t.Errorf("bad error: %v", err)
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Error("command not killed on timeout")
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("file written: %v", err)
+		}
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		st := NewStore()
+		tx := NewRenderTransaction(NewResolver(),
+			txWatcher{Store: st, complete: true})
+		for name, contents := range map[string]string{
+			"tx-good": "ok", "tx-bad": "bad"} {
+			d := &idep.FakeDep{Name: name}
+			st.Save(d.ID(), contents)
+			tx.Add(NewTemplate(TemplateInput{
+				Name:         name,
+				Contents:     `{{ echo "` + name + `" }}`,
+				FuncMapMerge: template.FuncMap{"echo": echoFunc},
+			}), NewFileRenderer(FileRendererInput{
+				Path: filepath.Join(dir, name), Validate: validFunc,
+			}))
+		}
+
+		_, err := tx.Run()
+		var verr *ValidationError
+		if !errors.As(err, &verr) ||
+			verr.Path != filepath.Join(dir, "tx-bad") {
+			t.Fatalf("bad error: %v", err)
+		}
+		// neither is rendered
+		for _, name := range []string{"tx-good", "tx-bad"} {
+			_, err := os.Stat(filepath.Join(dir, name))
+			if !os.IsNotExist(err) {
+				t.Errorf("file rendered: %s", name)
+			}
+		}
+	})
+}
@@ -1,12 +1,53 @@
 package hcat
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
 // Resolver is responsible rendering Templates and invoking Commands.
-// Empty but reserving the space for future use.
-type Resolver struct{}
+type Resolver struct {
+	// dryRun, when set, captures executed template output in place of
+	// having it rendered.
+	dryRun *DryRunSink
+
+	// staleTimeout, when set, is how long to wait for all of a template's
+	// dependencies before rendering it with partial data.
+	staleTimeout time.Duration
+	// waiting tracks the incomplete templates for the stale timeout, by ID
+	waiting map[string]*waitState
+	// hashes are the hashes of the templates' last rendered contents, by ID
+	hashes map[string]string
+	// parallelism is the maximum number of templates RunAll executes at once
+	parallelism int
+	// preExecute and postExecute are the hooks run around each execution
+	preExecute  []ResolverHook
+	postExecute []ResolverHook
+	// quarantine, when set, quarantines repeatedly failing templates
+	quarantine *quarantine
+	sync.Mutex
+}
+
+// ResolverHook is a function run by the Resolver before or after executing a
+// template, see AddPreExecuteHook and AddPostExecuteHook. Returning an error
+// vetoes the run, Run returns the error and the template isn't rendered.
+type ResolverHook func(Templater, *ResolveEvent) error
+
+// waitState is when an incomplete template started waiting for its
+// dependencies and if it has gone stale.
+type waitState struct {
+	since time.Time
+	stale bool
+}
 
 // ResolveEvent captures the whether the template dependencies have all been
 // resolved and rendered in memory.
 type ResolveEvent struct {
+	// ID is the ID of the template that was run.
+	ID string
+
 	// Complete is true if all dependencies have values and the template
 	// is fully rendered (in memory).
 	Complete bool
@@ -16,8 +57,39 @@ type ResolveEvent struct {
 	Contents []byte
 
 	// NoChange is true if no dependencies have changes in values and therefore
-	// templates were not re-rendered.
+	// templates were not re-rendered, or if they were but the Contents are the
+	// same as the last ones recorded as rendered (see Resolver.Rendered).
+	// Either way there is no need to render the Contents again.
 	NoChange bool
+
+	// Hash is the hex encoded SHA-256 checksum of the Contents.
+	Hash string
+
+	// DryRun is true if the resolver is in dry-run mode. The Contents have
+	// been captured by the DryRunSink and should not be passed on to the
+	// template's Renderer.
+	DryRun bool
+
+	// Stale is true if the template's stale-render timeout passed without
+	// all of its dependencies returning values. It is marked Complete, so it
+	// is rendered, with the values it has. See Resolver.SetStaleTimeout.
+	Stale bool
+
+	// Missing are the IDs of the dependencies without values when Stale.
+	Missing []string
+
+	// Calls are the data returning function calls made rendering the
+	// Contents, when the template is audited (see TemplateInput's Audit).
+	Calls []FuncCall
+
+	// Quarantined is true if the template wasn't executed because it is
+	// quarantined for failing repeatedly. See Resolver.SetQuarantine.
+	Quarantined bool
+
+	// Sensitive is true if the template's output is sensitive (see
+	// TemplateInput's Sensitive). Hooks and handlers shouldn't log or keep
+	// the Contents.
+	Sensitive bool
 }
 
 // Basic constructor, here for consistency and future flexibility.
@@ -25,6 +97,191 @@ func NewResolver() *Resolver {
 	return &Resolver{}
 }
 
+// SetDryRun puts the resolver into dry-run mode. Templates are still resolved
+// and executed but their output is recorded in the sink instead of being
+// rendered. Passing nil turns dry-run mode off.
+func (r *Resolver) SetDryRun(sink *DryRunSink) {
+	r.Lock()
+	defer r.Unlock()
+	r.dryRun = sink
+}
+
+// SetStaleTimeout enables rendering templates with partial data. If all of a
+// template's dependencies haven't returned values within the timeout of it
+// first being run, it is marked Complete anyway and the event is flagged as
+// Stale, listing the missing dependencies. So bootstrapping doesn't block
+// forever on one unhealthy backend. Passing 0 turns it off.
+//
+// The timeout is only checked when the template is Run, so use a Wait with a
+// deadline (context.WithTimeout) to make sure it is run again after it.
+func (r *Resolver) SetStaleTimeout(timeout time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	r.staleTimeout = timeout
+}
+
+// SetParallelism sets the maximum number of templates RunAll executes
+// concurrently. Values less than 2 (the default) run them one at a time.
+func (r *Resolver) SetParallelism(n int) {
+	r.Lock()
+	defer r.Unlock()
+	r.parallelism = n
+}
+
+// AddPreExecuteHook adds a hook run before each template is executed, eg. to
+// time the execution or to veto it. The event only has the ID set. Hooks are
+// run in the order they were added, stopping at the first error.
+func (r *Resolver) AddPreExecuteHook(h ResolverHook) {
+	r.Lock()
+	defer r.Unlock()
+	r.preExecute = append(r.preExecute, h)
+}
+
+// AddPostExecuteHook adds a hook run after each successful template
+// execution with the resulting event, which it can modify (eg. to annotate
+// the Contents) or veto. Hooks are run in the order they were added, stopping
+// at the first error. The event's Hash, and NoChange for contents that are
+// the same as the last rendered ones, are set once the hooks succeed.
+func (r *Resolver) AddPostExecuteHook(h ResolverHook) {
+	r.Lock()
+	defer r.Unlock()
+	r.postExecute = append(r.postExecute, hideSensitive(h))
+}
+
+// AddSensitivePostExecuteHook adds a post-execute hook, like
+// AddPostExecuteHook, that also gets the Contents of sensitive templates (see
+// TemplateInput's Sensitive). The hooks added with AddPostExecuteHook get
+// their events without the Contents.
+func (r *Resolver) AddSensitivePostExecuteHook(h ResolverHook) {
+	r.Lock()
+	defer r.Unlock()
+	r.postExecute = append(r.postExecute, h)
+}
+
+// hideSensitive wraps the hook to run it with the events of sensitive
+// templates without their Contents. Changes the hook makes to them are
+// dropped.
+func hideSensitive(h ResolverHook) ResolverHook {
+	return func(tmpl Templater, event *ResolveEvent) error {
+		if !event.Sensitive {
+			return h(tmpl, event)
+		}
+		contents := event.Contents
+		event.Contents = nil
+		defer func() { event.Contents = contents }()
+		return h(tmpl, event)
+	}
+}
+
+// runHooks runs the hooks with the template and event, returning the first
+// error.
+func runHooks(hooks []ResolverHook, tmpl Templater, event *ResolveEvent) error {
+	for _, h := range hooks {
+		if err := h(tmpl, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkStale updates the event for the template's stale-render timeout.
+func (r *Resolver) checkStale(event *ResolveEvent, tmpl Templater,
+	w Watcherer) {
+	r.Lock()
+	defer r.Unlock()
+	if r.staleTimeout <= 0 {
+		return
+	}
+	if event.Complete {
+		delete(r.waiting, event.ID)
+		return
+	}
+
+	if r.waiting == nil {
+		r.waiting = make(map[string]*waitState)
+	}
+	state, ok := r.waiting[event.ID]
+	if !ok {
+		state = &waitState{since: time.Now()}
+		r.waiting[event.ID] = state
+	}
+	if time.Since(state.since) < r.staleTimeout {
+		return
+	}
+
+	event.Complete = true
+	event.Stale = true
+	if m, ok := w.(missingReporter); ok {
+		event.Missing = m.Missing(tmpl)
+	}
+	// always render the first time it goes stale
+	if !state.stale {
+		state.stale = true
+		event.NoChange = false
+	}
+}
+
+// checkHash sets the event's Hash and marks it NoChange if the Contents are
+// the same as the last rendered ones. Only complete contents are compared
+// so the first complete run is never mistaken for a partial one. The hash is
+// only recorded once the contents are rendered, see Rendered.
+func (r *Resolver) checkHash(event *ResolveEvent) {
+	sum := sha256.Sum256(event.Contents)
+	event.Hash = hex.EncodeToString(sum[:])
+	if !event.Complete || event.NoChange || event.Contents == nil {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if h, ok := r.hashes[event.ID]; ok && h == event.Hash {
+		event.NoChange = true
+	}
+}
+
+// Rendered records that the event's Contents were rendered, so the next runs
+// of the template with the same Contents are marked NoChange. Call it once
+// the Contents are written, a failed render shouldn't be recorded. Dry-run
+// events and events without new Contents (incomplete, NoChange) are ignored.
+// RunLoop, RunOnce and RenderTransaction call it for you.
+func (r *Resolver) Rendered(event ResolveEvent) {
+	if event.DryRun || !event.Complete || event.NoChange ||
+		event.Contents == nil {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if r.hashes == nil {
+		r.hashes = make(map[string]string)
+	}
+	r.hashes[event.ID] = event.Hash
+}
+
+// missingReporter is implemented by Watcherers that can list the
+// dependencies a template is waiting on. Implemented by Watcher.
+type missingReporter interface {
+	Missing(Notifier) []string
+}
+
+// denyReporter is implemented by Watcherers that can deny templates the use
+// of dependencies. Implemented by Watcher.
+type denyReporter interface {
+	Denied(Notifier) error
+}
+
+// sensitiveReporter is implemented by Templaters whose output can be marked
+// sensitive. Implemented by Template.
+type sensitiveReporter interface {
+	Sensitive() bool
+}
+
+// callReporter is implemented by Templaters that can report the function
+// calls of their last execution. Implemented by Template.
+type callReporter interface {
+	Calls() []FuncCall
+}
+
 // Watcherer is the subset of the Watcher's API that the resolver needs.
 // The interface is used to make the used/required API explicit.
 type Watcherer interface {
@@ -54,6 +311,23 @@ type Collector interface {
 // output returns Complete as true. It uses the watcher for dependency
 // lookup state. The content will be updated each pass until complete.
 func (r *Resolver) Run(tmpl Templater, w Watcherer) (ResolveEvent, error) {
+	r.Lock()
+	preExecute, postExecute := r.preExecute, r.postExecute
+	q, dryRun := r.quarantine, r.dryRun
+	r.Unlock()
+
+	if q != nil && q.isQuarantined(tmpl.ID()) {
+		return ResolveEvent{ID: tmpl.ID(), NoChange: true,
+			Quarantined: true}, nil
+	}
+
+	if err := runHooks(preExecute, tmpl,
+		&ResolveEvent{ID: tmpl.ID()}); err != nil {
+		if dryRun != nil {
+			dryRun.record(DryRunResult{ID: tmpl.ID(), Err: err})
+		}
+		return ResolveEvent{}, err
+	}
 
 	// If Watcherer supports it, wrap the template call with the Mark-n-Sweep
 	// garbage collector to stop and dereference the old/unused views.
@@ -74,15 +348,175 @@ func (r *Resolver) Run(tmpl Templater, w Watcherer) (ResolveEvent, error) {
 	output, err := gcViews(func() ([]byte, error) {
 		return tmpl.Execute(w.Recaller(tmpl))
 	})
+	if d, ok := w.(denyReporter); ok && (err == nil || err == ErrNoNewValues) {
+		if derr := d.Denied(tmpl); derr != nil {
+			err = derr
+		}
+	}
+	// failed records the failed execution for the quarantine and dry-run
+	failed := func(err error) (ResolveEvent, error) {
+		if q != nil {
+			q.executed(tmpl.ID(), err)
+		}
+		if dryRun != nil {
+			dryRun.record(DryRunResult{ID: tmpl.ID(), Err: err})
+		}
+		return ResolveEvent{}, err
+	}
 	switch {
 	case err == ErrNoNewValues || err == nil:
 	default:
-		return ResolveEvent{}, err
+		return failed(err)
 	}
 
-	return ResolveEvent{
+	event := ResolveEvent{
+		ID:       tmpl.ID(),
 		Complete: w.Complete(tmpl),
 		Contents: output,
 		NoChange: err == ErrNoNewValues,
-	}, nil
+	}
+	if a, ok := tmpl.(callReporter); ok {
+		event.Calls = a.Calls()
+	}
+	if s, ok := tmpl.(sensitiveReporter); ok {
+		event.Sensitive = s.Sensitive()
+	}
+	r.checkStale(&event, tmpl, w)
+	if event.Sensitive && event.Stale && !event.NoChange && output == nil {
+		// sensitive templates don't keep their contents, so execute it again
+		// for its first stale render
+		tmpl.Notify(nil)
+		if event.Contents, err = tmpl.Execute(w.Recaller(tmpl)); err != nil {
+			return failed(err)
+		}
+	}
+	if err := runHooks(postExecute, tmpl, &event); err != nil {
+		return failed(err)
+	}
+	// hash the contents as the hooks left them
+	r.checkHash(&event)
+	// only an execution resets the failures, not a run without new values
+	if q != nil && err != ErrNoNewValues {
+		q.executed(tmpl.ID(), nil)
+	}
+	if dryRun != nil {
+		event.DryRun = true
+		result := DryRunResult{
+			ID:        tmpl.ID(),
+			Complete:  event.Complete,
+			Contents:  event.Contents,
+			Sensitive: event.Sensitive,
+		}
+		if event.Sensitive {
+			result.Contents = nil
+		}
+		dryRun.record(result)
+	}
+	return event, nil
+}
+
+// RunAll runs all the templates, like Run, returning their events in the
+// same order. Up to the resolver's parallelism (see SetParallelism) templates
+// are executed concurrently, so a set of templates that all have new data
+// renders in less time. The templates share the Watcherer, which needs to be
+// safe for concurrent use (Watcher is).
+//
+// All the templates are run even if some return errors, the first error (in
+// template order) is returned along with the events. The events of the
+// templates with errors are empty.
+func (r *Resolver) RunAll(tmpls []Templater, w Watcherer) (
+	[]ResolveEvent, error) {
+	r.Lock()
+	n := r.parallelism
+	r.Unlock()
+	if n < 1 {
+		n = 1
+	}
+
+	events := make([]ResolveEvent, len(tmpls))
+	errs := make([]error, len(tmpls))
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for i, tmpl := range tmpls {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, tmpl Templater) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			events[i], errs[i] = r.Run(tmpl, w)
+		}(i, tmpl)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return events, err
+		}
+	}
+	return events, nil
+}
+
+// DryRunResult is the captured outcome of the latest execution of a template
+// while in dry-run mode.
+type DryRunResult struct {
+	// ID is the ID of the template.
+	ID string
+	// Complete is true if all the template's dependencies had values.
+	Complete bool
+	// Contents is the executed output of the template, nil if it is
+	// Sensitive.
+	Contents []byte
+	// Sensitive is true if the template's output is sensitive, and so
+	// redacted from the Contents.
+	Sensitive bool
+	// Err is the error returned from executing the template, if any.
+	Err error
+}
+
+// DryRunSink collects the per-template results when the Resolver is in
+// dry-run mode. Useful for validating template and data combinations (eg. in
+// CI) without writing anything out.
+type DryRunSink struct {
+	sync.Mutex
+	order   []string
+	results map[string]DryRunResult
+}
+
+// NewDryRunSink returns a new, empty DryRunSink.
+func NewDryRunSink() *DryRunSink {
+	return &DryRunSink{
+		results: make(map[string]DryRunResult),
+	}
+}
+
+// record stores the result, replacing any previous result for the template.
+func (s *DryRunSink) record(r DryRunResult) {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.results[r.ID]; !ok {
+		s.order = append(s.order, r.ID)
+	}
+	s.results[r.ID] = r
+}
+
+// Result returns the latest result for the template ID.
+func (s *DryRunSink) Result(id string) (DryRunResult, bool) {
+	s.Lock()
+	defer s.Unlock()
+	r, ok := s.results[id]
+	return r, ok
+}
+
+// Results returns the latest result for each template in the order the
+// templates were first seen.
+func (s *DryRunSink) Results() []DryRunResult {
+	s.Lock()
+	defer s.Unlock()
+	results := make([]DryRunResult, 0, len(s.order))
+	for _, id := range s.order {
+		results = append(results, s.results[id])
+	}
+	return results
 }
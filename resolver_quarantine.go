@@ -0,0 +1,161 @@
+package hcat
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/hcat/events"
+)
+
+// quarantine tracks the consecutive failures of the templates and the ones
+// quarantined for them, see Resolver.SetQuarantine.
+type quarantine struct {
+	sync.Mutex
+	limit int
+	event events.EventHandler
+	// failures are the consecutive execute and render failures, by ID
+	failures map[string]*failureStreak
+	// quarantined are the quarantined templates' events, by ID
+	quarantined map[string]events.TemplateQuarantined
+}
+
+// failureStreak counts a template's consecutive failures. They are counted
+// separately as a successful execution doesn't mean its output renders.
+type failureStreak struct {
+	execute int
+	render  int
+}
+
+func newQuarantine(limit int, event events.EventHandler) *quarantine {
+	if event == nil {
+		event = func(events.Event) {}
+	}
+	return &quarantine{
+		limit:       limit,
+		event:       event,
+		failures:    make(map[string]*failureStreak),
+		quarantined: make(map[string]events.TemplateQuarantined),
+	}
+}
+
+// SetQuarantine enables quarantining templates that fail repeatedly. After
+// the given number of consecutive failures to execute (errors from the
+// template, denied dependencies or post-execute hooks) or to render (see
+// ReportRender) a template is quarantined. Run doesn't execute a quarantined
+// template, it returns an event marked Quarantined (and not Complete), until
+// it is unquarantined with Unquarantine, eg. after the template or its data
+// is fixed. So a broken template doesn't burn CPU failing over and over.
+//
+// The handler, which can be nil, is called with a TemplateQuarantined event
+// when a template is quarantined and a TemplateUnquarantined one when it is
+// unquarantined. Passing 0 turns it off, unquarantining all the templates.
+func (r *Resolver) SetQuarantine(failures int, handler events.EventHandler) {
+	r.Lock()
+	defer r.Unlock()
+	r.quarantine = nil
+	if failures > 0 {
+		r.quarantine = newQuarantine(failures, handler)
+	}
+}
+
+// Quarantined returns the IDs of the quarantined templates, sorted.
+func (r *Resolver) Quarantined() []string {
+	q := r.getQuarantine()
+	if q == nil {
+		return nil
+	}
+	q.Lock()
+	defer q.Unlock()
+	ids := make([]string, 0, len(q.quarantined))
+	for id := range q.quarantined {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Unquarantine lets the quarantined template be run again, with its failure
+// count reset. Returns false if the template wasn't quarantined.
+func (r *Resolver) Unquarantine(id string) bool {
+	q := r.getQuarantine()
+	if q == nil {
+		return false
+	}
+	q.Lock()
+	_, ok := q.quarantined[id]
+	delete(q.quarantined, id)
+	delete(q.failures, id)
+	q.Unlock()
+	if ok {
+		q.event(events.TemplateUnquarantined{ID: id})
+	}
+	return ok
+}
+
+// ReportRender records the outcome of rendering the template's contents, for
+// the render failures counted by the quarantine (see SetQuarantine). A nil
+// error resets the template's render failure count. A no-op when the
+// quarantine is off.
+func (r *Resolver) ReportRender(id string, err error) {
+	if q := r.getQuarantine(); q != nil {
+		q.record(id, err, func(s *failureStreak) *int { return &s.render })
+	}
+}
+
+func (r *Resolver) getQuarantine() *quarantine {
+	r.Lock()
+	defer r.Unlock()
+	return r.quarantine
+}
+
+// isQuarantined returns if the template is quarantined.
+func (q *quarantine) isQuarantined(id string) bool {
+	q.Lock()
+	defer q.Unlock()
+	_, ok := q.quarantined[id]
+	return ok
+}
+
+// executed records the outcome of executing the template.
+func (q *quarantine) executed(id string, err error) {
+	q.record(id, err, func(s *failureStreak) *int { return &s.execute })
+}
+
+// record adds the failure to, or with a nil error resets, the template's
+// streak picked by count. Quarantining it if it reaches the limit.
+func (q *quarantine) record(id string, err error,
+	count func(*failureStreak) *int) {
+	q.Lock()
+	if _, ok := q.quarantined[id]; ok {
+		q.Unlock()
+		return
+	}
+	streak, ok := q.failures[id]
+	if !ok {
+		if err == nil {
+			q.Unlock()
+			return
+		}
+		streak = &failureStreak{}
+		q.failures[id] = streak
+	}
+	n := count(streak)
+	if err == nil {
+		*n = 0
+		if streak.execute == 0 && streak.render == 0 {
+			delete(q.failures, id)
+		}
+		q.Unlock()
+		return
+	}
+	*n++
+	if *n < q.limit {
+		q.Unlock()
+		return
+	}
+	e := events.TemplateQuarantined{ID: id, Failures: *n, Error: err}
+	q.quarantined[id] = e
+	delete(q.failures, id)
+	q.Unlock()
+	q.event(e)
+}
@@ -0,0 +1,128 @@
+package hcat
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"text/template"
+
+	"github.com/hashicorp/hcat/events"
+)
+
+func TestResolverQuarantine(t *testing.T) {
+	t.Parallel()
+
+	fail := errors.New("fail")
+	failing := func() *Template {
+		return NewTemplate(TemplateInput{
+			Contents: `{{fail}}`,
+			FuncMapMerge: template.FuncMap{
+				"fail": func() (string, error) { return "", fail },
+			},
+		})
+	}
+
+	t.Run("execute", func(t *testing.T) {
+		rv := NewResolver()
+		var evs []events.Event
+		rv.SetQuarantine(2, func(e events.Event) { evs = append(evs, e) })
+		w := blindWatcher()
+		defer w.Stop()
+		tt := failing()
+		w.Register(tt)
+
+		// new data each time, as only then is it executed again
+		for i := 0; i < 2; i++ {
+			tt.Notify(nil)
+			if _, err := rv.Run(tt, w); err == nil {
+				t.Fatal("expected error")
+			}
+		}
+		tt.Notify(nil)
+		r, err := rv.Run(tt, w)
+		if err != nil {
+			t.Fatal("Run() error:", err)
+		}
+		if !r.Quarantined || r.Complete || !r.NoChange {
+			t.Errorf("bad quarantined event: %#v", r)
+		}
+		if ids := rv.Quarantined(); !reflect.DeepEqual(ids, []string{tt.ID()}) {
+			t.Errorf("bad quarantined: %v", ids)
+		}
+		if len(evs) != 1 {
+			t.Fatalf("expected 1 event, got %v", evs)
+		}
+		e, ok := evs[0].(events.TemplateQuarantined)
+		if !ok || e.ID != tt.ID() || e.Failures != 2 || !errors.Is(e.Error, fail) {
+			t.Errorf("bad quarantine event: %#v", evs[0])
+		}
+
+		if !rv.Unquarantine(tt.ID()) {
+			t.Error("expected template to be quarantined")
+		}
+		if rv.Unquarantine(tt.ID()) {
+			t.Error("template still quarantined")
+		}
+		if _, ok := evs[len(evs)-1].(events.TemplateUnquarantined); !ok {
+			t.Errorf("bad unquarantine event: %#v", evs[len(evs)-1])
+		}
+		// the failure count starts over
+		tt.Notify(nil)
+		if _, err := rv.Run(tt, w); err == nil {
+			t.Fatal("expected error")
+		}
+		if len(rv.Quarantined()) != 0 {
+			t.Error("quarantined after 1 failure")
+		}
+	})
+
+	t.Run("consecutive", func(t *testing.T) {
+		rv := NewResolver()
+		rv.SetQuarantine(2, nil)
+		w := blindWatcher()
+		defer w.Stop()
+		tt := echoTemplate("foo")
+		w.Register(tt)
+
+		veto := true
+		rv.AddPostExecuteHook(func(Templater, *ResolveEvent) error {
+			if veto {
+				return fail
+			}
+			return nil
+		})
+		for i := 0; i < 3; i++ {
+			veto = i != 1
+			tt.Notify(nil)
+			rv.Run(tt, w)
+		}
+		if len(rv.Quarantined()) != 0 {
+			t.Error("quarantined without consecutive failures")
+		}
+	})
+
+	t.Run("render", func(t *testing.T) {
+		rv := NewResolver()
+		rv.SetQuarantine(2, nil)
+		w := blindWatcher()
+		defer w.Stop()
+		tt := echoTemplate("foo")
+		w.Register(tt)
+
+		for i := 0; i < 2; i++ {
+			if _, err := rv.Run(tt, w); err != nil {
+				t.Fatal("Run() error:", err)
+			}
+			rv.ReportRender(tt.ID(), fail)
+		}
+		r, _ := rv.Run(tt, w)
+		if !r.Quarantined {
+			t.Errorf("render failures not quarantined: %#v", r)
+		}
+
+		rv.SetQuarantine(0, nil)
+		if r, _ := rv.Run(tt, w); r.Quarantined {
+			t.Error("quarantined with the policy off")
+		}
+	})
+}
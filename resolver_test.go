@@ -2,7 +2,12 @@ package hcat
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"text/template"
 	"time"
@@ -100,6 +105,123 @@ func TestResolverRun(t *testing.T) {
 		}
 	})
 
+	t.Run("same-contents-no-change", func(t *testing.T) {
+		rv := NewResolver()
+		w := blindWatcher()
+		defer w.Stop()
+		tt := echoTemplate("foo")
+		w.Register(tt)
+		d := &idep.FakeDep{Name: "foo"}
+		v := w.track(tt, d)
+		save := func(value string) {
+			v.store(value)
+			w.cache.Save(v.ID(), value)
+			tt.Notify(nil) // re-execute the template
+		}
+		hash := func(s string) string {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:])
+		}
+
+		for i, tc := range []struct {
+			value    string
+			noChange bool
+			rendered bool
+		}{
+			{"bar", false, false}, // failed to render
+			{"bar", false, true},
+			{"bar", true, true}, // re-executed, same contents
+			{"baz", false, true},
+		} {
+			save(tc.value)
+			r, err := rv.Run(tt, w)
+			if err != nil {
+				t.Fatal("Run() error:", err)
+			}
+			if !r.Complete || string(r.Contents) != tc.value {
+				t.Fatalf("%d: bad event: %#v", i, r)
+			}
+			if r.NoChange != tc.noChange {
+				t.Errorf("%d: NoChange should be %v", i, tc.noChange)
+			}
+			if r.Hash != hash(tc.value) {
+				t.Errorf("%d: bad hash: %s", i, r.Hash)
+			}
+			if tc.rendered {
+				rv.Rendered(r)
+			}
+		}
+	})
+
+	t.Run("dry-run-not-rendered", func(t *testing.T) {
+		rv := NewResolver()
+		w := blindWatcher()
+		defer w.Stop()
+		tt := echoTemplate("foo")
+		w.Register(tt)
+		d := &idep.FakeDep{Name: "foo"}
+		v := w.track(tt, d)
+		v.store("bar")
+		w.cache.Save(v.ID(), "bar")
+
+		rv.SetDryRun(NewDryRunSink())
+		r, err := rv.Run(tt, w)
+		if err != nil {
+			t.Fatal("Run() error:", err)
+		}
+		if !r.DryRun || r.NoChange {
+			t.Fatalf("bad dry-run event: %#v", r)
+		}
+		rv.Rendered(r)
+
+		rv.SetDryRun(nil)
+		tt.Notify(nil)
+		r, err = rv.Run(tt, w)
+		if err != nil {
+			t.Fatal("Run() error:", err)
+		}
+		if r.NoChange {
+			t.Fatal("dry-run shouldn't count as rendered")
+		}
+	})
+
+	t.Run("sensitive-no-new-values-keeps-hash", func(t *testing.T) {
+		rv := NewResolver()
+		w := blindWatcher()
+		defer w.Stop()
+		tt := NewTemplate(TemplateInput{
+			Contents:     `{{echo "foo"}}`,
+			FuncMapMerge: template.FuncMap{"echo": echoFunc},
+			Sensitive:    true,
+		})
+		w.Register(tt)
+		d := &idep.FakeDep{Name: "foo"}
+		v := w.track(tt, d)
+		v.store("bar")
+		w.cache.Save(v.ID(), "bar")
+
+		for i, tc := range []struct {
+			notify   bool
+			noChange bool
+		}{
+			{true, false},
+			{false, true}, // no new values, no contents
+			{true, true},  // re-executed, same contents
+		} {
+			if tc.notify {
+				tt.Notify(nil)
+			}
+			r, err := rv.Run(tt, w)
+			if err != nil {
+				t.Fatal("Run() error:", err)
+			}
+			if r.NoChange != tc.noChange {
+				t.Errorf("%d: NoChange should be %v", i, tc.noChange)
+			}
+			rv.Rendered(r)
+		}
+	})
+
 	t.Run("not-dirty-should-not-mean-complete", func(t *testing.T) {
 		// Tests a situation where template has unresolved dependencies
 		// but they don't count against complete as they haven't been
@@ -344,6 +466,466 @@ func TestResolverRun(t *testing.T) {
 		}
 		t.Error("Updating data failed?!?")
 	})
+
+	t.Run("explicit-dependencies", func(t *testing.T) {
+		rv := NewResolver()
+		w := blindWatcher()
+		defer w.Stop()
+		tt := NewTemplate(TemplateInput{
+			Contents: `{{ .a }}-{{ .b }}`,
+			Dependencies: map[string]dep.Dependency{
+				"a": &idep.FakeDep{Name: "foo"},
+				"b": &idep.FakeDep{Name: "bar"},
+			},
+		})
+		w.Register(tt)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		for ctx.Err() == nil {
+			r, err := rv.Run(tt, w)
+			if err != nil {
+				t.Fatal("Run() error:", err)
+			}
+			if r.Complete {
+				if string(r.Contents) != "foo-bar" {
+					t.Errorf("bad contents: %q", r.Contents)
+				}
+				return
+			}
+			w.Wait(ctx)
+		}
+		t.Error("template never completed")
+	})
+}
+
+func TestResolverDryRun(t *testing.T) {
+	t.Parallel()
+	rv := NewResolver()
+	sink := NewDryRunSink()
+	rv.SetDryRun(sink)
+	w := blindWatcher()
+	defer w.Stop()
+	tt := echoTemplate("foo")
+	w.Register(tt)
+
+	r, err := rv.Run(tt, w)
+	if err != nil {
+		t.Fatal("Run() error:", err)
+	}
+	if !r.DryRun {
+		t.Fatal("DryRun should be true")
+	}
+	w.Wait(context.Background())
+	r, err = rv.Run(tt, w)
+	if err != nil {
+		t.Fatal("Run() error:", err)
+	}
+	if !r.Complete {
+		t.Fatal("Complete should be true")
+	}
+
+	res, ok := sink.Result(tt.ID())
+	if !ok {
+		t.Fatal("missing dry-run result")
+	}
+	if !res.Complete || string(res.Contents) != "foo" {
+		t.Errorf("bad dry-run result: %#v", res)
+	}
+	if len(sink.Results()) != 1 {
+		t.Errorf("expected 1 result, got %d", len(sink.Results()))
+	}
+}
+
+func TestResolverHooks(t *testing.T) {
+	t.Parallel()
+
+	t.Run("order", func(t *testing.T) {
+		rv := NewResolver()
+		var calls []string
+		rv.AddPreExecuteHook(func(tmpl Templater, e *ResolveEvent) error {
+			calls = append(calls, "pre:"+e.ID)
+			return nil
+		})
+		rv.AddPostExecuteHook(func(tmpl Templater, e *ResolveEvent) error {
+			calls = append(calls, "post:"+string(e.Contents))
+			e.Contents = append(e.Contents, '!')
+			return nil
+		})
+		w := blindWatcher()
+		defer w.Stop()
+		tt := echoTemplate("foo")
+		w.Register(tt)
+		if _, err := rv.Run(tt, w); err != nil {
+			t.Fatal("Run() error:", err)
+		}
+		w.Wait(context.Background())
+
+		r, err := rv.Run(tt, w)
+		if err != nil {
+			t.Fatal("Run() error:", err)
+		}
+		if string(r.Contents) != "foo!" {
+			t.Errorf("bad contents: %q", r.Contents)
+		}
+		sum := sha256.Sum256([]byte("foo!"))
+		if r.Hash != hex.EncodeToString(sum[:]) {
+			t.Errorf("hash should be of the hooked contents: %s", r.Hash)
+		}
+		exp := []string{"pre:" + tt.ID(), "post:", "pre:" + tt.ID(), "post:foo"}
+		if !reflect.DeepEqual(calls, exp) {
+			t.Errorf("bad calls\nexp: %v\nact: %v", exp, calls)
+		}
+	})
+
+	t.Run("sensitive", func(t *testing.T) {
+		rv := NewResolver()
+		var plain, sensitive []string
+		rv.AddPostExecuteHook(func(tmpl Templater, e *ResolveEvent) error {
+			plain = append(plain, string(e.Contents))
+			e.Contents = []byte("leaked")
+			return nil
+		})
+		rv.AddSensitivePostExecuteHook(func(tmpl Templater, e *ResolveEvent) error {
+			sensitive = append(sensitive, string(e.Contents))
+			return nil
+		})
+		w := blindWatcher()
+		defer w.Stop()
+		tt := NewTemplate(TemplateInput{
+			Contents:     `{{echo "foo"}}`,
+			FuncMapMerge: template.FuncMap{"echo": echoFunc},
+			Audit:        true,
+			Sensitive:    true,
+		})
+		w.Register(tt)
+		if _, err := rv.Run(tt, w); err != nil {
+			t.Fatal("Run() error:", err)
+		}
+		w.Wait(context.Background())
+
+		r, err := rv.Run(tt, w)
+		if err != nil {
+			t.Fatal("Run() error:", err)
+		}
+		if string(r.Contents) != "foo" {
+			t.Errorf("bad contents: %q", r.Contents)
+		}
+		if exp := []string{"", ""}; !reflect.DeepEqual(plain, exp) {
+			t.Errorf("hook got sensitive contents: %q", plain)
+		}
+		if exp := []string{"", "foo"}; !reflect.DeepEqual(sensitive, exp) {
+			t.Errorf("bad sensitive hook contents: %q", sensitive)
+		}
+		exp := []FuncCall{{Name: "echo", Args: []interface{}{redacted},
+			Dependencies: []string{"test_dep(foo)"}}}
+		if !reflect.DeepEqual(r.Calls, exp) {
+			t.Errorf("bad calls\nexp: %#v\nact: %#v", exp, r.Calls)
+		}
+	})
+
+	t.Run("veto-not-hashed", func(t *testing.T) {
+		rv := NewResolver()
+		veto := errors.New("veto")
+		vetoing := false
+		rv.AddPostExecuteHook(func(Templater, *ResolveEvent) error {
+			if vetoing {
+				return veto
+			}
+			return nil
+		})
+		w := blindWatcher()
+		defer w.Stop()
+		tt := echoTemplate("foo")
+		w.Register(tt)
+		if _, err := rv.Run(tt, w); err != nil {
+			t.Fatal("Run() error:", err)
+		}
+		w.Wait(context.Background())
+
+		vetoing = true
+		if _, err := rv.Run(tt, w); err != veto {
+			t.Fatal("expected veto, got:", err)
+		}
+		vetoing = false
+		tt.Notify(nil) // re-execute the template
+		r, err := rv.Run(tt, w)
+		if err != nil {
+			t.Fatal("Run() error:", err)
+		}
+		if !r.Complete || r.NoChange {
+			t.Fatalf("vetoed contents shouldn't count as rendered: %#v", r)
+		}
+	})
+
+	t.Run("veto", func(t *testing.T) {
+		for _, pre := range []bool{true, false} {
+			rv := NewResolver()
+			sink := NewDryRunSink()
+			rv.SetDryRun(sink)
+			veto := errors.New("veto")
+			hook := func(Templater, *ResolveEvent) error { return veto }
+			if pre {
+				rv.AddPreExecuteHook(hook)
+			} else {
+				rv.AddPostExecuteHook(hook)
+			}
+			w := blindWatcher()
+			tt := echoTemplate("foo")
+			w.Register(tt)
+
+			if _, err := rv.Run(tt, w); err != veto {
+				t.Errorf("expected veto (pre: %v), got: %v", pre, err)
+			}
+			if res, _ := sink.Result(tt.ID()); res.Err != veto {
+				t.Errorf("bad dry-run result (pre: %v): %#v", pre, res)
+			}
+			w.Stop()
+		}
+	})
+}
+
+func TestResolverStaleTimeout(t *testing.T) {
+	t.Parallel()
+	rv := NewResolver()
+	rv.SetStaleTimeout(50 * time.Millisecond)
+	w := blindWatcher()
+	defer w.Stop()
+	blocked := &idep.FakeDepBlockingQuery{Name: "bar",
+		BlockDuration: time.Minute, Ctx: context.Background()}
+	tt := NewTemplate(
+		TemplateInput{
+			Contents: `{{echo "foo"}}{{blocked}}`,
+			FuncMapMerge: template.FuncMap{
+				"echo": echoFunc,
+				"blocked": func(recall Recaller) interface{} {
+					return func() string {
+						recall(blocked)
+						return "bar"
+					}
+				},
+			},
+		})
+	w.Register(tt)
+
+	// first run starts the fetches, wait for the one that returns
+	if _, err := rv.Run(tt, w); err != nil {
+		t.Fatal("Run() error:", err)
+	}
+	w.Wait(context.Background())
+
+	for i := 0; i < 10; i++ {
+		r, err := rv.Run(tt, w)
+		if err != nil {
+			t.Fatal("Run() error:", err)
+		}
+		if !r.Complete {
+			if r.Stale || r.Missing != nil {
+				t.Fatalf("bad incomplete event: %#v", r)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(),
+				20*time.Millisecond)
+			w.Wait(ctx)
+			cancel()
+			continue
+		}
+		if !r.Stale || r.NoChange {
+			t.Fatalf("bad stale event: %#v", r)
+		}
+		if string(r.Contents) != "foobar" {
+			t.Errorf("bad contents: %q", r.Contents)
+		}
+		exp := []string{blocked.ID()}
+		if !reflect.DeepEqual(r.Missing, exp) {
+			t.Errorf("bad missing, exp: %v, act: %v", exp, r.Missing)
+		}
+
+		// still stale, but no new values so no change
+		r, err = rv.Run(tt, w)
+		if err != nil {
+			t.Fatal("Run() error:", err)
+		}
+		if !r.Complete || !r.Stale || !r.NoChange {
+			t.Errorf("bad second stale event: %#v", r)
+		}
+		return
+	}
+	t.Fatal("template never went stale")
+}
+
+func TestResolverStaleSensitiveError(t *testing.T) {
+	t.Parallel()
+	rv := NewResolver()
+	rv.SetStaleTimeout(200 * time.Millisecond)
+	rv.SetQuarantine(1, nil)
+	sink := NewDryRunSink()
+	rv.SetDryRun(sink)
+	w := blindWatcher()
+	defer w.Stop()
+	blocked := &idep.FakeDepBlockingQuery{Name: "bar",
+		BlockDuration: time.Minute, Ctx: context.Background()}
+	fail := errors.New("fail")
+	var failing int32
+	tt := NewTemplate(
+		TemplateInput{
+			Contents: `{{echo "foo"}}{{blocked}}{{flaky}}`,
+			FuncMapMerge: template.FuncMap{
+				"echo": echoFunc,
+				"blocked": func(recall Recaller) interface{} {
+					return func() string {
+						recall(blocked)
+						return "bar"
+					}
+				},
+				"flaky": func() (string, error) {
+					if atomic.LoadInt32(&failing) == 1 {
+						return "", fail
+					}
+					return "", nil
+				},
+			},
+			Sensitive: true,
+		})
+	w.Register(tt)
+
+	// first run starts the fetches, wait for the one that returns
+	if _, err := rv.Run(tt, w); err != nil {
+		t.Fatal("Run() error:", err)
+	}
+	w.Wait(context.Background())
+	if r, err := rv.Run(tt, w); err != nil || r.Complete {
+		t.Fatalf("bad incomplete run: %#v, %v", r, err)
+	}
+
+	// executing it again for its stale render fails
+	atomic.StoreInt32(&failing, 1)
+	for i := 0; i < 20; i++ {
+		_, err := rv.Run(tt, w)
+		if err == nil {
+			time.Sleep(20 * time.Millisecond)
+			continue
+		}
+		if !errors.Is(err, fail) {
+			t.Fatal("bad error:", err)
+		}
+		if ids := rv.Quarantined(); !reflect.DeepEqual(ids, []string{tt.ID()}) {
+			t.Errorf("failure not recorded for the quarantine: %v", ids)
+		}
+		if res, _ := sink.Result(tt.ID()); !errors.Is(res.Err, fail) {
+			t.Errorf("failure not recorded for the dry-run: %#v", res)
+		}
+		return
+	}
+	t.Fatal("template never went stale")
+}
+
+func TestResolverRunAll(t *testing.T) {
+	t.Parallel()
+
+	t.Run("order", func(t *testing.T) {
+		rv := NewResolver()
+		rv.SetParallelism(2)
+		w := blindWatcher()
+		defer w.Stop()
+		tmpls := []Templater{
+			echoTemplate("foo"), echoTemplate("bar"), echoTemplate("zip"),
+		}
+		for _, tmpl := range tmpls {
+			w.Register(tmpl)
+		}
+
+		for i := 0; i < 5; i++ {
+			events, err := rv.RunAll(tmpls, w)
+			if err != nil {
+				t.Fatal("RunAll() error:", err)
+			}
+			if len(events) != len(tmpls) {
+				t.Fatalf("bad events: %#v", events)
+			}
+			complete := true
+			for _, e := range events {
+				complete = complete && e.Complete
+			}
+			if !complete {
+				w.Wait(context.Background())
+				continue
+			}
+			for j, exp := range []string{"foo", "bar", "zip"} {
+				if events[j].ID != tmpls[j].ID() {
+					t.Errorf("bad event order: %s", events[j].ID)
+				}
+				if string(events[j].Contents) != exp {
+					t.Errorf("bad contents, exp: %q, act: %q", exp,
+						events[j].Contents)
+				}
+			}
+			return
+		}
+		t.Fatal("templates never completed")
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		rv := NewResolver()
+		rv.SetParallelism(2)
+		w := blindWatcher()
+		defer w.Stop()
+
+		// both templates block until both are executing
+		arrived, release := make(chan struct{}), make(chan struct{})
+		fm := template.FuncMap{
+			"arrive": func() string {
+				arrived <- struct{}{}
+				<-release
+				return "done"
+			},
+		}
+		tmpls := []Templater{
+			NewTemplate(TemplateInput{Name: "a", Contents: `{{ arrive }}`,
+				FuncMapMerge: fm}),
+			NewTemplate(TemplateInput{Name: "b", Contents: `{{ arrive }}`,
+				FuncMapMerge: fm}),
+		}
+		for _, tmpl := range tmpls {
+			w.Register(tmpl)
+		}
+
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := rv.RunAll(tmpls, w)
+			errCh <- err
+		}()
+		for range tmpls {
+			select {
+			case <-arrived:
+			case <-time.After(time.Second):
+				t.Fatal("templates not executed concurrently")
+			}
+		}
+		close(release)
+		if err := <-errCh; err != nil {
+			t.Fatal("RunAll() error:", err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		rv := NewResolver()
+		w := blindWatcher()
+		defer w.Stop()
+		bad := NewTemplate(TemplateInput{Contents: `{{ bad_func }}`})
+		good := echoTemplate("foo")
+		tmpls := []Templater{bad, good}
+		for _, tmpl := range tmpls {
+			w.Register(tmpl)
+		}
+
+		events, err := rv.RunAll(tmpls, w)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if events[0].ID != "" || events[1].ID != good.ID() {
+			t.Errorf("bad events: %#v", events)
+		}
+	})
 }
 
 //////////////////////////
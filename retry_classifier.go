@@ -0,0 +1,95 @@
+package hcat
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+)
+
+// RetryClass is how a failed fetch is retried, see RetryClassifier.
+type RetryClass int
+
+const (
+	// RetryDefault leaves the error to the default handling, retried with
+	// the RetryFunc's backoff unless it is a 400 (bad request) response.
+	RetryDefault RetryClass = iota
+	// RetryBackoff retries the error with the RetryFunc's backoff.
+	RetryBackoff
+	// RetryFatal returns the error to the watcher without retrying, eg. a
+	// 403 (permission denied) that won't go away by retrying.
+	RetryFatal
+)
+
+// RetryClassifier classifies the errors fetching a dependency, deciding if
+// they are retried. If after is positive the retry waits that long instead of
+// the RetryFunc's sleep, eg. to honor a rate limit's Retry-After (see
+// ErrorRetryAfter), still counting as an attempt of the RetryFunc. Set per
+// type of dependency in the WatcherInput, see ErrorStatusCode to classify by
+// HTTP status.
+type RetryClassifier func(d dep.Dependency, err error) (class RetryClass,
+	after time.Duration)
+
+// statusCodeRe matches the status codes in the errors of the Consul API
+// ("Unexpected response code: 500") and Vault API ("Code: 403.").
+var statusCodeRe = regexp.MustCompile(
+	`(?:Unexpected response code: |Code: )(\d{3})\b`)
+
+// ErrorStatusCode returns the HTTP status code of the Consul or Vault API
+// response the fetch error is from, or 0 if it isn't from a response.
+func ErrorStatusCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var respErr *vaultapi.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode
+	}
+	if m := statusCodeRe.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return code
+	}
+	return 0
+}
+
+// retryAfterError is a fetch error of a response with a Retry-After header,
+// see ErrorRetryAfter.
+type retryAfterError struct {
+	error
+	after time.Duration
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.error
+}
+
+// withRetryAfter returns the error with the Retry-After of its response, the
+// error as is if it has none.
+func withRetryAfter(err error, after time.Duration) error {
+	if after <= 0 {
+		return err
+	}
+	return &retryAfterError{error: err, after: after}
+}
+
+// ErrorRetryAfter returns the Retry-After of the rate limited (429) or
+// unavailable (503) response the fetch error is from, or 0 if it has none.
+// Only the Consul responses have it, the Vault API client doesn't expose
+// them. Eg. a RetryClassifier honoring it:
+//
+//	func(d dep.Dependency, err error) (hcat.RetryClass, time.Duration) {
+//		if after := hcat.ErrorRetryAfter(err); after > 0 {
+//			return hcat.RetryBackoff, after
+//		}
+//		return hcat.RetryDefault, 0
+//	}
+func ErrorRetryAfter(err error) time.Duration {
+	var raErr *retryAfterError
+	if errors.As(err, &raErr) {
+		return raErr.after
+	}
+	return 0
+}
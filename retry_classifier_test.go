@@ -0,0 +1,117 @@
+package hcat
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+	vaultapi "github.com/hashicorp/vault/api"
+	pkgerrors "github.com/pkg/errors"
+)
+
+func TestErrorStatusCode(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		err  error
+		exp  int
+	}{
+		{"nil", nil, 0},
+		{"other", errors.New("connection refused"), 0},
+		{"consul", pkgerrors.Wrap(errors.New(
+			"Unexpected response code: 500 (rpc error)"), "health.service(web)"),
+			500},
+		{"vault", pkgerrors.Wrap(&vaultapi.ResponseError{StatusCode: 403},
+			"vault.read(secret/foo)"), 403},
+		{"vault-string", fmt.Errorf("Error making API request.\n\n" +
+			"URL: GET http://127.0.0.1:8200/v1/secret/foo\nCode: 429. " +
+			"Errors:\n\n* rate limited"), 429},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if act := ErrorStatusCode(tc.err); act != tc.exp {
+				t.Errorf("exp: %d, act: %d", tc.exp, act)
+			}
+		})
+	}
+}
+
+func TestErrorRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		err  error
+		exp  time.Duration
+	}{
+		{"nil", nil, 0},
+		{"none", withRetryAfter(errors.New("rate limited"), 0), 0},
+		{"after", withRetryAfter(errors.New("rate limited"), time.Second),
+			time.Second},
+		{"wrapped", pkgerrors.Wrap(withRetryAfter(errors.New("rate limited"),
+			time.Second), "kv.get(foo)"), time.Second},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if act := ErrorRetryAfter(tc.err); act != tc.exp {
+				t.Errorf("exp: %s, act: %s", tc.exp, act)
+			}
+		})
+	}
+
+	t.Run("consul", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/v1/status/leader" {
+					fmt.Fprint(w, `"127.0.0.1:8300"`)
+					return
+				}
+				w.Header().Set("Retry-After", "7")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+		defer ts.Close()
+
+		cs := NewClientSet()
+		if err := cs.AddConsul(ConsulInput{
+			Address: ts.Listener.Addr().String()}); err != nil {
+			t.Fatal(err)
+		}
+		d, err := idep.NewKVGetQuery("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		afterCh := make(chan time.Duration, 1)
+		vw := newView(&newViewInput{
+			Dependency: d,
+			Clients:    cs,
+			RetryFunc: func(int) (bool, time.Duration) {
+				return false, 0
+			},
+			RetryClassifier: func(_ dep.Dependency, err error) (RetryClass,
+				time.Duration) {
+				afterCh <- ErrorRetryAfter(err)
+				return RetryFatal, 0
+			},
+		})
+		viewCh := make(chan *view)
+		errCh := make(chan error, 1)
+		go vw.poll(viewCh, errCh)
+		defer vw.stop()
+
+		select {
+		case act := <-afterCh:
+			if act != 7*time.Second {
+				t.Errorf("exp: 7s, act: %s", act)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout")
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package hcat
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+const (
+	// runLoopMinBackoff and runLoopMaxBackoff bound the time RunLoop waits
+	// before restarting the watcher after an error.
+	runLoopMinBackoff = 250 * time.Millisecond
+	runLoopMaxBackoff = time.Minute
+
+	// runLoopMaxSkips is the number of passes in a row a template can be put
+	// off for higher priority ones before it is run regardless.
+	runLoopMaxSkips = 5
+)
+
+// RunLoop encapsulates the standard Run/Wait loop used to render templates.
+// It registers the templates with the watcher and then repeatedly runs each
+// through the resolver, calling handler with every ResolveEvent that is
+// Complete and has changes, and waits for new data. The templates are run in
+// order of their Priority, see TemplateInput. Pass a configured resolver for
+// its hooks, dry-run, stale timeout or quarantine, or nil for a new one.
+//
+// Errors fetching data are retried, with an exponential backoff, by restarting
+// polling on the watcher. Errors from executing a template or returned from the
+// handler stop the loop and are returned. Cancelling the context stops the loop
+// and returns nil.
+func RunLoop(ctx context.Context, r *Resolver, w *Watcher, tmpls []Templater,
+	handler func(ResolveEvent) error) error {
+	for _, tmpl := range tmpls {
+		// allow templates already registered (eg. on a previous RunLoop)
+		if err := w.Register(tmpl); err != nil && err != RegistryErr {
+			return err
+		}
+	}
+
+	if r == nil {
+		r = NewResolver()
+	}
+	schedule := newRunSchedule(tmpls)
+	pending := func() bool { return w.Pending() > 0 }
+	backoff := runLoopMinBackoff
+	for {
+		err := schedule.pass(pending, func(tmpl Templater) error {
+			event, err := r.Run(tmpl, w)
+			if err != nil {
+				return err
+			}
+			if !event.Complete || event.NoChange {
+				return nil
+			}
+			if err := handler(event); err != nil {
+				return err
+			}
+			r.Rendered(event)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = w.Wait(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil
+			}
+			if backoff *= 2; backoff > runLoopMaxBackoff {
+				backoff = runLoopMaxBackoff
+			}
+			// views stop polling after returning an error, start them back up
+			w.Poll()
+		default:
+			backoff = runLoopMinBackoff
+		}
+	}
+}
+
+// RunOnce renders the templates once, for render-and-exit use with a Watcher
+// in Once mode (see WatcherInput). It registers the templates with the watcher
+// and runs each through the resolver (a new one if nil) until it is Complete,
+// calling handler once with its ResolveEvent. The templates are run in order
+// of their Priority.
+//
+// It returns nil once all the templates were handled, the Once watcher then
+// has no dependencies polling and can be stopped right away. Errors fetching
+// data, executing a template or returned from the handler stop the run and are
+// returned, as is the context's error if it is done first.
+func RunOnce(ctx context.Context, r *Resolver, w *Watcher, tmpls []Templater,
+	handler func(ResolveEvent) error) error {
+	for _, tmpl := range tmpls {
+		if err := w.Register(tmpl); err != nil && err != RegistryErr {
+			return err
+		}
+	}
+
+	if r == nil {
+		r = NewResolver()
+	}
+	remaining := newRunSchedule(tmpls).tmpls
+	for {
+		var incomplete []Templater
+		for _, tmpl := range remaining {
+			event, err := r.Run(tmpl, w)
+			if err != nil {
+				return err
+			}
+			if !event.Complete {
+				incomplete = append(incomplete, tmpl)
+				continue
+			}
+			if err := handler(event); err != nil {
+				return err
+			}
+			r.Rendered(event)
+		}
+		if len(incomplete) == 0 {
+			return nil
+		}
+		remaining = incomplete
+
+		// Wait doesn't return the deadline being exceeded as an error
+		if err := w.Wait(ctx); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// prioritizer is implemented by Templaters with a priority for RunLoop.
+// Implemented by Template.
+type prioritizer interface {
+	Priority() int
+}
+
+// priority returns the template's priority, 0 if it hasn't one.
+func priority(tmpl Templater) int {
+	if p, ok := tmpl.(prioritizer); ok {
+		return p.Priority()
+	}
+	return 0
+}
+
+// runSchedule orders the templates of RunLoop's passes by priority.
+type runSchedule struct {
+	tmpls []Templater // highest priority first
+	skips []int       // passes in a row each template was put off
+}
+
+func newRunSchedule(tmpls []Templater) *runSchedule {
+	sorted := append([]Templater{}, tmpls...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return priority(sorted[i]) > priority(sorted[j])
+	})
+	return &runSchedule{tmpls: sorted, skips: make([]int, len(sorted))}
+}
+
+// pass runs the templates, highest priority first. Once the templates of a
+// priority are run, if there is new data pending, the lower priority ones are
+// put off to the next pass so the new data is processed first. Except those
+// already put off runLoopMaxSkips passes in a row.
+func (s *runSchedule) pass(pending func() bool, run func(Templater) error) error {
+	preempted := false
+	for i, tmpl := range s.tmpls {
+		if !preempted && i > 0 &&
+			priority(tmpl) < priority(s.tmpls[i-1]) && pending() {
+			preempted = true
+		}
+		if preempted && s.skips[i] < runLoopMaxSkips {
+			s.skips[i]++
+			continue
+		}
+		s.skips[i] = 0
+		if err := run(tmpl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package hcat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+func TestRunLoop(t *testing.T) {
+	t.Parallel()
+	t.Run("renders", func(t *testing.T) {
+		w := blindWatcher()
+		defer w.Stop()
+		tt := echoListTemplate("foo", "bar")
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var events []ResolveEvent
+		err := RunLoop(ctx, nil, w, []Templater{tt}, func(re ResolveEvent) error {
+			events = append(events, re)
+			cancel()
+			return nil
+		})
+		if err != nil {
+			t.Fatal("RunLoop() error:", err)
+		}
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event, got %d", len(events))
+		}
+		if events[0].ID != tt.ID() || string(events[0].Contents) != "foobar" {
+			t.Errorf("bad event: %#v", events[0])
+		}
+	})
+
+	t.Run("resolver", func(t *testing.T) {
+		w := blindWatcher()
+		defer w.Stop()
+		tt := echoTemplate("foo")
+		rv := NewResolver()
+		rv.AddPostExecuteHook(annotateHook)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var contents string
+		err := RunLoop(ctx, rv, w, []Templater{tt}, func(re ResolveEvent) error {
+			contents = string(re.Contents)
+			cancel()
+			return nil
+		})
+		if err != nil {
+			t.Fatal("RunLoop() error:", err)
+		}
+		if contents != "foo!" {
+			t.Errorf("resolver not used, bad contents: %q", contents)
+		}
+	})
+
+	t.Run("handler-error", func(t *testing.T) {
+		w := blindWatcher()
+		defer w.Stop()
+		tt := echoTemplate("foo")
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		handlerErr := errors.New("handler error")
+		err := RunLoop(ctx, nil, w, []Templater{tt}, func(re ResolveEvent) error {
+			return handlerErr
+		})
+		if err != handlerErr {
+			t.Fatal("expected handler error, got:", err)
+		}
+	})
+
+	t.Run("fetch-error-retried", func(t *testing.T) {
+		w := blindWatcher()
+		defer w.Stop()
+		tt := NewTemplate(TemplateInput{
+			Contents: `{{retry "foo"}}`,
+			FuncMapMerge: template.FuncMap{
+				"retry": func(recall Recaller) interface{} {
+					return func(s string) interface{} {
+						d := &idep.FakeDepRetry{Name: s}
+						if value, ok := recall(d); ok {
+							return value
+						}
+						return ""
+					}
+				},
+			},
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var contents string
+		err := RunLoop(ctx, nil, w, []Templater{tt}, func(re ResolveEvent) error {
+			contents = string(re.Contents)
+			cancel()
+			return nil
+		})
+		if err != nil {
+			t.Fatal("RunLoop() error:", err)
+		}
+		if contents != "this is some data" {
+			t.Errorf("bad contents: %q", contents)
+		}
+	})
+}
+
+// annotateHook is a post-execute hook that appends "!" to the contents
+func annotateHook(tmpl Templater, e *ResolveEvent) error {
+	if e.Complete {
+		e.Contents = append(e.Contents, '!')
+	}
+	return nil
+}
+
+func TestRunSchedule(t *testing.T) {
+	t.Parallel()
+	low := NewTemplate(TemplateInput{Contents: "low"})
+	high := NewTemplate(TemplateInput{Contents: "high", Priority: 10})
+	other := NewTemplate(TemplateInput{Contents: "other"})
+	schedule := newRunSchedule([]Templater{low, high, other})
+
+	pass := func(pending bool) string {
+		var ran []string
+		err := schedule.pass(func() bool { return pending },
+			func(tmpl Templater) error {
+				ran = append(ran, tmpl.(*Template).contents)
+				return nil
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(ran, ",")
+	}
+
+	if ran := pass(false); ran != "high,low,other" {
+		t.Fatalf("bad order: %s", ran)
+	}
+	// new data puts off the lower priorities, until they'd be starved
+	for i := 0; i < runLoopMaxSkips; i++ {
+		if ran := pass(true); ran != "high" {
+			t.Fatalf("pass %d: lower priority not put off: %s", i, ran)
+		}
+	}
+	if ran := pass(true); ran != "high,low,other" {
+		t.Fatalf("lower priority starved: %s", ran)
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	t.Parallel()
+
+	onceWatcher := func() *Watcher {
+		return NewWatcher(WatcherInput{Cache: NewStore(), Once: true})
+	}
+	// waitIndexFunc recalls the FakeDepWaitIndex, which blocks when
+	// fetched with a WaitIndex
+	waitIndexFunc := func(d *idep.FakeDepWaitIndex) interface{} {
+		return func(recall Recaller) interface{} {
+			return func() interface{} {
+				if value, ok := recall(d); ok {
+					return value
+				}
+				return ""
+			}
+		}
+	}
+
+	t.Run("renders", func(t *testing.T) {
+		w := onceWatcher()
+		defer w.Stop()
+		d := &idep.FakeDepWaitIndex{Name: "foo"}
+		tmpls := []Templater{
+			echoListTemplate("foo", "bar"),
+			NewTemplate(TemplateInput{
+				Contents: `{{waitIndex}}`,
+				FuncMapMerge: template.FuncMap{
+					"waitIndex": waitIndexFunc(d)},
+			}),
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		contents := make(map[string]string)
+		err := RunOnce(ctx, nil, w, tmpls, func(re ResolveEvent) error {
+			if _, ok := contents[re.ID]; ok {
+				t.Errorf("template handled twice: %s", re.ID)
+			}
+			contents[re.ID] = string(re.Contents)
+			return nil
+		})
+		if err != nil {
+			t.Fatal("RunOnce() error:", err)
+		}
+		if contents[tmpls[0].ID()] != "foobar" ||
+			contents[tmpls[1].ID()] != "foo_1" {
+			t.Errorf("bad contents: %v", contents)
+		}
+
+		// the dependencies stop polling after their data, without blocking
+		// queries, and polling doesn't fetch them again
+		w.Poll()
+		deadline := time.Now().Add(time.Second)
+		for _, st := range w.Status().Dependencies {
+			for st.Polling && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+				st = w.view(st.ID).status()
+			}
+			if st.Polling {
+				t.Errorf("still polling: %s", st.ID)
+			}
+		}
+		if idx := d.Indexes(); len(idx) != 1 || idx[0] != 0 {
+			t.Errorf("bad fetches: %v", idx)
+		}
+	})
+
+	t.Run("resolver", func(t *testing.T) {
+		w := onceWatcher()
+		defer w.Stop()
+		tt := echoTemplate("foo")
+		rv := NewResolver()
+		rv.AddPostExecuteHook(annotateHook)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var contents string
+		err := RunOnce(ctx, rv, w, []Templater{tt}, func(re ResolveEvent) error {
+			contents = string(re.Contents)
+			return nil
+		})
+		if err != nil {
+			t.Fatal("RunOnce() error:", err)
+		}
+		if contents != "foo!" {
+			t.Errorf("resolver not used, bad contents: %q", contents)
+		}
+	})
+
+	t.Run("fetch-error", func(t *testing.T) {
+		w := onceWatcher()
+		defer w.Stop()
+		tt := NewTemplate(TemplateInput{
+			Contents: `{{fail "foo"}}`,
+			FuncMapMerge: template.FuncMap{
+				"fail": func(recall Recaller) interface{} {
+					return func(s string) interface{} {
+						recall(&idep.FakeDepFetchError{Name: s})
+						return ""
+					}
+				},
+			},
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := RunOnce(ctx, nil, w, []Templater{tt}, func(re ResolveEvent) error {
+			t.Error("unexpected event")
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "connection refused") {
+			t.Fatal("expected fetch error, got:", err)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		w := onceWatcher()
+		defer w.Stop()
+		ctx, cancel := context.WithTimeout(context.Background(),
+			50*time.Millisecond)
+		defer cancel()
+		d := &idep.FakeDepBlockingQuery{Name: "foo", Data: "foo",
+			BlockDuration: time.Minute, Ctx: ctx}
+		tt := NewTemplate(TemplateInput{
+			Contents: `{{blocking}}`,
+			FuncMapMerge: template.FuncMap{
+				"blocking": func(recall Recaller) interface{} {
+					return func() interface{} {
+						recall(d)
+						return ""
+					}
+				},
+			},
+		})
+
+		err := RunOnce(ctx, nil, w, []Templater{tt}, func(re ResolveEvent) error {
+			t.Error("unexpected event")
+			return nil
+		})
+		if err != context.DeadlineExceeded {
+			t.Fatal("expected deadline exceeded, got:", err)
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package hcat
 
 import (
+	"container/list"
 	"sync"
+	"time"
 )
 
 // Store is what Template uses to determine the values that are
@@ -12,6 +14,58 @@ type Store struct {
 	// data is the map of individual dependencies and the most recent data for
 	// that dependency.
 	data map[string]interface{}
+
+	// eviction policies, lru and entries are only set if one is enabled
+	opts      StoreOptions
+	lru       *list.List // *storeEntry, most recently used at the front
+	entries   map[string]*list.Element
+	evictions uint64
+	now       func() time.Time // for testing
+}
+
+// StoreOptions are the eviction policies and serialization of the Store. The
+// eviction policies bound the memory used by the Store's entries. Evicted
+// entries are treated like they were never saved.
+//
+// As a Watcher's cache, eviction only frees the memory of the dependencies
+// that are no longer watched (eg. a Deregistered template's, or those saved
+// by Decode and never used). A watched dependency's view keeps its latest
+// data, the Watcher's Recaller saves it again when an evicted entry is
+// recalled, so evicting it saves nothing and costs a copy.
+type StoreOptions struct {
+	// TTL evicts entries that haven't been saved or recalled for this long.
+	// Zero disables it.
+	TTL time.Duration
+
+	// MaxEntries evicts the least recently used entries once the Store has
+	// more than this many. Zero disables it.
+	MaxEntries int
+
+	// Codec serializes the entries for Store.Encode and Decode. Defaults to
+	// GobCodec.
+	Codec StoreCodec
+
+	// Migrate converts entries decoded from an older schema version (see
+	// dep.SchemaVersion). Without it Decode doesn't restore them.
+	Migrate StoreMigration
+}
+
+// StoreStats are metrics about the size and age of the Store's entries.
+type StoreStats struct {
+	// Entries is the number of entries in the Store.
+	Entries int
+	// Evictions is the total number of entries evicted by the eviction
+	// policies (it doesn't include those explicitly deleted).
+	Evictions uint64
+	// OldestAge is the time since the least recently used entry was last
+	// saved or recalled. Only tracked when an eviction policy is enabled.
+	OldestAge time.Duration
+}
+
+// storeEntry tracks the last access of an entry for the eviction policies.
+type storeEntry struct {
+	id       string
+	accessed time.Time
 }
 
 // NewStore creates a new Store with empty values for each
@@ -22,6 +76,18 @@ func NewStore() *Store {
 	}
 }
 
+// NewStoreWithOptions creates a new Store using the given eviction policies.
+func NewStoreWithOptions(opts StoreOptions) *Store {
+	s := NewStore()
+	s.opts = opts
+	s.now = time.Now
+	if opts.TTL > 0 || opts.MaxEntries > 0 {
+		s.lru = list.New()
+		s.entries = make(map[string]*list.Element)
+	}
+	return s
+}
+
 // Save accepts a dependency and the data to store associated with that
 // dep. This function converts the given data to a proper type and stores
 // it interally.
@@ -29,15 +95,19 @@ func (s *Store) Save(id string, data interface{}) {
 	s.Lock()
 	defer s.Unlock()
 
-	if _, ok := s.data[id]; ok {
-		s.data[id] = data
-		return
-	}
 	s.data[id] = data
+	if s.lru != nil {
+		s.touch(id)
+		s.evict()
+	}
 }
 
 // Recall gets the current value for the given dependency in the Store.
 func (s *Store) Recall(id string) (interface{}, bool) {
+	if s.lru != nil {
+		return s.recallTracked(id)
+	}
+
 	s.RLock()
 	defer s.RUnlock()
 
@@ -45,13 +115,32 @@ func (s *Store) Recall(id string) (interface{}, bool) {
 	return data, ok
 }
 
+// recallTracked is Recall with the eviction policies enabled, it needs the
+// write lock to update the entry's access time.
+func (s *Store) recallTracked(id string) (interface{}, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	data, ok := s.data[id]
+	if !ok {
+		return nil, false
+	}
+	if s.expired(s.entries[id]) {
+		s.remove(id)
+		s.evictions++
+		return nil, false
+	}
+	s.touch(id)
+	return data, true
+}
+
 // Forget accepts a dependency and removes all associated data with this
 // dependency.
 func (s *Store) Delete(id string) {
 	s.Lock()
 	defer s.Unlock()
 
-	delete(s.data, id)
+	s.remove(id)
 }
 
 // Reset clears all stored data.
@@ -62,6 +151,72 @@ func (s *Store) Reset() {
 	for k := range s.data {
 		delete(s.data, k)
 	}
+	if s.lru != nil {
+		s.lru.Init()
+		s.entries = make(map[string]*list.Element)
+	}
+}
+
+// Stats returns the current size and age metrics of the Store.
+func (s *Store) Stats() StoreStats {
+	s.RLock()
+	defer s.RUnlock()
+
+	stats := StoreStats{
+		Entries:   len(s.data),
+		Evictions: s.evictions,
+	}
+	if s.lru != nil {
+		if e := s.lru.Back(); e != nil {
+			stats.OldestAge = s.now().Sub(e.Value.(*storeEntry).accessed)
+		}
+	}
+	return stats
+}
+
+// touch marks the entry as just accessed, moving it to the front of the lru.
+// Must be called with the lock held.
+func (s *Store) touch(id string) {
+	if e, ok := s.entries[id]; ok {
+		e.Value.(*storeEntry).accessed = s.now()
+		s.lru.MoveToFront(e)
+		return
+	}
+	s.entries[id] = s.lru.PushFront(&storeEntry{id: id, accessed: s.now()})
+}
+
+// evict removes the least recently used entries that are over the max
+// entries or have expired. Must be called with the lock held.
+func (s *Store) evict() {
+	for e := s.lru.Back(); e != nil; e = s.lru.Back() {
+		over := s.opts.MaxEntries > 0 && s.lru.Len() > s.opts.MaxEntries
+		if !over && !s.expired(e) {
+			return
+		}
+		s.remove(e.Value.(*storeEntry).id)
+		s.evictions++
+	}
+}
+
+// expired returns true if the entry hasn't been accessed within the TTL.
+func (s *Store) expired(e *list.Element) bool {
+	if s.opts.TTL <= 0 || e == nil {
+		return false
+	}
+	return s.now().Sub(e.Value.(*storeEntry).accessed) > s.opts.TTL
+}
+
+// remove deletes the entry's data and tracking. Must be called with the lock
+// held.
+func (s *Store) remove(id string) {
+	delete(s.data, id)
+	if s.lru == nil {
+		return
+	}
+	if e, ok := s.entries[id]; ok {
+		s.lru.Remove(e)
+		delete(s.entries, id)
+	}
 }
 
 // forceSet is used to force set the value of a dependency for a given hash
@@ -71,4 +226,7 @@ func (s *Store) forceSet(hashCode string, data interface{}) {
 	defer s.Unlock()
 
 	s.data[hashCode] = data
+	if s.lru != nil {
+		s.touch(hashCode)
+	}
 }
@@ -0,0 +1,167 @@
+package hcat
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/hcat/dep"
+	"github.com/pkg/errors"
+)
+
+// ErrStoreVersion is returned (wrapped) by Store.Decode for entries encoded
+// with another schema version (see dep.SchemaVersion) that can't be migrated.
+var ErrStoreVersion = errors.New("unsupported store schema version")
+
+// schemaVersionKey is the reserved entry holding the schema version of the
+// encoded entries. Entries encoded before it was added don't have it and are
+// version 0.
+const schemaVersionKey = "hcat.schema-version"
+
+// dataTypes are the data types of the built-in dependencies, registered with
+// gob for GobCodec and by name for FixtureJSONCodec.
+var dataTypes = []interface{}{
+	0, "", []string{}, map[string]interface{}{}, []interface{}{},
+	dep.KvValue(""), dep.KVExists(false), dep.KvChange{},
+	map[string]dep.KvValue{}, &dep.KeyPair{}, []*dep.KeyPair{},
+	&dep.CatalogNode{}, []*dep.Node{}, []*dep.CatalogSnippet{},
+	[]*dep.HealthService{}, []*dep.NodeCoordinate{}, []*dep.Namespace{},
+	[]*dep.Partition{}, &dep.ExportedServices{}, &dep.Secret{},
+	map[string]*dep.Secret{}, []*api.CARoot{}, &api.LeafCert{},
+	&api.LicenseReply{}, &api.OperatorHealthReply{},
+	// the timers' data, and the numbers in Vault secrets' data
+	time.Time{}, json.Number(""),
+}
+
+func init() {
+	for _, v := range dataTypes {
+		gob.Register(v)
+	}
+}
+
+// StoreCodec serializes the Store's entries, the dependencies' data keyed by
+// their IDs, for persisting the Store or sharing it with other processes. Set
+// it with StoreOptions' Codec, other formats (eg. msgpack) can be plugged in
+// by implementing it.
+type StoreCodec interface {
+	Encode(w io.Writer, entries map[string]interface{}) error
+	Decode(r io.Reader) (map[string]interface{}, error)
+}
+
+// StoreMigration converts entries decoded from an older schema version (see
+// dep.SchemaVersion) to the current one, eg. filling in a renamed field. It
+// returns the entries to restore, dropping those it can't convert.
+type StoreMigration func(version int, entries map[string]interface{}) (
+	map[string]interface{}, error)
+
+var (
+	// GobCodec encodes the entries with encoding/gob, the default. The data
+	// types of the built-in dependencies are registered with gob so the
+	// decoded entries have the same types as the originals, custom
+	// dependencies (see depext) register theirs with gob.Register.
+	GobCodec StoreCodec = gobCodec{}
+
+	// JSONCodec encodes the entries as a JSON object, for inspecting them
+	// with other tools. The decoded entries are generic JSON values (maps,
+	// slices, strings, float64s, etc.) and not the dependencies' data types,
+	// so don't use it to restore a Store used with templates.
+	JSONCodec StoreCodec = jsonCodec{}
+)
+
+type gobCodec struct{}
+
+func (gobCodec) Encode(w io.Writer, entries map[string]interface{}) error {
+	return gob.NewEncoder(w).Encode(entries)
+}
+
+func (gobCodec) Decode(r io.Reader) (map[string]interface{}, error) {
+	var entries map[string]interface{}
+	err := gob.NewDecoder(r).Decode(&entries)
+	return entries, err
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(w io.Writer, entries map[string]interface{}) error {
+	return json.NewEncoder(w).Encode(entries)
+}
+
+func (jsonCodec) Decode(r io.Reader) (map[string]interface{}, error) {
+	var entries map[string]interface{}
+	err := json.NewDecoder(r).Decode(&entries)
+	return entries, err
+}
+
+// codec returns the Store's codec, GobCodec if not set.
+func (s *Store) codec() StoreCodec {
+	if s.opts.Codec != nil {
+		return s.opts.Codec
+	}
+	return GobCodec
+}
+
+// Encode writes all the Store's entries to w using its codec (see
+// StoreOptions).
+func (s *Store) Encode(w io.Writer) error {
+	s.RLock()
+	entries := make(map[string]interface{}, len(s.data))
+	for k, v := range s.data {
+		entries[k] = v
+	}
+	s.RUnlock()
+	entries[schemaVersionKey] = dep.SchemaVersion
+
+	if err := s.codec().Encode(w, entries); err != nil {
+		return errors.Wrap(err, "store encode")
+	}
+	return nil
+}
+
+// Decode reads entries encoded by Encode, using the Store's codec, and saves
+// them in the Store. Existing entries with the same IDs are replaced. Entries
+// encoded with an older schema version are converted by the StoreOptions'
+// Migrate, without it (or for a newer version) nothing is restored and the
+// error is ErrStoreVersion.
+func (s *Store) Decode(r io.Reader) error {
+	entries, err := s.codec().Decode(r)
+	if err != nil {
+		return errors.Wrap(err, "store decode")
+	}
+	version, err := schemaVersion(entries)
+	if err != nil {
+		return errors.Wrap(err, "store decode")
+	}
+	switch {
+	case version == dep.SchemaVersion:
+	case version < dep.SchemaVersion && s.opts.Migrate != nil:
+		if entries, err = s.opts.Migrate(version, entries); err != nil {
+			return errors.Wrapf(err, "store decode: migrate version %d", version)
+		}
+	default:
+		return errors.Wrapf(ErrStoreVersion, "store decode: version %d, want %d",
+			version, dep.SchemaVersion)
+	}
+	for k, v := range entries {
+		s.Save(k, v)
+	}
+	return nil
+}
+
+// schemaVersion removes the schema version from the decoded entries and
+// returns it, 0 if they don't have one.
+func schemaVersion(entries map[string]interface{}) (int, error) {
+	v, ok := entries[schemaVersionKey]
+	if !ok {
+		return 0, nil
+	}
+	delete(entries, schemaVersionKey)
+	switch v := v.(type) {
+	case int:
+		return v, nil
+	case float64: // JSON
+		return int(v), nil
+	}
+	return 0, errors.Errorf("bad schema version: %#v", v)
+}
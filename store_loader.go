@@ -0,0 +1,96 @@
+package hcat
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// LoaderFunc loads the data of the entry with the id on a LoadingStore miss.
+type LoaderFunc func(id string) (interface{}, error)
+
+// LoadingStore is a Store with read-through semantics, for embedders reusing
+// the cache (and its eviction policies) for lookups outside of templates.
+// Load returns the entry's data, loading and saving it on a miss. Concurrent
+// loads of the same entry are deduplicated, the callers all wait for and
+// share the one load's result. Failed loads aren't saved.
+type LoadingStore struct {
+	*Store
+	loader LoaderFunc
+
+	mu    sync.Mutex
+	loads map[string]*storeLoad // in flight, by id
+}
+
+// storeLoad is an in flight load of an entry
+type storeLoad struct {
+	wg   sync.WaitGroup
+	data interface{}
+	err  error
+}
+
+// NewLoadingStore creates a new, empty LoadingStore using the eviction
+// policies and loading entries with the loader by default (see LoadWith).
+func NewLoadingStore(loader LoaderFunc, opts StoreOptions) *LoadingStore {
+	return &LoadingStore{
+		Store:  NewStoreWithOptions(opts),
+		loader: loader,
+		loads:  make(map[string]*storeLoad),
+	}
+}
+
+// Load returns the data of the entry with the id, loading it with the
+// store's loader if it isn't in the store.
+func (s *LoadingStore) Load(id string) (interface{}, error) {
+	return s.LoadWith(id, s.loader)
+}
+
+// LoadWith returns the data of the entry with the id, loading it with the
+// loader if it isn't in the store. Concurrent calls for the same id share
+// the first one's load (and loader). If the loader panics the panic is
+// passed on and the waiting calls return an error.
+func (s *LoadingStore) LoadWith(id string, loader LoaderFunc) (interface{}, error) {
+	if data, ok := s.Recall(id); ok {
+		return data, nil
+	}
+
+	s.mu.Lock()
+	if l, ok := s.loads[id]; ok {
+		s.mu.Unlock()
+		l.wg.Wait()
+		return l.data, l.err
+	}
+	// loaded by a call finishing since the recall
+	if data, ok := s.Recall(id); ok {
+		s.mu.Unlock()
+		return data, nil
+	}
+	l := &storeLoad{}
+	l.wg.Add(1)
+	s.loads[id] = l
+	s.mu.Unlock()
+
+	// release the waiting calls even if the loader panics, with an error
+	// as it returned nothing
+	defer func() {
+		r := recover()
+		if r != nil {
+			l.data, l.err = nil, errors.Errorf("store: loader panic for %q: %v",
+				id, r)
+		}
+		s.mu.Lock()
+		delete(s.loads, id)
+		s.mu.Unlock()
+		l.wg.Done()
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	if loader == nil {
+		l.err = errors.Errorf("store: no loader for %q", id)
+	} else if l.data, l.err = loader(id); l.err == nil {
+		s.Save(id, l.data)
+	}
+	return l.data, l.err
+}
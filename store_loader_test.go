@@ -0,0 +1,152 @@
+package hcat
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadingStore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("read-through", func(t *testing.T) {
+		var loads int32
+		st := NewLoadingStore(func(id string) (interface{}, error) {
+			atomic.AddInt32(&loads, 1)
+			return "loaded-" + id, nil
+		}, StoreOptions{})
+
+		for i := 0; i < 2; i++ {
+			data, err := st.Load("foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data != "loaded-foo" {
+				t.Errorf("bad data: %v", data)
+			}
+		}
+		if loads != 1 {
+			t.Errorf("expected 1 load, got %d", loads)
+		}
+		if data, ok := st.Recall("foo"); !ok || data != "loaded-foo" {
+			t.Errorf("load not saved: %v", data)
+		}
+
+		// saved entries aren't loaded
+		st.Save("bar", "saved")
+		if data, _ := st.Load("bar"); data != "saved" {
+			t.Errorf("bad saved data: %v", data)
+		}
+		if loads != 1 {
+			t.Errorf("expected 1 load, got %d", loads)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		fail := errors.New("fail")
+		st := NewLoadingStore(func(id string) (interface{}, error) {
+			return nil, fail
+		}, StoreOptions{})
+		if _, err := st.Load("foo"); err != fail {
+			t.Errorf("bad error: %v", err)
+		}
+		if _, ok := st.Recall("foo"); ok {
+			t.Error("failed load saved")
+		}
+
+		data, err := st.LoadWith("foo", func(id string) (interface{}, error) {
+			return "other", nil
+		})
+		if err != nil || data != "other" {
+			t.Errorf("bad load with: %v, %v", data, err)
+		}
+	})
+
+	t.Run("no-loader", func(t *testing.T) {
+		st := NewLoadingStore(nil, StoreOptions{})
+		if _, err := st.Load("foo"); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("deduplicated", func(t *testing.T) {
+		var loads int32
+		release := make(chan struct{})
+		st := NewLoadingStore(func(id string) (interface{}, error) {
+			atomic.AddInt32(&loads, 1)
+			<-release
+			return "foo", nil
+		}, StoreOptions{})
+
+		var wg sync.WaitGroup
+		results := make([]interface{}, 10)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], _ = st.Load("foo")
+			}(i)
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if loads != 1 {
+			t.Errorf("expected 1 load, got %d", loads)
+		}
+		for _, r := range results {
+			if r != "foo" {
+				t.Errorf("bad result: %v", r)
+			}
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		st := NewLoadingStore(func(id string) (interface{}, error) {
+			close(started)
+			<-release
+			panic("boom")
+		}, StoreOptions{})
+
+		panicked := make(chan interface{})
+		go func() {
+			defer func() { panicked <- recover() }()
+			st.Load("foo")
+		}()
+		<-started
+		errCh := make(chan error)
+		go func() {
+			_, err := st.Load("foo")
+			errCh <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+
+		if r := <-panicked; r != "boom" {
+			t.Errorf("panic not passed on: %v", r)
+		}
+		if err := <-errCh; err == nil {
+			t.Error("expected the waiting load to error")
+		}
+		if _, ok := st.Recall("foo"); ok {
+			t.Error("panicked load saved")
+		}
+	})
+
+	t.Run("evicted", func(t *testing.T) {
+		var loads int32
+		st := NewLoadingStore(func(id string) (interface{}, error) {
+			atomic.AddInt32(&loads, 1)
+			return id, nil
+		}, StoreOptions{MaxEntries: 1})
+		st.Load("foo")
+		st.Load("bar")
+		st.Load("foo")
+		if loads != 3 {
+			t.Errorf("expected 3 loads, got %d", loads)
+		}
+	})
+}
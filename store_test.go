@@ -1,11 +1,19 @@
 package hcat
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/hcat/dep"
 	idep "github.com/hashicorp/hcat/internal/dependency"
+	"github.com/pkg/errors"
 )
 
 func TestNewStore(t *testing.T) {
@@ -125,3 +133,341 @@ func TestReset(t *testing.T) {
 		t.Errorf("expected %#v to not be forgotten", d)
 	}
 }
+
+func TestStoreEviction(t *testing.T) {
+	t.Parallel()
+
+	// fake clock to control the entries ages
+	clock := func(now *time.Time) func() time.Time {
+		return func() time.Time { return *now }
+	}
+
+	t.Run("lru", func(t *testing.T) {
+		st := NewStoreWithOptions(StoreOptions{MaxEntries: 2})
+		st.Save("a", 1)
+		st.Save("b", 2)
+		st.Recall("a") // b is now the least recently used
+		st.Save("c", 3)
+
+		if _, ok := st.Recall("b"); ok {
+			t.Error("expected b to be evicted")
+		}
+		for _, id := range []string{"a", "c"} {
+			if _, ok := st.Recall(id); !ok {
+				t.Errorf("expected %s to be stored", id)
+			}
+		}
+		stats := st.Stats()
+		if stats.Entries != 2 || stats.Evictions != 1 {
+			t.Errorf("bad stats: %#v", stats)
+		}
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		now := time.Now()
+		st := NewStoreWithOptions(StoreOptions{TTL: time.Minute})
+		st.now = clock(&now)
+		st.Save("a", 1)
+		now = now.Add(40 * time.Second)
+		st.Save("b", 2)
+		if age := st.Stats().OldestAge; age != 40*time.Second {
+			t.Errorf("bad oldest age: %v", age)
+		}
+
+		now = now.Add(30 * time.Second) // a is 70s old, b 30s
+		if _, ok := st.Recall("a"); ok {
+			t.Error("expected a to be expired")
+		}
+		if _, ok := st.Recall("b"); !ok {
+			t.Error("expected b to be stored")
+		}
+
+		// expired entries are also evicted on save
+		now = now.Add(2 * time.Minute)
+		st.Save("c", 3)
+		stats := st.Stats()
+		if stats.Entries != 1 || stats.Evictions != 2 {
+			t.Errorf("bad stats: %#v", stats)
+		}
+	})
+
+	t.Run("delete-reset", func(t *testing.T) {
+		st := NewStoreWithOptions(StoreOptions{MaxEntries: 2})
+		st.Save("a", 1)
+		st.Save("b", 2)
+		st.Delete("a")
+		st.Save("c", 3)
+		if _, ok := st.Recall("b"); !ok {
+			t.Error("deleted entry should free up space")
+		}
+		st.Reset()
+		if stats := st.Stats(); stats.Entries != 0 || stats.OldestAge != 0 {
+			t.Errorf("bad stats: %#v", stats)
+		}
+	})
+
+	t.Run("watcher-restores-evicted", func(t *testing.T) {
+		st := NewStoreWithOptions(StoreOptions{MaxEntries: 1})
+		w := NewWatcher(WatcherInput{Cache: st})
+		defer w.Stop()
+
+		d := &idep.FakeDep{Name: "foo"}
+		n := fakeNotifier("foo")
+		w.Register(n)
+		w.track(n, d).store("bar")
+		st.Save("other", "data") // nothing saved for d, like it was evicted
+
+		data, ok := w.Recaller(n)(d)
+		if !ok || data != "bar" {
+			t.Fatalf("expected view's data, got: %v, %v", data, ok)
+		}
+		if _, ok := st.Recall(d.ID()); !ok {
+			t.Error("expected data to be restored to the cache")
+		}
+	})
+}
+
+func TestStoreCodec(t *testing.T) {
+	t.Parallel()
+
+	services := []*dep.CatalogSnippet{{Name: "web", Tags: dep.ServiceTags{"a"}}}
+	secret := testVaultSecret(t)
+	every, cron := testTimerData(t)
+	newStore := func(codec StoreCodec) *Store {
+		st := NewStoreWithOptions(StoreOptions{Codec: codec})
+		st.Save("catalog.services", services)
+		st.Save("kv.get(foo)", dep.KvValue("bar"))
+		st.Save("kv.list(foo)", []*dep.KeyPair{{Key: "a", Value: "b"}})
+		st.Save("vault.read(secret/foo)", secret)
+		st.Save("every(1m)", every)
+		st.Save("cron(* * * * *)", cron)
+		return st
+	}
+
+	t.Run("gob", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := newStore(nil).Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		st := NewStore()
+		st.Save("kv.get(foo)", "old")
+		if err := st.Decode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := st.Recall("catalog.services")
+		if !reflect.DeepEqual(data, services) {
+			t.Errorf("bad services: %#v", data)
+		}
+		if data, _ := st.Recall("kv.get(foo)"); data != dep.KvValue("bar") {
+			t.Errorf("bad key: %#v", data)
+		}
+		data, _ = st.Recall("kv.list(foo)")
+		if list, ok := data.([]*dep.KeyPair); !ok || len(list) != 1 ||
+			list[0].Value != "b" {
+			t.Errorf("bad keys: %#v", data)
+		}
+		data, _ = st.Recall("vault.read(secret/foo)")
+		if !reflect.DeepEqual(data, secret) {
+			t.Errorf("bad secret: %#v", data)
+		}
+		for id, exp := range map[string]time.Time{"every(1m)": every,
+			"cron(* * * * *)": cron} {
+			data, _ = st.Recall(id)
+			if tm, ok := data.(time.Time); !ok || !tm.Equal(exp) {
+				t.Errorf("bad %s: %#v", id, data)
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := newStore(JSONCodec).Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var raw map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+			t.Fatalf("not json: %v: %s", err, buf.String())
+		}
+		if raw["kv.get(foo)"] != "bar" {
+			t.Errorf("bad json: %s", buf.String())
+		}
+
+		st := NewStoreWithOptions(StoreOptions{Codec: JSONCodec})
+		if err := st.Decode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := st.Recall("catalog.services")
+		list, ok := data.([]interface{})
+		if !ok || len(list) != 1 {
+			t.Fatalf("bad services: %#v", data)
+		}
+		if list[0].(map[string]interface{})["Name"] != "web" {
+			t.Errorf("bad services: %#v", data)
+		}
+	})
+
+	t.Run("decode-error", func(t *testing.T) {
+		st := NewStoreWithOptions(StoreOptions{Codec: JSONCodec})
+		if err := st.Decode(strings.NewReader("{")); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("health-service", func(t *testing.T) {
+		hs := []*dep.HealthService{{
+			Node:        "node",
+			NodeMeta:    map[string]string{"a": "b"},
+			Address:     "127.0.0.1",
+			ID:          "web-1",
+			Name:        "web",
+			Tags:        dep.ServiceTags{"a"},
+			ServiceMeta: map[string]string{"c": "d"},
+			Status:      "passing",
+			Port:        8080,
+			Checks:      []*api.HealthCheck{{CheckID: "check"}},
+			Weights:     api.AgentWeights{Passing: 1},
+		}}
+		st := NewStore()
+		st.Save("health.service(web)", hs)
+		var buf bytes.Buffer
+		if err := st.Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		st = NewStore()
+		if err := st.Decode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := st.Recall("health.service(web)"); !reflect.DeepEqual(data, hs) {
+			t.Errorf("bad services: %#v", data)
+		}
+	})
+
+	t.Run("version", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := newStore(JSONCodec).Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var raw map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+			t.Fatal(err)
+		}
+		if raw[schemaVersionKey] != float64(dep.SchemaVersion) {
+			t.Errorf("bad version: %s", buf.String())
+		}
+	})
+
+	// encode encodes the entries as is, without a schema version
+	encode := func(entries map[string]interface{}) *bytes.Buffer {
+		var buf bytes.Buffer
+		if err := GobCodec.Encode(&buf, entries); err != nil {
+			t.Fatal(err)
+		}
+		return &buf
+	}
+
+	t.Run("old-version", func(t *testing.T) {
+		st := NewStore()
+		err := st.Decode(encode(map[string]interface{}{"kv.get(foo)": "bar"}))
+		if errors.Cause(err) != ErrStoreVersion {
+			t.Fatalf("bad error: %v", err)
+		}
+		if _, ok := st.Recall("kv.get(foo)"); ok {
+			t.Error("expected nothing restored")
+		}
+	})
+
+	t.Run("migrate", func(t *testing.T) {
+		st := NewStoreWithOptions(StoreOptions{
+			Migrate: func(version int, entries map[string]interface{}) (
+				map[string]interface{}, error) {
+				if version != 0 {
+					t.Errorf("bad version: %d", version)
+				}
+				return map[string]interface{}{
+					"kv.get(foo)": entries["kv.get(foo)"].(string) + "-migrated",
+				}, nil
+			},
+		})
+		err := st.Decode(encode(map[string]interface{}{"kv.get(foo)": "bar"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := st.Recall("kv.get(foo)"); data != "bar-migrated" {
+			t.Errorf("bad key: %#v", data)
+		}
+	})
+
+	t.Run("newer-version", func(t *testing.T) {
+		st := NewStoreWithOptions(StoreOptions{
+			Migrate: func(int, map[string]interface{}) (
+				map[string]interface{}, error) {
+				t.Error("unexpected migrate")
+				return nil, nil
+			},
+		})
+		err := st.Decode(encode(map[string]interface{}{
+			schemaVersionKey: dep.SchemaVersion + 1,
+			"kv.get(foo)":    "bar",
+		}))
+		if errors.Cause(err) != ErrStoreVersion {
+			t.Fatalf("bad error: %v", err)
+		}
+	})
+}
+
+// testVaultSecret returns a KV v2 secret fetched from a fake Vault server, so
+// it has the data types the Vault API decodes, eg. json.Number.
+func testVaultSecret(t *testing.T) *dep.Secret {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"lease_duration": 0, "data": {` +
+				`"data": {"password": "zap", "port": 8080}, ` +
+				`"metadata": {"version": 3, "deletion_time": "", ` +
+				`"destroyed": false}}}`))
+		}))
+	defer srv.Close()
+	clients := NewClientSet()
+	defer clients.Stop()
+	if err := clients.AddVault(VaultInput{Address: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	d, err := idep.NewVaultReadQuery("secret/foo?kv_version=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+	data, _, err := d.Fetch(clients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := data.(*dep.Secret)
+	md, _ := secret.Data["metadata"].(map[string]interface{})
+	if _, ok := md["version"].(json.Number); !ok {
+		t.Fatalf("no json.Number in the secret: %#v", secret.Data)
+	}
+	return secret
+}
+
+// testTimerData returns the data of an every and a cron dependency
+func testTimerData(t *testing.T) (every, cron time.Time) {
+	for _, q := range []struct {
+		new  func(string) (*idep.TimerQuery, error)
+		arg  string
+		data *time.Time
+	}{
+		{idep.NewEveryQuery, "1m", &every},
+		{idep.NewCronQuery, "* * * * *", &cron},
+	} {
+		d, err := q.new(q.arg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _, err := d.Fetch(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*q.data = data.(time.Time)
+		d.Stop()
+	}
+	return every, cron
+}
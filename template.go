@@ -2,13 +2,17 @@ package hcat
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"fmt"
+	"io"
 	"sync"
 	"sync/atomic"
 	"text/template"
 
 	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
 	"github.com/pkg/errors"
 )
 
@@ -24,6 +28,9 @@ type Template struct {
 	// template name, appened to ID (random if not specified)
 	name string
 
+	// label attributes the template to its owner, see TemplateInput's Label
+	label string
+
 	// contents is the string contents for the template. It is either given
 	// during template creation or read from disk when initialized.
 	contents string
@@ -55,9 +62,49 @@ type Template struct {
 	// Renderer is the default renderer used for this template
 	renderer Renderer
 
+	// destination describes the file the template is rendered to by RenderFor
+	destination *FileRendererInput
+
+	// limits enforced on each execution
+	limits TemplateLimits
+
+	// tracer traces the executions and renders
+	tracer Tracer
+
+	// allowedFuncs and deniedFuncs restrict the functions the template can
+	// use, checked when parsing
+	allowedFuncs []string
+	deniedFuncs  []string
+
+	// dependencies are registered on each execution, their data passed to
+	// the template keyed by name
+	dependencies map[string]dep.Dependency
+
+	// vars are the runtime values returned by the `var` function, replaced
+	// as a whole by SetVars
+	vars     map[string]interface{}
+	varsLock sync.RWMutex
+
+	// audit records the data returning function calls of each execution,
+	// those of the last successful one stored in calls
+	audit bool
+	calls atomic.Value
+
+	// sensitive templates don't cache their contents, see TemplateInput
+	sensitive bool
+	// priority orders the template in RunLoop, see TemplateInput
+	priority int
+
 	// cache for the current rendered template content
 	cache atomic.Value
 	once  sync.Once // for cache init
+
+	// owner is the watcher (tree, with its forks) the template is registered
+	// with and holders the ones of the tree it is registered with. Its notify
+	// state (dirty) can't be shared by unrelated watchers.
+	owner     *Watcher
+	holders   map[*Watcher]struct{}
+	ownerLock sync.Mutex
 }
 
 // Renderer defines the interface used to render (output) and template.
@@ -70,6 +117,12 @@ type Renderer interface {
 // Implemented by Store and Watcher (which wraps Store)
 type Recaller func(dep.Dependency) (value interface{}, found bool)
 
+// TemplateIDFunc is a special case of the FuncMapMerge functions, called with
+// the ID of the template being executed and returning the template function
+// (like the Recaller functions). Use it for functions whose output depends on
+// the template, eg. values that are stable across its executions.
+type TemplateIDFunc func(id string) interface{}
+
 // TemplateInput is used as input when creating the template.
 type TemplateInput struct {
 
@@ -77,6 +130,13 @@ type TemplateInput struct {
 	// to use the same content in more than one template with the same Watcher.
 	Name string
 
+	// Label optionally attributes the template to its owner (eg. a team) in
+	// daemons rendering templates for many. It isn't part of the ID, it is
+	// added to the template's trace spans and the Watcher reports the labels
+	// of the templates using each dependency (see Watcher.Labels), so load
+	// and errors can be attributed.
+	Label string
+
 	// Contents are the raw template contents.
 	Contents string
 
@@ -97,6 +157,7 @@ type TemplateInput struct {
 	// by text/template's Funcmap (masked by an interface).
 	// This special case function's signature should match:
 	//    func(Recaller) interface{}
+	// Similarly TemplateIDFunc functions get called with the template's ID.
 	FuncMapMerge template.FuncMap
 
 	// SandboxPath adds a prefix to any path provided to the `file` function
@@ -106,6 +167,63 @@ type TemplateInput struct {
 
 	// Renderer is the default renderer used for this template
 	Renderer Renderer
+
+	// Destination describes the file the template is rendered to, its path,
+	// permissions, owner, backups, etc. Used by RenderFor so the template
+	// carries its render configuration (optional).
+	Destination *FileRendererInput
+
+	// Limits on the output size, range iterations and nested template depth
+	// of each execution. Exceeding one fails the execution with a LimitError.
+	Limits TemplateLimits
+
+	// Tracer enables tracing the template's executions and renders (optional)
+	Tracer Tracer
+
+	// AllowedFuncs, if set, are the only functions the template can use (in
+	// addition to the text/template built in functions).
+	// DeniedFuncs are functions the template can't use, built in ones
+	// included. Both are checked when the template is parsed, before it is
+	// executed, and using any disallowed function fails with a
+	// FuncsNotAllowedError listing all of them.
+	AllowedFuncs []string
+	DeniedFuncs  []string
+
+	// Dependencies are registered with the template in addition to those of
+	// the template functions, eg. a watch set built from configuration. Their
+	// data is passed to the template as the dot, a map keyed by the names
+	// given here, so `{{ range .web }}` ranges over the data of the "web"
+	// dependency. The data is nil until it has been fetched.
+	Dependencies map[string]dep.Dependency
+
+	// Vars are runtime values, that aren't watched, made available to the
+	// template by the `var` function, eg. `{{ var "region" }}`. Use them for
+	// values known to the embedding application like the node name or build
+	// version. Update them with Template.SetVars.
+	Vars map[string]interface{}
+
+	// Audit records every call of a data returning template function (those
+	// built from the Recaller) on each execution, with its arguments and the
+	// dependencies it recalled. So what data each rendered file consumed can
+	// be audited. See Template.Calls and ResolveEvent's Calls.
+	Audit bool
+
+	// Sensitive marks the template's output as sensitive (eg. it contains
+	// secrets). Its rendered contents aren't kept by the template between
+	// executions, are zeroed once rendered by Template.Render or RenderFor,
+	// are redacted from dry-run results and files it renders to are created
+	// with 0600 permissions (unless set otherwise) and the arguments of its
+	// audited calls are redacted. Events for it are flagged Sensitive and
+	// post-execute hooks only get its contents if added with
+	// Resolver.AddSensitivePostExecuteHook.
+	Sensitive bool
+
+	// Priority orders the templates run by RunLoop, higher first (default
+	// 0). When many templates have new data at once the higher priority ones
+	// (eg. a load balancer's config) are rendered first and, if new data
+	// arrives meanwhile, it is processed before the lower priority ones. Those
+	// are only put off a few times in a row so they aren't starved.
+	Priority int
 }
 
 // NewTemplate creates a new Template and primes it for the initial run.
@@ -113,13 +231,40 @@ func NewTemplate(i TemplateInput) *Template {
 
 	var t Template
 	t.name = i.Name
+	t.label = i.Label
 	t.contents = i.Contents
 	t.leftDelim = i.LeftDelim
 	t.rightDelim = i.RightDelim
 	t.errMissingKey = i.ErrMissingKey
 	t.sandboxPath = i.SandboxPath
-	t.funcMapMerge = i.FuncMapMerge
+	// copy the function map and lists so later changes to the input don't
+	// leak into the template
+	if i.FuncMapMerge != nil {
+		t.funcMapMerge, _ = MergeFuncMaps(i.FuncMapMerge)
+	}
+	if len(i.AllowedFuncs) > 0 {
+		t.allowedFuncs = append([]string{}, i.AllowedFuncs...)
+	}
+	if len(i.DeniedFuncs) > 0 {
+		t.deniedFuncs = append([]string{}, i.DeniedFuncs...)
+	}
+	if len(i.Dependencies) > 0 {
+		t.dependencies = make(map[string]dep.Dependency, len(i.Dependencies))
+		for k, d := range i.Dependencies {
+			t.dependencies[k] = d
+		}
+	}
+	t.vars = copyVars(i.Vars)
 	t.renderer = i.Renderer
+	if i.Destination != nil {
+		dest := *i.Destination
+		t.destination = &dest
+	}
+	t.limits = i.Limits
+	t.tracer = i.Tracer
+	t.audit = i.Audit
+	t.sensitive = i.Sensitive
+	t.priority = i.Priority
 	t.dirty = make(drainableChan, 1)
 	t.Notify(nil) // prime template as needing to be run
 
@@ -139,6 +284,22 @@ func (t *Template) ID() string {
 	return t.hexMD5
 }
 
+// Label returns the template's label, see TemplateInput's Label.
+func (t *Template) Label() string {
+	return t.label
+}
+
+// Sensitive returns true if the template's output is sensitive, see
+// TemplateInput's Sensitive.
+func (t *Template) Sensitive() bool {
+	return t.sensitive
+}
+
+// Priority returns the template's priority, see TemplateInput's Priority.
+func (t *Template) Priority() int {
+	return t.priority
+}
+
 // Notify template that a dependency it relies on has been updated. Works by
 // marking the template so it knows it has new data to process when Execute is
 // called.
@@ -150,6 +311,72 @@ func (t *Template) Notify(interface{}) bool {
 	return true
 }
 
+// claim registers the template with the watcher, returning false if it is
+// registered with a watcher of another tree (not a fork or parent).
+func (t *Template) claim(w *Watcher) bool {
+	t.ownerLock.Lock()
+	defer t.ownerLock.Unlock()
+	root := w.root()
+	if t.owner != nil && t.owner != root {
+		return false
+	}
+	if t.holders == nil {
+		t.holders = make(map[*Watcher]struct{})
+	}
+	t.owner = root
+	t.holders[w] = struct{}{}
+	return true
+}
+
+// release deregisters the template from the watcher, once it is registered
+// with none of its tree's watchers it can be registered with another.
+func (t *Template) release(w *Watcher) {
+	t.ownerLock.Lock()
+	defer t.ownerLock.Unlock()
+	delete(t.holders, w)
+	if len(t.holders) == 0 {
+		t.owner = nil
+	}
+}
+
+// SetVars replaces the template's runtime variables, see TemplateInput's
+// Vars, and marks the template as needing to be re-rendered.
+func (t *Template) SetVars(vars map[string]interface{}) {
+	vars = copyVars(vars)
+	t.varsLock.Lock()
+	t.vars = vars
+	t.varsLock.Unlock()
+	t.Notify(nil)
+}
+
+// copyVars returns a copy of the vars so later changes to the map don't leak
+// into the template, nil if there are none.
+func copyVars(vars map[string]interface{}) map[string]interface{} {
+	if len(vars) == 0 {
+		return nil
+	}
+	c := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		c[k] = v
+	}
+	return c
+}
+
+// varFunc returns the `var` template function, returning the named runtime
+// variable. Unknown variables are nil, or an error with ErrMissingKey set.
+func (t *Template) varFunc() func(string) (interface{}, error) {
+	t.varsLock.RLock()
+	vars := t.vars
+	t.varsLock.RUnlock()
+	return func(name string) (interface{}, error) {
+		v, ok := vars[name]
+		if !ok && t.errMissingKey {
+			return nil, fmt.Errorf("var: no variable %q", name)
+		}
+		return v, nil
+	}
+}
+
 // Check and clear dirty flag
 func (t *Template) isDirty() bool {
 	select {
@@ -160,23 +387,78 @@ func (t *Template) isDirty() bool {
 	}
 }
 
-// Render calls the stored Renderer with the passed content
-func (t *Template) Render(content []byte) (RenderResult, error) {
+// Render calls the stored Renderer with the passed content. The content is
+// zeroed after rendering if the template is Sensitive.
+func (t *Template) Render(content []byte) (result RenderResult, err error) {
+	if t.tracer != nil {
+		_, span := t.tracer.StartSpan(context.Background(), SpanRender,
+			t.spanAttributes()...)
+		defer func() { span.End(err) }()
+	}
+	if t.sensitive {
+		defer zeroBytes(content)
+	}
 	return t.renderer.Render(content)
 }
 
+// spanAttributes returns the attributes of the template's trace spans.
+func (t *Template) spanAttributes() []SpanAttribute {
+	attrs := []SpanAttribute{{Key: AttrTemplateID, Value: t.ID()}}
+	if t.label != "" {
+		attrs = append(attrs, SpanAttribute{Key: AttrTemplateLabel,
+			Value: t.label})
+	}
+	return attrs
+}
+
 // Execute evaluates this template in the provided context.
-func (t *Template) Execute(rec Recaller) ([]byte, error) {
+func (t *Template) Execute(rec Recaller) (content []byte, err error) {
 	t.once.Do(func() { t.cache.Store([]byte{}) }) // init cache
 	if !t.isDirty() {
+		if t.sensitive {
+			return nil, ErrNoNewValues
+		}
 		return t.cache.Load().([]byte), ErrNoNewValues
 	}
 
+	if t.tracer != nil {
+		_, span := t.tracer.StartSpan(context.Background(), SpanExecute,
+			t.spanAttributes()...)
+		defer func() { span.End(err) }()
+	}
+
+	var audit *funcAudit
+	if t.audit {
+		audit = &funcAudit{}
+	}
+	content, err = t.execute(rec, audit)
+	if err != nil {
+		return nil, err
+	}
+	if !t.sensitive {
+		t.cache.Store(content)
+	}
+	if audit != nil {
+		if t.sensitive {
+			audit.redact()
+		}
+		t.calls.Store(audit.calls)
+	}
+
+	return content, nil
+}
+
+// execute parses and executes the template, recalling the dependencies' data
+// with the Recaller. The function calls are recorded by the audit, if not nil.
+func (t *Template) execute(rec Recaller, audit *funcAudit) ([]byte, error) {
 	tmpl := template.New(t.ID())
 	tmpl.Delims(t.leftDelim, t.rightDelim)
+	tmpl.Funcs(template.FuncMap{"var": t.varFunc()})
 	tmpl.Funcs(funcMap(&funcMapInput{
+		id:           t.ID(),
 		recaller:     rec,
 		funcMapMerge: t.funcMapMerge,
+		audit:        audit,
 	}))
 
 	if t.errMissingKey {
@@ -185,26 +467,57 @@ func (t *Template) Execute(rec Recaller) ([]byte, error) {
 		tmpl.Option("missingkey=zero")
 	}
 
+	var guard *limitGuard
+	if t.limits.enabled() {
+		guard = newLimitGuard(t.limits)
+		tmpl.Funcs(guard.funcs())
+	}
+
 	tmpl, err := tmpl.Parse(t.contents)
 	if err != nil {
 		return nil, errors.Wrap(err, "parse")
 	}
+	if err := t.checkFuncs(tmpl); err != nil {
+		return nil, errors.Wrap(err, "parse")
+	}
 
 	// Execute the template into the writer
 	var b bytes.Buffer
-	if err := tmpl.Execute(&b, nil); err != nil {
+	var w io.Writer = &b
+	if guard != nil {
+		if err := guard.instrument(tmpl); err != nil {
+			return nil, errors.Wrap(err, "limits")
+		}
+		if t.limits.MaxOutputSize > 0 {
+			w = &limitWriter{w: &b, max: t.limits.MaxOutputSize}
+		}
+	}
+	if err := tmpl.Execute(w, t.data(rec)); err != nil {
 		return nil, errors.Wrap(err, "execute")
 	}
-	content := b.Bytes()
-	t.cache.Store(content)
+	return b.Bytes(), nil
+}
 
-	return content, nil
+// data recalls the template's dependencies, returning their data keyed by
+// name for use as the dot. Returns nil (not a nil map, so the dot behaves as
+// before) if there are no dependencies.
+func (t *Template) data(rec Recaller) interface{} {
+	if len(t.dependencies) == 0 {
+		return nil
+	}
+	data := make(map[string]interface{}, len(t.dependencies))
+	for k, d := range t.dependencies {
+		data[k], _ = rec(d)
+	}
+	return data
 }
 
 // funcMapInput is input to the funcMap, which builds the template functions.
 type funcMapInput struct {
+	id           string
 	recaller     Recaller
 	funcMapMerge template.FuncMap
+	audit        *funcAudit
 }
 
 // funcMap is the map of template functions to their respective functions.
@@ -213,10 +526,67 @@ func funcMap(i *funcMapInput) template.FuncMap {
 	for k, v := range i.funcMapMerge {
 		switch f := v.(type) {
 		case func(Recaller) interface{}:
+			if i.audit != nil {
+				r[k] = i.audit.wrap(k, f(i.audit.recaller(i.recaller)))
+				break
+			}
 			r[k] = f(i.recaller)
+		case TemplateIDFunc:
+			r[k] = f(i.id)
 		default:
 			r[k] = v
 		}
 	}
 	return r
 }
+
+// DependencyStub describes a dependency a template would register when run.
+type DependencyStub struct {
+	// ID is the dependency's ID, eg. "health.service(web|passing)"
+	ID string
+	// Type is the upstream the dependency queries, "consul" or "vault". Empty
+	// for others (eg. files).
+	Type string
+	// Dependency is the (unstarted) dependency itself.
+	Dependency dep.Dependency
+}
+
+// ParseDependencies parses the template contents and reports the
+// dependencies (service names, KV paths, vault paths, etc.) it would register
+// with a Watcher, without fetching anything. The template functions are
+// merged in order, like TemplateInput's FuncMapMerge.
+//
+// As no data is fetched only dependencies that don't rely on the values of
+// other dependencies are found. Eg. a service lookup nested in a range over
+// the catalog services won't be reported.
+func ParseDependencies(contents string, funcs ...template.FuncMap) (
+	[]DependencyStub, error) {
+	funcMapMerge, _ := MergeFuncMaps(funcs...)
+
+	deps := NewDepSet()
+	recall := func(d dep.Dependency) (interface{}, bool) {
+		deps.Add(d)
+		return nil, false
+	}
+
+	tmpl := NewTemplate(TemplateInput{
+		Contents:     contents,
+		FuncMapMerge: funcMapMerge,
+	})
+	if _, err := tmpl.Execute(recall); err != nil {
+		return nil, err
+	}
+
+	stubs := make([]DependencyStub, 0, len(deps.List()))
+	for _, d := range deps.List() {
+		stub := DependencyStub{ID: d.ID(), Dependency: d}
+		switch d.(type) {
+		case idep.ConsulType:
+			stub.Type = "consul"
+		case idep.VaultType:
+			stub.Type = "vault"
+		}
+		stubs = append(stubs, stub)
+	}
+	return stubs, nil
+}
@@ -0,0 +1,92 @@
+package hcat
+
+import (
+	"reflect"
+
+	"github.com/hashicorp/hcat/dep"
+)
+
+// FuncCall is a call of a data returning template function (one built from
+// the Recaller, eg. `service` or `secret`) recorded by an audited template.
+type FuncCall struct {
+	// Name is the name of the function, as called in the template.
+	Name string
+	// Args are the arguments it was called with.
+	Args []interface{}
+	// Dependencies are the IDs of the dependencies it recalled the data of,
+	// in order. Empty if it didn't need any.
+	Dependencies []string
+}
+
+// funcAudit records the function calls of a single template execution.
+type funcAudit struct {
+	calls []FuncCall
+	cur   *FuncCall // call in progress
+}
+
+// recaller wraps the Recaller to record the dependencies recalled by the call
+// in progress.
+func (a *funcAudit) recaller(rec Recaller) Recaller {
+	return func(d dep.Dependency) (interface{}, bool) {
+		if a.cur != nil {
+			a.cur.Dependencies = append(a.cur.Dependencies, d.ID())
+		}
+		return rec(d)
+	}
+}
+
+// wrap returns a function of the same type as f that records its calls.
+func (a *funcAudit) wrap(name string, f interface{}) interface{} {
+	fv := reflect.ValueOf(f)
+	if fv.Kind() != reflect.Func {
+		return f
+	}
+	ft := fv.Type()
+	return reflect.MakeFunc(ft, func(in []reflect.Value) []reflect.Value {
+		call := FuncCall{Name: name, Args: make([]interface{}, 0, len(in))}
+		for i, v := range in {
+			if ft.IsVariadic() && i == len(in)-1 {
+				for j := 0; j < v.Len(); j++ {
+					call.Args = append(call.Args, v.Index(j).Interface())
+				}
+				break
+			}
+			call.Args = append(call.Args, v.Interface())
+		}
+
+		prev := a.cur
+		a.cur = &call
+		defer func() {
+			a.cur = prev
+			a.calls = append(a.calls, call)
+		}()
+		if ft.IsVariadic() {
+			return fv.CallSlice(in)
+		}
+		return fv.Call(in)
+	}).Interface()
+}
+
+// redact replaces the arguments of the recorded calls, for sensitive
+// templates whose arguments can be secrets too (eg. a `secret` write's).
+func (a *funcAudit) redact() {
+	for i, call := range a.calls {
+		args := make([]interface{}, len(call.Args))
+		for j := range args {
+			args[j] = redacted
+		}
+		a.calls[i].Args = args
+	}
+}
+
+// Calls returns the data returning function calls of the template's last
+// successful execution, in the order they were made. Only recorded when
+// TemplateInput's Audit is set, nil otherwise. The arguments of a Sensitive
+// template's calls are redacted.
+func (t *Template) Calls() []FuncCall {
+	calls, _ := t.calls.Load().([]FuncCall)
+	if calls == nil {
+		return nil
+	}
+	return append([]FuncCall(nil), calls...)
+}
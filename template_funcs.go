@@ -0,0 +1,143 @@
+package hcat
+
+import (
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// MergeFuncMaps merges the FuncMaps in order, functions in later maps
+// overriding those of the same name in earlier ones. It returns the merged
+// map and the sorted names of the functions that were overridden, so
+// collisions can be reported or rejected.
+func MergeFuncMaps(maps ...template.FuncMap) (template.FuncMap, []string) {
+	merged := make(template.FuncMap)
+	var overridden []string
+	seen := make(map[string]bool)
+	for _, fm := range maps {
+		for k, v := range fm {
+			if _, ok := merged[k]; ok && !seen[k] {
+				overridden = append(overridden, k)
+				seen[k] = true
+			}
+			merged[k] = v
+		}
+	}
+	sort.Strings(overridden)
+	return merged, overridden
+}
+
+// FuncsNotAllowedError is the error returned when a template uses functions
+// that are denied, or not allowed, by its TemplateInput's DeniedFuncs and
+// AllowedFuncs.
+type FuncsNotAllowedError struct {
+	// Funcs are the sorted names of all the disallowed functions used.
+	Funcs []string
+}
+
+func (e *FuncsNotAllowedError) Error() string {
+	return "template uses functions that are not allowed: " +
+		strings.Join(e.Funcs, ", ")
+}
+
+// builtinFuncs are the text/template built in functions, these are always
+// allowed unless explicitly denied.
+var builtinFuncs = map[string]bool{
+	"and": true, "call": true, "html": true, "index": true, "slice": true,
+	"js": true, "len": true, "not": true, "or": true, "print": true,
+	"printf": true, "println": true, "urlquery": true, "eq": true, "ge": true,
+	"gt": true, "le": true, "lt": true, "ne": true,
+}
+
+// checkFuncs returns a FuncsNotAllowedError listing all the functions the
+// parsed templates use that are denied or, if there is an allow list, not on
+// it. Returns nil if there are no allow or deny lists.
+func (t *Template) checkFuncs(tmpl *template.Template) error {
+	if len(t.allowedFuncs) == 0 && len(t.deniedFuncs) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool, len(t.allowedFuncs))
+	for _, f := range t.allowedFuncs {
+		allowed[f] = true
+	}
+	denied := make(map[string]bool, len(t.deniedFuncs))
+	for _, f := range t.deniedFuncs {
+		denied[f] = true
+	}
+
+	var bad []string
+	for _, f := range usedFuncs(tmpl) {
+		switch {
+		case denied[f]:
+		case len(allowed) == 0, allowed[f], builtinFuncs[f]:
+			continue
+		}
+		bad = append(bad, f)
+	}
+	if len(bad) > 0 {
+		return &FuncsNotAllowedError{Funcs: bad}
+	}
+	return nil
+}
+
+// usedFuncs returns the sorted names of the functions called in the parsed
+// templates.
+func usedFuncs(tmpl *template.Template) []string {
+	used := make(map[string]bool)
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, n := range n.Nodes {
+				walk(n)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Cmds {
+				walk(c)
+			}
+		case *parse.CommandNode:
+			for _, a := range n.Args {
+				walk(a)
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.IdentifierNode:
+			used[n.Ident] = true
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+
+	funcs := make([]string, 0, len(used))
+	for f := range used {
+		funcs = append(funcs, f)
+	}
+	sort.Strings(funcs)
+	return funcs
+}
@@ -0,0 +1,174 @@
+package hcat
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+	"text/template/parse"
+)
+
+// TemplateLimits are limits enforced on every execution of a template to keep
+// a runaway template (eg. ranging over a service that suddenly has 100k
+// instances) from exhausting the memory of the process. Zero values disable
+// the individual limits.
+type TemplateLimits struct {
+	// MaxOutputSize is the maximum size, in bytes, of the rendered output.
+	MaxOutputSize int64
+
+	// MaxRangeIterations is the maximum number of range loop iterations, the
+	// sum of all range loops in the template including nested ones.
+	MaxRangeIterations int64
+
+	// MaxTemplateDepth is the maximum depth of nested template calls, eg.
+	// `{{ template "name" }}` calls, including recursive ones.
+	MaxTemplateDepth int64
+}
+
+func (l TemplateLimits) enabled() bool {
+	return l.MaxOutputSize > 0 || l.MaxRangeIterations > 0 ||
+		l.MaxTemplateDepth > 0
+}
+
+// LimitError is the error returned when executing a template exceeds one of
+// its TemplateLimits. Use errors.As to check for it.
+type LimitError struct {
+	// Limit is the name of the exceeded limit, one of "output size", "range
+	// iterations" or "template depth".
+	Limit string
+	// Max is the configured value of the limit.
+	Max int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("template %s limit of %d exceeded", e.Limit, e.Max)
+}
+
+// names of the internal functions added to the templates to enforce the
+// limits, intentionally not something someone would use
+const (
+	rangeGuardFunc = "_hcatRangeGuard"
+	enterGuardFunc = "_hcatEnterGuard"
+	exitGuardFunc  = "_hcatExitGuard"
+)
+
+// limitGuard tracks the counters for a single template execution.
+type limitGuard struct {
+	limits     TemplateLimits
+	iterations int64
+	depth      int64
+}
+
+func newLimitGuard(limits TemplateLimits) *limitGuard {
+	return &limitGuard{limits: limits}
+}
+
+// funcs returns the guard functions that need to be added to the template's
+// functions before parsing.
+func (g *limitGuard) funcs() template.FuncMap {
+	return template.FuncMap{
+		rangeGuardFunc: func() (string, error) {
+			g.iterations++
+			max := g.limits.MaxRangeIterations
+			if max > 0 && g.iterations > max {
+				return "", &LimitError{Limit: "range iterations", Max: max}
+			}
+			return "", nil
+		},
+		enterGuardFunc: func() (string, error) {
+			g.depth++
+			max := g.limits.MaxTemplateDepth
+			if max > 0 && g.depth > max {
+				return "", &LimitError{Limit: "template depth", Max: max}
+			}
+			return "", nil
+		},
+		exitGuardFunc: func() string {
+			g.depth--
+			return ""
+		},
+	}
+}
+
+// instrument adds the guard function calls to the parsed templates. A range
+// guard call is added to the start of every range body and all templates
+// other than the top level one are wrapped in enter/exit guard calls to track
+// the depth.
+func (g *limitGuard) instrument(tmpl *template.Template) error {
+	rangeGuard, err := g.guardNode(rangeGuardFunc)
+	if err != nil {
+		return err
+	}
+	enterGuard, err := g.guardNode(enterGuardFunc)
+	if err != nil {
+		return err
+	}
+	exitGuard, err := g.guardNode(exitGuardFunc)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		root := t.Tree.Root
+		addRangeGuards(root, rangeGuard)
+		if t.Name() != tmpl.Name() {
+			nodes := make([]parse.Node, 0, len(root.Nodes)+2)
+			nodes = append(nodes, enterGuard)
+			nodes = append(nodes, root.Nodes...)
+			root.Nodes = append(nodes, exitGuard)
+		}
+	}
+	return nil
+}
+
+// guardNode returns the parsed action node calling the named guard function.
+func (g *limitGuard) guardNode(name string) (parse.Node, error) {
+	t, err := template.New(name).Funcs(g.funcs()).
+		Parse("{{" + name + "}}")
+	if err != nil {
+		return nil, err
+	}
+	return t.Tree.Root.Nodes[0], nil
+}
+
+// addRangeGuards walks the nodes, prepending the guard to all range bodies.
+func addRangeGuards(list *parse.ListNode, guard parse.Node) {
+	if list == nil {
+		return
+	}
+	for _, n := range list.Nodes {
+		switch n := n.(type) {
+		case *parse.RangeNode:
+			addRangeGuards(n.List, guard)
+			addRangeGuards(n.ElseList, guard)
+			n.List.Nodes = append([]parse.Node{guard}, n.List.Nodes...)
+		case *parse.IfNode:
+			addRangeGuards(n.List, guard)
+			addRangeGuards(n.ElseList, guard)
+		case *parse.WithNode:
+			addRangeGuards(n.List, guard)
+			addRangeGuards(n.ElseList, guard)
+		case *parse.ListNode:
+			addRangeGuards(n, guard)
+		}
+	}
+}
+
+// limitWriter is an io.Writer that errors once more than max bytes are
+// written to it.
+type limitWriter struct {
+	w   io.Writer
+	max int64
+	n   int64
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if l.n+int64(len(p)) > l.max {
+		return 0, &LimitError{Limit: "output size", Max: l.max}
+	}
+	n, err := l.w.Write(p)
+	l.n += int64(n)
+	return n, err
+}
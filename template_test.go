@@ -2,11 +2,16 @@ package hcat
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"text/template"
 
 	"github.com/hashicorp/hcat/dep"
 	idep "github.com/hashicorp/hcat/internal/dependency"
@@ -165,6 +170,57 @@ func TestTemplate_Execute(t *testing.T) {
 			"<no value>",
 			false,
 		},
+		// explicit dependencies
+		{
+			"dependencies",
+			TemplateInput{
+				Contents: `{{ .foo }}{{ with .bar }}{{ . }}{{ end }}`,
+				Dependencies: map[string]dep.Dependency{
+					"foo": &idep.FakeDep{Name: "foo"},
+					"bar": &idep.FakeDep{Name: "bar"},
+				},
+			},
+			func() *Store {
+				st := NewStore()
+				st.Save((&idep.FakeDep{Name: "foo"}).ID(), "1")
+				return st
+			}(),
+			"1",
+			false,
+		},
+		// runtime vars
+		{
+			"vars",
+			TemplateInput{
+				Contents: `{{ var "region" }}-{{ var "build" }}`,
+				Vars: map[string]interface{}{
+					"region": "us-east-1",
+					"build":  3,
+				},
+			},
+			nil,
+			"us-east-1-3",
+			false,
+		},
+		{
+			"vars_missing",
+			TemplateInput{
+				Contents: `{{ if var "region" }}yes{{ else }}no{{ end }}`,
+			},
+			nil,
+			"no",
+			false,
+		},
+		{
+			"vars_missing_err_missing_key",
+			TemplateInput{
+				Contents:      `{{ var "region" }}`,
+				ErrMissingKey: true,
+			},
+			nil,
+			"",
+			true,
+		},
 	}
 
 	for i, tc := range cases {
@@ -183,6 +239,486 @@ func TestTemplate_Execute(t *testing.T) {
 	}
 }
 
+func TestTemplate_SetVars(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]interface{}{"node": "a"}
+	tpl := NewTemplate(TemplateInput{
+		Contents: `{{ var "node" }}`,
+		Vars:     vars,
+	})
+	// changes to the input don't leak into the template
+	vars["node"] = "b"
+
+	a, err := tpl.Execute(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != "a" {
+		t.Fatalf("bad output: %q", a)
+	}
+
+	// not dirty, nothing new
+	if _, err := tpl.Execute(nil); err != ErrNoNewValues {
+		t.Fatalf("expected ErrNoNewValues, got: %v", err)
+	}
+
+	tpl.SetVars(map[string]interface{}{"node": "c"})
+	a, err = tpl.Execute(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != "c" {
+		t.Fatalf("bad output: %q", a)
+	}
+}
+
+func TestTemplate_Audit(t *testing.T) {
+	t.Parallel()
+
+	st := NewStore()
+	st.Save("test_list_dep(words)", []string{"a", "b"})
+	st.Save("test_dep(a)", "A")
+	st.Save("test_dep(b)", "B")
+	w := fakeWatcher{st}
+	newTmpl := func(audit bool) *Template {
+		return NewTemplate(TemplateInput{
+			Contents: `{{ upper "x" }}{{ range words "a" "b" }}{{ echo . }}{{ end }}`,
+			FuncMapMerge: template.FuncMap{
+				"echo":  echoFunc,
+				"words": wordListFunc,
+				"upper": strings.ToUpper,
+			},
+			Audit: audit,
+		})
+	}
+
+	t.Run("audit", func(t *testing.T) {
+		tpl := newTmpl(true)
+		event, err := NewResolver().Run(tpl, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(event.Contents) != "XAB" {
+			t.Fatalf("bad output: %q", event.Contents)
+		}
+		exp := []FuncCall{
+			{Name: "words", Args: []interface{}{"a", "b"},
+				Dependencies: []string{"test_list_dep(words)"}},
+			{Name: "echo", Args: []interface{}{"a"},
+				Dependencies: []string{"test_dep(a)"}},
+			{Name: "echo", Args: []interface{}{"b"},
+				Dependencies: []string{"test_dep(b)"}},
+		}
+		if !reflect.DeepEqual(event.Calls, exp) {
+			t.Errorf("bad calls\nexp: %#v\nact: %#v", exp, event.Calls)
+		}
+		if !reflect.DeepEqual(tpl.Calls(), exp) {
+			t.Errorf("bad template calls: %#v", tpl.Calls())
+		}
+	})
+
+	t.Run("sensitive", func(t *testing.T) {
+		tpl := NewTemplate(TemplateInput{
+			Contents: `{{ range words "a" "b" }}{{ echo . }}{{ end }}`,
+			FuncMapMerge: template.FuncMap{
+				"echo":  echoFunc,
+				"words": wordListFunc,
+			},
+			Audit:     true,
+			Sensitive: true,
+		})
+		event, err := NewResolver().Run(tpl, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := []FuncCall{
+			{Name: "words", Args: []interface{}{redacted, redacted},
+				Dependencies: []string{"test_list_dep(words)"}},
+			{Name: "echo", Args: []interface{}{redacted},
+				Dependencies: []string{"test_dep(a)"}},
+			{Name: "echo", Args: []interface{}{redacted},
+				Dependencies: []string{"test_dep(b)"}},
+		}
+		if !reflect.DeepEqual(event.Calls, exp) {
+			t.Errorf("bad calls\nexp: %#v\nact: %#v", exp, event.Calls)
+		}
+		if !reflect.DeepEqual(tpl.Calls(), exp) {
+			t.Errorf("bad template calls: %#v", tpl.Calls())
+		}
+	})
+
+	t.Run("no-audit", func(t *testing.T) {
+		event, err := NewResolver().Run(newTmpl(false), w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(event.Contents) != "XAB" || event.Calls != nil {
+			t.Errorf("bad event: %#v", event)
+		}
+	})
+}
+
+func TestTemplate_Validate(t *testing.T) {
+	t.Parallel()
+
+	serviceFunc := func(recall Recaller) interface{} {
+		return func(s string) ([]*dep.HealthService, error) {
+			d, err := idep.NewHealthServiceQuery(s)
+			if err != nil {
+				return nil, err
+			}
+			if value, ok := recall(d); ok {
+				return value.([]*dep.HealthService), nil
+			}
+			return nil, nil
+		}
+	}
+	kvFunc := func(newQuery func(string, []string) (*idep.KVGetQuery,
+		error)) interface{} {
+		return func(recall Recaller) interface{} {
+			return func(key string, opts ...string) (interface{}, error) {
+				d, err := newQuery(key, opts)
+				if err != nil {
+					return nil, err
+				}
+				value, _ := recall(d)
+				return value, nil
+			}
+		}
+	}
+	newTemplate := func(contents string) *Template {
+		return NewTemplate(TemplateInput{
+			Contents: contents,
+			FuncMapMerge: template.FuncMap{
+				"service": serviceFunc,
+				"echo":    echoFunc,
+				"key":     kvFunc(idep.NewKVGetQueryV1),
+				"keyMeta": kvFunc(idep.NewKVMetaQueryV1),
+			},
+		})
+	}
+	webID := func() string {
+		d, err := idep.NewHealthServiceQuery("web")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.ID()
+	}()
+
+	cases := []struct {
+		name     string
+		contents string
+		sample   map[string]interface{}
+		err      bool
+	}{
+		{
+			"placeholder",
+			`{{ range service "web" }}{{ .Address }}:{{ .Port }}{{ end }}`,
+			nil,
+			false,
+		},
+		{
+			"bad-field",
+			`{{ range service "web" }}{{ .Adress }}{{ end }}`,
+			nil,
+			true,
+		},
+		{
+			"sample",
+			`{{ range service "web" }}{{ if eq .Name "db" }}{{ .Bad }}{{ end }}{{ end }}`,
+			map[string]interface{}{
+				webID: []*dep.HealthService{{Name: "db"}},
+			},
+			true,
+		},
+		{
+			"no-placeholder",
+			`{{ echo "foo" }}`,
+			nil,
+			false,
+		},
+		{
+			"kv",
+			`{{ key "foo" }}`,
+			nil,
+			false,
+		},
+		{
+			"kv-meta",
+			`{{ with keyMeta "foo" }}{{ .Flags }}{{ .Locked }}{{ end }}`,
+			nil,
+			false,
+		},
+		{
+			"kv-meta-bad-field",
+			`{{ with keyMeta "foo" }}{{ .Flagz }}{{ end }}`,
+			nil,
+			true,
+		},
+		{
+			"kv-diff",
+			`{{ with key "foo" "diff=true" }}{{ if .Changed }}{{ .Old.Value }}{{ .New.Flags }}{{ end }}{{ end }}`,
+			nil,
+			false,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := newTemplate(tc.contents).Validate(tc.sample)
+			if (err != nil) != tc.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	t.Run("no-state-change", func(t *testing.T) {
+		tpl := newTemplate(`{{ range service "web" }}{{ .Address }}{{ end }}`)
+		if err := tpl.Validate(nil); err != nil {
+			t.Fatal(err)
+		}
+		// still dirty, so it is executed
+		a, err := tpl.Execute(func(dep.Dependency) (interface{}, bool) {
+			return nil, false
+		})
+		if err != nil || len(a) != 0 {
+			t.Fatalf("bad execute: %q, %v", a, err)
+		}
+	})
+}
+
+func TestTemplate_ExecuteLimits(t *testing.T) {
+	t.Parallel()
+
+	seq := func(n int) []int { return make([]int, n) }
+	recursive := `{{define "r"}}{{if .}}x{{template "r" (slice . 1)}}{{end}}` +
+		`{{end}}{{template "r" (seq 5)}}`
+
+	cases := []struct {
+		name     string
+		contents string
+		limits   TemplateLimits
+		e        string
+		limit    string
+	}{
+		{
+			"no_limits",
+			`{{range seq 3}}{{range seq 3}}.{{end}}{{end}}`,
+			TemplateLimits{},
+			".........",
+			"",
+		},
+		{
+			"output_size_ok",
+			`{{range seq 5}}.{{end}}`,
+			TemplateLimits{MaxOutputSize: 5},
+			".....",
+			"",
+		},
+		{
+			"output_size_exceeded",
+			`{{range seq 6}}.{{end}}`,
+			TemplateLimits{MaxOutputSize: 5},
+			"",
+			"output size",
+		},
+		{
+			"range_iterations_ok",
+			`{{range seq 2}}{{range seq 2}}.{{end}}{{else}}none{{end}}`,
+			TemplateLimits{MaxRangeIterations: 6},
+			"....",
+			"",
+		},
+		{
+			"range_iterations_exceeded",
+			`{{range seq 3}}{{range seq 2}}.{{end}}{{end}}`,
+			TemplateLimits{MaxRangeIterations: 8},
+			"",
+			"range iterations",
+		},
+		{
+			"range_iterations_in_define",
+			`{{define "d"}}{{range .}}.{{end}}{{end}}{{template "d" (seq 3)}}`,
+			TemplateLimits{MaxRangeIterations: 2},
+			"",
+			"range iterations",
+		},
+		{
+			"template_depth_ok",
+			recursive,
+			TemplateLimits{MaxTemplateDepth: 6},
+			"xxxxx",
+			"",
+		},
+		{
+			"template_depth_exceeded",
+			recursive,
+			TemplateLimits{MaxTemplateDepth: 5},
+			"",
+			"template depth",
+		},
+		{
+			"template_depth_sequential",
+			`{{define "d"}}.{{end}}{{range seq 10}}{{template "d"}}{{end}}`,
+			TemplateLimits{MaxTemplateDepth: 1},
+			"..........",
+			"",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			tpl := NewTemplate(TemplateInput{
+				Contents:     tc.contents,
+				FuncMapMerge: template.FuncMap{"seq": seq},
+				Limits:       tc.limits,
+			})
+			a, err := tpl.Execute(fakeWatcher{}.Recaller(tpl))
+			if tc.limit == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(a) != tc.e {
+					t.Errorf("\nexp: %#v\nact: %#v", tc.e, string(a))
+				}
+				return
+			}
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("expected LimitError, got: %v", err)
+			}
+			if limitErr.Limit != tc.limit {
+				t.Errorf("bad limit, exp: %q, act: %q", tc.limit, limitErr.Limit)
+			}
+		})
+	}
+}
+
+func TestTemplate_ExecuteFuncs(t *testing.T) {
+	t.Parallel()
+
+	funcs := template.FuncMap{
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"env":   func(string) string { return "" },
+	}
+	cases := []struct {
+		name     string
+		contents string
+		allowed  []string
+		denied   []string
+		e        string
+		bad      []string
+	}{
+		{
+			"no_lists",
+			`{{ "a" | upper }}`,
+			nil, nil,
+			"A",
+			nil,
+		},
+		{
+			"allowed",
+			`{{ "a" | upper }}{{ len "ab" }}`,
+			[]string{"upper"}, nil,
+			"A2",
+			nil,
+		},
+		{
+			"not_allowed",
+			`{{ "a" | upper }}{{ env "X" }}{{ if true }}{{ lower "B" }}{{ end }}`,
+			[]string{"upper"}, nil,
+			"",
+			[]string{"env", "lower"},
+		},
+		{
+			"denied",
+			`{{ "a" | upper }}{{ define "t" }}{{ env "X" }}{{ end }}`,
+			nil, []string{"env"},
+			"",
+			[]string{"env"},
+		},
+		{
+			"denied_builtin",
+			`{{ printf "%s" "a" }}`,
+			[]string{"upper"}, []string{"printf"},
+			"",
+			[]string{"printf"},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			tpl := NewTemplate(TemplateInput{
+				Contents:     tc.contents,
+				FuncMapMerge: funcs,
+				AllowedFuncs: tc.allowed,
+				DeniedFuncs:  tc.denied,
+			})
+			a, err := tpl.Execute(fakeWatcher{}.Recaller(tpl))
+			if tc.bad == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(a) != tc.e {
+					t.Errorf("\nexp: %#v\nact: %#v", tc.e, string(a))
+				}
+				return
+			}
+			var funcsErr *FuncsNotAllowedError
+			if !errors.As(err, &funcsErr) {
+				t.Fatalf("expected FuncsNotAllowedError, got: %v", err)
+			}
+			if !reflect.DeepEqual(funcsErr.Funcs, tc.bad) {
+				t.Errorf("bad funcs, exp: %v, act: %v", tc.bad, funcsErr.Funcs)
+			}
+		})
+	}
+}
+
+func TestTemplate_TemplateIDFunc(t *testing.T) {
+	t.Parallel()
+
+	tpl := NewTemplate(TemplateInput{
+		Contents: `{{ templateID }}`,
+		Name:     "foo",
+		FuncMapMerge: template.FuncMap{
+			"templateID": TemplateIDFunc(func(id string) interface{} {
+				return func() string { return id }
+			}),
+		},
+	})
+	a, err := tpl.Execute(fakeWatcher{}.Recaller(tpl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != tpl.ID() {
+		t.Errorf("exp: %q, act: %q", tpl.ID(), a)
+	}
+}
+
+func TestMergeFuncMaps(t *testing.T) {
+	t.Parallel()
+
+	a := template.FuncMap{"a": strings.ToUpper, "b": strings.ToUpper}
+	b := template.FuncMap{"b": strings.ToLower, "c": strings.ToLower}
+	c := template.FuncMap{"b": strings.TrimSpace, "a": strings.TrimSpace}
+	merged, overridden := MergeFuncMaps(a, b, c)
+	if len(merged) != 3 {
+		t.Errorf("bad merged: %v", merged)
+	}
+	if merged["b"].(func(string) string)(" B ") != "B" {
+		t.Error("last map should win")
+	}
+	if exp := []string{"a", "b"}; !reflect.DeepEqual(exp, overridden) {
+		t.Errorf("bad overridden, exp: %v, act: %v", exp, overridden)
+	}
+
+	if _, overridden := MergeFuncMaps(a, b); len(overridden) != 1 {
+		t.Errorf("bad overridden: %v", overridden)
+	}
+}
+
 func TestCachedTemplate(t *testing.T) {
 	d, err := idep.NewKVGetQuery("key")
 	if err != nil {
@@ -225,11 +761,122 @@ func TestCachedTemplate(t *testing.T) {
 	})
 }
 
+func TestTemplate_Sensitive(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newSensitive := func(path string) *Template {
+		return NewTemplate(TemplateInput{
+			Contents:     `{{ echo "secret" }}`,
+			FuncMapMerge: template.FuncMap{"echo": echoFunc},
+			Sensitive:    true,
+			Destination:  &FileRendererInput{Path: path},
+		})
+	}
+	st := NewStore()
+	st.Save((&idep.FakeDep{Name: "secret"}).ID(), "s3cr3t")
+	w := fakeWatcher{st}
+
+	t.Run("not-cached", func(t *testing.T) {
+		tpl := newSensitive("")
+		if _, err := tpl.Execute(w.Recaller(tpl)); err != nil {
+			t.Fatal(err)
+		}
+		content, err := tpl.Execute(w.Recaller(tpl))
+		if err != ErrNoNewValues || content != nil {
+			t.Fatalf("contents kept: %q, %v", content, err)
+		}
+	})
+
+	t.Run("render-for", func(t *testing.T) {
+		path := filepath.Join(dir, "secret")
+		tpl := newSensitive(path)
+		content, err := tpl.Execute(w.Recaller(tpl))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := RenderFor(tpl, content); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(content, make([]byte, len("s3cr3t"))) {
+			t.Errorf("contents not zeroed: %q", content)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil || string(b) != "s3cr3t" {
+			t.Fatalf("bad file: %q, %v", b, err)
+		}
+		if info, _ := os.Stat(path); runtime.GOOS != "windows" &&
+			info.Mode().Perm() != 0600 {
+			t.Errorf("bad permissions: %v", info.Mode())
+		}
+	})
+
+	t.Run("resolver", func(t *testing.T) {
+		rv := NewResolver()
+		sink := NewDryRunSink()
+		rv.SetDryRun(sink)
+		tpl := newSensitive("")
+		event, err := rv.Run(tpl, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !event.Sensitive || string(event.Contents) != "s3cr3t" {
+			t.Errorf("bad event: %#v", event)
+		}
+		if res, _ := sink.Result(tpl.ID()); !res.Sensitive || res.Contents != nil {
+			t.Errorf("dry-run contents not redacted: %#v", res)
+		}
+	})
+}
+
+func TestParseDependencies(t *testing.T) {
+	t.Parallel()
+	keyFunc := func(recall Recaller) interface{} {
+		return func(s string) interface{} {
+			d, err := idep.NewKVGetQuery(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recall(d)
+			return ""
+		}
+	}
+	funcs := template.FuncMap{"echo": echoFunc, "words": wordListFunc}
+	stubs, err := ParseDependencies(
+		`{{echo "foo"}}{{key "bar"}}{{echo "foo"}}`+
+			`{{range words "a" "b"}}{{echo .}}{{end}}`,
+		funcs, template.FuncMap{"key": keyFunc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{"test_dep(foo)", "kv.get(bar)", "test_list_dep(words)"}
+	act := make([]string, 0, len(stubs))
+	for _, s := range stubs {
+		act = append(act, s.ID)
+		if s.Type != "consul" {
+			t.Errorf("bad type for %s: %q", s.ID, s.Type)
+		}
+	}
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("\nexp: %#v\nact: %#v", exp, act)
+	}
+
+	_, err = ParseDependencies(`{{ nope }}`)
+	if err == nil {
+		t.Error("expected parse error")
+	}
+}
+
 type fakeWatcher struct {
 	*Store
 }
 
-func (fakeWatcher) Buffering(string) bool    { return false }
+func (fakeWatcher) Buffering(Notifier) bool  { return false }
 func (f fakeWatcher) Complete(Notifier) bool { return true }
 func (f fakeWatcher) Mark(Notifier)          {}
 func (f fakeWatcher) Sweep(Notifier)         {}
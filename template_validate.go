@@ -0,0 +1,105 @@
+package hcat
+
+import (
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+	"github.com/pkg/errors"
+)
+
+// Validate executes the template against sample data, without fetching
+// anything, to catch errors that only show up when it is executed with data
+// (eg. misspelled field names) before it is deployed.
+//
+// The sample is the data of the template's dependencies keyed by their IDs
+// (see ParseDependencies), in the type the dependency returns. Dependencies
+// without sample data are given placeholder data, a single entry for lists,
+// if they are a Consul catalog, health or KV dependency. Others (eg. Vault
+// secrets, whose data has no fixed shape) are treated as not fetched yet.
+//
+// Validate doesn't change the template's state, it can be called at any time.
+func (t *Template) Validate(sample map[string]interface{}) error {
+	recall := func(d dep.Dependency) (interface{}, bool) {
+		if data, ok := sample[d.ID()]; ok {
+			return data, true
+		}
+		return placeholderData(d)
+	}
+	if _, err := t.execute(recall, nil); err != nil {
+		return errors.Wrap(err, "validate")
+	}
+	return nil
+}
+
+// placeholderData returns placeholder data for the dependency, in the type
+// it returns, and true. Returns false for dependencies without a fixed shape
+// of data.
+func placeholderData(d dep.Dependency) (interface{}, bool) {
+	node := &dep.Node{
+		ID:         "00000000-0000-0000-0000-000000000000",
+		Node:       "node",
+		Address:    "127.0.0.1",
+		Datacenter: "dc1",
+	}
+
+	pair := &dep.KeyPair{
+		Path:   "key",
+		Key:    "key",
+		Value:  "value",
+		Exists: true,
+	}
+
+	switch d := d.(type) {
+	case *idep.HealthServiceQuery:
+		return []*dep.HealthService{{
+			Node:           node.Node,
+			NodeID:         node.ID,
+			NodeAddress:    node.Address,
+			NodeDatacenter: node.Datacenter,
+			Address:        node.Address,
+			ID:             "service",
+			Name:           "service",
+			Tags:           dep.ServiceTags{"tag"},
+			Status:         api.HealthPassing,
+			Port:           8080,
+			Weights:        api.AgentWeights{Passing: 1, Warning: 1},
+		}}, true
+	case *idep.CatalogServicesQuery:
+		return []*dep.CatalogSnippet{{
+			Name: "service",
+			Tags: dep.ServiceTags{"tag"},
+		}}, true
+	case *idep.CatalogNodesQuery:
+		return []*dep.Node{node}, true
+	case *idep.CatalogNodeQuery:
+		return &dep.CatalogNode{
+			Node: node,
+			Services: []*dep.CatalogNodeService{{
+				ID:      "service",
+				Service: "service",
+				Tags:    dep.ServiceTags{"tag"},
+				Port:    8080,
+				Address: node.Address,
+			}},
+		}, true
+	case *idep.CatalogDatacentersQuery:
+		return []string{node.Datacenter}, true
+	case *idep.KVGetQuery:
+		switch {
+		case d.Meta():
+			return pair, true
+		case d.Diff():
+			old := *pair
+			old.Value = "old"
+			return dep.KvChange{Old: &old, New: pair}, true
+		}
+		return dep.KvValue(pair.Value), true
+	case *idep.KVExistsQuery:
+		return dep.KVExists(true), true
+	case *idep.KVListQuery:
+		return []*dep.KeyPair{pair}, true
+	case *idep.KVKeysQuery:
+		return []string{"key"}, true
+	}
+	return nil, false
+}
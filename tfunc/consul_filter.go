@@ -3,10 +3,12 @@ package tfunc
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
 	"github.com/pkg/errors"
 )
 
@@ -121,3 +123,100 @@ func byTag(in interface{}) (map[string][]interface{}, error) {
 
 	return m, nil
 }
+
+// byWeight returns a copy of the services sorted by their effective weight
+// (see HealthService.EffectiveWeight), heaviest first. Services with the same
+// weight are sorted by node and then ID.
+func byWeight(services []*dep.HealthService) []*dep.HealthService {
+	sorted := make([]*dep.HealthService, len(services))
+	copy(sorted, services)
+	sort.Stable(idep.ByWeight(sorted))
+	return sorted
+}
+
+// byLocality returns a copy of the services sorted by how local they are to
+// the region and zone (see HealthService.LocalTo), those in the zone first,
+// then those in the region and then the others, each in their original
+// order. Eg. `{{ range byLocality "us-east-1" "us-east-1a" (service "web") }}`.
+func byLocality(region, zone string, services []*dep.HealthService) []*dep.HealthService {
+	sorted := make([]*dep.HealthService, len(services))
+	copy(sorted, services)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].LocalTo(region, zone) > sorted[j].LocalTo(region, zone)
+	})
+	return sorted
+}
+
+// nonZeroWeight returns the services that have an effective weight greater
+// than 0, dropping those that shouldn't receive any traffic.
+func nonZeroWeight(services []*dep.HealthService) []*dep.HealthService {
+	result := make([]*dep.HealthService, 0, len(services))
+	for _, s := range services {
+		if s.EffectiveWeight() > 0 {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// maxSRVWeight is the maximum weight of a SRV record
+const maxSRVWeight = 65535
+
+// srvRecords returns the services as SRV records, sorted by weight (see
+// byWeight). The weight is the services' effective weight and those with 0
+// weight are dropped as they shouldn't get traffic. All have priority 1, as in
+// Consul's DNS interface.
+func srvRecords(services []*dep.HealthService) []dep.SRVRecord {
+	records := make([]dep.SRVRecord, 0, len(services))
+	for _, s := range byWeight(nonZeroWeight(services)) {
+		weight := s.EffectiveWeight()
+		if weight > maxSRVWeight {
+			weight = maxSRVWeight
+		}
+		records = append(records, dep.SRVRecord{
+			Priority: 1,
+			Weight:   weight,
+			Port:     s.Port,
+			Target:   s.Node,
+			Address:  s.Address,
+		})
+	}
+	return records
+}
+
+// byDrain splits the services into those that are active and those that are
+// draining, either in maintenance mode or tagged with the tag (if not empty).
+// The services need to include those in maintenance, which are filtered out
+// by default, for them to be found.
+//
+// Template: {{ with service "web|passing,maintenance" | byDrain "draining" }}
+// {{ range .Draining }}server {{ .Address }} backup;{{ end }}{{ end }}
+func byDrain(tag string, services []*dep.HealthService) dep.DrainBuckets {
+	var buckets dep.DrainBuckets
+	for _, s := range services {
+		if s.InMaintenance() || (tag != "" && s.Tags.Contains(tag)) {
+			buckets.Draining = append(buckets.Draining, s)
+			continue
+		}
+		buckets.Active = append(buckets.Active, s)
+	}
+	return buckets
+}
+
+// addressFor returns the service's address for the named tagged address (eg.
+// "wan"), falling back to its default address. See HealthService.AddressFor.
+func addressFor(service *dep.HealthService, name string) string {
+	if service == nil {
+		return ""
+	}
+	return service.AddressFor(name)
+}
+
+// portFor returns the service's port for the named tagged address, falling
+// back to its default port. See HealthService.PortFor.
+func portFor(service *dep.HealthService, name string) int {
+	if service == nil {
+		return 0
+	}
+	return service.PortFor(name)
+}
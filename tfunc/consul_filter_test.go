@@ -6,8 +6,10 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/hcat"
 	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
 )
 
 func Test_byMeta(t *testing.T) {
@@ -194,6 +196,197 @@ func TestConsulFilterExecute(t *testing.T) {
 			"prod:1.2.3.4staging:1.2.3.45.6.7.8",
 			false,
 		},
+		{
+			"helper_by_weight",
+			hcat.TemplateInput{
+				Contents: `{{ range service "webapp" | nonZeroWeight | byWeight }}{{ .Address }}:{{ .EffectiveWeight }} {{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				id := testHealthServiceQueryID("webapp")
+				st.Save(id, []*dep.HealthService{
+					{
+						Node:    "a",
+						Address: "1.1.1.1",
+						Status:  "passing",
+						Weights: api.AgentWeights{Passing: 1, Warning: 1},
+					},
+					{
+						Node:    "b",
+						Address: "2.2.2.2",
+						Status:  "warning",
+						Weights: api.AgentWeights{Passing: 10, Warning: 0},
+					},
+					{
+						Node:    "c",
+						Address: "3.3.3.3",
+						Status:  "passing",
+						Weights: api.AgentWeights{Passing: 5, Warning: 1},
+					},
+					{
+						Node:    "d",
+						Address: "4.4.4.4",
+						Status:  "passing",
+						Weights: api.AgentWeights{Passing: 5, Warning: 1},
+					},
+				})
+				return fakeWatcher{st}
+			}(),
+			"3.3.3.3:5 4.4.4.4:5 1.1.1.1:1 ",
+			false,
+		},
+		{
+			"helper_srv_records",
+			hcat.TemplateInput{
+				Contents: `{{ range service "webapp" | srvRecords }}` +
+					`SRV {{ .Priority }} {{ .Weight }} {{ .Port }} {{ .Target }} ` +
+					`A {{ .Address }}|{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				id := testHealthServiceQueryID("webapp")
+				st.Save(id, []*dep.HealthService{
+					{
+						Node:    "a",
+						Address: "1.1.1.1",
+						Port:    80,
+						Status:  "passing",
+						Weights: api.AgentWeights{Passing: 1, Warning: 1},
+					},
+					{
+						Node:    "b",
+						Address: "2.2.2.2",
+						Port:    80,
+						Status:  "critical",
+						Weights: api.AgentWeights{Passing: 10, Warning: 1},
+					},
+					{
+						Node:    "c",
+						Address: "3.3.3.3",
+						Port:    8080,
+						Status:  "passing",
+						Weights: api.AgentWeights{Passing: 100000, Warning: 1},
+					},
+				})
+				return fakeWatcher{st}
+			}(),
+			"SRV 1 65535 8080 c A 3.3.3.3|SRV 1 1 80 a A 1.1.1.1|",
+			false,
+		},
+		{
+			"helper_by_drain",
+			hcat.TemplateInput{
+				Contents: `{{ with service "webapp|passing,maintenance" | byDrain "draining" }}` +
+					`{{ range .Active }}server {{ .Address }};{{ end }}` +
+					`{{ range .Draining }}server {{ .Address }} backup;{{ end }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewHealthServiceQuery("webapp|passing,maintenance")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), []*dep.HealthService{
+					{
+						Address: "1.1.1.1",
+						Status:  "passing",
+					},
+					{
+						Address: "2.2.2.2",
+						Status:  "maintenance",
+					},
+					{
+						Address: "3.3.3.3",
+						Status:  "passing",
+						Tags:    dep.ServiceTags{"v1", "draining"},
+					},
+				})
+				return fakeWatcher{st}
+			}(),
+			"server 1.1.1.1;server 2.2.2.2 backup;server 3.3.3.3 backup;",
+			false,
+		},
+		{
+			"helper_by_drain_maintenance_only",
+			hcat.TemplateInput{
+				Contents: `{{ with service "webapp|passing,maintenance" | byDrain "" }}` +
+					`{{ len .Active }}/{{ len .Draining }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewHealthServiceQuery("webapp|passing,maintenance")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), []*dep.HealthService{
+					{Status: "maintenance"},
+					{Status: "passing", Tags: dep.ServiceTags{"draining"}},
+				})
+				return fakeWatcher{st}
+			}(),
+			"1/1",
+			false,
+		},
+		{
+			"helper_address_for",
+			hcat.TemplateInput{
+				Contents: `{{ range service "webapp" }}` +
+					`{{ addressFor . "wan" }}:{{ portFor . "wan" }} ` +
+					`{{ addressFor . "virtual" }}:{{ portFor . "virtual" }}|{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				id := testHealthServiceQueryID("webapp")
+				st.Save(id, []*dep.HealthService{
+					{
+						Address: "10.0.0.1",
+						Port:    8080,
+						NodeTaggedAddresses: map[string]string{
+							"wan": "1.1.1.1",
+						},
+						ServiceTaggedAddresses: map[string]api.ServiceAddress{
+							"virtual": {Address: "240.0.0.1", Port: 80},
+						},
+					},
+					{
+						Address: "10.0.0.2",
+						Port:    8080,
+						NodeTaggedAddresses: map[string]string{
+							"wan": "2.2.2.2",
+						},
+						ServiceTaggedAddresses: map[string]api.ServiceAddress{
+							"wan": {Address: "3.3.3.3", Port: 9090},
+						},
+					},
+				})
+				return fakeWatcher{st}
+			}(),
+			"1.1.1.1:8080 240.0.0.1:80|3.3.3.3:9090 10.0.0.2:8080|",
+			false,
+		},
+		{
+			"helper_by_locality",
+			hcat.TemplateInput{
+				Contents: `{{ range byLocality "east" "east-b" (service "webapp") }}` +
+					`{{ .Address }} {{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				id := testHealthServiceQueryID("webapp")
+				st.Save(id, []*dep.HealthService{
+					{Address: "1.1.1.1"},
+					{Address: "2.2.2.2",
+						Locality: &dep.Locality{Region: "west", Zone: "west-a"}},
+					{Address: "3.3.3.3",
+						Locality: &dep.Locality{Region: "east", Zone: "east-a"}},
+					{Address: "4.4.4.4",
+						Locality: &dep.Locality{Region: "east", Zone: "east-b"}},
+				})
+				return fakeWatcher{st}
+			}(),
+			"4.4.4.4 3.3.3.3 1.1.1.1 2.2.2.2 ",
+			false,
+		},
 	}
 
 	for i, tc := range cases {
@@ -3,6 +3,7 @@ package tfunc
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/hcat"
@@ -66,6 +67,61 @@ func keyFunc(recall hcat.Recaller) interface{} {
 	}
 }
 
+// mustKeyFunc is keyFunc that fails the render if the key doesn't exist or is
+// empty. Before the key has been fetched it returns an empty string.
+func mustKeyFunc(recall hcat.Recaller) interface{} {
+	return func(s string) (string, error) {
+		if len(s) == 0 {
+			return "", fmt.Errorf("mustKey: missing key")
+		}
+
+		d, err := idep.NewKVGetQuery(s)
+		if err != nil {
+			return "", err
+		}
+
+		value, ok := recall(d)
+		if !ok {
+			return "", nil
+		}
+		var result string
+		switch v := value.(type) {
+		case string:
+			result = v
+		case dep.KvValue:
+			result = string(v)
+		}
+		if result == "" {
+			return "", fmt.Errorf("mustKey: key %q is missing or empty", s)
+		}
+		return result, nil
+	}
+}
+
+// keyChunkedFunc returns or accumulates a chunked key dependency, the value
+// of a key too large for a single key stored in chunks under it
+// (`key/part-0000`, `key/part-0001`, ...) joined in order.
+func keyChunkedFunc(recall hcat.Recaller) interface{} {
+	return func(s string) (string, error) {
+		if len(s) == 0 {
+			return "", nil
+		}
+
+		d, err := idep.NewKVChunkedQuery(s)
+		if err != nil {
+			return "", err
+		}
+
+		if value, ok := recall(d); ok {
+			if v, ok := value.(string); ok {
+				return v, nil
+			}
+		}
+
+		return "", nil
+	}
+}
+
 // keyExistsFunc returns true if a key exists, false otherwise.
 func keyExistsFunc(recall hcat.Recaller) interface{} {
 	return func(s string) (bool, error) {
@@ -118,17 +174,21 @@ func safeLsFunc(recall hcat.Recaller) interface{} {
 	return lsFunc(false)(recall)
 }
 
-// lsFunc returns list of top level key-pairs at a given path.
+// lsFunc returns list of top level key-pairs at a given path. Optional
+// "key=value" filters ("glob", "regex" and "limit") drop the keys that don't
+// match right after fetching, so changes to them don't re-render the template.
+//
+// Template: {{ range ls "service/web" "glob=*.conf" }}{{ .Key }}{{ end }}
 func lsFunc(emptyIsSafe bool) func(hcat.Recaller) interface{} {
 	return func(recall hcat.Recaller) interface{} {
-		return func(s string) ([]*dep.KeyPair, error) {
+		return func(s string, filters ...string) ([]*dep.KeyPair, error) {
 			result := []*dep.KeyPair{}
 
 			if len(s) == 0 {
 				return result, nil
 			}
 
-			d, err := idep.NewKVListQuery(s)
+			d, err := idep.NewKVListQueryFiltered(s, filters)
 			if err != nil {
 				return result, err
 			}
@@ -181,12 +241,23 @@ func nodeFunc(recall hcat.Recaller) interface{} {
 	}
 }
 
-// nodesFunc returns or accumulates catalog node dependencies.
+// nodesFunc returns or accumulates catalog node dependencies. Besides the
+// "@dc~near" form it takes "key=value" options, "dc", "near", "partition" and
+// "limit" (the maximum number of nodes returned).
+//
+// Endpoint: /v1/catalog/nodes
+// Template: {{ range nodes "partition=web" "limit=100" }}{{ .Node }}{{ end }}
 func nodesFunc(recall hcat.Recaller) interface{} {
 	return func(s ...string) ([]*dep.Node, error) {
 		result := []*dep.Node{}
 
-		d, err := idep.NewCatalogNodesQuery(strings.Join(s, ""))
+		var d *idep.CatalogNodesQuery
+		var err error
+		if len(s) > 0 && strings.Contains(strings.Join(s, ""), "=") {
+			d, err = idep.NewCatalogNodesQueryV1(s)
+		} else {
+			d, err = idep.NewCatalogNodesQuery(strings.Join(s, ""))
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -199,6 +270,198 @@ func nodesFunc(recall hcat.Recaller) interface{} {
 	}
 }
 
+// coordinatesFunc returns or accumulates node coordinate dependencies.
+func coordinatesFunc(recall hcat.Recaller) interface{} {
+	return func(s ...string) ([]*dep.NodeCoordinate, error) {
+		result := []*dep.NodeCoordinate{}
+
+		d, err := idep.NewCoordinateNodesQuery(strings.Join(s, ""))
+		if err != nil {
+			return nil, err
+		}
+
+		if value, ok := recall(d); ok {
+			return value.([]*dep.NodeCoordinate), nil
+		}
+
+		return result, nil
+	}
+}
+
+// rttFunc returns the estimated round trip time between the two nodes based
+// on their network coordinates. An optional datacenter ("@dc") can be given.
+// It uses the nodes' coordinates in the same network segment.
+func rttFunc(recall hcat.Recaller) interface{} {
+	return func(node1, node2 string, s ...string) (time.Duration, error) {
+		if node1 == "" || node2 == "" {
+			return 0, nil
+		}
+
+		d, err := idep.NewCoordinateNodesQuery(strings.Join(s, ""))
+		if err != nil {
+			return 0, err
+		}
+
+		value, ok := recall(d)
+		if !ok {
+			return 0, nil
+		}
+
+		var coords1, coords2 []*dep.NodeCoordinate
+		for _, c := range value.([]*dep.NodeCoordinate) {
+			switch c.Node {
+			case node1:
+				coords1 = append(coords1, c)
+			case node2:
+				coords2 = append(coords2, c)
+			}
+		}
+		if node1 == node2 {
+			coords2 = coords1
+		}
+
+		switch {
+		case len(coords1) == 0:
+			return 0, fmt.Errorf("rtt: no coordinates for node %q", node1)
+		case len(coords2) == 0:
+			return 0, fmt.Errorf("rtt: no coordinates for node %q", node2)
+		}
+
+		for _, c1 := range coords1 {
+			for _, c2 := range coords2 {
+				if c1.Segment != c2.Segment {
+					continue
+				}
+				rtt, err := c1.Coord.DistanceTo(c2.Coord)
+				if err != nil {
+					return 0, fmt.Errorf("rtt: %s", err)
+				}
+				return rtt, nil
+			}
+		}
+
+		return 0, fmt.Errorf("rtt: nodes %q and %q are not in the same "+
+			"network segment", node1, node2)
+	}
+}
+
+// autopilotHealthFunc returns the health of the Consul servers as reported
+// by autopilot. An optional datacenter ("@dc") can be given.
+func autopilotHealthFunc(recall hcat.Recaller) interface{} {
+	return func(s ...string) (*api.OperatorHealthReply, error) {
+		d, err := idep.NewOperatorAutopilotHealthQuery(strings.Join(s, ""))
+		if err != nil {
+			return nil, err
+		}
+
+		if value, ok := recall(d); ok {
+			return value.(*api.OperatorHealthReply), nil
+		}
+
+		return nil, nil
+	}
+}
+
+// licenseFunc returns the license status of a Consul Enterprise cluster. An
+// optional datacenter ("@dc") can be given.
+func licenseFunc(recall hcat.Recaller) interface{} {
+	return func(s ...string) (*api.LicenseReply, error) {
+		d, err := idep.NewOperatorLicenseQuery(strings.Join(s, ""))
+		if err != nil {
+			return nil, err
+		}
+
+		if value, ok := recall(d); ok {
+			return value.(*api.LicenseReply), nil
+		}
+
+		return nil, nil
+	}
+}
+
+// exportedServicesFunc returns or accumulates the exported-services config
+// entry of the partition, the services exported to other partitions and
+// cluster peers. It is nil if there is no entry. An optional datacenter
+// ("@dc") can be given.
+func exportedServicesFunc(recall hcat.Recaller) interface{} {
+	return func(s ...string) (*dep.ExportedServices, error) {
+		d, err := idep.NewExportedServicesQuery(strings.Join(s, ""))
+		if err != nil {
+			return nil, err
+		}
+
+		if value, ok := recall(d); ok {
+			if value == nil {
+				return nil, nil
+			}
+			return value.(*dep.ExportedServices), nil
+		}
+
+		return nil, nil
+	}
+}
+
+// consulRawFunc returns or accumulates a dependency on a Consul HTTP API
+// endpoint, eg. `consulRaw "GET" "/v1/operator/raft/configuration"`, for
+// endpoints without a dedicated function. Returns the decoded JSON response,
+// nil until it has been fetched.
+func consulRawFunc(recall hcat.Recaller) interface{} {
+	return func(method, path string) (interface{}, error) {
+		d, err := idep.NewConsulRawQuery(method, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if value, ok := recall(d); ok {
+			return value, nil
+		}
+
+		return nil, nil
+	}
+}
+
+// namespacesFunc returns or accumulates the namespaces of a Consul Enterprise
+// cluster. An optional datacenter ("@dc") can be given.
+func namespacesFunc(recall hcat.Recaller) interface{} {
+	return func(s ...string) ([]*dep.Namespace, error) {
+		result := []*dep.Namespace{}
+
+		d, err := idep.NewNamespaceListQuery(strings.Join(s, ""))
+		if err != nil {
+			return nil, err
+		}
+
+		if value, ok := recall(d); ok {
+			return value.([]*dep.Namespace), nil
+		}
+
+		return result, nil
+	}
+}
+
+// partitionsFunc returns or accumulates the admin partitions of a Consul
+// Enterprise cluster. An optional datacenter ("@dc") can be given. With the
+// partition options of services and nodes it templates each partition.
+//
+// Endpoint: /v1/partitions
+// Template: {{ range partitions }}{{ range services (print "partition=" .Name) }}...
+func partitionsFunc(recall hcat.Recaller) interface{} {
+	return func(s ...string) ([]*dep.Partition, error) {
+		result := []*dep.Partition{}
+
+		d, err := idep.NewPartitionListQuery(strings.Join(s, ""))
+		if err != nil {
+			return nil, err
+		}
+
+		if value, ok := recall(d); ok {
+			return value.([]*dep.Partition), nil
+		}
+
+		return result, nil
+	}
+}
+
 // serviceFunc returns or accumulates health service dependencies.
 func serviceFunc(recall hcat.Recaller) interface{} {
 	return func(s ...string) ([]*dep.HealthService, error) {
@@ -221,12 +484,136 @@ func serviceFunc(recall hcat.Recaller) interface{} {
 	}
 }
 
-// servicesFunc returns or accumulates catalog services dependencies.
+// mustServiceFunc is serviceFunc that fails the render if the service has no
+// instances. Before the service has been fetched it returns an empty list.
+func mustServiceFunc(recall hcat.Recaller) interface{} {
+	return func(s ...string) ([]*dep.HealthService, error) {
+		result := []*dep.HealthService{}
+
+		if len(s) == 0 || s[0] == "" {
+			return nil, fmt.Errorf("mustService: missing service")
+		}
+
+		d, err := idep.NewHealthServiceQuery(strings.Join(s, "|"))
+		if err != nil {
+			return nil, err
+		}
+
+		value, ok := recall(d)
+		if !ok {
+			return result, nil
+		}
+		if result = value.([]*dep.HealthService); len(result) == 0 {
+			return nil, fmt.Errorf("mustService: no instances of %q",
+				strings.Join(s, "|"))
+		}
+		return result, nil
+	}
+}
+
+// serviceWithFallbackFunc returns the instances of the first of the given
+// service queries (eg. "web@dc1" "web@dc2") that has passing instances. All
+// the queries are tracked so the result fails over, and back, as the
+// instances' health changes. Returns an empty list if none have passing
+// instances.
+func serviceWithFallbackFunc(recall hcat.Recaller) interface{} {
+	return func(s ...string) ([]*dep.HealthService, error) {
+		result := []*dep.HealthService{}
+
+		queries := make([]*idep.HealthServiceQuery, 0, len(s))
+		for _, q := range s {
+			if q == "" {
+				continue
+			}
+			d, err := idep.NewHealthServiceQuery(q)
+			if err != nil {
+				return nil, err
+			}
+			queries = append(queries, d)
+		}
+
+		// recall all of them, not just up to the active one, so they are
+		// all tracked
+		var active []*dep.HealthService
+		for _, d := range queries {
+			value, ok := recall(d)
+			if !ok || active != nil {
+				continue
+			}
+			services := value.([]*dep.HealthService)
+			for _, svc := range services {
+				if svc.Status == api.HealthPassing {
+					active = services
+					break
+				}
+			}
+		}
+
+		if active != nil {
+			return active, nil
+		}
+		return result, nil
+	}
+}
+
+// servicesFunc returns or accumulates catalog services dependencies. Besides
+// the "@dc" form it takes "key=value" options, "dc", "ns" and "partition".
+//
+// Endpoint: /v1/catalog/services
+// Template: {{ range services "partition=web" }}{{ .Name }}{{ end }}
 func servicesFunc(recall hcat.Recaller) interface{} {
 	return func(s ...string) ([]*dep.CatalogSnippet, error) {
 		result := []*dep.CatalogSnippet{}
 
-		d, err := idep.NewCatalogServicesQuery(strings.Join(s, ""))
+		var d *idep.CatalogServicesQuery
+		var err error
+		if len(s) > 0 && strings.Contains(strings.Join(s, ""), "=") {
+			d, err = idep.NewCatalogServicesQueryV1(s)
+		} else {
+			d, err = idep.NewCatalogServicesQuery(strings.Join(s, ""))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if value, ok := recall(d); ok {
+			return value.([]*dep.CatalogSnippet), nil
+		}
+
+		return result, nil
+	}
+}
+
+// servicesByNodeMetaFunc returns or accumulates the catalog services present
+// on the nodes matching all of the "key=value" node meta filters. An "@dc"
+// argument queries that datacenter.
+//
+// Endpoint: /v1/catalog/services?node-meta=key:value
+// Template: {{ range servicesByNodeMeta "rack=1a" }}{{ .Name }}{{ end }}
+func servicesByNodeMetaFunc(recall hcat.Recaller) interface{} {
+	return func(s ...string) ([]*dep.CatalogSnippet, error) {
+		result := []*dep.CatalogSnippet{}
+
+		var opts []string
+		var filtered bool
+		for _, arg := range s {
+			if strings.HasPrefix(arg, "@") {
+				opts = append(opts, "dc="+strings.TrimPrefix(arg, "@"))
+				continue
+			}
+			kv := strings.SplitN(arg, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return nil, fmt.Errorf(
+					"servicesByNodeMeta: invalid node meta filter: %q", arg)
+			}
+			opts = append(opts, "node-meta="+kv[0]+":"+kv[1])
+			filtered = true
+		}
+		if !filtered {
+			return nil, fmt.Errorf("servicesByNodeMeta: missing node meta filter")
+		}
+
+		d, err := idep.NewCatalogServicesQueryV1(opts)
 		if err != nil {
 			return nil, err
 		}
@@ -297,16 +684,18 @@ func safeTreeFunc(recall hcat.Recaller) interface{} {
 }
 
 // treeFunc returns *all* kv pairs at the given key path and all nested paths.
+// It takes the same filters as lsFunc, matched against the nested paths, eg.
+// "regex=^[^/]+/config$".
 func treeFunc(emptyIsSafe bool) func(hcat.Recaller) interface{} {
 	return func(recall hcat.Recaller) interface{} {
-		return func(s string) ([]*dep.KeyPair, error) {
+		return func(s string, filters ...string) ([]*dep.KeyPair, error) {
 			result := []*dep.KeyPair{}
 
 			if len(s) == 0 {
 				return result, nil
 			}
 
-			d, err := idep.NewKVListQuery(s)
+			d, err := idep.NewKVListQueryFiltered(s, filters)
 			if err != nil {
 				return result, err
 			}
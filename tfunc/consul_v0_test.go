@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/hcat"
@@ -103,6 +104,83 @@ func TestConsulV0Execute(t *testing.T) {
 			"5",
 			false,
 		},
+		{
+			"func_mustKey",
+			hcat.TemplateInput{
+				Contents: `{{ mustKey "key" }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewKVGetQuery("key")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), dep.KvValue("5"))
+				return fakeWatcher{st}
+			}(),
+			"5",
+			false,
+		},
+		{
+			"func_mustKey_missing",
+			hcat.TemplateInput{
+				Contents: `{{ mustKey "key" }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewKVGetQuery("key")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), nil)
+				return fakeWatcher{st}
+			}(),
+			"",
+			true,
+		},
+		{
+			"func_mustKey_not_fetched",
+			hcat.TemplateInput{
+				Contents: `{{ mustKey "key" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			false,
+		},
+		{
+			"func_keyChunked",
+			hcat.TemplateInput{
+				Contents: `{{ keyChunked "blob" }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewKVChunkedQuery("blob")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), "hello world")
+				return fakeWatcher{st}
+			}(),
+			"hello world",
+			false,
+		},
+		{
+			"func_keyChunked_missing",
+			hcat.TemplateInput{
+				Contents: `{{ keyChunked "blob" }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewKVChunkedQuery("blob")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), nil)
+				return fakeWatcher{st}
+			}(),
+			"",
+			false,
+		},
 		{
 			"func_keyExists",
 			hcat.TemplateInput{
@@ -158,6 +236,35 @@ func TestConsulV0Execute(t *testing.T) {
 			"foo=bar",
 			false,
 		},
+		{
+			"func_ls_filtered",
+			hcat.TemplateInput{
+				Contents: `{{ range ls "list" "glob=*.conf" }}{{ .Key }} {{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewKVListQueryFiltered("list",
+					[]string{"glob=*.conf"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), []*dep.KeyPair{
+					{Key: "a.conf", Value: "a"},
+				})
+				return fakeWatcher{st}
+			}(),
+			"a.conf ",
+			false,
+		},
+		{
+			"func_ls_bad_filter",
+			hcat.TemplateInput{
+				Contents: `{{ ls "list" "limit=none" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
 		{
 			"func_node",
 			hcat.TemplateInput{
@@ -213,6 +320,428 @@ func TestConsulV0Execute(t *testing.T) {
 			"node1node2",
 			false,
 		},
+		{
+			"func_nodes_partition",
+			hcat.TemplateInput{
+				Contents: `{{ range nodes "partition=part1" "limit=1" }}{{ .Node }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewCatalogNodesQueryV1(
+					[]string{"partition=part1", "limit=1"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), []*dep.Node{
+					{Node: "node1"},
+				})
+				return fakeWatcher{st}
+			}(),
+			"node1",
+			false,
+		},
+		{
+			"func_coordinates",
+			hcat.TemplateInput{
+				Contents: `{{ range coordinates "@dc1" }}{{ .Node }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewCoordinateNodesQuery("@dc1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), []*dep.NodeCoordinate{
+					{Node: "node1", Coord: &dep.Coordinate{}},
+					{Node: "node2", Coord: &dep.Coordinate{}},
+				})
+				return fakeWatcher{st}
+			}(),
+			"node1node2",
+			false,
+		},
+		{
+			"func_rtt",
+			hcat.TemplateInput{
+				Contents: `{{ rtt "node1" "node2" }} {{ rtt "node1" "node1" }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewCoordinateNodesQuery("")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), []*dep.NodeCoordinate{
+					{Node: "node1", Coord: &dep.Coordinate{
+						Vec: []float64{0.001, 0}, Height: 0.0005}},
+					{Node: "node2", Coord: &dep.Coordinate{
+						Vec: []float64{0.004, 0.004}, Height: 0.0005}},
+				})
+				return fakeWatcher{st}
+			}(),
+			"6ms 1ms",
+			false,
+		},
+		{
+			"func_rtt_no_data",
+			hcat.TemplateInput{
+				Contents: `{{ rtt "node1" "node2" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"0s",
+			false,
+		},
+		{
+			"func_rtt_missing_node",
+			hcat.TemplateInput{
+				Contents: `{{ rtt "node1" "node3" }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewCoordinateNodesQuery("")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), []*dep.NodeCoordinate{
+					{Node: "node1", Coord: &dep.Coordinate{}},
+				})
+				return fakeWatcher{st}
+			}(),
+			"",
+			true,
+		},
+		{
+			"func_rtt_segments",
+			hcat.TemplateInput{
+				Contents: `{{ rtt "node1" "node2" }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewCoordinateNodesQuery("")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), []*dep.NodeCoordinate{
+					{Node: "node1", Segment: "alpha", Coord: &dep.Coordinate{}},
+					{Node: "node2", Segment: "beta", Coord: &dep.Coordinate{}},
+				})
+				return fakeWatcher{st}
+			}(),
+			"",
+			true,
+		},
+		{
+			"func_autopilot",
+			hcat.TemplateInput{
+				Contents: `{{ with autopilot }}{{ .Healthy }} {{ .FailureTolerance }}` +
+					`{{ range .Servers }} {{ .Name }}={{ .Healthy }}{{ end }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewOperatorAutopilotHealthQuery("")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &api.OperatorHealthReply{
+					Healthy:          false,
+					FailureTolerance: 0,
+					Servers: []api.ServerHealth{
+						{Name: "server1", Healthy: true},
+						{Name: "server2", Healthy: false},
+					},
+				})
+				return fakeWatcher{st}
+			}(),
+			"false 0 server1=true server2=false",
+			false,
+		},
+		{
+			"func_license",
+			hcat.TemplateInput{
+				Contents: `{{ with license "@dc2" }}{{ .Valid }} ` +
+					`{{ .License.ExpirationTime.Format "2006-01-02" }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewOperatorLicenseQuery("@dc2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &api.LicenseReply{
+					Valid: true,
+					License: &api.License{
+						ExpirationTime: time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+					},
+				})
+				return fakeWatcher{st}
+			}(),
+			"true 2030-01-02",
+			false,
+		},
+		{
+			"func_license_no_data",
+			hcat.TemplateInput{
+				Contents: `{{ with license }}{{ .Valid }}{{ end }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			false,
+		},
+		{
+			"func_namespaces",
+			hcat.TemplateInput{
+				Contents: `{{ range namespaces "@dc2" }}{{ .Name }}:` +
+					`{{ .Meta.team }} {{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewNamespaceListQuery("@dc2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), []*dep.Namespace{
+					{Name: "default"},
+					{Name: "tenant-a", Meta: map[string]string{"team": "a"}},
+				})
+				return fakeWatcher{st}
+			}(),
+			"default: tenant-a:a ",
+			false,
+		},
+		{
+			"func_namespaces_no_data",
+			hcat.TemplateInput{
+				Contents: `{{ range namespaces }}{{ .Name }}{{ end }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			false,
+		},
+		{
+			"func_namespaces_bad_format",
+			hcat.TemplateInput{
+				Contents: `{{ namespaces "foo" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+		{
+			"func_partitions",
+			hcat.TemplateInput{
+				Contents: `{{ range partitions }}{{ .Name }}:` +
+					`{{ range services (print "partition=" .Name) }}` +
+					`{{ .Name }} {{ end }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewPartitionListQuery("")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), []*dep.Partition{
+					{Name: "default"}, {Name: "web"},
+				})
+				for _, p := range []string{"default", "web"} {
+					d, err := idep.NewCatalogServicesQueryV1(
+						[]string{"partition=" + p})
+					if err != nil {
+						t.Fatal(err)
+					}
+					st.Save(d.ID(), []*dep.CatalogSnippet{{Name: p + "-svc"}})
+				}
+				return fakeWatcher{st}
+			}(),
+			"default:default-svc web:web-svc ",
+			false,
+		},
+		{
+			"func_partitions_bad_format",
+			hcat.TemplateInput{
+				Contents: `{{ partitions "foo" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+		{
+			"func_consulRaw",
+			hcat.TemplateInput{
+				Contents: `{{ with consulRaw "GET" "/v1/operator/raft/configuration" }}` +
+					`{{ range .Servers }}{{ .Node }} {{ end }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewConsulRawQuery("GET",
+					"/v1/operator/raft/configuration")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), map[string]interface{}{
+					"Servers": []interface{}{
+						map[string]interface{}{"Node": "a"},
+						map[string]interface{}{"Node": "b"},
+					},
+				})
+				return fakeWatcher{st}
+			}(),
+			"a b ",
+			false,
+		},
+		{
+			"func_consulRaw_no_data",
+			hcat.TemplateInput{
+				Contents: `{{ with consulRaw "GET" "/v1/agent/self" }}x{{ end }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			false,
+		},
+		{
+			"func_consulRaw_bad_method",
+			hcat.TemplateInput{
+				Contents: `{{ consulRaw "DELETE" "/v1/kv/foo" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+		{
+			"func_exported_services",
+			hcat.TemplateInput{
+				Contents: `{{ with exportedServices }}{{ range .Services }}` +
+					`{{ .Name }}:{{ range .Consumers }}{{ .Peer }}{{ .Partition }} ` +
+					`{{ end }}{{ end }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewExportedServicesQuery("")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &dep.ExportedServices{
+					Name: "default",
+					Services: []dep.ExportedService{{
+						Name:      "web",
+						Namespace: "default",
+						Consumers: []dep.ServiceConsumer{
+							{Peer: "east"}, {Partition: "ops"},
+						},
+					}},
+				})
+				return fakeWatcher{st}
+			}(),
+			"web:east ops ",
+			false,
+		},
+		{
+			"func_exported_services_no_data",
+			hcat.TemplateInput{
+				Contents: `{{ with exportedServices "@dc2" }}{{ .Name }}{{ end }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			false,
+		},
+		{
+			"func_exported_services_bad_format",
+			hcat.TemplateInput{
+				Contents: `{{ exportedServices "foo" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+		{
+			"func_service_with_fallback_primary",
+			hcat.TemplateInput{
+				Contents: `{{ range serviceWithFallback "web@dc1" "web@dc2" }}{{ .Address }}{{ end }}`,
+			},
+			fallbackWatcher(t, map[string][]*dep.HealthService{
+				"web@dc1": {{Address: "1.1.1.1", Status: "passing"}},
+				"web@dc2": {{Address: "2.2.2.2", Status: "passing"}},
+			}),
+			"1.1.1.1",
+			false,
+		},
+		{
+			"func_service_with_fallback_failover",
+			hcat.TemplateInput{
+				Contents: `{{ range serviceWithFallback "web@dc1" "web@dc2" }}{{ .Address }}{{ end }}`,
+			},
+			fallbackWatcher(t, map[string][]*dep.HealthService{
+				"web@dc1": {},
+				"web@dc2": {{Address: "2.2.2.2", Status: "passing"}},
+			}),
+			"2.2.2.2",
+			false,
+		},
+		{
+			"func_service_with_fallback_filter",
+			hcat.TemplateInput{
+				Contents: `{{ range serviceWithFallback "web@dc1|any" "web@dc2" }}{{ .Address }}{{ end }}`,
+			},
+			fallbackWatcher(t, map[string][]*dep.HealthService{
+				"web@dc1|any": {{Address: "1.1.1.1", Status: "critical"}},
+				"web@dc2":     {{Address: "2.2.2.2", Status: "passing"}},
+			}),
+			"2.2.2.2",
+			false,
+		},
+		{
+			"func_service_with_fallback_none",
+			hcat.TemplateInput{
+				Contents: `{{ range serviceWithFallback "web@dc1" "web@dc2" }}{{ .Address }}{{ else }}none{{ end }}`,
+			},
+			fallbackWatcher(t, map[string][]*dep.HealthService{
+				"web@dc1": {},
+			}),
+			"none",
+			false,
+		},
+		{
+			"func_mustService",
+			hcat.TemplateInput{
+				Contents: `{{ range mustService "webapp" }}{{ .Address }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewHealthServiceQuery("webapp")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), []*dep.HealthService{{Address: "1.2.3.4"}})
+				return fakeWatcher{st}
+			}(),
+			"1.2.3.4",
+			false,
+		},
+		{
+			"func_mustService_empty",
+			hcat.TemplateInput{
+				Contents: `{{ range mustService "webapp" }}{{ .Address }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewHealthServiceQuery("webapp")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), []*dep.HealthService{})
+				return fakeWatcher{st}
+			}(),
+			"",
+			true,
+		},
+		{
+			"func_mustService_not_fetched",
+			hcat.TemplateInput{
+				Contents: `{{ range mustService "webapp" }}{{ .Address }}{{ end }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			false,
+		},
 		{
 			"func_service",
 			hcat.TemplateInput{
@@ -289,6 +818,50 @@ func TestConsulV0Execute(t *testing.T) {
 			"service1service2",
 			false,
 		},
+		{
+			"func_services_by_node_meta",
+			hcat.TemplateInput{
+				Contents: `{{ range servicesByNodeMeta "rack=1a" "@dc2" }}{{ .Name }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewCatalogServicesQueryV1([]string{
+					"node-meta=rack:1a", "dc=dc2"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), []*dep.CatalogSnippet{
+					{
+						Name: "service1",
+					},
+				})
+				return fakeWatcher{st}
+			}(),
+			"service1",
+			false,
+		},
+		{
+			"func_services_by_node_meta_no_filter",
+			hcat.TemplateInput{
+				Contents: `{{ servicesByNodeMeta "@dc2" }}`,
+			},
+			func() hcat.Watcherer {
+				return fakeWatcher{hcat.NewStore()}
+			}(),
+			"",
+			true,
+		},
+		{
+			"func_services_by_node_meta_bad_filter",
+			hcat.TemplateInput{
+				Contents: `{{ servicesByNodeMeta "rack" }}`,
+			},
+			func() hcat.Watcherer {
+				return fakeWatcher{hcat.NewStore()}
+			}(),
+			"",
+			true,
+		},
 		{
 			"func_tree",
 			hcat.TemplateInput{
@@ -436,3 +1009,17 @@ func TestConsulV0Execute(t *testing.T) {
 		t.Run(fmt.Sprintf("%d_%s", i, tc.name), testFunc(tc))
 	}
 }
+
+// fallbackWatcher returns a watcher with the health service query results
+func fallbackWatcher(t *testing.T, results map[string][]*dep.HealthService,
+) hcat.Watcherer {
+	st := hcat.NewStore()
+	for q, services := range results {
+		d, err := idep.NewHealthServiceQuery(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		st.Save(d.ID(), services)
+	}
+	return fakeWatcher{st}
+}
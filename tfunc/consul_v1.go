@@ -2,6 +2,7 @@ package tfunc
 
 import (
 	"fmt"
+	"strings"
 	"text/template"
 
 	"github.com/hashicorp/hcat"
@@ -23,6 +24,10 @@ func FuncMapConsulV1() template.FuncMap {
 		"key":          v1KVGetFunc,
 		"keyExists":    v1KVExistsFunc,
 		"keyExistsGet": v1KVExistsGetFunc,
+		"keyMeta":      v1KVMetaFunc,
+		"keysTxn":      v1KVTxnFunc,
+		"serviceFrom":  v1ServiceFromFunc,
+		"keyFrom":      v1KVGetFromFunc,
 
 		// Set of Consul functions that are not yet implemented for v1. These
 		// intentionally error instead of defaulting to the v0 implementations
@@ -86,6 +91,36 @@ func v1ServiceFunc(recall hcat.Recaller) interface{} {
 	}
 }
 
+// v1ServiceFromFunc is v1ServiceFunc using the named Consul client, see
+// ClientSet.AddConsulNamed.
+//
+// Endpoint: /v1/health/service/:service
+// Template: {{ serviceFrom "clientName" "serviceName" <filter options> ... }}
+func v1ServiceFromFunc(recall hcat.Recaller) interface{} {
+	return func(client, service string, opts ...string) ([]*dep.HealthService, error) {
+		result := []*dep.HealthService{}
+
+		if service == "" {
+			return result, nil
+		}
+
+		hsq, err := idep.NewHealthServiceQueryV1(service, opts)
+		if err != nil {
+			return nil, err
+		}
+		d, err := idep.NewNamedClientQuery(client, hsq)
+		if err != nil {
+			return nil, err
+		}
+
+		if value, ok := recall(d); ok {
+			return value.([]*dep.HealthService), nil
+		}
+
+		return result, nil
+	}
+}
+
 // v1ConnectFunc returns or accumulates health information of datacenter nodes
 // and its services that are in Consul Connect, the the service mesh collection
 // of Consul.
@@ -156,13 +191,123 @@ func v1KVGetFunc(recall hcat.Recaller) interface{} {
 		}
 
 		if value, ok := recall(d); ok {
-			return value.(dep.KvValue), nil
+			return kvValue(value), nil
 		}
 
 		return result, nil
 	}
 }
 
+// v1KVMetaFunc returns a single key's pair with its metadata (flags, session
+// and indexes), eg. to check if a lock is held. Like key, it blocks until the
+// key exists.
+//
+// Endpoint: /v1/kv/:key
+// Template: {{ with keyMeta "key" <filter options> ... }}{{ .Locked }}{{ end }}
+func v1KVMetaFunc(recall hcat.Recaller) interface{} {
+	return func(key string, opts ...string) (*dep.KeyPair, error) {
+		var result *dep.KeyPair
+
+		if key == "" {
+			return result, nil
+		}
+
+		d, err := idep.NewKVMetaQueryV1(key, opts)
+		if err != nil {
+			return result, err
+		}
+
+		if value, ok := recall(d); ok {
+			return value.(*dep.KeyPair), nil
+		}
+
+		return result, nil
+	}
+}
+
+// v1KVTxnFunc returns the values of the keys read atomically, in a single
+// Consul transaction, so they are a consistent snapshot. The values are by
+// key, missing keys are left out. Arguments in the "dc=" and "ns=" format are
+// options, the others keys. Each key is read as a prefix, avoid keys that are
+// the prefix of large trees (see KVTxnQuery).
+//
+// Endpoint: /v1/txn
+// Template: {{ with keysTxn "key1" "key2" <options> ... }}{{ index . "key1" }}{{ end }}
+func v1KVTxnFunc(recall hcat.Recaller) interface{} {
+	return func(args ...string) (map[string]dep.KvValue, error) {
+		result := map[string]dep.KvValue{}
+
+		var keys, opts []string
+		for _, arg := range args {
+			switch {
+			case strings.HasPrefix(arg, "dc="),
+				strings.HasPrefix(arg, "datacenter="),
+				strings.HasPrefix(arg, "ns="),
+				strings.HasPrefix(arg, "namespace="):
+				opts = append(opts, arg)
+			default:
+				keys = append(keys, arg)
+			}
+		}
+		if len(keys) == 0 {
+			return result, nil
+		}
+
+		d, err := idep.NewKVTxnQuery(keys, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		if value, ok := recall(d); ok {
+			return value.(map[string]dep.KvValue), nil
+		}
+
+		return result, nil
+	}
+}
+
+// v1KVGetFromFunc is v1KVGetFunc using the named Consul client, see
+// ClientSet.AddConsulNamed.
+//
+// Endpoint: /v1/kv/:key
+// Template: {{ keyFrom "clientName" "key" <filter options> ... }}
+func v1KVGetFromFunc(recall hcat.Recaller) interface{} {
+	return func(client, key string, opts ...string) (dep.KvValue, error) {
+		var result dep.KvValue
+
+		if key == "" {
+			return result, nil
+		}
+
+		kvq, err := idep.NewKVGetQueryV1(key, opts)
+		if err != nil {
+			return "", err
+		}
+		d, err := idep.NewNamedClientQuery(client, kvq)
+		if err != nil {
+			return "", err
+		}
+
+		if value, ok := recall(d); ok {
+			return kvValue(value), nil
+		}
+
+		return result, nil
+	}
+}
+
+// kvValue returns the key's value from the kv.get dependency data which is
+// a dep.KvChange when using the "diff=true" option.
+func kvValue(value interface{}) dep.KvValue {
+	switch v := value.(type) {
+	case dep.KvValue:
+		return v
+	case dep.KvChange:
+		return v.Value()
+	}
+	return ""
+}
+
 // v1KVExistsFunc returns if a key value exists
 //
 // Endpoint: /v1/kv/:key
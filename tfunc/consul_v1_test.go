@@ -70,6 +70,70 @@ func TestTemplateExecuteConsulV1(t *testing.T) {
 			}(),
 			"1.2.3.45.6.7.8",
 			false,
+		}, {
+			"func_service_from",
+			hcat.TemplateInput{
+				Contents: `{{ range serviceFrom "dc2" "webapp" }}{{ .Address }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				hsq, err := idep.NewHealthServiceQueryV1("webapp", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				d, err := idep.NewNamedClientQuery("dc2", hsq)
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), []*dep.HealthService{
+					{
+						Node:    "node1",
+						Address: "1.2.3.4",
+					},
+				})
+				return fakeWatcher{st}
+			}(),
+			"1.2.3.4",
+			false,
+		}, {
+			"func_key_from",
+			hcat.TemplateInput{
+				Contents: `{{ keyFrom "dc2" "foo" }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				kvq, err := idep.NewKVGetQueryV1("foo", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				d, err := idep.NewNamedClientQuery("dc2", kvq)
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), dep.KvValue("bar"))
+				return fakeWatcher{st}
+			}(),
+			"bar",
+			false,
+		}, {
+			"func_key_diff",
+			hcat.TemplateInput{
+				Contents: `{{ key "foo" "diff=true" }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewKVGetQueryV1("foo", []string{"diff=true"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), dep.KvChange{
+					Old: &dep.KeyPair{Key: "foo", Value: "bar", ModifyIndex: 1},
+					New: &dep.KeyPair{Key: "foo", Value: "baz", ModifyIndex: 2},
+				})
+				return fakeWatcher{st}
+			}(),
+			"baz",
+			false,
 		}, {
 			"func_connect",
 			hcat.TemplateInput{
@@ -236,6 +300,60 @@ func TestTemplateExecuteConsulV1(t *testing.T) {
 			"key:value-1",
 			false,
 		},
+		{
+			"func_key_meta",
+			hcat.TemplateInput{
+				Contents: `{{ with keyMeta "key" }}{{ .Value }}:{{ .Flags }}:{{ .Locked }}:{{ .HasFlag 4 }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewKVMetaQueryV1("key", []string{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &dep.KeyPair{
+					Key:     "key",
+					Value:   "value-1",
+					Exists:  true,
+					Flags:   6,
+					Session: "session-id",
+				})
+				return fakeWatcher{st}
+			}(),
+			"value-1:6:true:true",
+			false,
+		},
+		{
+			"func_keys_txn",
+			hcat.TemplateInput{
+				Contents: `{{ with keysTxn "b" "a" "missing" "dc=dc1" }}` +
+					`{{ index . "a" }}:{{ index . "b" }}:{{ len . }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewKVTxnQuery([]string{"a", "b", "missing"},
+					[]string{"dc=dc1"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), map[string]dep.KvValue{
+					"a": "value-a",
+					"b": "value-b",
+				})
+				return fakeWatcher{st}
+			}(),
+			"value-a:value-b:2",
+			false,
+		},
+		{
+			"func_keys_txn_no_data",
+			hcat.TemplateInput{
+				Contents: `{{ len (keysTxn "a" "b") }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"0",
+			false,
+		},
 	}
 
 	for i, tc := range cases {
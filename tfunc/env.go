@@ -3,6 +3,9 @@ package tfunc
 import (
 	"os"
 	"strings"
+
+	"github.com/hashicorp/hcat"
+	idep "github.com/hashicorp/hcat/internal/dependency"
 )
 
 // envFunc returns a function which checks the value of an environment variable.
@@ -42,3 +45,34 @@ func envOrDefaultFunc(env []string) func(string, string) (string, error) {
 		return def, nil
 	}
 }
+
+// envWatchFunc returns the value of the process' environment variable,
+// re-rendering the template when it changes.
+func envWatchFunc(recall hcat.Recaller) interface{} {
+	return func(s string) (string, error) {
+		d, err := idep.NewEnvQuery(s)
+		if err != nil {
+			return "", err
+		}
+		if value, ok := recall(d); ok {
+			return value.(string), nil
+		}
+		return "", nil
+	}
+}
+
+// envFileFunc returns the value of the environment variable set in the env
+// file, re-rendering the template when it changes, eg.
+// `envFile "/etc/app.env" "DB_HOST"`.
+func envFileFunc(recall hcat.Recaller) interface{} {
+	return func(path, s string) (string, error) {
+		d, err := idep.NewEnvFileQuery(path, s)
+		if err != nil {
+			return "", err
+		}
+		if value, ok := recall(d); ok {
+			return value.(string), nil
+		}
+		return "", nil
+	}
+}
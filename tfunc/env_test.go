@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/hashicorp/hcat"
+	idep "github.com/hashicorp/hcat/internal/dependency"
 )
 
 func TestEnvExecute(t *testing.T) {
@@ -63,3 +64,86 @@ func TestEnvExecute(t *testing.T) {
 		})
 	}
 }
+
+func TestEnvV1Execute(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		ti   hcat.TemplateInput
+		i    hcat.Watcherer
+		e    string
+		err  bool
+	}{
+		{
+			"func_env",
+			hcat.TemplateInput{
+				Contents:     `{{ env "FOO" }}`,
+				FuncMapMerge: EnvV1(),
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewEnvQuery("FOO")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), "foo")
+				return fakeWatcher{st}
+			}(),
+			"foo",
+			false,
+		},
+		{
+			"func_env_no_data",
+			hcat.TemplateInput{
+				Contents:     `{{ env "FOO" }}`,
+				FuncMapMerge: EnvV1(),
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			false,
+		},
+		{
+			"func_envFile",
+			hcat.TemplateInput{
+				Contents:     `{{ envFile "/etc/app.env" "FOO" }}`,
+				FuncMapMerge: EnvV1(),
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewEnvFileQuery("/etc/app.env", "FOO")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), "bar")
+				return fakeWatcher{st}
+			}(),
+			"bar",
+			false,
+		},
+		{
+			"func_envFile_bad_name",
+			hcat.TemplateInput{
+				Contents:     `{{ envFile "/etc/app.env" "" }}`,
+				FuncMapMerge: EnvV1(),
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			tpl := newTemplate(tc.ti)
+
+			a, err := tpl.Execute(tc.i.Recaller(tpl))
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if !bytes.Equal([]byte(tc.e), a) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.e, string(a))
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package tfunc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcat"
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+// lastErrorFunc returns the error of the last fetch of the dependency while
+// it is failing, empty once it succeeds again, so templates can render
+// degraded output for stale data. The template is rendered again when the
+// dependency starts failing or recovers.
+//
+// The dependency is the one of the template function called with the
+// arguments, eg. `lastError "service" "web"` for `service "web"`, or given
+// by its ID, eg. `lastError "health.service(web|passing)"`. See
+// dependencyID for the functions supported.
+func lastErrorFunc(recall hcat.Recaller) interface{} {
+	return func(id string, args ...string) (string, error) {
+		id, err := dependencyID(id, args)
+		if err != nil {
+			return "", err
+		}
+		st := recallStatus(recall, id)
+		if st.Failures == 0 || st.LastError == nil {
+			return "", nil
+		}
+		return st.LastError.Error(), nil
+	}
+}
+
+// dependencyStatusFunc returns the hcat.DependencyStatus of the dependency,
+// given like to lastError, eg. for its number of consecutive Failures. The
+// template is rendered again when the dependency starts failing or recovers.
+func dependencyStatusFunc(recall hcat.Recaller) interface{} {
+	return func(id string, args ...string) (hcat.DependencyStatus, error) {
+		id, err := dependencyID(id, args)
+		if err != nil {
+			return hcat.DependencyStatus{}, err
+		}
+		return recallStatus(recall, id), nil
+	}
+}
+
+// dependencyID returns the ID of the dependency the template function (by
+// name) watches when called with the arguments. Without arguments fn is
+// the ID. Supports the service, services, nodes, key, ls, tree and secret
+// functions.
+func dependencyID(fn string, args []string) (string, error) {
+	if len(args) == 0 {
+		return fn, nil
+	}
+
+	var d dep.Dependency
+	var err error
+	switch fn {
+	case "service":
+		d, err = idep.NewHealthServiceQuery(strings.Join(args, "|"))
+	case "services":
+		if strings.Contains(strings.Join(args, ""), "=") {
+			d, err = idep.NewCatalogServicesQueryV1(args)
+		} else {
+			d, err = idep.NewCatalogServicesQuery(strings.Join(args, ""))
+		}
+	case "nodes":
+		if strings.Contains(strings.Join(args, ""), "=") {
+			d, err = idep.NewCatalogNodesQueryV1(args)
+		} else {
+			d, err = idep.NewCatalogNodesQuery(strings.Join(args, ""))
+		}
+	case "key":
+		d, err = idep.NewKVGetQuery(args[0])
+	case "ls", "tree":
+		d, err = idep.NewKVListQueryFiltered(args[0], args[1:])
+	case "secret":
+		d, err = secretDep(args[0], args[1:])
+	default:
+		return "", fmt.Errorf("no dependency ID for function %q", fn)
+	}
+	if err != nil {
+		return "", err
+	}
+	return d.ID(), nil
+}
+
+// recallStatus recalls the status of the dependency with the ID, zero (but
+// for the ID) if it isn't watched.
+func recallStatus(recall hcat.Recaller, id string) hcat.DependencyStatus {
+	if value, ok := recall(&hcat.DependencyErrorQuery{Target: id}); ok {
+		if st, ok := value.(hcat.DependencyStatus); ok {
+			return st
+		}
+	}
+	return hcat.DependencyStatus{ID: id}
+}
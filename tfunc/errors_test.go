@@ -0,0 +1,141 @@
+package tfunc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/hcat"
+)
+
+func TestErrorsExecute(t *testing.T) {
+	t.Parallel()
+
+	const id = "health.service(web|passing)"
+	// withStatus returns a store with the status of the web service
+	withStatus := func(st hcat.DependencyStatus) *hcat.Store {
+		store := hcat.NewStore()
+		d := &hcat.DependencyErrorQuery{Target: id}
+		store.Save(d.ID(), st)
+		return store
+	}
+
+	cases := []struct {
+		name string
+		ti   hcat.TemplateInput
+		i    hcat.Watcherer
+		e    string
+		err  bool
+	}{
+		{
+			"lastError_failing",
+			hcat.TemplateInput{
+				Contents: `{{ with lastError "health.service(web|passing)" }}` +
+					`# stale: {{ . }}{{ end }}`,
+			},
+			fakeWatcher{withStatus(hcat.DependencyStatus{
+				ID:        id,
+				LastError: errors.New("connection refused"),
+				Failures:  3,
+			})},
+			"# stale: connection refused",
+			false,
+		},
+		{
+			"lastError_recovered",
+			hcat.TemplateInput{
+				Contents: `{{ with lastError "health.service(web|passing)" }}` +
+					`# stale: {{ . }}{{ end }}`,
+			},
+			fakeWatcher{withStatus(hcat.DependencyStatus{
+				ID:        id,
+				LastError: errors.New("connection refused"),
+			})},
+			"",
+			false,
+		},
+		{
+			"lastError_not_watched",
+			hcat.TemplateInput{
+				Contents: `{{ lastError "health.service(web|passing)" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			false,
+		},
+		{
+			"dependencyStatus",
+			hcat.TemplateInput{
+				Contents: `{{ with dependencyStatus "service" "web" }}` +
+					`{{ .ID }} {{ .Failures }}{{ end }}`,
+			},
+			fakeWatcher{withStatus(hcat.DependencyStatus{
+				ID:       id,
+				Failures: 5,
+			})},
+			"health.service(web|passing) 5",
+			false,
+		},
+		{
+			"lastError_function",
+			hcat.TemplateInput{
+				Contents: `{{ lastError "service" "web" }}`,
+			},
+			fakeWatcher{withStatus(hcat.DependencyStatus{
+				ID:        id,
+				LastError: errors.New("connection refused"),
+				Failures:  1,
+			})},
+			"connection refused",
+			false,
+		},
+		{
+			"lastError_unknown_function",
+			hcat.TemplateInput{
+				Contents: `{{ lastError "nope" "web" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			tpl := newTemplate(tc.ti)
+
+			a, err := tpl.Execute(tc.i.Recaller(tpl))
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if !bytes.Equal([]byte(tc.e), a) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.e, string(a))
+			}
+		})
+	}
+}
+
+func TestDependencyID(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		fn   string
+		args []string
+		exp  string
+	}{
+		{"health.service(web|passing)", nil, "health.service(web|passing)"},
+		{"service", []string{"web", "any"}, "health.service(web|any)"},
+		{"key", []string{"foo"}, "kv.get(foo)"},
+		{"secret", []string{"secret/foo"}, "vault.read(secret/foo)"},
+	}
+	for _, tc := range cases {
+		id, err := dependencyID(tc.fn, tc.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != tc.exp {
+			t.Errorf("bad %s ID: %s", tc.fn, id)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package tfunc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcat"
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+// identityEntityFunc returns the entity of Vault's identity secrets engine
+// with the name or, with "mount=<path>", the one with the alias in the auth
+// method mounted at the path. The alias lookup needs read access to sys/auth
+// for the mount's accessor. It is nil if there is no entity with the alias,
+// an entity name that doesn't exist fails like a missing secret. Entities
+// are read again at the default lease duration, so templates follow changes
+// to their policies, metadata and groups.
+//
+// Endpoint: /v1/identity/entity/name/:name or /v1/identity/lookup/entity
+// Template: {{ with identityEntity "alice" }}{{ .Policies }}{{ end }}
+func identityEntityFunc(recall hcat.Recaller) interface{} {
+	return func(name string, rest ...string) (*dep.IdentityEntity, error) {
+		s, err := identityLookup(recall, "identityEntity", "entity", name, rest)
+		if s == nil || err != nil {
+			return nil, err
+		}
+		e := &dep.IdentityEntity{
+			ID:                secretString(s, "id"),
+			Name:              secretString(s, "name"),
+			Disabled:          secretString(s, "disabled") == "true",
+			Policies:          secretStrings(s, "policies"),
+			Metadata:          stringMap(s.Data["metadata"]),
+			GroupIDs:          secretStrings(s, "group_ids"),
+			DirectGroupIDs:    secretStrings(s, "direct_group_ids"),
+			InheritedGroupIDs: secretStrings(s, "inherited_group_ids"),
+		}
+		if aliases, ok := s.Data["aliases"].([]interface{}); ok {
+			for _, a := range aliases {
+				if a, ok := a.(map[string]interface{}); ok {
+					e.Aliases = append(e.Aliases, identityAlias(a))
+				}
+			}
+		}
+		return e, nil
+	}
+}
+
+// identityGroupFunc returns the group of Vault's identity secrets engine with
+// the name or, with "mount=<path>", the external group with the alias in the
+// auth method mounted at the path. See identityEntityFunc.
+//
+// Endpoint: /v1/identity/group/name/:name or /v1/identity/lookup/group
+// Template: {{ with identityGroup "admins" }}{{ .MemberEntityIDs }}{{ end }}
+func identityGroupFunc(recall hcat.Recaller) interface{} {
+	return func(name string, rest ...string) (*dep.IdentityGroup, error) {
+		s, err := identityLookup(recall, "identityGroup", "group", name, rest)
+		if s == nil || err != nil {
+			return nil, err
+		}
+		g := &dep.IdentityGroup{
+			ID:              secretString(s, "id"),
+			Name:            secretString(s, "name"),
+			Type:            secretString(s, "type"),
+			Policies:        secretStrings(s, "policies"),
+			Metadata:        stringMap(s.Data["metadata"]),
+			MemberEntityIDs: secretStrings(s, "member_entity_ids"),
+			MemberGroupIDs:  secretStrings(s, "member_group_ids"),
+			ParentGroupIDs:  secretStrings(s, "parent_group_ids"),
+		}
+		if a, ok := s.Data["alias"].(map[string]interface{}); ok && len(a) > 0 {
+			g.Alias = identityAlias(a)
+		}
+		return g, nil
+	}
+}
+
+// identityLookup returns the secret of the identity entity or group (the
+// kind) with the name, or the alias with a "mount=<path>" argument. It is nil
+// until fetched.
+func identityLookup(recall hcat.Recaller, fn, kind, name string,
+	rest []string) (*dep.Secret, error) {
+	if name == "" {
+		return nil, nil
+	}
+	data, err := kvPairs(rest)
+	if err != nil {
+		return nil, err
+	}
+	var mount string
+	for k, v := range data {
+		switch k {
+		case "mount":
+			mount = strings.Trim(v.(string), "/")
+		default:
+			return nil, fmt.Errorf("%s: invalid argument: %q", fn, k)
+		}
+	}
+
+	var d dep.Dependency
+	if mount == "" {
+		d, err = idep.NewVaultReadQuery("identity/" + kind + "/name/" + name)
+	} else {
+		var accessor string
+		accessor, err = authMountAccessor(recall, mount)
+		if accessor == "" || err != nil {
+			return nil, err
+		}
+		d, err = idep.NewVaultWriteQuery("identity/lookup/"+kind,
+			map[string]interface{}{
+				"alias_name":           name,
+				"alias_mount_accessor": accessor,
+			})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := recall(d); ok {
+		s, _ := value.(*dep.Secret)
+		return s, nil
+	}
+	return nil, nil
+}
+
+// authMountAccessor returns the accessor of the auth method mounted at the
+// path, from the sys/auth mounts. It is empty until they are fetched.
+func authMountAccessor(recall hcat.Recaller, mount string) (string, error) {
+	d, err := idep.NewVaultReadQuery("sys/auth")
+	if err != nil {
+		return "", err
+	}
+	value, ok := recall(d)
+	if !ok {
+		return "", nil
+	}
+	s, _ := value.(*dep.Secret)
+	if s == nil {
+		return "", nil
+	}
+	if m, ok := s.Data[mount+"/"].(map[string]interface{}); ok {
+		if accessor, ok := m["accessor"].(string); ok && accessor != "" {
+			return accessor, nil
+		}
+	}
+	return "", fmt.Errorf("no auth method mounted at %q", mount)
+}
+
+// identityAlias returns the alias from its data in an entity or group.
+func identityAlias(a map[string]interface{}) *dep.IdentityAlias {
+	str := func(k string) string {
+		if v, ok := a[k]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+	return &dep.IdentityAlias{
+		ID:            str("id"),
+		Name:          str("name"),
+		MountAccessor: str("mount_accessor"),
+		MountPath:     str("mount_path"),
+		MountType:     str("mount_type"),
+		Metadata:      stringMap(a["metadata"]),
+	}
+}
+
+// secretStrings returns the secret's data value for the key as a list of
+// strings, sorted so the templates' output is stable.
+func secretStrings(s *dep.Secret, key string) []string {
+	list, ok := s.Data[key].([]interface{})
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		out = append(out, fmt.Sprint(v))
+	}
+	sort.Strings(out)
+	return out
+}
+
+// stringMap returns the map of strings, nil if it isn't a map.
+func stringMap(v interface{}) map[string]string {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = fmt.Sprint(v)
+	}
+	return out
+}
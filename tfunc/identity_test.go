@@ -0,0 +1,204 @@
+package tfunc
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/hcat"
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+func TestIdentityExecute(t *testing.T) {
+	t.Parallel()
+
+	entity := &dep.Secret{Data: map[string]interface{}{
+		"id":       "e-1",
+		"name":     "alice",
+		"disabled": false,
+		"policies": []interface{}{"ops", "default"},
+		"metadata": map[string]interface{}{"team": "sre"},
+		"aliases": []interface{}{map[string]interface{}{
+			"id":             "a-1",
+			"name":           "alice",
+			"mount_accessor": "auth_userpass_1",
+			"mount_path":     "auth/userpass/",
+			"mount_type":     "userpass",
+		}},
+		"group_ids":        []interface{}{"g-1"},
+		"direct_group_ids": []interface{}{"g-1"},
+	}}
+	group := &dep.Secret{Data: map[string]interface{}{
+		"id":                "g-1",
+		"name":              "admins",
+		"type":              "external",
+		"policies":          []interface{}{"admin"},
+		"member_entity_ids": []interface{}{"e-2", "e-1"},
+		"alias": map[string]interface{}{
+			"name":           "admins",
+			"mount_accessor": "auth_oidc_1",
+		},
+	}}
+	sysAuth := &dep.Secret{Data: map[string]interface{}{
+		"userpass/": map[string]interface{}{"accessor": "auth_userpass_1"},
+		"oidc/":     map[string]interface{}{"accessor": "auth_oidc_1"},
+	}}
+	save := func(st *hcat.Store, d dep.Dependency, err error, v interface{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		st.Save(d.ID(), v)
+	}
+
+	cases := []struct {
+		name string
+		ti   hcat.TemplateInput
+		i    hcat.Watcherer
+		e    string
+		err  bool
+	}{
+		{
+			"entity",
+			hcat.TemplateInput{
+				Contents: `{{ with identityEntity "alice" }}{{ .ID }} ` +
+					`{{ .Policies }} {{ .Metadata.team }} {{ .GroupIDs }} ` +
+					`{{ range .Aliases }}{{ .MountPath }}{{ end }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultReadQuery("identity/entity/name/alice")
+				save(st, d, err, entity)
+				return fakeWatcher{st}
+			}(),
+			"e-1 [default ops] sre [g-1] auth/userpass/",
+			false,
+		},
+		{
+			"entity_alias",
+			hcat.TemplateInput{
+				Contents: `{{ with identityEntity "alice" "mount=userpass/" }}` +
+					`{{ .Name }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultReadQuery("sys/auth")
+				save(st, d, err, sysAuth)
+				w, err := idep.NewVaultWriteQuery("identity/lookup/entity",
+					map[string]interface{}{
+						"alias_name":           "alice",
+						"alias_mount_accessor": "auth_userpass_1",
+					})
+				save(st, w, err, entity)
+				return fakeWatcher{st}
+			}(),
+			"alice",
+			false,
+		},
+		{
+			"entity_alias_not_found",
+			hcat.TemplateInput{
+				Contents: `{{ with identityEntity "bob" "mount=userpass" }}` +
+					`{{ .Name }}{{ else }}none{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultReadQuery("sys/auth")
+				save(st, d, err, sysAuth)
+				w, err := idep.NewVaultWriteQuery("identity/lookup/entity",
+					map[string]interface{}{
+						"alias_name":           "bob",
+						"alias_mount_accessor": "auth_userpass_1",
+					})
+				save(st, w, err, (*dep.Secret)(nil))
+				return fakeWatcher{st}
+			}(),
+			"none",
+			false,
+		},
+		{
+			"entity_alias_no_mount",
+			hcat.TemplateInput{
+				Contents: `{{ identityEntity "alice" "mount=ldap" }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultReadQuery("sys/auth")
+				save(st, d, err, sysAuth)
+				return fakeWatcher{st}
+			}(),
+			"",
+			true,
+		},
+		{
+			"entity_not_fetched",
+			hcat.TemplateInput{
+				Contents: `{{ with identityEntity "alice" "mount=userpass" }}` +
+					`{{ .Name }}{{ end }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			false,
+		},
+		{
+			"entity_bad_arg",
+			hcat.TemplateInput{
+				Contents: `{{ identityEntity "alice" "id=e-1" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+		{
+			"group",
+			hcat.TemplateInput{
+				Contents: `{{ with identityGroup "admins" }}{{ .Type }} ` +
+					`{{ .Policies }} {{ .MemberEntityIDs }} ` +
+					`{{ .Alias.MountAccessor }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultReadQuery("identity/group/name/admins")
+				save(st, d, err, group)
+				return fakeWatcher{st}
+			}(),
+			"external [admin] [e-1 e-2] auth_oidc_1",
+			false,
+		},
+		{
+			"group_alias",
+			hcat.TemplateInput{
+				Contents: `{{ with identityGroup "admins" "mount=oidc" }}` +
+					`{{ .ID }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultReadQuery("sys/auth")
+				save(st, d, err, sysAuth)
+				w, err := idep.NewVaultWriteQuery("identity/lookup/group",
+					map[string]interface{}{
+						"alias_name":           "admins",
+						"alias_mount_accessor": "auth_oidc_1",
+					})
+				save(st, w, err, group)
+				return fakeWatcher{st}
+			}(),
+			"g-1",
+			false,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			tpl := newTemplate(tc.ti)
+
+			a, err := tpl.Execute(tc.i.Recaller(tpl))
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if !bytes.Equal([]byte(tc.e), a) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.e, string(a))
+			}
+		})
+	}
+}
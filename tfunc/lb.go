@@ -0,0 +1,78 @@
+package tfunc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/hcat/dep"
+)
+
+// maxHAProxyWeight is the maximum weight of a HAProxy server
+const maxHAProxyWeight = 256
+
+// haproxyNameRe matches the characters not allowed in HAProxy server names
+var haproxyNameRe = regexp.MustCompile(`[^a-zA-Z0-9_.:-]`)
+
+// toHAProxyServers returns the services as HAProxy `server` lines, one per
+// instance, for a backend section. The server is named for the node and
+// service ID and is health checked. Its weight is the instance's effective
+// weight (see HealthService.EffectiveWeight), instances in maintenance are
+// disabled and each line ends with a comment of the instance's status.
+//
+// Template: backend web
+// {{ service "web|any" | toHAProxyServers | indent 2 }}
+func toHAProxyServers(services []*dep.HealthService) string {
+	var b strings.Builder
+	for _, s := range services {
+		name := haproxyNameRe.ReplaceAllString(s.Node+"_"+s.ID, "_")
+		weight := s.EffectiveWeight()
+		if weight > maxHAProxyWeight {
+			weight = maxHAProxyWeight
+		}
+		fmt.Fprintf(&b, "server %s %s check weight %d", name,
+			hostPort(s), weight)
+		if s.InMaintenance() {
+			b.WriteString(" disabled")
+		}
+		fmt.Fprintf(&b, " # %s\n", s.Status)
+	}
+	return b.String()
+}
+
+// toNginxUpstream returns the services as a NGINX `upstream` block with the
+// name, a `server` per instance. The server's weight is the instance's
+// effective weight (see HealthService.EffectiveWeight), instances with 0
+// weight (critical or in maintenance) are marked down and each line ends with
+// a comment of the node and instance's status. An upstream with no servers
+// is invalid, so the block has a single down server (on the discard port)
+// when there are no instances.
+//
+// Template: {{ service "web|any" | toNginxUpstream "web" }}
+func toNginxUpstream(name string, services []*dep.HealthService) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "upstream %s {\n", name)
+	for _, s := range services {
+		fmt.Fprintf(&b, "    server %s", hostPort(s))
+		if weight := s.EffectiveWeight(); weight > 0 {
+			fmt.Fprintf(&b, " weight=%d;", weight)
+		} else {
+			b.WriteString(" down;")
+		}
+		fmt.Fprintf(&b, " # %s %s\n", s.Node, s.Status)
+	}
+	if len(services) == 0 {
+		b.WriteString("    server 127.0.0.1:9 down; # no instances\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// hostPort returns the service's address and port, with IPv6 addresses in
+// brackets.
+func hostPort(s *dep.HealthService) string {
+	if strings.Contains(s.Address, ":") {
+		return fmt.Sprintf("[%s]:%d", s.Address, s.Port)
+	}
+	return fmt.Sprintf("%s:%d", s.Address, s.Port)
+}
@@ -0,0 +1,102 @@
+package tfunc
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/hcat"
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+func TestLBExecute(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name string
+		ti   hcat.TemplateInput
+		i    hcat.Watcherer
+		e    string
+		err  bool
+	}
+
+	testFunc := func(tc testCase) func(*testing.T) {
+		return func(t *testing.T) {
+			tpl := newTemplate(tc.ti)
+
+			a, err := tpl.Execute(tc.i.Recaller(tpl))
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if !bytes.Equal([]byte(tc.e), a) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.e, string(a))
+			}
+		}
+	}
+
+	services := func() hcat.Watcherer {
+		st := hcat.NewStore()
+		d, err := idep.NewHealthServiceQuery("web|any")
+		if err != nil {
+			t.Fatal(err)
+		}
+		weights := api.AgentWeights{Passing: 10, Warning: 1}
+		st.Save(d.ID(), []*dep.HealthService{
+			{Node: "node1", ID: "web 1", Address: "10.0.0.1", Port: 8080,
+				Status: "passing", Weights: weights},
+			{Node: "node2", ID: "web2", Address: "10.0.0.2", Port: 8080,
+				Status: "warning", Weights: weights},
+			{Node: "node3", ID: "web3", Address: "::1", Port: 8080,
+				Status: "critical", Weights: weights},
+			{Node: "node4", ID: "web4", Address: "10.0.0.4", Port: 8080,
+				Status: "maintenance", Weights: weights},
+		})
+		return fakeWatcher{st}
+	}
+
+	cases := []testCase{
+		{
+			"haproxy_servers",
+			hcat.TemplateInput{
+				Contents: `{{ service "web|any" | toHAProxyServers }}`,
+			},
+			services(),
+			"server node1_web_1 10.0.0.1:8080 check weight 10 # passing\n" +
+				"server node2_web2 10.0.0.2:8080 check weight 1 # warning\n" +
+				"server node3_web3 [::1]:8080 check weight 0 # critical\n" +
+				"server node4_web4 10.0.0.4:8080 check weight 0 disabled # maintenance\n",
+			false,
+		},
+		{
+			"nginx_upstream",
+			hcat.TemplateInput{
+				Contents: `{{ service "web|any" | toNginxUpstream "web" }}`,
+			},
+			services(),
+			"upstream web {\n" +
+				"    server 10.0.0.1:8080 weight=10; # node1 passing\n" +
+				"    server 10.0.0.2:8080 weight=1; # node2 warning\n" +
+				"    server [::1]:8080 down; # node3 critical\n" +
+				"    server 10.0.0.4:8080 down; # node4 maintenance\n" +
+				"}\n",
+			false,
+		},
+		{
+			"nginx_upstream_empty",
+			hcat.TemplateInput{
+				Contents: `{{ service "api" | toNginxUpstream "api" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"upstream api {\n" +
+				"    server 127.0.0.1:9 down; # no instances\n" +
+				"}\n",
+			false,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), testFunc(tc))
+	}
+}
@@ -2,6 +2,7 @@ package tfunc
 
 import (
 	"fmt"
+	"reflect"
 	"sort"
 	"strings"
 
@@ -63,6 +64,37 @@ func explodeMap(mapIn map[string]interface{}) (map[string]interface{}, error) {
 	return mapOut, nil
 }
 
+// implode is the inverse of explode and explodeMap, it flattens a
+// deeply-nested hash into a single-level map with the nested keys joined by
+// "/", eg. {"a": {"b": 1}} into {"a/b": 1}. Empty nested maps are kept as
+// folder keys ending with "/" and an empty value, like in Consul's KV. Nil
+// values are kept as they are, not as folders.
+func implode(mapIn interface{}) (map[string]interface{}, error) {
+	m, err := toMap(mapIn)
+	if err != nil {
+		return nil, errors.Wrap(err, "implode")
+	}
+	mapOut := make(map[string]interface{})
+	implodeHelper(mapOut, "", m)
+	return mapOut, nil
+}
+
+// implodeHelper is a recursive helper for implode, setting the keys of the
+// nested map m, prefixed with p, in mapOut.
+func implodeHelper(mapOut map[string]interface{}, p string, m map[string]interface{}) {
+	for k, v := range m {
+		nested, err := toMap(v)
+		switch {
+		case v == nil, err != nil:
+			mapOut[p+k] = v
+		case len(nested) == 0:
+			mapOut[p+k+"/"] = ""
+		default:
+			implodeHelper(mapOut, p+k+"/", nested)
+		}
+	}
+}
+
 type _map = map[string]interface{}
 
 // mergeMap is used to merge two maps
@@ -77,3 +109,75 @@ func mergeMap(dstMap _map, srcMap _map, args ...func(*mergo.Config)) (_map, erro
 func mergeMapWithOverride(dstMap _map, srcMap _map) (_map, error) {
 	return mergeMap(dstMap, srcMap, mergo.WithOverride)
 }
+
+// merge returns a new map with the keys of all the maps, the values of later
+// maps overriding those of earlier ones. So defaults go first, eg.
+// `merge $defaults .ServiceMeta`. The maps can be of any value type but must
+// have string keys, nil ones are skipped. Nested maps are replaced as a whole,
+// see mergeDeep.
+func merge(maps ...interface{}) (_map, error) {
+	return mergeMaps("merge", false, maps)
+}
+
+// mergeDeep is merge that merges nested maps instead of replacing them, so a
+// later map only overrides the nested keys it has. Other values, including
+// lists, are replaced.
+func mergeDeep(maps ...interface{}) (_map, error) {
+	return mergeMaps("mergeDeep", true, maps)
+}
+
+// mergeMaps merges the maps into a new one, later maps overriding earlier
+// ones and merging the nested maps if deep.
+func mergeMaps(name string, deep bool, maps []interface{}) (_map, error) {
+	out := make(_map)
+	for i, m := range maps {
+		src, err := toMap(m)
+		if err != nil {
+			return nil, fmt.Errorf("%s: argument %d: %s", name, i+1, err)
+		}
+		mergeInto(out, src, deep)
+	}
+	return out, nil
+}
+
+// mergeInto sets the keys of src in dst, merging the nested maps if deep.
+// Nested maps are copied, so dst never shares them with the arguments.
+func mergeInto(dst, src _map, deep bool) {
+	for k, v := range src {
+		nested, err := toMap(v)
+		if err != nil || nested == nil {
+			dst[k] = v
+			continue
+		}
+		existing, ok := dst[k].(_map)
+		if !ok || !deep {
+			existing = make(_map)
+		}
+		mergeInto(existing, nested, deep)
+		dst[k] = existing
+	}
+}
+
+// toMap returns the map, with string keys, as a map[string]interface{}. It is
+// nil for a nil value.
+func toMap(m interface{}) (_map, error) {
+	if m == nil {
+		return nil, nil
+	}
+	if m, ok := m.(_map); ok {
+		return m, nil
+	}
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("not a map with string keys: %T", m)
+	}
+	if v.IsNil() {
+		return nil, nil
+	}
+	out := make(_map, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		out[iter.Key().String()] = iter.Value().Interface()
+	}
+	return out, nil
+}
@@ -55,6 +55,52 @@ func TestMapExecute(t *testing.T) {
 			"map[foo:map[bar:a] qux:c zip:map[zap:d]]",
 			false,
 		},
+		{
+			"helper_implode",
+			hcat.TemplateInput{
+				Contents: `{{ range $k, $v := tree "list" | explode | implode }}` +
+					`{{ $k }}={{ $v }};{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				id := testKVListQueryID("list")
+				st.Save(id, []*dep.KeyPair{
+					{Key: "foo/bar/baz", Value: "a"},
+					{Key: "foo/qux", Value: "b"},
+					{Key: "zip", Value: "c"},
+				})
+				return fakeWatcher{st}
+			}(),
+			"foo/bar/baz=a;foo/qux=b;zip=c;",
+			false,
+		},
+		{
+			"helper_implode_folder",
+			hcat.TemplateInput{
+				Contents: `{{ testMap | implode }}`,
+				FuncMapMerge: map[string]interface{}{
+					"testMap": func() map[string]interface{} {
+						return map[string]interface{}{
+							"empty": map[string]interface{}{},
+							"null":  nil,
+							"tls":   map[string]string{"ca": "/ca.pem"},
+						}
+					},
+				},
+			},
+			fakeWatcher{hcat.NewStore()},
+			"map[empty/: null:<nil> tls/ca:/ca.pem]",
+			false,
+		},
+		{
+			"helper_implode_not_map",
+			hcat.TemplateInput{
+				Contents: `{{ "foo" | implode }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
 		{
 			"helper_mergeMap",
 			hcat.TemplateInput{
@@ -91,6 +137,54 @@ func TestMapExecute(t *testing.T) {
 			"foomap[bar:a]voomap[bar:v]zipmap[zap:b]",
 			false,
 		},
+		{
+			"helper_merge",
+			hcat.TemplateInput{
+				Contents: `{{ $defaults := tree "list" | explode }}` +
+					`{{ merge $defaults meta }}`,
+				FuncMapMerge: map[string]interface{}{"meta": testMeta},
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				id := testKVListQueryID("list")
+				st.Save(id, []*dep.KeyPair{
+					{Key: "port", Value: "80"},
+					{Key: "tls/enabled", Value: "false"},
+				})
+				return fakeWatcher{st}
+			}(),
+			"map[port:8080 tls:map[ca:/ca.pem] version:2]",
+			false,
+		},
+		{
+			"helper_mergeDeep",
+			hcat.TemplateInput{
+				Contents: `{{ $defaults := tree "list" | explode }}` +
+					`{{ mergeDeep $defaults meta }}`,
+				FuncMapMerge: map[string]interface{}{"meta": testMeta},
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				id := testKVListQueryID("list")
+				st.Save(id, []*dep.KeyPair{
+					{Key: "port", Value: "80"},
+					{Key: "tls/enabled", Value: "false"},
+				})
+				return fakeWatcher{st}
+			}(),
+			"map[port:8080 tls:map[ca:/ca.pem enabled:false] version:2]",
+			false,
+		},
+		{
+			"helper_merge_not_map",
+			hcat.TemplateInput{
+				Contents:     `{{ merge meta "foo" }}`,
+				FuncMapMerge: map[string]interface{}{"meta": testMeta},
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
 	}
 
 	for i, tc := range cases {
@@ -107,3 +201,12 @@ func TestMapExecute(t *testing.T) {
 		})
 	}
 }
+
+// testMeta returns service metadata like overrides for the merge tests
+func testMeta() map[string]interface{} {
+	return map[string]interface{}{
+		"port":    "8080",
+		"tls":     map[string]string{"ca": "/ca.pem"},
+		"version": "2",
+	}
+}
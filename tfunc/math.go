@@ -2,13 +2,44 @@ package tfunc
 
 import (
 	"fmt"
+	"math"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
+// mathValue returns the reflect.Value of the operand for the math functions,
+// parsing strings (eg. values from KV) into an int64, or a float64 if they
+// aren't an integer. Strings that aren't numbers are left as is.
+func mathValue(v interface{}) reflect.Value {
+	if s, ok := v.(string); ok {
+		s = strings.TrimSpace(s)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return reflect.ValueOf(i)
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return reflect.ValueOf(f)
+		}
+	}
+	return reflect.ValueOf(v)
+}
+
+// isZeroInt returns true if the value is an integer zero, used to return an
+// error instead of panicking on integer division by zero.
+func isZeroInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	}
+	return false
+}
+
 // add returns the sum of a and b.
 func add(b, a interface{}) (interface{}, error) {
-	av := reflect.ValueOf(a)
-	bv := reflect.ValueOf(b)
+	av := mathValue(a)
+	bv := mathValue(b)
 
 	switch av.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -51,8 +82,8 @@ func add(b, a interface{}) (interface{}, error) {
 
 // subtract returns the difference of b from a.
 func subtract(b, a interface{}) (interface{}, error) {
-	av := reflect.ValueOf(a)
-	bv := reflect.ValueOf(b)
+	av := mathValue(a)
+	bv := mathValue(b)
 
 	switch av.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -95,8 +126,8 @@ func subtract(b, a interface{}) (interface{}, error) {
 
 // multiply returns the product of a and b.
 func multiply(b, a interface{}) (interface{}, error) {
-	av := reflect.ValueOf(a)
-	bv := reflect.ValueOf(b)
+	av := mathValue(a)
+	bv := mathValue(b)
 
 	switch av.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -139,8 +170,16 @@ func multiply(b, a interface{}) (interface{}, error) {
 
 // divide returns the division of b from a.
 func divide(b, a interface{}) (interface{}, error) {
-	av := reflect.ValueOf(a)
-	bv := reflect.ValueOf(b)
+	av := mathValue(a)
+	bv := mathValue(b)
+
+	if isZeroInt(bv) {
+		switch av.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return nil, fmt.Errorf("divide: division by zero")
+		}
+	}
 
 	switch av.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -183,8 +222,16 @@ func divide(b, a interface{}) (interface{}, error) {
 
 // modulo returns the modulo of b from a.
 func modulo(b, a interface{}) (interface{}, error) {
-	av := reflect.ValueOf(a)
-	bv := reflect.ValueOf(b)
+	av := mathValue(a)
+	bv := mathValue(b)
+
+	if isZeroInt(bv) {
+		switch av.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return nil, fmt.Errorf("modulo: division by zero")
+		}
+	}
 
 	switch av.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -212,8 +259,8 @@ func modulo(b, a interface{}) (interface{}, error) {
 
 // minimum returns the minimum between a and b.
 func minimum(b, a interface{}) (interface{}, error) {
-	av := reflect.ValueOf(a)
-	bv := reflect.ValueOf(b)
+	av := mathValue(a)
+	bv := mathValue(b)
 
 	switch av.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -283,8 +330,8 @@ func minimum(b, a interface{}) (interface{}, error) {
 
 // maximum returns the maximum between a and b.
 func maximum(b, a interface{}) (interface{}, error) {
-	av := reflect.ValueOf(a)
-	bv := reflect.ValueOf(b)
+	av := mathValue(a)
+	bv := mathValue(b)
 
 	switch av.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -351,3 +398,47 @@ func maximum(b, a interface{}) (interface{}, error) {
 		return nil, fmt.Errorf("maximum: unknown type for %q (%T)", av, a)
 	}
 }
+
+// round returns the value rounded to the given number of decimal places, or
+// to the nearest integer if no places are given. Halves are rounded away from
+// zero.
+//
+//	{{ 2.567 | round 2 }} => 2.57
+//	{{ 2.5 | round }} => 3
+func round(args ...interface{}) (float64, error) {
+	var places int64
+	switch len(args) {
+	case 1:
+	case 2:
+		pv := mathValue(args[0])
+		switch pv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			places = pv.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			places = int64(pv.Uint())
+		default:
+			return 0, fmt.Errorf("round: places must be an integer, got %q (%T)",
+				pv, args[0])
+		}
+	default:
+		return 0, fmt.Errorf("round: wrong number of args for round: "+
+			"want 1 or 2, got %d", len(args))
+	}
+
+	v := args[len(args)-1]
+	var f float64
+	vv := mathValue(v)
+	switch vv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f = float64(vv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f = float64(vv.Uint())
+	case reflect.Float32, reflect.Float64:
+		f = vv.Float()
+	default:
+		return 0, fmt.Errorf("round: unknown type for %q (%T)", vv, v)
+	}
+
+	shift := math.Pow(10, float64(places))
+	return math.Round(f*shift) / shift, nil
+}
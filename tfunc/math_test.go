@@ -81,6 +81,132 @@ func TestMathExecute(t *testing.T) {
 			"3",
 			false,
 		},
+		{
+			"math_add_strings",
+			hcat.TemplateInput{
+				Contents: `{{ "2" | add "2.5" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"4.5",
+			false,
+		},
+		{
+			"math_sub_alias",
+			hcat.TemplateInput{
+				Contents: `{{ 5 | sub 2 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"3",
+			false,
+		},
+		{
+			"math_mul_alias",
+			hcat.TemplateInput{
+				Contents: `{{ "3" | mul 2 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"6",
+			false,
+		},
+		{
+			"math_div_alias",
+			hcat.TemplateInput{
+				Contents: `{{ 9 | div 2 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"4",
+			false,
+		},
+		{
+			"math_div_zero",
+			hcat.TemplateInput{
+				Contents: `{{ 9 | div 0 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+		{
+			"math_mod_zero",
+			hcat.TemplateInput{
+				Contents: `{{ 9 | mod "0" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+		{
+			"math_min_alias",
+			hcat.TemplateInput{
+				Contents: `{{ 3 | min 2 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"2",
+			false,
+		},
+		{
+			"math_max_alias",
+			hcat.TemplateInput{
+				Contents: `{{ 3 | max 2 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"3",
+			false,
+		},
+		{
+			"math_weight",
+			hcat.TemplateInput{
+				Contents: `{{ multiply "25" 100 | divide 200 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"12",
+			false,
+		},
+		{
+			"math_bad_string",
+			hcat.TemplateInput{
+				Contents: `{{ "foo" | add 1 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+		{
+			"math_round",
+			hcat.TemplateInput{
+				Contents: `{{ 2.5 | round }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"3",
+			false,
+		},
+		{
+			"math_round_places",
+			hcat.TemplateInput{
+				Contents: `{{ 2.567 | round 2 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"2.57",
+			false,
+		},
+		{
+			"math_round_string",
+			hcat.TemplateInput{
+				Contents: `{{ "-1.25" | round 1 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"-1.3",
+			false,
+		},
+		{
+			"math_round_bad_places",
+			hcat.TemplateInput{
+				Contents: `{{ 2.5 | round 1.5 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
 	}
 
 	for i, tc := range cases {
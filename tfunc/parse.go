@@ -2,8 +2,12 @@ package tfunc
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -21,6 +25,42 @@ func parseBool(s string) (bool, error) {
 	return result, nil
 }
 
+// parseBoolOr parses a value into a boolean, returning the default if it is
+// empty or not a boolean
+func parseBoolOr(v interface{}, def bool) bool {
+	result, err := strconv.ParseBool(looseString(v))
+	if err != nil {
+		return def
+	}
+	return result
+}
+
+// parseDuration parses a string into a duration (eg. "1m30s")
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	result, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, errors.Wrap(err, "parseDuration")
+	}
+	return result, nil
+}
+
+// parseDurationOr parses a value into a duration, returning the default (also
+// a duration string) if it is empty or not a duration
+func parseDurationOr(v interface{}, def string) (time.Duration, error) {
+	if result, err := time.ParseDuration(looseString(v)); err == nil {
+		return result, nil
+	}
+	result, err := time.ParseDuration(def)
+	if err != nil {
+		return 0, errors.Wrap(err, "parseDurationOr: bad default")
+	}
+	return result, nil
+}
+
 // parseFloat parses a string into a base 10 float
 func parseFloat(s string) (float64, error) {
 	if s == "" {
@@ -47,6 +87,26 @@ func parseInt(s string) (int64, error) {
 	return result, nil
 }
 
+// parseFloatOr parses a value into a base 10 float, returning the default if
+// it is empty or not a number
+func parseFloatOr(v interface{}, def float64) float64 {
+	result, err := strconv.ParseFloat(looseString(v), 64)
+	if err != nil {
+		return def
+	}
+	return result
+}
+
+// parseIntOr parses a value into a base 10 int, returning the default if it
+// is empty or not an int
+func parseIntOr(v interface{}, def int64) int64 {
+	result, err := strconv.ParseInt(looseString(v), 10, 64)
+	if err != nil {
+		return def
+	}
+	return result
+}
+
 // parseJSON returns a structure for valid JSON
 func parseJSON(s string) (interface{}, error) {
 	if s == "" {
@@ -73,6 +133,16 @@ func parseUint(s string) (uint64, error) {
 	return result, nil
 }
 
+// parseUintOr parses a value into a base 10 unsigned int, returning the
+// default if it is empty or not an unsigned int
+func parseUintOr(v interface{}, def uint64) uint64 {
+	result, err := strconv.ParseUint(looseString(v), 10, 64)
+	if err != nil {
+		return def
+	}
+	return result
+}
+
 // parseYAML returns a structure for valid YAML
 func parseYAML(s string) (interface{}, error) {
 	if s == "" {
@@ -83,5 +153,46 @@ func parseYAML(s string) (interface{}, error) {
 	if err := yaml.Unmarshal([]byte(s), &data); err != nil {
 		return nil, err
 	}
+	return stringKeys(data), nil
+}
+
+// stringKeys converts the map[interface{}]interface{} maps YAML decodes to
+// map[string]interface{}, as parseJSON returns, so the maps work the same with
+// the other functions (eg. toJSON or merge).
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = stringKeys(e)
+		}
+	}
+	return v
+}
+
+// parseTOML returns a structure for valid TOML
+func parseTOML(s string) (interface{}, error) {
+	if s == "" {
+		return map[string]interface{}{}, nil
+	}
+
+	var data map[string]interface{}
+	if _, err := toml.Decode(s, &data); err != nil {
+		return nil, errors.Wrap(err, "parseTOML")
+	}
 	return data, nil
 }
+
+// looseString returns the value, of any type (eg. KV values), as a trimmed
+// string for the parse*Or functions. Nil is the empty string.
+func looseString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(v))
+}
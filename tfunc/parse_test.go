@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"github.com/hashicorp/hcat"
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
 )
 
 func TestParseExecute(t *testing.T) {
@@ -90,6 +92,138 @@ func TestParseExecute(t *testing.T) {
 			"map[foo:map[bar:baz baz:7]]",
 			false,
 		},
+		{
+			"parseYAML_toJSON",
+			hcat.TemplateInput{
+				Contents: `{{ "foo:\n  bar: [{baz: 7}]" | parseYAML | toJSON }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			`{"foo":{"bar":[{"baz":7}]}}`,
+			false,
+		},
+		{
+			"parseTOML",
+			hcat.TemplateInput{
+				Contents: `{{ "foo = \"bar\"" | parseTOML }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"map[foo:bar]",
+			false,
+		},
+		{
+			"parseTOMLnested",
+			hcat.TemplateInput{
+				Contents: `{{ with "[foo]\nbar = \"baz\"\nbaz = 7" | parseTOML }}` +
+					`{{ .foo.bar }} {{ .foo.baz }}{{ end }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"baz 7",
+			false,
+		},
+		{
+			"parseTOMLempty",
+			hcat.TemplateInput{
+				Contents: `{{ "" | parseTOML }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"map[]",
+			false,
+		},
+		{
+			"parseTOMLinvalid",
+			hcat.TemplateInput{
+				Contents: `{{ "foo = " | parseTOML }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+		{
+			"parseDuration",
+			hcat.TemplateInput{
+				Contents: `{{ "1m30s" | parseDuration }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"1m30s",
+			false,
+		},
+		{
+			"parseDuration_bad",
+			hcat.TemplateInput{
+				Contents: `{{ "nope" | parseDuration }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+		{
+			"parseIntOr",
+			hcat.TemplateInput{
+				Contents: `{{ parseIntOr "9090" 8080 }} {{ parseIntOr "" 8080 }} {{ parseIntOr "x" 8080 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"9090 8080 8080",
+			false,
+		},
+		{
+			"parseIntOr_kv",
+			hcat.TemplateInput{
+				Contents: `{{ parseIntOr (key "port") 8080 }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, _ := idep.NewKVGetQuery("port")
+				st.Save(d.ID(), dep.KvValue(" 9090 "))
+				return fakeWatcher{st}
+			}(),
+			"9090",
+			false,
+		},
+		{
+			"parseUintOr",
+			hcat.TemplateInput{
+				Contents: `{{ parseUintOr "1" 2 }} {{ parseUintOr "-1" 2 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"1 2",
+			false,
+		},
+		{
+			"parseFloatOr",
+			hcat.TemplateInput{
+				Contents: `{{ parseFloatOr "1.5" 0.5 }} {{ parseFloatOr "" 0.5 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"1.5 0.5",
+			false,
+		},
+		{
+			"parseBoolOr",
+			hcat.TemplateInput{
+				Contents: `{{ parseBoolOr "false" true }} {{ parseBoolOr "" true }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"false true",
+			false,
+		},
+		{
+			"parseDurationOr",
+			hcat.TemplateInput{
+				Contents: `{{ parseDurationOr "1m" "5s" }} {{ parseDurationOr "" "5s" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"1m0s 5s",
+			false,
+		},
+		{
+			"parseDurationOr_bad_default",
+			hcat.TemplateInput{
+				Contents: `{{ parseDurationOr "" "nope" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
 	}
 
 	for i, tc := range cases {
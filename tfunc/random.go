@@ -0,0 +1,99 @@
+package tfunc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	mrand "math/rand"
+)
+
+// alphaNum are the characters of randAlphaNum's strings
+const alphaNum = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// maxRandLength caps the length of randAlphaNum's strings
+const maxRandLength = 4096
+
+// uuidv4 returns a random (version 4) UUID. It differs on every render, see
+// SeededRandom for stable ones.
+//
+// Template: {{ uuidv4 }}
+func uuidv4() (string, error) {
+	return newUUID(rand.Reader)
+}
+
+// randAlphaNum returns a random string of n letters and digits. It differs on
+// every render, see SeededRandom for stable ones.
+//
+// Template: {{ randAlphaNum 16 }}
+func randAlphaNum(n int) (string, error) {
+	return newAlphaNum(rand.Reader, n)
+}
+
+// seededUUIDv4Func is uuidv4 generating the UUIDs from a seed derived from the
+// template's ID, they are the same on every render of the template.
+func seededUUIDv4Func(id string) interface{} {
+	r := seededReader(id, "uuidv4")
+	return func() (string, error) {
+		return newUUID(r)
+	}
+}
+
+// seededRandAlphaNumFunc is randAlphaNum generating the strings from a seed
+// derived from the template's ID, they are the same on every render of the
+// template.
+func seededRandAlphaNumFunc(id string) interface{} {
+	r := seededReader(id, "randAlphaNum")
+	return func(n int) (string, error) {
+		return newAlphaNum(r, n)
+	}
+}
+
+// seededReader returns a pseudo-random source seeded with the hash of the
+// template's ID and the function's name. Each render gets a new one, so the
+// nth call of the function returns the same value in every render.
+func seededReader(id, name string) io.Reader {
+	sum := sha256.Sum256([]byte(id + "\x00" + name))
+	seed := int64(binary.BigEndian.Uint64(sum[:8]))
+	return mrand.New(mrand.NewSource(seed))
+}
+
+// newUUID returns a version 4 UUID read from the source.
+func newUUID(r io.Reader) (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return "", fmt.Errorf("uuidv4: %s", err)
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10],
+		b[10:16]), nil
+}
+
+// newAlphaNum returns a string of n letters and digits read from the source.
+func newAlphaNum(r io.Reader, n int) (string, error) {
+	if n < 0 || n > maxRandLength {
+		return "", fmt.Errorf("randAlphaNum: length must be 0-%d, got %d",
+			maxRandLength, n)
+	}
+	out := make([]byte, 0, n)
+	buf := make([]byte, n+n/4+1)
+	for len(out) < n {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", fmt.Errorf("randAlphaNum: %s", err)
+		}
+		for _, c := range buf {
+			// drop the bytes past the largest multiple of the alphabet's
+			// size, so every character is equally likely
+			if int(c) >= 256-256%len(alphaNum) {
+				continue
+			}
+			out = append(out, alphaNum[int(c)%len(alphaNum)])
+			if len(out) == n {
+				break
+			}
+		}
+	}
+	return string(out), nil
+}
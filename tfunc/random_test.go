@@ -0,0 +1,77 @@
+package tfunc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcat"
+)
+
+var uuidRe = regexp.MustCompile(
+	`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRandom(t *testing.T) {
+	t.Parallel()
+
+	t.Run("uuidv4", func(t *testing.T) {
+		a, err := uuidv4()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := uuidv4()
+		if !uuidRe.MatchString(a) || a == b {
+			t.Errorf("bad uuids: %q, %q", a, b)
+		}
+	})
+
+	t.Run("randAlphaNum", func(t *testing.T) {
+		for _, n := range []int{0, 1, 16, 300} {
+			s, err := randAlphaNum(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(s) != n || strings.Trim(s, alphaNum) != "" {
+				t.Errorf("bad string of %d: %q", n, s)
+			}
+		}
+		for _, n := range []int{-1, maxRandLength + 1} {
+			if _, err := randAlphaNum(n); err == nil {
+				t.Errorf("expected error for %d", n)
+			}
+		}
+	})
+}
+
+func TestSeededRandomExecute(t *testing.T) {
+	t.Parallel()
+
+	contents := `{{ uuidv4 }} {{ uuidv4 }} {{ randAlphaNum 8 }}`
+	execute := func(name string) []string {
+		tpl := newTemplate(hcat.TemplateInput{
+			Contents:     contents,
+			Name:         name,
+			FuncMapMerge: SeededRandom(),
+		})
+		a, err := tpl.Execute(fakeWatcher{hcat.NewStore()}.Recaller(tpl))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Fields(string(a))
+	}
+
+	first, again := execute("foo"), execute("foo")
+	if fmt.Sprint(first) != fmt.Sprint(again) {
+		t.Errorf("not stable across renders: %v, %v", first, again)
+	}
+	if !uuidRe.MatchString(first[0]) || first[0] == first[1] {
+		t.Errorf("bad uuids: %v", first)
+	}
+	if len(first[2]) != 8 {
+		t.Errorf("bad string: %q", first[2])
+	}
+	if other := execute("bar"); fmt.Sprint(first) == fmt.Sprint(other) {
+		t.Errorf("same values for another template: %v", other)
+	}
+}
@@ -3,6 +3,7 @@ package tfunc
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -28,6 +29,22 @@ func indent(spaces int, s string) (string, error) {
 	return string(output[:size]), nil
 }
 
+// nindent is indent with a leading newline, for indenting a block under the
+// key on the current line, eg. YAML `key: {{ . | nindent 2 }}`.
+func nindent(spaces int, s string) (string, error) {
+	out, err := indent(spaces, s)
+	if err != nil {
+		return "", err
+	}
+	return "\n" + out, nil
+}
+
+// quote wraps the string in double quotes, escaping it using Go escape
+// sequences. Useful for YAML and HCL values that need to be strings.
+func quote(s string) (string, error) {
+	return strconv.Quote(s), nil
+}
+
 // join is a version of strings.Join that can be piped
 func join(sep string, a []string) (string, error) {
 	return strings.Join(a, sep), nil
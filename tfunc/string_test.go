@@ -45,6 +45,42 @@ func TestStringExecute(t *testing.T) {
 			"hello\nhello\r\nHELLO\r\nhello\nHELLO",
 			false,
 		},
+		{
+			"indentN",
+			hcat.TemplateInput{
+				Contents: `{{ "a\nb" | indentN 2 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"  a\n  b",
+			false,
+		},
+		{
+			"nindent",
+			hcat.TemplateInput{
+				Contents: `key:{{ "a: 1\nb: 2" | nindent 2 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"key:\n  a: 1\n  b: 2",
+			false,
+		},
+		{
+			"nindent_negative",
+			hcat.TemplateInput{
+				Contents: `{{ "a" | nindent -2 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+		{
+			"quote",
+			hcat.TemplateInput{
+				Contents: `{{ "say \"hi\"\n" | quote }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			`"say \"hi\"\n"`,
+			false,
+		},
 		{
 			"join",
 			hcat.TemplateInput{
@@ -75,7 +111,7 @@ func TestStringExecute(t *testing.T) {
 		{
 			"replaceAll",
 			hcat.TemplateInput{
-				Contents: `{{ "hello my hello" | regexReplaceAll "hello" "bye" }}`,
+				Contents: `{{ "hello my hello" | replaceAll "hello" "bye" }}`,
 			},
 			fakeWatcher{hcat.NewStore()},
 			"bye my bye",
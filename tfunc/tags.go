@@ -0,0 +1,187 @@
+package tfunc
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/hcat/dep"
+)
+
+// serviceTags returns the tags of the value, a dep.ServiceTags or []string,
+// or the service (or catalog entry) with the tags.
+func serviceTags(name string, v interface{}) (dep.ServiceTags, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case dep.ServiceTags:
+		return v, nil
+	case []string:
+		return dep.ServiceTags(v), nil
+	case *dep.HealthService:
+		return v.Tags, nil
+	case *dep.CatalogSnippet:
+		return v.Tags, nil
+	case *dep.CatalogNodeService:
+		return v.Tags, nil
+	default:
+		return nil, fmt.Errorf("%s: wrong argument type %T", name, v)
+	}
+}
+
+// tagsContainAll returns true if the tags contain all of the given tags. The
+// tags can be given as the service itself.
+//
+// Template: {{ range service "web" }}{{ if tagsContainAll . "prod" "v2" }}...
+func tagsContainAll(v interface{}, tags ...string) (bool, error) {
+	st, err := serviceTags("tagsContainAll", v)
+	if err != nil {
+		return false, err
+	}
+	for _, t := range tags {
+		if !st.Contains(t) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// tagsContainAny returns true if the tags contain any of the given tags. The
+// tags can be given as the service itself.
+//
+// Template: {{ range service "web" }}{{ if tagsContainAny . "v1" "v2" }}...
+func tagsContainAny(v interface{}, tags ...string) (bool, error) {
+	st, err := serviceTags("tagsContainAny", v)
+	if err != nil {
+		return false, err
+	}
+	for _, t := range tags {
+		if st.Contains(t) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// tagExpr returns the result of the boolean expression over the tags, where
+// each tag in it is true if the tags contain it. Tags are combined with "&&"
+// (or "and"), "||" (or "or"), negated with "!" (or "not") and grouped with
+// parentheses. "!" binds tightest, then "&&" and "||". The tags can be given
+// as the service itself.
+//
+// Template: {{ range service "web" }}{{ if tagExpr . "prod && !(canary || v1)" }}...
+func tagExpr(v interface{}, expr string) (bool, error) {
+	st, err := serviceTags("tagExpr", v)
+	if err != nil {
+		return false, err
+	}
+	p := &tagExprParser{tokens: tagExprTokens(expr), tags: st}
+	result, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	if err != nil {
+		return false, fmt.Errorf("tagExpr: invalid expression %q: %s", expr,
+			err)
+	}
+	return result, nil
+}
+
+// tagExprTokens splits the expression into its tokens, the operators,
+// parentheses and tags.
+func tagExprTokens(expr string) []string {
+	var tokens []string
+	var tag strings.Builder
+	flush := func() {
+		if tag.Len() > 0 {
+			tokens = append(tokens, tag.String())
+			tag.Reset()
+		}
+	}
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			flush()
+		case c == '(' || c == ')' || c == '!':
+			flush()
+			tokens = append(tokens, string(c))
+		case (c == '&' || c == '|') && i+1 < len(expr) && expr[i+1] == c:
+			flush()
+			tokens = append(tokens, expr[i:i+2])
+			i++
+		default:
+			tag.WriteByte(c)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// tagExprParser evaluates the tokens of a tag expression with recursive
+// descent, one method per precedence level.
+type tagExprParser struct {
+	tokens []string
+	pos    int
+	tags   dep.ServiceTags
+}
+
+// next returns the next token (empty at the end) without consuming it.
+func (p *tagExprParser) next() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// or: and { ("||" | "or") and }
+func (p *tagExprParser) or() (bool, error) {
+	result, err := p.and()
+	for err == nil && (p.next() == "||" || p.next() == "or") {
+		p.pos++
+		var r bool
+		r, err = p.and()
+		result = result || r
+	}
+	return result, err
+}
+
+// and: not { ("&&" | "and") not }
+func (p *tagExprParser) and() (bool, error) {
+	result, err := p.not()
+	for err == nil && (p.next() == "&&" || p.next() == "and") {
+		p.pos++
+		var r bool
+		r, err = p.not()
+		result = result && r
+	}
+	return result, err
+}
+
+// not: ("!" | "not") not | "(" or ")" | tag
+func (p *tagExprParser) not() (bool, error) {
+	switch tok := p.next(); tok {
+	case "":
+		return false, fmt.Errorf("unexpected end")
+	case "!", "not":
+		p.pos++
+		result, err := p.not()
+		return !result, err
+	case "(":
+		p.pos++
+		result, err := p.or()
+		if err != nil {
+			return false, err
+		}
+		if p.next() != ")" {
+			return false, fmt.Errorf("missing \")\"")
+		}
+		p.pos++
+		return result, nil
+	case ")", "&&", "||", "and", "or":
+		return false, fmt.Errorf("unexpected %q", tok)
+	default:
+		p.pos++
+		return p.tags.Contains(tok), nil
+	}
+}
@@ -0,0 +1,130 @@
+package tfunc
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/hcat"
+	"github.com/hashicorp/hcat/dep"
+)
+
+func TestTagExpr(t *testing.T) {
+	t.Parallel()
+
+	tags := dep.ServiceTags{"prod", "v2", "env=eu"}
+	cases := []struct {
+		expr string
+		exp  bool
+		err  bool
+	}{
+		{"prod", true, false},
+		{"canary", false, false},
+		{"prod && v2", true, false},
+		{"prod and canary", false, false},
+		{"canary || v2", true, false},
+		{"!canary", true, false},
+		{"not prod", false, false},
+		{"prod && !(canary || v1)", true, false},
+		{"canary || prod && v2", true, false},
+		{"(canary || prod) && v1", false, false},
+		{"env=eu&&!v1", true, false},
+		{"", false, true},
+		{"prod &&", false, true},
+		{"(prod", false, true},
+		{"prod)", false, true},
+		{"prod v2", false, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.expr, func(t *testing.T) {
+			act, err := tagExpr(tags, tc.expr)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if act != tc.exp {
+				t.Errorf("exp: %v, act: %v", tc.exp, act)
+			}
+		})
+	}
+
+	if _, err := tagExpr("prod", "prod"); err == nil {
+		t.Error("expected wrong type error")
+	}
+}
+
+func TestTagsExecute(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		ti   hcat.TemplateInput
+		i    hcat.Watcherer
+		e    string
+		err  bool
+	}{
+		{
+			"helper_tagsContainAll",
+			hcat.TemplateInput{
+				Contents: `{{ range service "web" }}` +
+					`{{ if tagsContainAll . "prod" "v2" }}{{ .ID }}{{ end }}{{ end }}`,
+			},
+			tagServices(),
+			"bc",
+			false,
+		},
+		{
+			"helper_tagsContainAny",
+			hcat.TemplateInput{
+				Contents: `{{ range service "web" }}` +
+					`{{ if tagsContainAny .Tags "v1" "v2" }}{{ .ID }}{{ end }}{{ end }}`,
+			},
+			tagServices(),
+			"abc",
+			false,
+		},
+		{
+			"helper_tagExpr",
+			hcat.TemplateInput{
+				Contents: `{{ range service "web" }}` +
+					`{{ if tagExpr . "prod && !canary" }}{{ .ID }}{{ end }}{{ end }}`,
+			},
+			tagServices(),
+			"ab",
+			false,
+		},
+		{
+			"helper_tagExpr_invalid",
+			hcat.TemplateInput{
+				Contents: `{{ range service "web" }}` +
+					`{{ if tagExpr . "prod &&" }}{{ .ID }}{{ end }}{{ end }}`,
+			},
+			tagServices(),
+			"",
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			tpl := newTemplate(tc.ti)
+			a, err := tpl.Execute(tc.i.Recaller(tpl))
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if !bytes.Equal([]byte(tc.e), a) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.e, string(a))
+			}
+		})
+	}
+}
+
+// tagServices returns a watcher with the "web" service's instances
+func tagServices() hcat.Watcherer {
+	st := hcat.NewStore()
+	st.Save(testHealthServiceQueryID("web"), []*dep.HealthService{
+		{ID: "a", Tags: dep.ServiceTags{"prod", "v1"}},
+		{ID: "b", Tags: dep.ServiceTags{"prod", "v2"}},
+		{ID: "c", Tags: dep.ServiceTags{"prod", "v2", "canary"}},
+	})
+	return fakeWatcher{st}
+}
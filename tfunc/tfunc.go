@@ -3,6 +3,8 @@ package tfunc
 import (
 	"os"
 	"text/template"
+
+	"github.com/hashicorp/hcat"
 )
 
 // AllUnversioned available template functions
@@ -21,21 +23,34 @@ func AllUnversioned() template.FuncMap {
 // ConsulV0 is a set of template functions for querying Consul endpoints.
 func ConsulV0() template.FuncMap {
 	return template.FuncMap{
-		"datacenters":  datacentersFunc,
-		"key":          keyFunc,
-		"keyExists":    keyExistsFunc,
-		"keyOrDefault": keyWithDefaultFunc,
-		"ls":           lsFunc(true),
-		"safeLs":       safeLsFunc,
-		"node":         nodeFunc,
-		"nodes":        nodesFunc,
-		"service":      serviceFunc,
-		"connect":      connectFunc,
-		"services":     servicesFunc,
-		"tree":         treeFunc(true),
-		"safeTree":     safeTreeFunc,
-		"caRoots":      connectCARootsFunc,
-		"caLeaf":       connectLeafFunc,
+		"datacenters":         datacentersFunc,
+		"key":                 keyFunc,
+		"keyExists":           keyExistsFunc,
+		"keyOrDefault":        keyWithDefaultFunc,
+		"mustKey":             mustKeyFunc,
+		"keyChunked":          keyChunkedFunc,
+		"ls":                  lsFunc(true),
+		"safeLs":              safeLsFunc,
+		"node":                nodeFunc,
+		"nodes":               nodesFunc,
+		"service":             serviceFunc,
+		"mustService":         mustServiceFunc,
+		"serviceWithFallback": serviceWithFallbackFunc,
+		"connect":             connectFunc,
+		"services":            servicesFunc,
+		"servicesByNodeMeta":  servicesByNodeMetaFunc,
+		"tree":                treeFunc(true),
+		"safeTree":            safeTreeFunc,
+		"caRoots":             connectCARootsFunc,
+		"caLeaf":              connectLeafFunc,
+		"coordinates":         coordinatesFunc,
+		"rtt":                 rttFunc,
+		"autopilot":           autopilotHealthFunc,
+		"license":             licenseFunc,
+		"namespaces":          namespacesFunc,
+		"partitions":          partitionsFunc,
+		"exportedServices":    exportedServicesFunc,
+		"consulRaw":           consulRawFunc,
 	}
 }
 
@@ -51,6 +66,10 @@ func ConsulV1() template.FuncMap {
 		"key":          v1KVGetFunc,
 		"keyExists":    v1KVExistsFunc,
 		"keyExistsGet": v1KVExistsGetFunc,
+		"keyMeta":      v1KVMetaFunc,
+		"keysTxn":      v1KVTxnFunc,
+		"serviceFrom":  v1ServiceFromFunc,
+		"keyFrom":      v1KVGetFromFunc,
 
 		// Set of Consul functions that are not yet implemented for v1. These
 		// intentionally error instead of defaulting to the v0 implementations
@@ -63,17 +82,49 @@ func ConsulV1() template.FuncMap {
 // ConsulFilters provides functions to filter consul results
 func ConsulFilters() template.FuncMap {
 	return template.FuncMap{
-		"byKey":  byKey,
-		"byTag":  byTag,
-		"byMeta": byMeta,
+		"byKey":         byKey,
+		"byTag":         byTag,
+		"byMeta":        byMeta,
+		"byWeight":      byWeight,
+		"nonZeroWeight": nonZeroWeight,
+		"addressFor":    addressFor,
+		"byLocality":    byLocality,
+		"portFor":       portFor,
+		"srvRecords":    srvRecords,
+		"byDrain":       byDrain,
+
+		// service tags
+		"tagsContainAll": tagsContainAll,
+		"tagsContainAny": tagsContainAny,
+		"tagExpr":        tagExpr,
+
+		// load balancer configuration
+		"toHAProxyServers": toHAProxyServers,
+		"toNginxUpstream":  toNginxUpstream,
 	}
 }
 
 // VaultV0 querying functions
 func VaultV0() template.FuncMap {
 	return template.FuncMap{
-		"secret":  secretFunc,
-		"secrets": secretsFunc,
+		"secret":         secretFunc,
+		"secretFrom":     secretFromFunc,
+		"mustSecret":     mustSecretFunc,
+		"secrets":        secretsFunc,
+		"secretBatch":    secretBatchFunc,
+		"secretMap":      secretMapFunc,
+		"sshSign":        sshSignFunc,
+		"sshOTP":         sshOTPFunc,
+		"awsCreds":       awsCredsFunc,
+		"gcpToken":       gcpTokenFunc,
+		"azureCreds":     azureCredsFunc,
+		"pkiCAChain":     pkiCAChainFunc,
+		"pkiCRL":         pkiCRLFunc,
+		"pkiIssue":       pkiIssueFunc,
+		"pkiSign":        pkiSignFunc,
+		"totp":           totpFunc,
+		"identityEntity": identityEntityFunc,
+		"identityGroup":  identityGroupFunc,
 	}
 }
 
@@ -85,6 +136,29 @@ func Env() template.FuncMap {
 	}
 }
 
+// EnvV1 is a set of environment variable functions that are dependencies,
+// polling the variables and re-rendering the template when they change. Merge
+// it after Env to replace its static env function, eg. when a supervisor
+// rewrites the process' env file.
+func EnvV1() template.FuncMap {
+	return template.FuncMap{
+		"env":     envWatchFunc,
+		"envFile": envFileFunc,
+	}
+}
+
+// SeededRandom is a set of random functions that return the same values on
+// every render of a template, so embedding generated identifiers doesn't
+// change the output (and re-render) each time. Merge it after Helpers to
+// replace its random uuidv4 and randAlphaNum functions. The values are derived
+// from the template's ID, they aren't secret and change with its contents.
+func SeededRandom() template.FuncMap {
+	return template.FuncMap{
+		"uuidv4":       hcat.TemplateIDFunc(seededUUIDv4Func),
+		"randAlphaNum": hcat.TemplateIDFunc(seededRandAlphaNumFunc),
+	}
+}
+
 // Control flow functions
 func Control() template.FuncMap {
 	return template.FuncMap{
@@ -108,6 +182,15 @@ func Math() template.FuncMap {
 		"modulo":   modulo,
 		"minimum":  minimum,
 		"maximum":  maximum,
+		"round":    round,
+
+		// short aliases
+		"sub": subtract,
+		"mul": multiply,
+		"div": divide,
+		"mod": modulo,
+		"min": minimum,
+		"max": maximum,
 	}
 }
 
@@ -115,18 +198,28 @@ func Math() template.FuncMap {
 func Helpers() template.FuncMap {
 	return template.FuncMap{
 		// Parsing
-		"parseBool":  parseBool,
-		"parseFloat": parseFloat,
-		"parseInt":   parseInt,
-		"parseJSON":  parseJSON,
-		"parseUint":  parseUint,
-		"parseYAML":  parseYAML,
+		"parseBool":       parseBool,
+		"parseBoolOr":     parseBoolOr,
+		"parseDuration":   parseDuration,
+		"parseDurationOr": parseDurationOr,
+		"parseFloat":      parseFloat,
+		"parseFloatOr":    parseFloatOr,
+		"parseInt":        parseInt,
+		"parseIntOr":      parseIntOr,
+		"parseJSON":       parseJSON,
+		"parseTOML":       parseTOML,
+		"parseUint":       parseUint,
+		"parseUintOr":     parseUintOr,
+		"parseYAML":       parseYAML,
 		// ToSomething
 		"toLower":               toLower,
 		"toUpper":               toUpper,
 		"toTitle":               toTitle,
 		"toJSON":                toJSON,
 		"toJSONPretty":          toJSONPretty,
+		"toJSONIndentN":         toJSONIndentN,
+		"toCanonicalJSON":       toCanonicalJSON,
+		"toCanonicalJSONPretty": toCanonicalJSONPretty,
 		"toUnescapedJSON":       toUnescapedJSON,
 		"toUnescapedJSONPretty": toUnescapedJSONPretty,
 		"toTOML":                toTOML,
@@ -137,23 +230,38 @@ func Helpers() template.FuncMap {
 		"base64URLDecode": base64URLDecode,
 		"base64URLEncode": base64URLEncode,
 		"sha256Hex":       sha256Hex,
+		"hmacSHA256":      hmacSHA256,
+		"bcrypt":          bcryptHash,
 		"md5sum":          md5sum,
 		// String
 		"join":            join,
 		"split":           split,
 		"trimSpace":       trimSpace,
 		"indent":          indent,
+		"indentN":         indent,
+		"nindent":         nindent,
+		"quote":           quote,
 		"replaceAll":      replaceAll,
 		"regexReplaceAll": regexReplaceAll,
 		"regexMatch":      regexMatch,
 		// Data type (map, slice, etc) oriented
 		"explode":              explode,
 		"explodeMap":           explodeMap,
+		"implode":              implode,
 		"mergeMap":             mergeMap,
 		"mergeMapWithOverride": mergeMapWithOverride,
+		"merge":                merge,
+		"mergeDeep":            mergeDeep,
 		// Misc/Other
-		"timestamp":   timestamp,
-		"sockaddr":    sockaddr,
-		"writeToFile": writeToFile,
+		"timestamp":    timestamp,
+		"every":        everyFunc,
+		"cron":         cronFunc,
+		"sockaddr":     sockaddr,
+		"uuidv4":       uuidv4,
+		"randAlphaNum": randAlphaNum,
+		"writeToFile":  writeToFile,
+		// Dependency status
+		"lastError":        lastErrorFunc,
+		"dependencyStatus": dependencyStatusFunc,
 	}
 }
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"strconv"
 	"time"
+
+	"github.com/hashicorp/hcat"
+	idep "github.com/hashicorp/hcat/internal/dependency"
 )
 
 // now is function that represents the current time in UTC. This is here
@@ -26,3 +29,29 @@ func timestamp(s ...string) (string, error) {
 			"expected 0 or 1, but got %d", len(s))
 	}
 }
+
+// everyFunc registers a timer dependency that fires at the interval (eg.
+// "5m"), re-rendering the template periodically. It outputs nothing.
+func everyFunc(recall hcat.Recaller) interface{} {
+	return func(s string) (string, error) {
+		d, err := idep.NewEveryQuery(s)
+		if err != nil {
+			return "", err
+		}
+		recall(d)
+		return "", nil
+	}
+}
+
+// cronFunc registers a timer dependency that fires on the cron schedule (eg.
+// "0 * * * *"), re-rendering the template on it. It outputs nothing.
+func cronFunc(recall hcat.Recaller) interface{} {
+	return func(s string) (string, error) {
+		d, err := idep.NewCronQuery(s)
+		if err != nil {
+			return "", err
+		}
+		recall(d)
+		return "", nil
+	}
+}
@@ -40,6 +40,42 @@ func TestTimeExecute(t *testing.T) {
 			"1970-01-01",
 			false,
 		},
+		{
+			"every",
+			hcat.TemplateInput{
+				Contents: `{{ every "5m" }}{{ timestamp "2006" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"1970",
+			false,
+		},
+		{
+			"every_bad_interval",
+			hcat.TemplateInput{
+				Contents: `{{ every "often" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+		{
+			"cron",
+			hcat.TemplateInput{
+				Contents: `{{ cron "0 * * * *" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			false,
+		},
+		{
+			"cron_bad_schedule",
+			hcat.TemplateInput{
+				Contents: `{{ cron "0 * * *" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
 	}
 
 	for i, tc := range cases {
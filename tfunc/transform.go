@@ -2,6 +2,8 @@ package tfunc
 
 import (
 	"bytes"
+	"container/list"
+	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha256"
 	"encoding/base64"
@@ -10,9 +12,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"strings"
+	"sync"
 
 	"github.com/BurntSushi/toml"
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -58,6 +62,96 @@ func md5sum(item string) string {
 	return fmt.Sprintf("%x", md5.Sum([]byte(item)))
 }
 
+// hmacSHA256 returns the hex encoded HMAC-SHA256 of the message with the key,
+// eg. to derive a cache key from a secret without rendering the secret.
+//
+// Template: {{ "message" | hmacSHA256 (secret "secret/key").Data.value }}
+func hmacSHA256(key, message string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(message))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bcryptCacheSize is the number of bcrypt hashes cached, the least recently
+// used are dropped.
+const bcryptCacheSize = 1024
+
+// bcryptHashes caches the bcrypt hashes by the SHA-256 of the password, so
+// re-rendering a template doesn't change the output (bcrypt salts each hash
+// randomly) and trigger a render every time.
+var bcryptHashes = newBcryptCache(bcryptCacheSize)
+
+// bcryptCache is a bounded LRU cache of bcrypt hashes.
+type bcryptCache struct {
+	sync.Mutex
+	size    int
+	lru     *list.List // *bcryptEntry, most recently used at the front
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+type bcryptEntry struct {
+	sum  [sha256.Size]byte
+	hash string
+}
+
+func newBcryptCache(size int) *bcryptCache {
+	return &bcryptCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// get returns the cached hash of the password's sum, marking it used.
+func (c *bcryptCache) get(sum [sha256.Size]byte) (string, bool) {
+	c.Lock()
+	defer c.Unlock()
+	e, ok := c.entries[sum]
+	if !ok {
+		return "", false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*bcryptEntry).hash, true
+}
+
+// add caches the hash of the password's sum, dropping the least recently
+// used hashes over the size. Returns the cached hash, which is the one
+// already cached if the same password was hashed concurrently.
+func (c *bcryptCache) add(sum [sha256.Size]byte, hash string) string {
+	c.Lock()
+	defer c.Unlock()
+	if e, ok := c.entries[sum]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*bcryptEntry).hash
+	}
+	c.entries[sum] = c.lru.PushFront(&bcryptEntry{sum: sum, hash: hash})
+	for c.lru.Len() > c.size {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.entries, e.Value.(*bcryptEntry).sum)
+	}
+	return hash
+}
+
+// bcryptHash returns the bcrypt hash of the password, eg. for a basic-auth
+// htpasswd entry. The same password returns the same hash while it is cached,
+// the last bcryptCacheSize passwords hashed are.
+//
+// Template: admin:{{ (secret "secret/admin").Data.password | bcrypt }}
+func bcryptHash(password string) (string, error) {
+	sum := sha256.Sum256([]byte(password))
+	if hash, ok := bcryptHashes.get(sum); ok {
+		return hash, nil
+	}
+	// hashing is slow by design, don't hold up the other templates' lookups
+	hash, err := bcrypt.GenerateFromPassword([]byte(password),
+		bcrypt.DefaultCost)
+	if err != nil {
+		return "", errors.Wrap(err, "bcrypt")
+	}
+	return bcryptHashes.add(sum, string(hash)), nil
+}
+
 // toLower converts the given string (usually by a pipe) to lowercase.
 func toLower(s string) (string, error) {
 	return strings.ToLower(s), nil
@@ -92,6 +186,63 @@ func toJSONPretty(i interface{}) (string, error) {
 	return string(bytes.TrimSpace(result)), err
 }
 
+// toJSONIndentN converts the given structure into a deeply nested JSON string
+// indented by the given number of spaces.
+func toJSONIndentN(spaces int, i interface{}) (string, error) {
+	if spaces < 0 {
+		return "", fmt.Errorf("toJSONIndentN: indent must be non-negative")
+	}
+	result, err := json.MarshalIndent(i, "", strings.Repeat(" ", spaces))
+	if err != nil {
+		return "", errors.Wrap(err, "toJSONIndentN")
+	}
+	return string(bytes.TrimSpace(result)), err
+}
+
+// toCanonicalJSON converts the given structure into a JSON string with all
+// object keys sorted, including those from structs, so the output is stable
+// between runs.
+func toCanonicalJSON(i interface{}) (string, error) {
+	result, err := canonicalJSON(i, "")
+	if err != nil {
+		return "", errors.Wrap(err, "toCanonicalJSON")
+	}
+	return result, nil
+}
+
+// toCanonicalJSONPretty is toCanonicalJSON with pretty printing.
+func toCanonicalJSONPretty(i interface{}) (string, error) {
+	result, err := canonicalJSON(i, "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "toCanonicalJSONPretty")
+	}
+	return result, nil
+}
+
+// canonicalJSON round trips the value through a generic interface{} so all
+// objects are maps, which encoding/json always outputs with sorted keys.
+func canonicalJSON(i interface{}, indent string) (string, error) {
+	b, err := json.Marshal(i)
+	if err != nil {
+		return "", err
+	}
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber() // don't lose precision on large numbers
+	if err := dec.Decode(&generic); err != nil {
+		return "", err
+	}
+	if indent != "" {
+		b, err = json.MarshalIndent(generic, "", indent)
+	} else {
+		b, err = json.Marshal(generic)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(b)), nil
+}
+
 // toUnescapedJSON converts the given structure into a deeply nested JSON
 // string without HTML escaping.
 func toUnescapedJSON(i interface{}) (string, error) {
@@ -2,11 +2,13 @@ package tfunc
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"testing"
 
 	"github.com/hashicorp/hcat"
 	"github.com/hashicorp/hcat/dep"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestTransformExecute(t *testing.T) {
@@ -82,6 +84,15 @@ func TestTransformExecute(t *testing.T) {
 			"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
 			false,
 		},
+		{
+			"func_hmacSHA256",
+			hcat.TemplateInput{
+				Contents: `{{ "The quick brown fox jumps over the lazy dog" | hmacSHA256 "key" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+			false,
+		},
 		{
 			"func_md5sum",
 			hcat.TemplateInput{
@@ -109,6 +120,49 @@ func TestTransformExecute(t *testing.T) {
 			"[\n  \"a\",\n  \"b\",\n  \"c\"\n]",
 			false,
 		},
+		{
+			"helper_toJSONIndentN",
+			hcat.TemplateInput{
+				Contents: `{{ "a,b" | split "," | toJSONIndentN 4 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"[\n    \"a\",\n    \"b\"\n]",
+			false,
+		},
+		{
+			"helper_toJSONIndentN_negative",
+			hcat.TemplateInput{
+				Contents: `{{ "a,b" | split "," | toJSONIndentN -1 }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+		{
+			"helper_toCanonicalJSON",
+			hcat.TemplateInput{
+				Contents:     `{{ with keyExistsGet "foo" }}{{ . | toCanonicalJSON }}{{ end }}`,
+				FuncMapMerge: ConsulV1(),
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				st.Save("kv.exists.get(foo)", &dep.KeyPair{
+					Key: "foo", Value: "bar", Exists: true, ModifyIndex: 12,
+				})
+				return fakeWatcher{st}
+			}(),
+			`{"CreateIndex":0,"Exists":true,"Flags":0,"Key":"foo","LockIndex":0,"ModifyIndex":12,"Path":"","Session":"","Value":"bar"}`,
+			false,
+		},
+		{
+			"helper_toCanonicalJSONPretty",
+			hcat.TemplateInput{
+				Contents: `{{ parseJSON "{\"b\": 1, \"a\": {\"d\": 2, \"c\": 3}}" | toCanonicalJSONPretty }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"{\n  \"a\": {\n    \"c\": 3,\n    \"d\": 2\n  },\n  \"b\": 1\n}",
+			false,
+		},
 		{
 			"helper_toUnescapedJSON",
 			hcat.TemplateInput{
@@ -197,3 +251,58 @@ func TestTransformExecute(t *testing.T) {
 		})
 	}
 }
+
+func TestBcryptHash(t *testing.T) {
+	t.Parallel()
+
+	hash, err := bcryptHash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash),
+		[]byte("hunter2")); err != nil {
+		t.Errorf("bad hash %q: %v", hash, err)
+	}
+
+	// the same password hashes the same so renders don't change
+	again, err := bcryptHash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != hash {
+		t.Errorf("hash changed: %q != %q", again, hash)
+	}
+	other, err := bcryptHash("hunter3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == hash {
+		t.Error("different passwords hashed the same")
+	}
+}
+
+func TestBcryptCache(t *testing.T) {
+	t.Parallel()
+
+	c := newBcryptCache(2)
+	sum := func(s string) [sha256.Size]byte { return sha256.Sum256([]byte(s)) }
+	c.add(sum("a"), "hash-a")
+	c.add(sum("b"), "hash-b")
+	if _, ok := c.get(sum("a")); !ok { // a is now the most recently used
+		t.Fatal("a not cached")
+	}
+	c.add(sum("c"), "hash-c")
+	if _, ok := c.get(sum("b")); ok {
+		t.Error("least recently used b not dropped")
+	}
+	for _, s := range []string{"a", "c"} {
+		if hash, ok := c.get(sum(s)); !ok || hash != "hash-"+s {
+			t.Errorf("bad %s: %q, %v", s, hash, ok)
+		}
+	}
+
+	// concurrent hashes of the same password use the first one cached
+	if hash := c.add(sum("a"), "other"); hash != "hash-a" {
+		t.Errorf("hash replaced: %q", hash)
+	}
+}
@@ -1,8 +1,10 @@
 package tfunc
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/hcat"
 	"github.com/hashicorp/hcat/dep"
@@ -16,31 +18,60 @@ func secretFunc(recall hcat.Recaller) interface{} {
 			return nil, nil
 		}
 
-		path, rest := s[0], s[1:]
-		data := make(map[string]interface{})
-		for _, str := range rest {
-			if len(str) == 0 {
-				continue
-			}
-			parts := strings.SplitN(str, "=", 2)
-			if len(parts) != 2 {
-				return nil, fmt.Errorf("not k=v pair %q", str)
-			}
+		d, err := secretDep(s[0], s[1:])
+		if err != nil {
+			return nil, err
+		}
 
-			k, v := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
-			data[k] = v
+		if value, ok := recall(d); ok {
+			return value.(*dep.Secret), nil
 		}
 
-		var d dep.Dependency
-		var err error
+		return nil, nil
+	}
+}
+
+// mustSecretFunc is secretFunc that fails the render if the secret doesn't
+// exist or has no data. Before the secret has been fetched it returns nil.
+func mustSecretFunc(recall hcat.Recaller) interface{} {
+	return func(s ...string) (interface{}, error) {
+		if len(s) == 0 {
+			return nil, fmt.Errorf("mustSecret: missing path")
+		}
+
+		d, err := secretDep(s[0], s[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		value, ok := recall(d)
+		if !ok {
+			return nil, nil
+		}
+		secret, _ := value.(*dep.Secret)
+		if secret == nil || len(secret.Data) == 0 {
+			return nil, fmt.Errorf("mustSecret: secret %q is missing or empty",
+				s[0])
+		}
+		return secret, nil
+	}
+}
 
-		isReadQuery := len(rest) == 0
-		if isReadQuery {
-			d, err = idep.NewVaultReadQuery(path)
-		} else {
-			d, err = idep.NewVaultWriteQuery(path, data)
+// secretFromFunc is secretFunc using the named Vault client, see
+// ClientSet.AddVaultNamed.
+//
+// Template: {{ with secretFrom "vault-eu" "secret/foo" }}{{ .Data.foo }}{{ end }}
+func secretFromFunc(recall hcat.Recaller) interface{} {
+	return func(client string, s ...string) (interface{}, error) {
+		if len(s) == 0 {
+			return nil, nil
 		}
 
+		d, err := secretDep(s[0], s[1:])
+		if err != nil {
+			return nil, err
+		}
+		d, err = idep.NewNamedClientQuery(client, d)
 		if err != nil {
 			return nil, err
 		}
@@ -53,6 +84,21 @@ func secretFunc(recall hcat.Recaller) interface{} {
 	}
 }
 
+// secretDep returns the read dependency for the path, or the write dependency
+// if there are "k=v" data arguments.
+func secretDep(path string, rest []string) (dep.Dependency, error) {
+	data, err := kvPairs(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	isReadQuery := len(rest) == 0
+	if isReadQuery {
+		return idep.NewVaultReadQuery(path)
+	}
+	return idep.NewVaultWriteQuery(path, data)
+}
+
 // secretsFunc returns or accumulates a list of secret dependencies from Vault.
 func secretsFunc(recall hcat.Recaller) interface{} {
 	return func(s string) ([]string, error) {
@@ -75,3 +121,449 @@ func secretsFunc(recall hcat.Recaller) interface{} {
 		return result, nil
 	}
 }
+
+// secretBatchFunc reads the secrets at the paths as one dependency, instead of
+// one per secret, and returns them keyed by path (without surrounding
+// slashes). The secrets are read again together and their leases aren't
+// renewed, so use it for static secrets (eg. KV) and not dynamic credentials.
+//
+// Template: {{ with secretBatch "secret/foo" "secret/bar" }}{{ (index . "secret/foo").Data.zip }}{{ end }}
+func secretBatchFunc(recall hcat.Recaller) interface{} {
+	return func(paths ...string) (map[string]*dep.Secret, error) {
+		if len(paths) == 0 {
+			return nil, nil
+		}
+		return secretBatch(recall, paths)
+	}
+}
+
+// secretMapFunc reads all the secrets under the prefix, found by listing it,
+// with secretBatch. Sub-directories aren't read.
+//
+// Template: {{ range $path, $s := secretMap "secret/app" }}{{ $path }}={{ $s.Data.zip }}{{ end }}
+func secretMapFunc(recall hcat.Recaller) interface{} {
+	return func(prefix string) (map[string]*dep.Secret, error) {
+		prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+		if prefix == "" {
+			return nil, nil
+		}
+
+		d, err := idep.NewVaultListQuery(prefix)
+		if err != nil {
+			return nil, err
+		}
+		value, ok := recall(d)
+		if !ok {
+			return nil, nil
+		}
+
+		var paths []string
+		for _, key := range value.([]string) {
+			if !strings.HasSuffix(key, "/") {
+				paths = append(paths, prefix+"/"+key)
+			}
+		}
+		if len(paths) == 0 {
+			return map[string]*dep.Secret{}, nil
+		}
+		return secretBatch(recall, paths)
+	}
+}
+
+// secretBatch returns the secrets at the paths read as one dependency.
+func secretBatch(recall hcat.Recaller, paths []string) (
+	map[string]*dep.Secret, error) {
+	d, err := idep.NewVaultReadBatchQuery(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := recall(d); ok {
+		return value.(map[string]*dep.Secret), nil
+	}
+
+	return nil, nil
+}
+
+// sshSignFunc signs an SSH public key using Vault's SSH secrets engine. The
+// signed certificate is re-signed before it expires. Extra "k=v" arguments are
+// passed along with the request, "mount=<path>" sets the engine's mount path
+// (defaults to "ssh").
+//
+// Endpoint: /v1/:mount/sign/:role
+// Template: {{ with sshSign "role" "ssh-rsa AAA..." }}{{ .Data.signed_key }}{{ end }}
+func sshSignFunc(recall hcat.Recaller) interface{} {
+	return func(role, pubkey string, rest ...string) (*dep.Secret, error) {
+		if role == "" || pubkey == "" {
+			return nil, nil
+		}
+		data, err := kvPairs(rest)
+		if err != nil {
+			return nil, err
+		}
+		data["public_key"] = pubkey
+		return sshWrite(recall, "sign", role, data)
+	}
+}
+
+// sshOTPFunc fetches a one-time-password for the given IP address from
+// Vault's SSH secrets engine. Extra "k=v" arguments are handled as with
+// sshSign.
+//
+// Endpoint: /v1/:mount/creds/:role
+// Template: {{ with sshOTP "role" "10.0.0.1" }}{{ .Data.key }}{{ end }}
+func sshOTPFunc(recall hcat.Recaller) interface{} {
+	return func(role, ip string, rest ...string) (*dep.Secret, error) {
+		if role == "" || ip == "" {
+			return nil, nil
+		}
+		data, err := kvPairs(rest)
+		if err != nil {
+			return nil, err
+		}
+		data["ip"] = ip
+		return sshWrite(recall, "creds", role, data)
+	}
+}
+
+// sshWrite makes the write request to the SSH secrets engine endpoint.
+func sshWrite(recall hcat.Recaller, endpoint, role string,
+	data map[string]interface{}) (*dep.Secret, error) {
+	mount := "ssh"
+	if m, ok := data["mount"]; ok {
+		mount = strings.Trim(m.(string), "/")
+		delete(data, "mount")
+	}
+
+	path := strings.Join([]string{mount, endpoint, role}, "/")
+	d, err := idep.NewVaultWriteQuery(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := recall(d); ok {
+		return value.(*dep.Secret), nil
+	}
+
+	return nil, nil
+}
+
+// awsCredsFunc returns credentials from Vault's AWS secrets engine. Extra
+// "k=v" arguments (eg. "ttl=15m", "role_arn=...") are passed along with the
+// request, "mount=<path>" sets the engine's mount path (defaults to "aws") and
+// "sts=true" gets temporary STS credentials, with a session token, from the
+// sts endpoint. The credentials are re-fetched before their lease expires.
+//
+// Endpoint: /v1/:mount/creds/:role or /v1/:mount/sts/:role
+// Template: {{ with awsCreds "deploy" }}{{ .AccessKey }}:{{ .SecretKey }}{{ end }}
+func awsCredsFunc(recall hcat.Recaller) interface{} {
+	return func(role string, rest ...string) (*dep.AWSCredentials, error) {
+		if role == "" {
+			return nil, nil
+		}
+		data, err := kvPairs(rest)
+		if err != nil {
+			return nil, err
+		}
+		endpoint := "creds"
+		if sts, ok := data["sts"]; ok {
+			if sts == "true" {
+				endpoint = "sts"
+			}
+			delete(data, "sts")
+		}
+
+		s, err := cloudCreds(recall, "aws", endpoint+"/"+role, data)
+		if s == nil || err != nil {
+			return nil, err
+		}
+		sessionToken := secretString(s, "security_token")
+		if sessionToken == "" {
+			sessionToken = secretString(s, "session_token")
+		}
+		return &dep.AWSCredentials{
+			AccessKey:     secretString(s, "access_key"),
+			SecretKey:     secretString(s, "secret_key"),
+			SessionToken:  sessionToken,
+			ARN:           secretString(s, "arn"),
+			LeaseID:       s.LeaseID,
+			LeaseDuration: time.Duration(s.LeaseDuration) * time.Second,
+			Renewable:     s.Renewable,
+		}, nil
+	}
+}
+
+// gcpTokenFunc returns an OAuth2 access token for the roleset from Vault's GCP
+// secrets engine. "mount=<path>" sets the engine's mount path (defaults to
+// "gcp"). The token is re-fetched before it expires.
+//
+// Endpoint: /v1/:mount/roleset/:roleset/token
+// Template: {{ with gcpToken "deploy" }}{{ .Token }}{{ end }}
+func gcpTokenFunc(recall hcat.Recaller) interface{} {
+	return func(roleset string, rest ...string) (*dep.GCPToken, error) {
+		if roleset == "" {
+			return nil, nil
+		}
+		data, err := kvPairs(rest)
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := cloudCreds(recall, "gcp", "roleset/"+roleset+"/token", data)
+		if s == nil || err != nil {
+			return nil, err
+		}
+		token := &dep.GCPToken{Token: secretString(s, "token")}
+		if exp, ok := s.Data["expires_at_seconds"].(json.Number); ok {
+			if secs, err := exp.Int64(); err == nil {
+				token.ExpiresAt = time.Unix(secs, 0)
+			}
+		}
+		if ttl, ok := s.Data["token_ttl"].(json.Number); ok {
+			if secs, err := ttl.Int64(); err == nil {
+				token.TTL = time.Duration(secs) * time.Second
+			}
+		}
+		return token, nil
+	}
+}
+
+// azureCredsFunc returns service principal credentials from Vault's Azure
+// secrets engine. "mount=<path>" sets the engine's mount path (defaults to
+// "azure"). The credentials are re-fetched before their lease expires.
+//
+// Endpoint: /v1/:mount/creds/:role
+// Template: {{ with azureCreds "deploy" }}{{ .ClientID }}{{ end }}
+func azureCredsFunc(recall hcat.Recaller) interface{} {
+	return func(role string, rest ...string) (*dep.AzureCredentials, error) {
+		if role == "" {
+			return nil, nil
+		}
+		data, err := kvPairs(rest)
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := cloudCreds(recall, "azure", "creds/"+role, data)
+		if s == nil || err != nil {
+			return nil, err
+		}
+		return &dep.AzureCredentials{
+			ClientID:      secretString(s, "client_id"),
+			ClientSecret:  secretString(s, "client_secret"),
+			LeaseID:       s.LeaseID,
+			LeaseDuration: time.Duration(s.LeaseDuration) * time.Second,
+			Renewable:     s.Renewable,
+		}, nil
+	}
+}
+
+// pkiCAChainFunc returns the PEM encoded CA certificate chain of the PKI
+// mount. The chain is polled for changes, every 5 minutes by default or the
+// interval given as the optional second argument.
+//
+// Endpoint: /v1/:mount/ca_chain
+// Template: {{ pkiCAChain "pki" "1h" }}
+func pkiCAChainFunc(recall hcat.Recaller) interface{} {
+	return func(mount string, interval ...string) (string, error) {
+		return pkiPEM(recall, mount, idep.PKICAChain, interval)
+	}
+}
+
+// pkiCRLFunc returns the PEM encoded certificate revocation list of the PKI
+// mount. The CRL is polled for changes as with pkiCAChain.
+//
+// Endpoint: /v1/:mount/crl/pem
+// Template: {{ pkiCRL "pki" "1m" }}
+func pkiCRLFunc(recall hcat.Recaller) interface{} {
+	return func(mount string, interval ...string) (string, error) {
+		return pkiPEM(recall, mount, idep.PKICRL, interval)
+	}
+}
+
+// pkiPEM returns the PEM of the kind of PKI endpoint, polled at the optional
+// interval.
+func pkiPEM(recall hcat.Recaller, mount, kind string,
+	interval []string) (string, error) {
+	if mount == "" {
+		return "", nil
+	}
+	var dur time.Duration
+	switch len(interval) {
+	case 0:
+	case 1:
+		var err error
+		if dur, err = time.ParseDuration(interval[0]); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("too many arguments, expected mount and interval")
+	}
+
+	d, err := idep.NewVaultPKIQuery(mount, kind, dur)
+	if err != nil {
+		return "", err
+	}
+
+	if value, ok := recall(d); ok {
+		return value.(string), nil
+	}
+
+	return "", nil
+}
+
+// totpFunc returns the current code of the key of Vault's TOTP secrets
+// engine, fetched again as each period starts. "mount=<path>" sets the
+// engine's mount path (defaults to "totp") and "period=<duration>" the key's
+// period, if not the default 30s.
+//
+// Endpoint: /v1/:mount/code/:name
+// Template: {{ totp "vpn" }}
+func totpFunc(recall hcat.Recaller) interface{} {
+	return func(name string, rest ...string) (string, error) {
+		if name == "" {
+			return "", nil
+		}
+		data, err := kvPairs(rest)
+		if err != nil {
+			return "", err
+		}
+		var mount string
+		var period time.Duration
+		for k, v := range data {
+			switch k {
+			case "mount":
+				mount = v.(string)
+			case "period":
+				if period, err = time.ParseDuration(v.(string)); err != nil {
+					return "", err
+				}
+			default:
+				return "", fmt.Errorf("totp: invalid argument: %q", k)
+			}
+		}
+
+		d, err := idep.NewVaultTOTPQuery(mount, name, period)
+		if err != nil {
+			return "", err
+		}
+
+		if value, ok := recall(d); ok {
+			return value.(string), nil
+		}
+
+		return "", nil
+	}
+}
+
+// pkiIssueFunc issues a certificate from the role of Vault's PKI secrets
+// engine, Vault generating its private key (returned as the private_key data).
+// Extra "k=v" arguments (eg. "common_name=...", "alt_names=...", "ttl=...")
+// are passed along with the request, "mount=<path>" sets the engine's mount
+// path (defaults to "pki"). A new certificate is issued before it expires.
+//
+// Endpoint: /v1/:mount/issue/:role
+// Template: {{ with pkiIssue "web" "common_name=web.example.com" }}{{ .Data.certificate }}{{ end }}
+func pkiIssueFunc(recall hcat.Recaller) interface{} {
+	return func(role string, rest ...string) (*dep.Secret, error) {
+		return pkiCert(recall, idep.PKIIssue, role, rest)
+	}
+}
+
+// pkiSignFunc is pkiIssue with the private key generated locally, only a CSR
+// for it is sent to Vault to be signed. The key is returned as the
+// private_key data, as with pkiIssue. "key_type=ec|rsa" and "key_bits=<n>"
+// set the key's type and size (defaults to a 256 bit ec key), they aren't
+// sent.
+//
+// Endpoint: /v1/:mount/sign/:role
+// Template: {{ with pkiSign "web" "common_name=web.example.com" }}{{ .Data.private_key }}{{ end }}
+func pkiSignFunc(recall hcat.Recaller) interface{} {
+	return func(role string, rest ...string) (*dep.Secret, error) {
+		return pkiCert(recall, idep.PKISign, role, rest)
+	}
+}
+
+// pkiCert fetches the certificate of the role using the mode's flow.
+func pkiCert(recall hcat.Recaller, mode, role string,
+	rest []string) (*dep.Secret, error) {
+	if role == "" {
+		return nil, nil
+	}
+	data, err := kvPairs(rest)
+	if err != nil {
+		return nil, err
+	}
+	mount := "pki"
+	if m, ok := data["mount"]; ok {
+		mount = m.(string)
+		delete(data, "mount")
+	}
+
+	d, err := idep.NewVaultPKICertQuery(mode, mount, role, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := recall(d); ok {
+		return value.(*dep.Secret), nil
+	}
+
+	return nil, nil
+}
+
+// cloudCreds fetches the secret from the cloud secrets engine endpoint. The
+// "mount" in data overrides the default mount, any other data makes it a
+// write request.
+func cloudCreds(recall hcat.Recaller, mount, endpoint string,
+	data map[string]interface{}) (*dep.Secret, error) {
+	if m, ok := data["mount"]; ok {
+		mount = strings.Trim(m.(string), "/")
+		delete(data, "mount")
+	}
+
+	path := mount + "/" + endpoint
+	var d dep.Dependency
+	var err error
+	if len(data) == 0 {
+		d, err = idep.NewVaultReadQuery(path)
+	} else {
+		d, err = idep.NewVaultWriteQuery(path, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := recall(d); ok {
+		return value.(*dep.Secret), nil
+	}
+
+	return nil, nil
+}
+
+// secretString returns the secret's data value for the key as a string.
+func secretString(s *dep.Secret, key string) string {
+	if v, ok := s.Data[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// kvPairs parses "k=v" strings into a map for use as the data sent with
+// vault write requests. Empty strings are ignored.
+func kvPairs(pairs []string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	for _, str := range pairs {
+		if len(str) == 0 {
+			continue
+		}
+		parts := strings.SplitN(str, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("not k=v pair %q", str)
+		}
+
+		k, v := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		data[k] = v
+	}
+	return data, nil
+}
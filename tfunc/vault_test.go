@@ -2,8 +2,10 @@ package tfunc
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/hcat"
 	"github.com/hashicorp/hcat/dep"
@@ -58,6 +60,51 @@ func TestVaultExecute(t *testing.T) {
 			"zap",
 			false,
 		},
+		{
+			"func_mustSecret",
+			hcat.TemplateInput{
+				Contents: `{{ with mustSecret "secret/foo" }}{{ .Data.zip }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultReadQuery("secret/foo")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &dep.Secret{
+					Data: map[string]interface{}{"zip": "zap"},
+				})
+				return fakeWatcher{st}
+			}(),
+			"zap",
+			false,
+		},
+		{
+			"func_mustSecret_empty",
+			hcat.TemplateInput{
+				Contents: `{{ with mustSecret "secret/foo" }}{{ .Data.zip }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultReadQuery("secret/foo")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &dep.Secret{})
+				return fakeWatcher{st}
+			}(),
+			"",
+			true,
+		},
+		{
+			"func_mustSecret_not_fetched",
+			hcat.TemplateInput{
+				Contents: `{{ with mustSecret "secret/foo" }}{{ .Data.zip }}{{ end }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			false,
+		},
 		{
 			"func_secret_read_dash_error",
 			hcat.TemplateInput{
@@ -228,6 +275,359 @@ func TestVaultExecute(t *testing.T) {
 			"",
 			false,
 		},
+		{
+			"func_ssh_sign",
+			hcat.TemplateInput{
+				Contents: `{{ with sshSign "web" "ssh-rsa AAAA" "ttl=1h" }}{{ .Data.signed_key }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultWriteQuery("ssh/sign/web", map[string]interface{}{
+					"public_key": "ssh-rsa AAAA",
+					"ttl":        "1h",
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &dep.Secret{
+					Data: map[string]interface{}{"signed_key": "ssh-rsa-cert"},
+				})
+				return fakeWatcher{st}
+			}(),
+			"ssh-rsa-cert",
+			false,
+		},
+		{
+			"func_ssh_sign_mount",
+			hcat.TemplateInput{
+				Contents: `{{ with sshSign "web" "ssh-rsa AAAA" "mount=ssh-client/" }}{{ .Data.signed_key }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultWriteQuery("ssh-client/sign/web", map[string]interface{}{
+					"public_key": "ssh-rsa AAAA",
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &dep.Secret{
+					Data: map[string]interface{}{"signed_key": "ssh-rsa-cert"},
+				})
+				return fakeWatcher{st}
+			}(),
+			"ssh-rsa-cert",
+			false,
+		},
+		{
+			"func_ssh_otp",
+			hcat.TemplateInput{
+				Contents: `{{ with sshOTP "otp" "10.0.0.1" }}{{ .Data.key }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultWriteQuery("ssh/creds/otp", map[string]interface{}{
+					"ip": "10.0.0.1",
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &dep.Secret{
+					LeaseID:       "ssh/creds/otp/abcd",
+					LeaseDuration: 60,
+					Data:          map[string]interface{}{"key": "one-time"},
+				})
+				return fakeWatcher{st}
+			}(),
+			"one-time",
+			false,
+		},
+		{
+			"func_aws_creds",
+			hcat.TemplateInput{
+				Contents: `{{ with awsCreds "deploy" }}{{ .AccessKey }}:{{ .SecretKey }}:{{ .LeaseDuration }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultReadQuery("aws/creds/deploy")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &dep.Secret{
+					LeaseID:       "aws/creds/deploy/abcd",
+					LeaseDuration: 900,
+					Data: map[string]interface{}{
+						"access_key":     "AKIA",
+						"secret_key":     "shh",
+						"security_token": nil,
+					},
+				})
+				return fakeWatcher{st}
+			}(),
+			"AKIA:shh:15m0s",
+			false,
+		},
+		{
+			"func_aws_creds_sts",
+			hcat.TemplateInput{
+				Contents: `{{ with awsCreds "deploy" "sts=true" "ttl=15m" "mount=aws-prod/" }}{{ .AccessKey }}:{{ .SessionToken }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultWriteQuery("aws-prod/sts/deploy",
+					map[string]interface{}{"ttl": "15m"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &dep.Secret{
+					LeaseID:       "aws-prod/sts/deploy/abcd",
+					LeaseDuration: 900,
+					Data: map[string]interface{}{
+						"access_key":     "ASIA",
+						"secret_key":     "shh",
+						"security_token": "session",
+					},
+				})
+				return fakeWatcher{st}
+			}(),
+			"ASIA:session",
+			false,
+		},
+		{
+			"func_aws_creds_no_exist_falsey",
+			hcat.TemplateInput{
+				Contents: `{{ if awsCreds "deploy" }}yes{{ else }}no{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				return fakeWatcher{hcat.NewStore()}
+			}(),
+			"no",
+			false,
+		},
+		{
+			"func_gcp_token",
+			hcat.TemplateInput{
+				Contents: `{{ with gcpToken "deploy" }}{{ .Token }}:{{ .ExpiresAt.Unix }}:{{ .TTL }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultReadQuery("gcp/roleset/deploy/token")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &dep.Secret{
+					Data: map[string]interface{}{
+						"token":              "ya29",
+						"expires_at_seconds": json.Number("1600000000"),
+						"token_ttl":          json.Number("3599"),
+					},
+				})
+				return fakeWatcher{st}
+			}(),
+			"ya29:1600000000:59m59s",
+			false,
+		},
+		{
+			"func_azure_creds",
+			hcat.TemplateInput{
+				Contents: `{{ with azureCreds "deploy" "mount=azure-prod" }}{{ .ClientID }}:{{ .ClientSecret }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultReadQuery("azure-prod/creds/deploy")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &dep.Secret{
+					LeaseID:       "azure-prod/creds/deploy/abcd",
+					LeaseDuration: 3600,
+					Data: map[string]interface{}{
+						"client_id":     "id",
+						"client_secret": "secret",
+					},
+				})
+				return fakeWatcher{st}
+			}(),
+			"id:secret",
+			false,
+		},
+		{
+			"func_ssh_sign_no_exist_falsey",
+			hcat.TemplateInput{
+				Contents: `{{ if sshSign "web" "ssh-rsa AAAA" }}yes{{ else }}no{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				return fakeWatcher{hcat.NewStore()}
+			}(),
+			"no",
+			false,
+		},
+		{
+			"func_totp",
+			hcat.TemplateInput{
+				Contents: `{{ totp "vpn" "mount=otp" "period=1m" }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultTOTPQuery("otp", "vpn", time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), "123456")
+				return fakeWatcher{st}
+			}(),
+			"123456",
+			false,
+		},
+		{
+			"func_totp_bad_arg",
+			hcat.TemplateInput{
+				Contents: `{{ totp "vpn" "digits=8" }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			true,
+		},
+		{
+			"func_pki_ca_chain",
+			hcat.TemplateInput{
+				Contents: `{{ pkiCAChain "pki" }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultPKIQuery("pki", idep.PKICAChain, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), "-----BEGIN CERTIFICATE-----")
+				return fakeWatcher{st}
+			}(),
+			"-----BEGIN CERTIFICATE-----",
+			false,
+		},
+		{
+			"func_pki_crl_interval",
+			hcat.TemplateInput{
+				Contents: `{{ pkiCRL "pki_int" "1m" }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultPKIQuery("pki_int", idep.PKICRL, time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), "-----BEGIN X509 CRL-----")
+				return fakeWatcher{st}
+			}(),
+			"-----BEGIN X509 CRL-----",
+			false,
+		},
+		{
+			"func_pki_crl_no_exist",
+			hcat.TemplateInput{
+				Contents: `{{ pkiCRL "pki" }}`,
+			},
+			func() hcat.Watcherer {
+				return fakeWatcher{hcat.NewStore()}
+			}(),
+			"",
+			false,
+		},
+		{
+			"func_pki_bad_interval",
+			hcat.TemplateInput{
+				Contents: `{{ pkiCRL "pki" "often" }}`,
+			},
+			func() hcat.Watcherer {
+				return fakeWatcher{hcat.NewStore()}
+			}(),
+			"",
+			true,
+		},
+		{
+			"func_pki_issue",
+			hcat.TemplateInput{
+				Contents: `{{ with pkiIssue "web" "common_name=web.example.com" }}{{ .Data.certificate }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultPKICertQuery(idep.PKIIssue, "pki", "web",
+					map[string]interface{}{"common_name": "web.example.com"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &dep.Secret{
+					Data: map[string]interface{}{"certificate": "cert"},
+				})
+				return fakeWatcher{st}
+			}(),
+			"cert",
+			false,
+		},
+		{
+			"func_pki_sign_mount",
+			hcat.TemplateInput{
+				Contents: `{{ with pkiSign "web" "mount=pki_int" "key_type=rsa" }}{{ .Data.private_key }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultPKICertQuery(idep.PKISign, "pki_int", "web",
+					map[string]interface{}{"key_type": "rsa"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &dep.Secret{
+					Data: map[string]interface{}{"private_key": "key"},
+				})
+				return fakeWatcher{st}
+			}(),
+			"key",
+			false,
+		},
+		{
+			"func_pki_sign_bad_key_type",
+			hcat.TemplateInput{
+				Contents: `{{ pkiSign "web" "key_type=dsa" }}`,
+			},
+			func() hcat.Watcherer {
+				return fakeWatcher{hcat.NewStore()}
+			}(),
+			"",
+			true,
+		},
+		{
+			"func_secret_from",
+			hcat.TemplateInput{
+				Contents: `{{ with secretFrom "vault-eu" "secret/foo" }}{{ .Data.zip }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				rq, err := idep.NewVaultReadQuery("secret/foo")
+				if err != nil {
+					t.Fatal(err)
+				}
+				d, err := idep.NewNamedClientQuery("vault-eu", rq)
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), &dep.Secret{
+					Data: map[string]interface{}{"zip": "zap"},
+				})
+				return fakeWatcher{st}
+			}(),
+			"zap",
+			false,
+		},
+		{
+			"func_secret_from_no_client_name",
+			hcat.TemplateInput{
+				Contents: `{{ with secretFrom "" "secret/foo" }}{{ .Data.zip }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				return fakeWatcher{hcat.NewStore()}
+			}(),
+			"",
+			true,
+		},
 		{
 			"func_secrets",
 			hcat.TemplateInput{
@@ -278,6 +678,82 @@ func TestVaultExecute(t *testing.T) {
 			"",
 			false,
 		},
+		{
+			"func_secretBatch",
+			hcat.TemplateInput{
+				Contents: `{{ with secretBatch "secret/foo" "/secret/bar" }}` +
+					`{{ (index . "secret/foo").Data.zip }},` +
+					`{{ (index . "secret/bar").Data.zip }}{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				d, err := idep.NewVaultReadBatchQuery(
+					[]string{"secret/foo", "secret/bar"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), map[string]*dep.Secret{
+					"secret/foo": {Data: map[string]interface{}{"zip": "zap"}},
+					"secret/bar": {Data: map[string]interface{}{"zip": "zop"}},
+				})
+				return fakeWatcher{st}
+			}(),
+			"zap,zop",
+			false,
+		},
+		{
+			"func_secretBatch_no_exist",
+			hcat.TemplateInput{
+				Contents: `{{ with secretBatch "secret/foo" }}{{ . }}{{ end }}`,
+			},
+			fakeWatcher{hcat.NewStore()},
+			"",
+			false,
+		},
+		{
+			"func_secretMap",
+			hcat.TemplateInput{
+				Contents: `{{ range $p, $s := secretMap "secret/" }}` +
+					`{{ $p }}={{ $s.Data.zip }};{{ end }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				l, err := idep.NewVaultListQuery("secret")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(l.ID(), []string{"bar", "dir/", "foo"})
+				d, err := idep.NewVaultReadBatchQuery(
+					[]string{"secret/bar", "secret/foo"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(d.ID(), map[string]*dep.Secret{
+					"secret/foo": {Data: map[string]interface{}{"zip": "zap"}},
+					"secret/bar": {Data: map[string]interface{}{"zip": "zop"}},
+				})
+				return fakeWatcher{st}
+			}(),
+			"secret/bar=zop;secret/foo=zap;",
+			false,
+		},
+		{
+			"func_secretMap_empty",
+			hcat.TemplateInput{
+				Contents: `{{ secretMap "secret" | len }}`,
+			},
+			func() hcat.Watcherer {
+				st := hcat.NewStore()
+				l, err := idep.NewVaultListQuery("secret")
+				if err != nil {
+					t.Fatal(err)
+				}
+				st.Save(l.ID(), []string{"dir/"})
+				return fakeWatcher{st}
+			}(),
+			"0",
+			false,
+		},
 		{
 			"func_secret_nil_pointer_evaluation",
 			hcat.TemplateInput{
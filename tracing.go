@@ -0,0 +1,89 @@
+package hcat
+
+import "context"
+
+// Span names for the stages of the pipeline.
+const (
+	// SpanFetch covers a dependency's fetch from its upstream, including the
+	// time it spends blocking waiting for changes.
+	SpanFetch = "hcat.fetch"
+	// SpanNotify covers notifying a template of a dependency's new data.
+	SpanNotify = "hcat.notify"
+	// SpanExecute covers executing a template.
+	SpanExecute = "hcat.execute"
+	// SpanRender covers rendering a template's output with its Renderer.
+	SpanRender = "hcat.render"
+)
+
+// Span attribute keys used to identify what the span is for.
+const (
+	AttrTemplateID    = "hcat.template.id"
+	AttrTemplateLabel = "hcat.template.label"
+	AttrDependencyID  = "hcat.dependency.id"
+)
+
+// Tracer starts the spans used to trace the fetch, notify, execute and render
+// stages of the pipeline. Tracing is off by default, enable it by setting the
+// Tracer on the WatcherInput (fetch and notify) and TemplateInput (execute
+// and render).
+//
+// It is meant to be a thin wrapper around a tracing library, eg. with
+// OpenTelemetry:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) StartSpan(ctx context.Context, name string,
+//		attrs ...hcat.SpanAttribute) (context.Context, hcat.Span) {
+//		kvs := make([]attribute.KeyValue, 0, len(attrs))
+//		for _, a := range attrs {
+//			kvs = append(kvs, attribute.String(a.Key, a.Value))
+//		}
+//		ctx, span := t.Start(ctx, name, trace.WithAttributes(kvs...))
+//		return ctx, otelSpan{span}
+//	}
+//
+//	type otelSpan struct{ trace.Span }
+//
+//	func (s otelSpan) End(err error) {
+//		if err != nil {
+//			s.RecordError(err)
+//			s.SetStatus(codes.Error, err.Error())
+//		}
+//		s.Span.End()
+//	}
+type Tracer interface {
+	StartSpan(ctx context.Context, name string, attrs ...SpanAttribute) (
+		context.Context, Span)
+}
+
+// Span is a single traced operation started by a Tracer.
+type Span interface {
+	// End completes the span, err is the operation's error (nil if none).
+	End(err error)
+}
+
+// SpanAttribute is a key/value attribute of a span.
+type SpanAttribute struct {
+	Key   string
+	Value string
+}
+
+// noopTracer is the default Tracer, it doesn't trace anything.
+type noopTracer struct{}
+
+func (noopTracer) StartSpan(ctx context.Context, _ string, _ ...SpanAttribute) (
+	context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(error) {}
+
+// tracerOrNoop returns the tracer, or the no-op one if nil.
+func tracerOrNoop(t Tracer) Tracer {
+	if t == nil {
+		return noopTracer{}
+	}
+	return t
+}
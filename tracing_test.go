@@ -0,0 +1,108 @@
+package hcat
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+// fakeTracer records the ended spans
+type fakeTracer struct {
+	sync.Mutex
+	spans []fakeSpan
+}
+
+type fakeSpan struct {
+	tracer *fakeTracer
+	name   string
+	attrs  map[string]string
+	err    error
+}
+
+func (t *fakeTracer) StartSpan(ctx context.Context, name string,
+	attrs ...SpanAttribute) (context.Context, Span) {
+	s := &fakeSpan{tracer: t, name: name, attrs: make(map[string]string)}
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+	return ctx, s
+}
+
+func (s *fakeSpan) End(err error) {
+	s.err = err
+	s.tracer.Lock()
+	defer s.tracer.Unlock()
+	s.tracer.spans = append(s.tracer.spans, *s)
+}
+
+// find returns the first ended span with the name
+func (t *fakeTracer) find(name string) (fakeSpan, bool) {
+	t.Lock()
+	defer t.Unlock()
+	for _, s := range t.spans {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return fakeSpan{}, false
+}
+
+type fakeRenderer struct{}
+
+func (fakeRenderer) Render(b []byte) (RenderResult, error) {
+	return RenderResult{DidRender: true, WouldRender: true}, nil
+}
+
+func TestTracing(t *testing.T) {
+	t.Parallel()
+	tracer := &fakeTracer{}
+	w := NewWatcher(WatcherInput{Cache: NewStore(), Tracer: tracer})
+	defer w.Stop()
+	tt := NewTemplate(TemplateInput{
+		Contents:     `{{echo "foo"}}`,
+		FuncMapMerge: template.FuncMap{"echo": echoFunc},
+		Renderer:     fakeRenderer{},
+		Tracer:       tracer,
+		Label:        "team-a",
+	})
+	w.Register(tt)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := RunLoop(ctx, nil, w, []Templater{tt}, func(re ResolveEvent) error {
+		_, err := tt.Render(re.Contents)
+		cancel()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	depID := (&idep.FakeDep{Name: "foo"}).ID()
+	exp := map[string]map[string]string{
+		SpanFetch:   {AttrDependencyID: depID},
+		SpanNotify:  {AttrDependencyID: depID, AttrTemplateID: tt.ID()},
+		SpanExecute: {AttrTemplateID: tt.ID(), AttrTemplateLabel: "team-a"},
+		SpanRender:  {AttrTemplateID: tt.ID(), AttrTemplateLabel: "team-a"},
+	}
+	for name, attrs := range exp {
+		s, ok := tracer.find(name)
+		if !ok {
+			t.Errorf("missing span %q", name)
+			continue
+		}
+		for k, v := range attrs {
+			if s.attrs[k] != v {
+				t.Errorf("span %q: bad %s attribute, exp: %q, act: %q",
+					name, k, v, s.attrs[k])
+			}
+		}
+		if s.err != nil {
+			t.Errorf("span %q: unexpected error: %v", name, s.err)
+		}
+	}
+}
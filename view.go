@@ -33,6 +33,9 @@ type view struct {
 	// event holds the callback for event processing
 	event events.EventHandler
 
+	// tracer traces the fetches
+	tracer Tracer
+
 	// data is the most-recently-received data from Consul for this view. It is
 	// accompanied by a series of locks and booleans to ensure consistency.
 	dataLock     sync.RWMutex
@@ -43,6 +46,37 @@ type view struct {
 	// flag to denote that polling is active
 	isPolling bool
 
+	// status of the fetches for reporting, also guarded by dataLock
+	lastSuccess time.Time
+	lastErr     error
+	lastErrTime time.Time
+	failures    int
+	retrying    bool
+
+	// fallbackAfter is the number of consecutive failures after which the
+	// next fetch is served from the Consul agent's cache (0 disables it), with
+	// data up to fallbackMaxAge old. Also guarded by dataLock are fallback, set
+	// for that fetch, fellBack, set if it was done in the current run of
+	// failures, and degraded, set if the data is from the fallback.
+	fallbackAfter  int
+	fallbackMaxAge time.Duration
+	fallback       bool
+	fellBack       bool
+	degraded       bool
+
+	// queue, if set, is used to send the view to the watcher (see poll)
+	queue *viewQueue
+	// queued is set while the view is in the queue when coalescing (atomic)
+	queued int32
+	// once stops the polling after the first data (see WatcherInput's Once)
+	once bool
+	// pause holds the fetches while the watcher is paused (optional)
+	pause *pauseGate
+
+	// errorObserver is called when the fetches start failing or recover,
+	// guarded by dataLock
+	errorObserver func(id string)
+
 	// blockWaitTime is amount of time in seconds to do a blocking query for
 	blockWaitTime time.Duration
 
@@ -52,10 +86,20 @@ type view struct {
 	// defaultLease is used for non-renewable leases when secret has no lease
 	defaultLease time.Duration
 
+	// leaseObserver receives the lease events of Vault secrets (optional)
+	leaseObserver LeaseObserver
+
+	// cachePolicy is the Vault cache policy of the dependency (optional)
+	cachePolicy VaultCachePolicy
+
 	// retryFunc is the function to invoke on failure to determine if a retry
 	// should be attempted.
 	retryFunc RetryFunc
 
+	// retryClassifier, if set, classifies the fetch errors as retryable or
+	// not, overriding the default classification
+	retryClassifier RetryClassifier
+
 	// stopCh is used to stop polling on this view
 	stopCh chan struct{}
 
@@ -66,6 +110,12 @@ type view struct {
 	// change the TCP connection status from active to idle, to then be reaped.
 	ctx       context.Context
 	ctxCancel context.CancelFunc
+
+	// fetchCtx is the context of the current fetch, a child of ctx. It is
+	// canceled by setIndex to interrupt the fetch so it restarts with the new
+	// index. Guarded by dataLock.
+	fetchCtx    context.Context
+	fetchCancel context.CancelFunc
 }
 
 // NewViewInput is used as input to the NewView function.
@@ -80,6 +130,9 @@ type newViewInput struct {
 	// EventHandler takes the callback for event processing
 	EventHandler events.EventHandler
 
+	// Tracer traces the fetches (optional)
+	Tracer Tracer
+
 	// BlockWaitTime is amount of time in seconds to do a blocking query for
 	BlockWaitTime time.Duration
 
@@ -91,8 +144,38 @@ type newViewInput struct {
 	// upstream errors.
 	RetryFunc RetryFunc
 
+	// RetryClassifier classifies the upstream errors as retryable or not
+	// (optional)
+	RetryClassifier RetryClassifier
+
 	// Default non-renewable secret duration
 	VaultDefaultLease time.Duration
+
+	// LeaseObserver receives the lease events of Vault secrets (optional)
+	LeaseObserver LeaseObserver
+
+	// CachePolicy is the Vault cache policy of the dependency (optional)
+	CachePolicy VaultCachePolicy
+
+	// FallbackAfter is the number of consecutive failed fetches after which
+	// to fetch from the Consul agent's cache, with data up to FallbackMaxAge
+	// old (optional)
+	FallbackAfter  int
+	FallbackMaxAge time.Duration
+
+	// Queue is the watcher's queue of views with new data (optional)
+	Queue *viewQueue
+
+	// ErrorObserver is called with the view's ID when its fetches start
+	// failing and when they succeed again (optional)
+	ErrorObserver func(id string)
+
+	// Once stops the polling after the first data is received
+	Once bool
+
+	// Pause is the watcher's paused state, no fetches are started while it
+	// is paused (optional)
+	Pause *pauseGate
 }
 
 // NewView constructs a new view with the given inputs.
@@ -106,6 +189,7 @@ func newView(i *newViewInput) *view {
 		dependency:    i.Dependency,
 		clients:       i.Clients,
 		event:         eventHandler,
+		tracer:        tracerOrNoop(i.Tracer),
 		blockWaitTime: i.BlockWaitTime,
 		maxStale:      i.MaxStale,
 		retryFunc:     i.RetryFunc,
@@ -113,6 +197,16 @@ func newView(i *newViewInput) *view {
 		ctx:           ctx,
 		ctxCancel:     cancel,
 		defaultLease:  i.VaultDefaultLease,
+		leaseObserver: i.LeaseObserver,
+		cachePolicy:   i.CachePolicy,
+		queue:         i.Queue,
+		errorObserver: i.ErrorObserver,
+		once:          i.Once,
+		pause:         i.Pause,
+
+		retryClassifier: i.RetryClassifier,
+		fallbackAfter:   i.FallbackAfter,
+		fallbackMaxAge:  i.FallbackMaxAge,
 	}
 }
 
@@ -137,6 +231,65 @@ func (v *view) DataAndLastIndex() (interface{}, uint64) {
 	return v.data, v.lastIndex
 }
 
+// receivedDataOK returns the most-recently-received data and whether any has
+// been received.
+func (v *view) receivedDataOK() (interface{}, bool) {
+	v.dataLock.RLock()
+	defer v.dataLock.RUnlock()
+	return v.data, v.receivedData
+}
+
+// lastIndexOK returns the index of the last blocking query and if any data has
+// been received.
+func (v *view) lastIndexOK() (uint64, bool) {
+	v.dataLock.RLock()
+	defer v.dataLock.RUnlock()
+	return v.lastIndex, v.receivedData
+}
+
+// setIndex sets the index used for the view's next blocking query. If a fetch
+// is in flight it is interrupted and restarted using the new index, so setting
+// it to 0 triggers an immediate full refresh.
+func (v *view) setIndex(index uint64) {
+	v.dataLock.Lock()
+	v.lastIndex = index
+	cancel := v.fetchCancel
+	v.dataLock.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// startFetch runs fetch in a goroutine with a new fetch context. It returns
+// the context and a channel that is closed when fetch returns.
+func (v *view) startFetch(doneCh, successCh chan<- struct{},
+	errCh chan<- error) (context.Context, <-chan struct{}) {
+	ctx, cancel := context.WithCancel(v.ctx)
+	v.dataLock.Lock()
+	if v.fetchCancel != nil {
+		v.fetchCancel() // release the previous (finished) fetch's context
+	}
+	v.fetchCtx, v.fetchCancel = ctx, cancel
+	v.dataLock.Unlock()
+
+	exitedCh := make(chan struct{})
+	go func() {
+		defer close(exitedCh)
+		v.fetch(doneCh, successCh, errCh)
+	}()
+	return ctx, exitedCh
+}
+
+// fetchContext returns the context of the current fetch.
+func (v *view) fetchContext() context.Context {
+	v.dataLock.RLock()
+	defer v.dataLock.RUnlock()
+	if v.fetchCtx != nil {
+		return v.fetchCtx
+	}
+	return v.ctx
+}
+
 // ID outputs a unique string identifier for the view
 // It is identical to it's contained Dependency ID.
 func (v *view) ID() string {
@@ -179,10 +332,13 @@ func (v *view) poll(viewCh chan<- *view, errCh chan<- error) {
 	}()
 
 	for {
+		if !v.pause.wait(v.stopCh) {
+			return
+		}
 		doneCh := make(chan struct{}, 1)
 		successCh := make(chan struct{}, 1)
 		fetchErrCh := make(chan error, 1)
-		go v.fetch(doneCh, successCh, fetchErrCh)
+		fetchCtx, exitedCh := v.startFetch(doneCh, successCh, fetchErrCh)
 
 	WAIT:
 		select {
@@ -191,10 +347,8 @@ func (v *view) poll(viewCh chan<- *view, errCh chan<- error) {
 			// have some successful requests
 			retries = 0
 
-			select {
-			case <-v.stopCh:
+			if !v.send(viewCh) || v.once {
 				return
-			case viewCh <- v:
 			}
 
 		case <-successCh:
@@ -209,11 +363,19 @@ func (v *view) poll(viewCh chan<- *view, errCh chan<- error) {
 			goto WAIT
 		case err := <-fetchErrCh:
 			v.event(events.ServerError{ID: v.ID(), Error: err})
+			v.recordError(err)
 			var skipRetry bool
 			if strings.Contains(err.Error(), "Unexpected response code: 400") {
 				// 400 is not useful to retry
 				skipRetry = true
 			}
+			class, after := v.classify(err)
+			switch class {
+			case RetryBackoff:
+				skipRetry = false
+			case RetryFatal:
+				skipRetry = true
+			}
 
 			if strings.Contains(err.Error(), "connection refused") {
 				// This indicates that Consul may have restarted. If Consul
@@ -226,8 +388,19 @@ func (v *view) poll(viewCh chan<- *view, errCh chan<- error) {
 				v.dataLock.Unlock()
 			}
 
+			if failures, ok := v.startFallback(); ok && !skipRetry {
+				// fetch from the agent's cache right away
+				v.event(events.CacheFallback{
+					ID: v.ID(), Failures: failures, Error: err})
+				continue
+			}
+
 			if v.retryFunc != nil && !skipRetry {
 				retry, sleep := v.retryFunc(retries)
+				if after > 0 {
+					sleep = after
+				}
+				v.setRetrying(retry)
 				if retry {
 					v.event(events.RetryAttempt{
 						ID:      v.ID(),
@@ -253,12 +426,50 @@ func (v *view) poll(viewCh chan<- *view, errCh chan<- error) {
 			case errCh <- err:
 				return
 			}
+		case <-fetchCtx.Done():
+			// interrupted by setIndex (or stopped), wait for the fetch to
+			// return before restarting it
+			select {
+			case <-exitedCh:
+			case <-v.stopCh:
+				return
+			}
+			select {
+			case <-doneCh: // it received data before being interrupted
+				if !v.send(viewCh) || v.once {
+					return
+				}
+			default:
+			}
 		case <-v.stopCh:
 			return
 		}
 	}
 }
 
+// classify returns the retry classification of the fetch error, RetryDefault
+// if the view has no classifier.
+func (v *view) classify(err error) (RetryClass, time.Duration) {
+	if v.retryClassifier == nil {
+		return RetryDefault, 0
+	}
+	return v.retryClassifier(v.dependency, err)
+}
+
+// send sends the view to the watcher, using the queue if set. Returns false
+// if the view was stopped.
+func (v *view) send(viewCh chan<- *view) bool {
+	if v.queue != nil {
+		return v.queue.send(v, v.stopCh)
+	}
+	select {
+	case <-v.stopCh:
+		return false
+	case viewCh <- v:
+		return true
+	}
+}
+
 // fetch queries the Consul instance for the attached dependency. This API
 // promises that either data will be written to doneCh or an error will be
 // written to errCh. It is designed to be run in a goroutine that selects the
@@ -272,31 +483,49 @@ func (v *view) fetch(doneCh, successCh chan<- struct{}, errCh chan<- error) {
 		allowStale = true
 	}
 
+	ctx := v.fetchContext()
 	for {
 		// If the view was stopped, short-circuit this loop. This prevents a bug
 		// where a view can get "lost" in the event Consul Template is reloaded.
 		select {
 		case <-v.stopCh:
 			return
-		case <-v.ctx.Done():
+		case <-ctx.Done():
 			return
 		default:
 		}
 
 		start := time.Now() // for rateLimiter below
 
+		fallback := v.takeFallback()
+		var retryAfter idep.RetryAfter
 		if d, ok := v.dependency.(QueryOptionsSetter); ok {
+			lastIndex, _ := v.lastIndexOK()
 			opts := QueryOptions{
 				AllowStale:   allowStale,
 				WaitTime:     v.blockWaitTime,
-				WaitIndex:    v.lastIndex,
+				WaitIndex:    lastIndex,
 				DefaultLease: v.defaultLease,
+				MaxAge:       v.cachePolicy.TTL,
+			}
+			if fallback {
+				// whatever the agent has cached, without blocking
+				opts.AllowStale = true
+				opts.UseCache = true
+				opts.MaxAge = v.fallbackMaxAge
+				opts.WaitIndex = 0
+			}
+			opts = opts.SetContext(idep.WithRetryAfter(ctx, &retryAfter))
+			if v.leaseObserver != nil {
+				opts = opts.SetLeaseObserver(v.leaseObserver.ObserveLease)
 			}
-			opts = opts.SetContext(v.ctx)
 			d.SetOptions(opts)
 		}
 		v.event(events.Trace{ID: v.ID(), Message: "fetching value"})
+		_, span := v.tracer.StartSpan(ctx, SpanFetch,
+			SpanAttribute{Key: AttrDependencyID, Value: v.ID()})
 		data, rm, err := v.dependency.Fetch(v.clients)
+		span.End(err)
 		if err != nil {
 			switch {
 			case err == dep.ErrStopped:
@@ -305,7 +534,7 @@ func (v *view) fetch(doneCh, successCh chan<- struct{}, errCh chan<- error) {
 				// This is a wrapped error so relying on string matching
 				v.event(events.Trace{ID: v.ID(), Message: err.Error()})
 			default:
-				errCh <- err
+				errCh <- withRetryAfter(err, retryAfter.After())
 			}
 			return
 		}
@@ -316,16 +545,19 @@ func (v *view) fetch(doneCh, successCh chan<- struct{}, errCh chan<- error) {
 			return
 		}
 
+		rm.Degraded = fallback
+
 		// If we got this far, we received data successfully. That data might not
 		// trigger a data update (because we could continue below), but we need to
 		// inform the poller to reset the retry count.
 		v.event(events.Trace{ID: v.ID(), Message: "successful data response"})
+		v.recordSuccess(rm.Degraded)
 		select {
 		case successCh <- struct{}{}:
 		default:
 		}
 
-		if allowStale && rm.LastContact > v.maxStale {
+		if allowStale && !fallback && rm.LastContact > v.maxStale {
 			allowStale = false
 			v.event(events.StaleData{ID: v.ID(), LastContant: rm.LastContact})
 			continue
@@ -339,12 +571,12 @@ func (v *view) fetch(doneCh, successCh chan<- struct{}, errCh chan<- error) {
 			time.Sleep(dur)
 		}
 
+		v.dataLock.Lock()
 		if rm.LastIndex == v.lastIndex {
 			v.event(events.Trace{ID: v.ID(), Message: "same index, no new data"})
+			v.dataLock.Unlock()
 			continue
 		}
-
-		v.dataLock.Lock()
 		if rm.LastIndex < v.lastIndex {
 			v.event(events.Trace{ID: v.ID(),
 				Message: "wrong index order, resetting"})
@@ -375,6 +607,93 @@ func (v *view) fetch(doneCh, successCh chan<- struct{}, errCh chan<- error) {
 	}
 }
 
+// recordSuccess records a successful response for the status, degraded if
+// it was served from the agent's cache.
+func (v *view) recordSuccess(degraded bool) {
+	v.dataLock.Lock()
+	recovered := v.failures > 0
+	v.lastSuccess = time.Now()
+	v.failures = 0
+	v.retrying = false
+	v.fellBack = false
+	v.degraded = degraded
+	observer := v.errorObserver
+	v.dataLock.Unlock()
+	if recovered && observer != nil {
+		observer(v.ID())
+	}
+}
+
+// startFallback sets the next fetch to be served from the agent's cache if
+// the view has failed fallbackAfter times in a row, once per run of failures.
+// Returns the number of failures and true if set.
+func (v *view) startFallback() (int, bool) {
+	v.dataLock.Lock()
+	defer v.dataLock.Unlock()
+	if v.fallbackAfter <= 0 || v.fellBack || v.failures < v.fallbackAfter {
+		return v.failures, false
+	}
+	v.fallback, v.fellBack = true, true
+	return v.failures, true
+}
+
+// takeFallback returns if the fetch is to be served from the agent's cache,
+// clearing it so only one fetch is.
+func (v *view) takeFallback() bool {
+	v.dataLock.Lock()
+	defer v.dataLock.Unlock()
+	fallback := v.fallback
+	v.fallback = false
+	return fallback
+}
+
+// recordError records a failed fetch for the status.
+func (v *view) recordError(err error) {
+	v.dataLock.Lock()
+	v.lastErr = err
+	v.lastErrTime = time.Now()
+	v.failures++
+	started := v.failures == 1
+	observer := v.errorObserver
+	v.dataLock.Unlock()
+	if started && observer != nil {
+		observer(v.ID())
+	}
+}
+
+// setErrorObserver replaces the observer of the view's errors, eg. when a
+// watcher takes over the view from its fork.
+func (v *view) setErrorObserver(observer func(id string)) {
+	v.dataLock.Lock()
+	defer v.dataLock.Unlock()
+	v.errorObserver = observer
+}
+
+// setRetrying records if the failed fetch is being retried.
+func (v *view) setRetrying(retrying bool) {
+	v.dataLock.Lock()
+	defer v.dataLock.Unlock()
+	v.retrying = retrying
+}
+
+// status returns a snapshot of the view's status.
+func (v *view) status() DependencyStatus {
+	v.dataLock.RLock()
+	defer v.dataLock.RUnlock()
+	return DependencyStatus{
+		ID:            v.ID(),
+		Polling:       v.isPolling,
+		HasData:       v.receivedData,
+		Index:         v.lastIndex,
+		LastSuccess:   v.lastSuccess,
+		LastError:     v.lastErr,
+		LastErrorTime: v.lastErrTime,
+		Failures:      v.failures,
+		Retrying:      v.retrying,
+		Degraded:      v.degraded,
+	}
+}
+
 // Store-s the data and marks that it was received
 // Returns the view to make test setup easier.
 func (v *view) store(data interface{}) *view {
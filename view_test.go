@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	hcatdep "github.com/hashicorp/hcat/dep"
 	"github.com/hashicorp/hcat/events"
 	dep "github.com/hashicorp/hcat/internal/dependency"
 )
@@ -100,10 +101,18 @@ func TestPoll_retries(t *testing.T) {
 		t.Errorf("should not have gotten data yet")
 	case <-time.After(100 * time.Millisecond):
 	}
+	if st := vw.status(); !st.Retrying || st.Failures != 1 ||
+		st.LastError == nil {
+		t.Errorf("bad retrying status: %#v", st)
+	}
 
 	select {
 	case <-viewCh:
 		// Got this far, so the test passes
+		if st := vw.status(); st.Retrying || st.Failures != 0 ||
+			st.LastError == nil || st.LastSuccess.IsZero() {
+			t.Errorf("bad recovered status: %#v", st)
+		}
 	case err := <-errCh:
 		t.Errorf("error while polling: %s", err)
 	case <-vw.stopCh:
@@ -113,6 +122,65 @@ func TestPoll_retries(t *testing.T) {
 	}
 }
 
+func TestPoll_retryClassifier(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fatal", func(t *testing.T) {
+		var retried bool
+		vw := newView(&newViewInput{
+			Dependency: &dep.FakeDepRetry{},
+			RetryFunc: func(retry int) (bool, time.Duration) {
+				retried = true
+				return true, time.Millisecond
+			},
+			RetryClassifier: func(hcatdep.Dependency, error) (RetryClass,
+				time.Duration) {
+				return RetryFatal, 0
+			},
+		})
+		viewCh := make(chan *view)
+		errCh := make(chan error)
+		go vw.poll(viewCh, errCh)
+		defer vw.stop()
+
+		select {
+		case <-errCh:
+		case <-viewCh:
+			t.Fatal("fatal error retried")
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+		if retried {
+			t.Error("retry func called for a fatal error")
+		}
+	})
+
+	t.Run("after", func(t *testing.T) {
+		vw := newView(&newViewInput{
+			Dependency: &dep.FakeDepRetry{},
+			RetryFunc: func(retry int) (bool, time.Duration) {
+				return true, time.Hour
+			},
+			RetryClassifier: func(hcatdep.Dependency, error) (RetryClass,
+				time.Duration) {
+				return RetryBackoff, 10 * time.Millisecond
+			},
+		})
+		viewCh := make(chan *view)
+		errCh := make(chan error)
+		go vw.poll(viewCh, errCh)
+		defer vw.stop()
+
+		select {
+		case <-viewCh:
+		case err := <-errCh:
+			t.Fatalf("error while polling: %s", err)
+		case <-time.After(time.Second):
+			t.Fatal("retry didn't wait the classifier's time")
+		}
+	})
+}
+
 func TestFetch_resetRetries(t *testing.T) {
 	view := newView(&newViewInput{
 		Dependency: &dep.FakeDepSameIndex{},
@@ -161,6 +229,54 @@ func TestFetch_defaultLease(t *testing.T) {
 	}
 }
 
+func TestFetch_leaseObserver(t *testing.T) {
+	d := &dep.FakeDepLease{Name: "foo"}
+	observer := &testLeaseObserver{}
+	view := newView(&newViewInput{
+		Dependency:    d,
+		LeaseObserver: observer,
+	})
+
+	doneCh := make(chan struct{})
+	successCh := make(chan struct{}, 1)
+	errCh := make(chan error, 1)
+
+	go view.fetch(doneCh, successCh, errCh)
+
+	select {
+	case <-doneCh:
+	case err := <-errCh:
+		t.Fatalf("error while fetching: %s", err)
+	}
+	exp := []hcatdep.LeaseEvent{{
+		Type:          hcatdep.LeaseAcquired,
+		ID:            d.ID(),
+		LeaseID:       "foo/lease",
+		LeaseDuration: time.Minute,
+	}}
+	if act := observer.events(); !reflect.DeepEqual(act, exp) {
+		t.Errorf("bad lease events, exp: %#v, act: %#v", exp, act)
+	}
+}
+
+// testLeaseObserver records the lease events
+type testLeaseObserver struct {
+	sync.Mutex
+	observed []hcatdep.LeaseEvent
+}
+
+func (o *testLeaseObserver) ObserveLease(e hcatdep.LeaseEvent) {
+	o.Lock()
+	defer o.Unlock()
+	o.observed = append(o.observed, e)
+}
+
+func (o *testLeaseObserver) events() []hcatdep.LeaseEvent {
+	o.Lock()
+	defer o.Unlock()
+	return append([]hcatdep.LeaseEvent{}, o.observed...)
+}
+
 func TestFetch_maxStale(t *testing.T) {
 	view := newView(&newViewInput{
 		Dependency: &dep.FakeDepStale{},
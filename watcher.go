@@ -3,6 +3,7 @@ package hcat
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -18,6 +19,12 @@ const dataBufferSize = 2048
 // standard error returned when you try to register the same notifier twice
 var RegistryErr = fmt.Errorf("duplicate watcher registry entry")
 
+// ErrOtherWatcher is returned when registering a Template that is registered
+// with another Watcher. The template keeps its notify state, so it can only
+// be used with one Watcher (and its forks) at a time. Deregister it, or stop
+// the other Watcher, first.
+var ErrOtherWatcher = fmt.Errorf("template registered with another watcher")
+
 // RetryFunc defines the function type used to determine how many and how often
 // to retry calls to the external services.
 type RetryFunc func(int) (bool, time.Duration)
@@ -42,14 +49,22 @@ type Watcher struct {
 	cache Cacher
 	// event holds the callback for event processing
 	event events.EventHandler
+	// tracer traces the fetches and notifications
+	tracer Tracer
 
-	// dataCh is the chan where Views will be published.
+	// dataCh is the chan where Views will be published, it is the queue's
+	// channel.
 	dataCh chan *view
+	// queue bounds the views waiting to be processed
+	queue *viewQueue
 	// errCh is the chan where any errors will be published.
 	errCh chan error
 
 	// stopCh is the chan used internally to notify of Stop calls
 	stopCh drainableChan
+	// doneCh is closed on Stop, stopOnce guards closing it
+	doneCh   chan struct{}
+	stopOnce sync.Once
 	// waitingCh is used internally to test when Wait is waiting
 	waitingCh chan struct{}
 
@@ -65,15 +80,51 @@ type Watcher struct {
 
 	// Consul related
 	retryFuncConsul RetryFunc
+	// retryClassifierConsul classifies the errors as retryable (optional)
+	retryClassifierConsul RetryClassifier
 	// blockWaitTime is how long to block on consul's blocking queries
 	blockWaitTime time.Duration
 	// maxStale passed to consul to control staleness
 	maxStale time.Duration
+	// fallbackAfter failures, fetch from the agent's cache with data up to
+	// fallbackMaxAge old
+	fallbackAfter  int
+	fallbackMaxAge time.Duration
 
 	// Vault related
 	retryFuncVault RetryFunc
+	// retryClassifierVault classifies the errors as retryable (optional)
+	retryClassifierVault RetryClassifier
 	// defaultLease is used for non-renewable leases when secret has no lease
 	defaultLease time.Duration
+	// leaseObserver receives the secrets' lease events (optional)
+	leaseObserver LeaseObserver
+	// cachePolicies control the caching of secrets by path (optional)
+	cachePolicies []VaultCachePolicy
+
+	// deny is the denied dependency classes and the notifiers using them
+	deny *denyList
+
+	// errors are the notifiers rendering the status of dependencies (see
+	// DependencyErrorQuery)
+	errors *errorWatchers
+
+	// probes checks the health of the backends, nil if disabled
+	probes *prober
+
+	// once is set to fetch each dependency only once (see WatcherInput)
+	once bool
+
+	// pause is the paused state, see Pause
+	pause *pauseGate
+
+	// recorder captures the dependencies' data, nil if disabled
+	recorder *Recorder
+
+	// parent is the watcher this one is a fork of (see Fork), swapped is set
+	// once it has been swapped into it (guarded by the tracker's lock)
+	parent  *Watcher
+	swapped bool
 }
 
 type WatcherInput struct {
@@ -85,11 +136,54 @@ type WatcherInput struct {
 	// EventHandler takes the callback for event processing
 	EventHandler events.EventHandler
 
+	// Tracer enables tracing the dependency fetches and template
+	// notifications (optional)
+	Tracer Tracer
+
 	// Optional Vault specific parameters
 	// Default non-renewable secret duration
 	VaultDefaultLease time.Duration
 	// RetryFun for Vault
 	VaultRetryFunc RetryFunc
+	// VaultRetryClassifier decides which errors VaultRetryFunc retries, eg.
+	// to not retry permission denied (403) errors (optional)
+	VaultRetryClassifier RetryClassifier
+	// LeaseObserver receives the lease events of the Vault secrets (optional)
+	LeaseObserver LeaseObserver
+	// VaultCachePolicies control the caching of the secrets by path, eg. to
+	// keep sensitive secrets out of the Cache (optional)
+	VaultCachePolicies []VaultCachePolicy
+
+	// DenyDependencies are the classes of dependencies templates aren't
+	// allowed to use, eg. to constrain what tenant supplied templates may
+	// watch. Templates using them fail to run with a DeniedError, they are
+	// denied when first used not when Registered, see DependencyClass
+	// (optional)
+	DenyDependencies []DependencyClass
+
+	// HealthProbeInterval enables probing the health of the configured
+	// Consul (status/leader) and Vault (sys/health) backends at the
+	// interval. The results are in the WatcherStatus' Backends and changes
+	// send a BackendHealth event, so an unreachable backend can be told
+	// apart from no data changing. Zero disables the probes.
+	HealthProbeInterval time.Duration
+
+	// Once fetches each dependency only once, with no blocking queries or
+	// polling for changes after its first data, for rendering the templates
+	// once and exiting (see RunOnce). Vault secrets and tokens aren't
+	// renewed. Refresh fetches a dependency once more.
+	Once bool
+
+	// Recorder records the data of every dependency fetched, for writing
+	// snapshots replayed with a FixtureWatcher (optional)
+	Recorder *Recorder
+
+	// QueueSize is the maximum number of views with new data waiting to be
+	// processed by Wait or Watch. Defaults to 2048.
+	QueueSize int
+	// QueueOverflow is what to do when the queue is full, see OverflowPolicy.
+	// Defaults to OverflowBlock.
+	QueueOverflow OverflowPolicy
 
 	// Optional Consul specific parameters
 	// MaxStale is the max time Consul will return a stale value.
@@ -98,6 +192,17 @@ type WatcherInput struct {
 	ConsulBlockWait time.Duration
 	// RetryFun for Consul
 	ConsulRetryFunc RetryFunc
+	// ConsulRetryClassifier decides which errors ConsulRetryFunc retries
+	// (optional)
+	ConsulRetryClassifier RetryClassifier
+	// ConsulFallbackAfter enables falling back to the Consul agent's cache
+	// during a server outage. After this many consecutive failed queries a
+	// dependency's data is requested from the agent's cache, up to
+	// ConsulFallbackMaxAge old (0 is any age), so templates keep rendering.
+	// The data is flagged as Degraded in the DependencyStatus. It needs a
+	// ConsulRetryFunc retrying at least as many times. Zero disables it.
+	ConsulFallbackAfter  int
+	ConsulFallbackMaxAge time.Duration
 }
 
 type drainableChan chan struct{}
@@ -128,25 +233,45 @@ func NewWatcher(i WatcherInput) *Watcher {
 	}
 
 	bufferTriggerCh := make(chan string, dataBufferSize/2)
+	queue := newViewQueue(i.QueueSize, i.QueueOverflow)
 	w := &Watcher{
 		clients:         clients,
 		cache:           cache,
 		event:           eventHandler,
-		dataCh:          make(chan *view, dataBufferSize),
+		tracer:          tracerOrNoop(i.Tracer),
+		dataCh:          queue.ch,
+		queue:           queue,
 		errCh:           make(chan error),
 		waitingCh:       make(chan struct{}, 1),
 		stopCh:          make(chan struct{}, 1),
+		doneCh:          make(chan struct{}),
 		tracker:         newTracker(),
 		bufferTrigger:   bufferTriggerCh,
 		bufferTemplates: newTimers(),
 		retryFuncConsul: i.ConsulRetryFunc,
 		maxStale:        i.ConsulMaxStale,
 		blockWaitTime:   i.ConsulBlockWait,
+		fallbackAfter:   i.ConsulFallbackAfter,
+		fallbackMaxAge:  i.ConsulFallbackMaxAge,
 		retryFuncVault:  i.VaultRetryFunc,
 		defaultLease:    i.VaultDefaultLease,
+		leaseObserver:   i.LeaseObserver,
+		cachePolicies:   i.VaultCachePolicies,
+		deny:            newDenyList(i.DenyDependencies),
+		errors:          newErrorWatchers(),
+		probes:          newProber(clients, eventHandler, i.HealthProbeInterval),
+		once:            i.Once,
+		recorder:        i.Recorder,
+		pause:           newPauseGate(),
+
+		retryClassifierConsul: i.ConsulRetryClassifier,
+		retryClassifierVault:  i.VaultRetryClassifier,
 	}
 
 	go w.bufferTemplates.Run(bufferTriggerCh)
+	if w.probes != nil {
+		go w.probes.run()
+	}
 
 	return w
 }
@@ -156,10 +281,11 @@ const vaultTokenDummyTemplateID = "dummy.watcher.vault-token.id"
 // WatchVaultToken takes a vault token and watches it to keep it updated.
 // This is a specialized method as this token can be required without being in
 // a template. I hope to generalize this idea so you can watch arbitrary
-// dependencies in the future.
+// dependencies in the future. It is a no-op in Once mode, the token isn't
+// renewed.
 func (w *Watcher) WatchVaultToken(token string) error {
 	// Start a watcher for the Vault renew if that config was specified
-	if token != "" {
+	if token != "" && !w.once {
 		vt, err := idep.NewVaultTokenQuery(token)
 		if err != nil {
 			return errors.Wrap(err, "watcher")
@@ -198,26 +324,31 @@ func (w *Watcher) Wait(ctx context.Context) error {
 
 	// combine cache and changed updates so we don't forget one
 	dataUpdate := func(v *view) (notify bool) {
+		w.queue.received(v)
 		id := v.ID()
-		w.cache.Save(id, v.Data())
+		prev, _ := w.cache.Recall(id)
+		w.save(v)
 		for _, n := range w.tracker.notifiersFor(v) {
-			if n.Notify(v.Data()) && !w.Buffering(n) {
+			if w.notify(n, v, prev) && !w.Buffering(n) {
 				notify = true
 			}
 		}
 		return notify
 	}
 	for {
+		dataCh, errorsTrigger, bufferTrigger, pauseChanged := w.pausable()
 		select {
-		case view := <-w.dataCh:
-			// this case (<-w.dataCh) only happens if there is new/udpated data
+		case <-pauseChanged:
+			// paused or resumed, select with the channels of the new state
+		case view := <-dataCh:
+			// this case (<-dataCh) only happens if there is new/udpated data
 			notify := dataUpdate(view)
 			// Drain all dependency data. Prevents re-rendering templates over
 			// and over when a large batch of dependencies are updated.
 			// See consul-template GH-168 for background.
 			for drain := true; drain; {
 				select {
-				case view := <-w.dataCh:
+				case view := <-dataCh:
 					if dataUpdate(view) && !notify {
 						notify = true
 					}
@@ -228,7 +359,19 @@ func (w *Watcher) Wait(ctx context.Context) error {
 			if notify {
 				return nil
 			}
-		case <-w.bufferTrigger:
+		case <-errorsTrigger:
+			// Dependencies whose status templates render started failing
+			// or recovered.
+			notify := false
+			for _, n := range w.errors.take() {
+				if n.Notify(nil) && !w.Buffering(n) {
+					notify = true
+				}
+			}
+			if notify {
+				return nil
+			}
+		case <-bufferTrigger:
 			// A template is now ready to be rendered, though there might be a
 			// few ready around the same time if they have the same dependencies.
 			// Drain the channel similar for the dataCh above.
@@ -272,18 +415,23 @@ func (w *Watcher) Watch(ctx context.Context, tmplCh chan string) error {
 	}
 
 	dataUpdateAndNotify := func(v *view) {
+		w.queue.received(v)
 		id := v.ID()
-		w.cache.Save(id, v.Data())
+		prev, _ := w.cache.Recall(id)
+		w.save(v)
 		for _, n := range w.tracker.notifiersFor(v) {
-			if n.Notify(v.Data()) && !w.Buffering(n) {
+			if w.notify(n, v, prev) && !w.Buffering(n) {
 				tmplCh <- n.ID()
 			}
 		}
 	}
 
 	for {
+		dataCh, errorsTrigger, bufferTrigger, pauseChanged := w.pausable()
 		select {
-		case view := <-w.dataCh:
+		case <-pauseChanged:
+			// paused or resumed, select with the channels of the new state
+		case view := <-dataCh:
 			dataUpdateAndNotify(view)
 
 			// Drain all dependency data. Prevents re-rendering templates over
@@ -291,13 +439,19 @@ func (w *Watcher) Watch(ctx context.Context, tmplCh chan string) error {
 			// See consul-template GH-168 for background.
 			for drain := true; drain; {
 				select {
-				case view := <-w.dataCh:
+				case view := <-dataCh:
 					dataUpdateAndNotify(view)
 				case <-time.After(time.Microsecond):
 					drain = false
 				}
 			}
-		case tmplID := <-w.bufferTrigger:
+		case <-errorsTrigger:
+			for _, n := range w.errors.take() {
+				if n.Notify(nil) && !w.Buffering(n) {
+					tmplCh <- n.ID()
+				}
+			}
+		case tmplID := <-bufferTrigger:
 			// A template is now ready to be rendered, though there might be a
 			// few ready around the same time if they have the same dependencies.
 			// Drain the channel similar for the dataCh above.
@@ -329,6 +483,47 @@ func (w *Watcher) Watch(ctx context.Context, tmplCh chan string) error {
 	}
 }
 
+// pausable returns the channels Wait and Watch deliver the notifications
+// from, nil while paused (see Pause), and the channel closed when the paused
+// state changes.
+func (w *Watcher) pausable() (<-chan *view, <-chan struct{}, <-chan string,
+	<-chan struct{}) {
+	paused, changed := w.pause.state()
+	if paused {
+		return nil, nil, nil, changed
+	}
+	return w.dataCh, w.errors.trigger, w.bufferTrigger, changed
+}
+
+// notify passes the view's data to the notifier, tracing the call. prev is
+// the view's previous data, for DiffNotifiers.
+func (w *Watcher) notify(n Notifier, v *view, prev interface{}) bool {
+	_, span := w.tracer.StartSpan(context.Background(), SpanNotify,
+		SpanAttribute{Key: AttrTemplateID, Value: n.ID()},
+		SpanAttribute{Key: AttrDependencyID, Value: v.ID()})
+	defer span.End(nil)
+	if dn, ok := n.(DiffNotifier); ok {
+		data := v.Data()
+		return dn.NotifyDiff(v.ID(), data, diffLists(prev, data))
+	}
+	if dn, ok := n.(DependencyNotifier); ok {
+		return dn.NotifyDependency(v.ID(), v.Data())
+	}
+	return n.Notify(v.Data())
+}
+
+// QueueOverflows returns the number of times a view with new data found the
+// queue full or, with OverflowCoalesce, was merged into its queued entry.
+func (w *Watcher) QueueOverflows() uint64 {
+	return w.queue.Overflows()
+}
+
+// Pending returns the number of views with new data waiting to be processed
+// by Wait or Watch.
+func (w *Watcher) Pending() int {
+	return len(w.dataCh)
+}
+
 // Buffering sets the template to activate buffer and accumulate changes for a
 // period. If the template has not been initalized or a buffer period is not
 // configured for the template, it will skip the buffering.
@@ -352,15 +547,19 @@ func (w *Watcher) BufferReset(n Notifier) {
 // Trying to register the same Notifier twice will result in an error and none
 // of the Notifiers will be registered (all or nothing).
 // Trying to use a Notifier without Registering it will result in a *panic*.
+// Registering a Template registered with another Watcher returns
+// ErrOtherWatcher, see it for details.
 func (w *Watcher) Register(ns ...Notifier) error {
-	return w.tracker.registerNotifiers(ns...)
+	return w.tracker.registerNotifiers(w, ns...)
 }
 
 // Deregister de-registers one or more Notifiers from the Watcher.
 func (w *Watcher) Deregister(ns ...Notifier) {
-	w.tracker.deregisterNotifiers(ns...)
+	w.tracker.deregisterNotifiers(w, ns...)
 
 	for _, n := range ns {
+		w.deny.forget(n)
+		w.errors.forget(n)
 		w.tracker.markForSweep(n)
 		w.tracker.sweep(n, w.cache)
 	}
@@ -371,8 +570,15 @@ func (w *Watcher) Deregister(ns ...Notifier) {
 // explicit start (see Poll below).
 // It calls Register as a convenience, but ignores the returned error so it can
 // be used with already Registered Notifiers.
+// Dependencies of denied classes aren't tracked, see Denied, nor are those of
+// Templates registered with another Watcher.
 func (w *Watcher) Track(n Notifier, d dep.Dependency) {
-	w.Register(n)
+	if w.Register(n) == ErrOtherWatcher {
+		return
+	}
+	if w.deny.check(n, d) {
+		return
+	}
 	w.track(n, d)
 }
 
@@ -388,30 +594,79 @@ func (w *Watcher) track(n Notifier, d dep.Dependency) *view {
 	// NOTE: I would like to abstract this part out to not have type specific
 	//       things embedded in general code.
 	var retryFunc RetryFunc
+	var retryClassifier RetryClassifier
+	var fallbackAfter int
+	cachePolicy, _ := vaultCachePolicy(w.cachePolicies, d)
 	switch d.(type) {
 	case idep.ConsulType:
 		retryFunc = w.retryFuncConsul
+		retryClassifier = w.retryClassifierConsul
+		fallbackAfter = w.fallbackAfter
 	case idep.VaultType:
 		retryFunc = w.retryFuncVault
+		retryClassifier = w.retryClassifierVault
 	}
 
 	v := newView(&newViewInput{
 		Dependency:        d,
 		Clients:           w.clients,
-		EventHandler:      w.event,
+		EventHandler:      w.labeledEvent,
+		Tracer:            w.tracer,
 		MaxStale:          w.maxStale,
 		BlockWaitTime:     w.blockWaitTime,
 		RetryFunc:         retryFunc,
+		RetryClassifier:   retryClassifier,
 		VaultDefaultLease: w.defaultLease,
+		LeaseObserver:     w.leaseObserver,
+		CachePolicy:       cachePolicy,
+		FallbackAfter:     fallbackAfter,
+		FallbackMaxAge:    w.fallbackMaxAge,
+		Queue:             w.queue,
+		ErrorObserver:     w.errors.errorChanged,
+		Once:              w.once,
+		Pause:             w.pause,
 	})
-	w.event(events.TrackStart{ID: v.ID()})
 	w.tracker.add(v, n)
+	w.event(events.TrackStart{ID: v.ID(),
+		Labels: w.tracker.labelsFor(v.ID())})
+	// another notifier may already be tracking a view of the dependency
+	if tv := w.tracker.view(v.ID()); tv != nil {
+		return tv
+	}
 	return v
 }
 
+// labeledEvent sends the view's event, adding the labels of the templates
+// using its dependency to those that have them.
+func (w *Watcher) labeledEvent(e events.Event) {
+	switch e := e.(type) {
+	case events.TrackStart:
+		e.Labels = w.tracker.labelsFor(e.ID)
+		w.event(e)
+	case events.NewData:
+		e.Labels = w.tracker.labelsFor(e.ID)
+		w.event(e)
+	default:
+		w.event(e)
+	}
+}
+
+// save stores the view's data in the cache, unless its cache policy says not
+// to, and records it with the Recorder.
+func (w *Watcher) save(v *view) {
+	if w.recorder != nil {
+		w.recorder.record(v.Dependency(), v.Data())
+	}
+	if v.cachePolicy.NoCache {
+		return
+	}
+	w.cache.Save(v.ID(), v.Data())
+}
+
 // Poll starts any/all polling as needed.
 // It is idepotent.
 // If nothing is passed it checks all views (dependencies).
+// In Once mode the views that have data aren't polled again.
 func (w *Watcher) Poll(deps ...dep.Dependency) {
 	if len(deps) == 0 {
 		for _, v := range w.tracker.views {
@@ -420,6 +675,9 @@ func (w *Watcher) Poll(deps ...dep.Dependency) {
 	}
 	for _, d := range deps {
 		if v := w.tracker.view(d.ID()); v != nil {
+			if w.once && v.status().HasData {
+				continue
+			}
 			// view.poll checks if it is already polling
 			go v.poll(w.dataCh, w.errCh)
 		}
@@ -430,8 +688,28 @@ func (w *Watcher) Poll(deps ...dep.Dependency) {
 // to enable tracking dependencies on the Watcher.
 func (w *Watcher) Recaller(n Notifier) Recaller {
 	return func(dep dep.Dependency) (interface{}, bool) {
-		w.track(n, dep)
-		data, ok := w.cache.Recall(dep.ID())
+		if d, ok := dep.(*DependencyErrorQuery); ok {
+			// not a fetched dependency, always found
+			return w.recallStatus(n, d), true
+		}
+		if w.deny.check(n, dep) {
+			// never tracked, the resolver returns the error (see Denied)
+			return nil, false
+		}
+		v := w.track(n, dep)
+		var data interface{}
+		var ok bool
+		if v.cachePolicy.NoCache {
+			// never cached, use the view's data directly
+			data, ok = v.receivedDataOK()
+		} else if data, ok = w.cache.Recall(dep.ID()); !ok {
+			// the data may have been evicted from the cache (see
+			// StoreOptions), restore it from the view if it has it. The view
+			// keeps it either way, eviction only frees untracked entries.
+			if data, ok = v.receivedDataOK(); ok {
+				w.cache.Save(dep.ID(), data)
+			}
+		}
 		switch {
 		case ok:
 			w.tracker.cacheAccessed(n, dep)
@@ -447,6 +725,20 @@ func (w *Watcher) Complete(n Notifier) bool {
 	return w.tracker.complete(n)
 }
 
+// Denied returns a *DeniedError if the notifier used a dependency of a class
+// denied by the WatcherInput's DenyDependencies, nil otherwise. Denied
+// dependencies are never tracked or fetched. The error is kept until the
+// notifier is Deregistered.
+func (w *Watcher) Denied(n Notifier) error {
+	return w.deny.err(n)
+}
+
+// Missing returns the IDs of the dependencies used by the notifier that don't
+// have values yet, ie. the ones keeping it from being Complete.
+func (w *Watcher) Missing(n Notifier) []string {
+	return w.tracker.missing(n)
+}
+
 // Mark-n-Sweep garbage-collector-like cleaning of views that are no in use.
 // Stops the (garbage) views and removes all references.
 // Should be used before/after the code that uses the dependencies (eg. template).
@@ -479,11 +771,19 @@ func (w *Watcher) ID() string {
 func (w *Watcher) Stop() {
 	w.event(events.Trace{ID: w.ID(), Message: "stopping watcher"})
 	w.bufferTemplates.Stop()
+	w.probes.stop()
 
 	w.tracker.stopViews()
+	w.tracker.releaseNotifiers(w)
 
 	w.stopCh.drain() // So calling Stop twice doesn't block
 	w.stopCh <- struct{}{}
+	w.stopOnce.Do(func() { close(w.doneCh) })
+
+	// forks share the cache and clients with their parent
+	if w.parent != nil {
+		return
+	}
 
 	// Empty cache
 	if w.cache != nil {
@@ -507,6 +807,132 @@ func (w *Watcher) Watching(id string) bool {
 	return (v != nil)
 }
 
+// Index returns the index of the dependency's (id) last blocking query. This
+// is the WaitIndex of its next query. Returns false if the dependency isn't
+// being watched or hasn't received data yet.
+func (w *Watcher) Index(id string) (uint64, bool) {
+	v := w.tracker.view(id)
+	if v == nil {
+		return 0, false
+	}
+	return v.lastIndexOK()
+}
+
+// SetIndex sets the WaitIndex used for the dependency's (id) next blocking
+// query, interrupting any query in flight. Use it to replay from a known index
+// or, with 0, to trigger an immediate full refresh of the dependency. The
+// refreshed data only notifies if it differs from the current data. Returns
+// false if the dependency isn't being watched.
+func (w *Watcher) SetIndex(id string, index uint64) bool {
+	v := w.tracker.view(id)
+	if v == nil {
+		return false
+	}
+	v.setIndex(index)
+	return true
+}
+
+// Refresh forces an immediate full refresh of the dependency (id), for when
+// it was changed out-of-band or its data is suspected to be stale. Its index is
+// reset and any fetch in flight, or wait between polled fetches, interrupted.
+// A dependency no longer polled after failing is polled again. Like SetIndex,
+// the refreshed data only notifies if it differs from the current data.
+// Returns false if the dependency isn't being watched.
+func (w *Watcher) Refresh(id string) bool {
+	v := w.tracker.view(id)
+	if v == nil {
+		return false
+	}
+	w.refresh(v)
+	return true
+}
+
+// RefreshTemplate refreshes (see Refresh) all the dependencies used by the
+// template (id). Returns false if it isn't using any watched dependencies.
+func (w *Watcher) RefreshTemplate(id string) bool {
+	views := w.tracker.viewsFor(id)
+	for _, v := range views {
+		w.refresh(v)
+	}
+	return len(views) > 0
+}
+
+// refresh resets the view's index, interrupting its fetch, and polls it if it
+// isn't polling.
+func (w *Watcher) refresh(v *view) {
+	w.event(events.Trace{ID: v.ID(), Message: "refreshing"})
+	v.setIndex(0)
+	if !v.status().Polling {
+		go v.poll(w.dataCh, w.errCh)
+	}
+}
+
+// DependencyStatus is a snapshot of the status of a watched dependency.
+type DependencyStatus struct {
+	// ID is the dependency's ID
+	ID string
+	// Polling is true while the dependency is being polled. It stops when the
+	// dependency's fetches fail and are no longer retried.
+	Polling bool
+	// HasData is true once data has been received
+	HasData bool
+	// Index is the index of the last blocking query, the WaitIndex of the next
+	Index uint64
+	// LastSuccess is the time of the last successful response, zero if none.
+	// Blocking queries respond at least once every block wait time (even
+	// without changes) so a LastSuccess older than that indicates a stuck
+	// dependency.
+	LastSuccess time.Time
+	// LastError is the last fetch error and LastErrorTime when it happened.
+	// They are kept after later successes, compare with LastSuccess.
+	LastError     error
+	LastErrorTime time.Time
+	// Failures is the number of consecutive failed fetches since the last
+	// success, Retrying is true if the last failure is being retried.
+	Failures int
+	Retrying bool
+	// Degraded is true if the data was served from the Consul agent's cache
+	// after the queries to the servers failed (see ConsulFallbackAfter). It
+	// is cleared by the next successful query to the servers.
+	Degraded bool
+	// Labels are the labels of the templates using the dependency, sorted.
+	// See TemplateInput's Label.
+	Labels []string
+}
+
+// WatcherStatus is a snapshot of the status of the watcher's dependencies,
+// eg. for serving from a health endpoint.
+type WatcherStatus struct {
+	// Dependencies are the watched dependencies' statuses, sorted by ID
+	Dependencies []DependencyStatus
+	// Backends are the health probe results of the backends, sorted by
+	// name. Empty if the probes are disabled (see HealthProbeInterval).
+	Backends []BackendStatus
+	// Paused is true if the watcher is paused, see Pause
+	Paused bool
+}
+
+// Status returns a snapshot of the status of all the watched dependencies.
+func (w *Watcher) Status() WatcherStatus {
+	views := w.tracker.allViews()
+	deps := make([]DependencyStatus, 0, len(views))
+	for _, v := range views {
+		st := v.status()
+		st.Labels = w.tracker.labelsFor(v.ID())
+		deps = append(deps, st)
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i].ID < deps[j].ID })
+	return WatcherStatus{Dependencies: deps, Backends: w.probes.backends(),
+		Paused: w.Paused()}
+}
+
+// Labels returns the labels of the templates using the dependency (by ID),
+// sorted, eg. to attribute the dependency's events to the templates' owners.
+// See TemplateInput's Label.
+func (w *Watcher) Labels(id string) []string {
+	return w.tracker.labelsFor(id)
+}
+
 // view is a convenience function for accessing stored views by id
 // note that dependency IDs and their corresponding view IDs are identical
 func (w *Watcher) view(id string) *view {
@@ -521,6 +947,7 @@ func newTracker() *tracker {
 		tracked:   make([]trackedPair, 0, 8),
 		views:     make(map[string]*view),
 		notifiers: make(map[string]Notifier),
+		labels:    make(map[string]map[string]int),
 	}
 }
 
@@ -532,6 +959,8 @@ type trackedPair struct {
 	mark bool
 	// cacheAccessed is set when recalled from cache the first time
 	cacheAccessed bool
+	// label of the notifier, see labeler
+	label string
 }
 
 // markUsed sets as mark (=true) and returns new pair to keep as value
@@ -564,6 +993,23 @@ type Notifier interface {
 	Notify(interface{}) bool
 }
 
+// LeaseObserver receives events when the leases of the Vault secrets used by
+// templates are acquired, renewed, close to expiring or revoked. Use it to
+// alert on impending credential expiry instead of finding out when rendering
+// fails. ObserveLease is called from the dependencies' fetch goroutines, so it
+// needs to be safe for concurrent use and shouldn't block.
+type LeaseObserver interface {
+	ObserveLease(dep.LeaseEvent)
+}
+
+// DependencyNotifier is a Notifier that also needs the ID of the dependency
+// the data is from. The Watcher calls NotifyDependency in place of Notify for
+// notifiers that implement it.
+type DependencyNotifier interface {
+	Notifier
+	NotifyDependency(id string, data interface{}) bool
+}
+
 // If performance of looping through tracked gets to be to much build 2 indexes
 // of views/notifiers to their trackedPair entries and use that to accel lookups.
 // It will require updating though, and complicates things. So wait.
@@ -576,6 +1022,8 @@ type tracker struct {
 	views map[string]*view
 	// stringID -> Notifier (stringID is usually template-id)
 	notifiers map[string]Notifier
+	// viewID -> label -> number of tracked pairs with the label
+	labels map[string]map[string]int
 }
 
 // cacheAccessed records that the fetched data was used at least once
@@ -600,7 +1048,7 @@ func (t *tracker) viewCount() int {
 // registerNotifiers adds the notifiers to those tracked, it returns an error
 // if a notifier (indexed by n.ID()) has already been registered. If an error
 // occurs none of the notifiers will be added (all or nothing).
-func (t *tracker) registerNotifiers(ns ...Notifier) error {
+func (t *tracker) registerNotifiers(w *Watcher, ns ...Notifier) error {
 	t.Lock()
 	defer t.Unlock()
 	for _, n := range ns {
@@ -608,6 +1056,16 @@ func (t *tracker) registerNotifiers(ns ...Notifier) error {
 			return RegistryErr
 		}
 	}
+	for i, n := range ns {
+		if c, ok := n.(claimer); ok && !c.claim(w) {
+			for _, n := range ns[:i] {
+				if c, ok := n.(claimer); ok {
+					c.release(w)
+				}
+			}
+			return ErrOtherWatcher
+		}
+	}
 	for _, n := range ns {
 		t.notifiers[n.ID()] = n
 	}
@@ -615,15 +1073,30 @@ func (t *tracker) registerNotifiers(ns ...Notifier) error {
 }
 
 // deregisterNotifiers removes the notifiers from those tracked
-func (t *tracker) deregisterNotifiers(ns ...Notifier) {
+func (t *tracker) deregisterNotifiers(w *Watcher, ns ...Notifier) {
 	t.Lock()
 	defer t.Unlock()
 	for _, n := range ns {
+		if c, ok := n.(claimer); ok {
+			c.release(w)
+		}
 		// Delete from notifier map
 		delete(t.notifiers, n.ID())
 	}
 }
 
+// releaseNotifiers releases the watcher's claims on the notifiers, so they
+// can be registered with other watchers once it is stopped.
+func (t *tracker) releaseNotifiers(w *Watcher) {
+	t.Lock()
+	defer t.Unlock()
+	for _, n := range t.notifiers {
+		if c, ok := n.(claimer); ok {
+			c.release(w)
+		}
+	}
+}
+
 // notifierTracked tests if a registered notifier has been paired with a
 // dependency (a tracked_pair added) and thus used at least once
 func (t *tracker) notifierTracked(n Notifier) bool {
@@ -661,6 +1134,37 @@ func (t *tracker) view(viewID string) *view {
 	return t.views[viewID]
 }
 
+// allViews returns all the views
+func (t *tracker) allViews() []*view {
+	t.Lock()
+	defer t.Unlock()
+	views := make([]*view, 0, len(t.views))
+	for _, v := range t.views {
+		if v != nil {
+			views = append(views, v)
+		}
+	}
+	return views
+}
+
+// viewsFor returns the views used by the notifier (id)
+func (t *tracker) viewsFor(notifierID string) []*view {
+	t.Lock()
+	defer t.Unlock()
+	var views []*view
+	seen := make(map[string]struct{})
+	for _, tp := range t.tracked {
+		if _, ok := seen[tp.view]; ok || tp.notify != notifierID {
+			continue
+		}
+		seen[tp.view] = struct{}{}
+		if v := t.views[tp.view]; v != nil {
+			views = append(views, v)
+		}
+	}
+	return views
+}
+
 // adds new tracked entry
 func (t *tracker) add(v *view, n Notifier) {
 	t.Lock()
@@ -671,8 +1175,15 @@ func (t *tracker) add(v *view, n Notifier) {
 	if _, ok := t.notifiers[n.ID()]; !ok {
 		panic("attempt to use an unregistered notifier")
 	}
-	t.tracked = append(t.tracked,
-		trackedPair{view: v.ID(), notify: n.ID(), mark: true})
+	tp := trackedPair{view: v.ID(), notify: n.ID(), mark: true}
+	if l, ok := n.(labeler); ok && l.Label() != "" {
+		tp.label = l.Label()
+		if t.labels[tp.view] == nil {
+			t.labels[tp.view] = make(map[string]int)
+		}
+		t.labels[tp.view][tp.label]++
+	}
+	t.tracked = append(t.tracked, tp)
 }
 
 // Marks all trackedPairs w/ a view as having been used
@@ -704,6 +1215,8 @@ func (t *tracker) stopViews() {
 func (t *tracker) notifiersFor(view IDer) []Notifier {
 	viewID := view.ID()
 	results := make([]Notifier, 0, 8)
+	t.Lock()
+	defer t.Unlock()
 	for _, tp := range t.tracked {
 		if tp.view == viewID {
 			results = append(results, t.notifiers[tp.notify])
@@ -712,9 +1225,36 @@ func (t *tracker) notifiersFor(view IDer) []Notifier {
 	return results
 }
 
+// claimer is implemented by notifiers that can only be registered with one
+// watcher (tree) at a time, eg. Template.
+type claimer interface {
+	claim(*Watcher) bool
+	release(*Watcher)
+}
+
+// labeler is implemented by notifiers with a label, eg. Template.
+type labeler interface {
+	Label() string
+}
+
+// labelsFor returns the distinct labels of the notifiers of the view (by ID),
+// sorted.
+func (t *tracker) labelsFor(viewID string) []string {
+	t.Lock()
+	defer t.Unlock()
+	var labels []string
+	for l := range t.labels[viewID] {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
 // complete returns true if every dependency used has been initialized
 // ie. it returns true if all values have been fetched
 func (t *tracker) complete(notifier IDer) bool {
+	t.Lock()
+	defer t.Unlock()
 	for _, tp := range t.tracked {
 		thisNotifier := tp.notify == notifier.ID()
 		cacheNotAccessed := !tp.cacheAccessed
@@ -725,6 +1265,20 @@ func (t *tracker) complete(notifier IDer) bool {
 	return true
 }
 
+// missing returns the IDs of the views used by the notifier that haven't been
+// initialized
+func (t *tracker) missing(notifier IDer) []string {
+	t.Lock()
+	defer t.Unlock()
+	var missing []string
+	for _, tp := range t.tracked {
+		if tp.notify == notifier.ID() && !tp.cacheAccessed {
+			missing = append(missing, tp.view)
+		}
+	}
+	return missing
+}
+
 // Clean out un-used trackedPair entries and their views (if the last use).
 // Checks based on passed in notifier, ignores others.
 //
@@ -753,6 +1307,8 @@ func (t *tracker) sweep(notifier IDer, cache Cacher) {
 			tmp = append(tmp, tp)
 			used[tp.view] = struct{}{}
 			used[tp.notify] = struct{}{}
+		} else if tp.label != "" {
+			t.unlabel(tp)
 		}
 	}
 	t.tracked = tmp
@@ -766,6 +1322,17 @@ func (t *tracker) sweep(notifier IDer, cache Cacher) {
 	}
 }
 
+// unlabel removes the label of the pair from its view's labels.
+func (t *tracker) unlabel(tp trackedPair) {
+	labels := t.labels[tp.view]
+	if labels[tp.label]--; labels[tp.label] <= 0 {
+		delete(labels, tp.label)
+	}
+	if len(labels) == 0 {
+		delete(t.labels, tp.view)
+	}
+}
+
 // dummy Notifier for use by vault token above and in tests
 type dummyNotifier struct {
 	name   string
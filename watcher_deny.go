@@ -0,0 +1,146 @@
+package hcat
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+// DependencyClass is a class of dependencies, for denying them with the
+// WatcherInput's DenyDependencies.
+//
+// A template's dependencies are only known as it is executed, so they are
+// denied as they are tracked rather than when the template is Registered.
+// The denied dependency is never fetched and the Resolver's Run returns the
+// DeniedError, see the Watcher's Denied.
+type DependencyClass string
+
+const (
+	// ConsulDependencies are the Consul (catalog, health, KV, etc.) queries.
+	ConsulDependencies DependencyClass = "consul"
+	// VaultDependencies are the Vault secret reads, writes and lists.
+	VaultDependencies DependencyClass = "vault"
+	// FileDependencies are the reads of local files, env files included.
+	FileDependencies DependencyClass = "file"
+	// ExecDependencies are the dependencies running commands, the custom
+	// (depext) ones marked with IsExec.
+	ExecDependencies DependencyClass = "exec"
+	// OtherDependencies are all the others, eg. timers and the unmarked
+	// custom (depext) dependencies.
+	OtherDependencies DependencyClass = "other"
+)
+
+// dependencyClass returns the class of the dependency.
+func dependencyClass(d dep.Dependency) DependencyClass {
+	switch d := d.(type) {
+	case idep.ExecType:
+		return ExecDependencies
+	case idep.ConsulType:
+		return ConsulDependencies
+	case idep.VaultType:
+		return VaultDependencies
+	case *idep.FileQuery:
+		return FileDependencies
+	case *idep.EnvQuery:
+		if d.File() != "" {
+			return FileDependencies
+		}
+	}
+	return OtherDependencies
+}
+
+// DeniedError is the error for a template (notifier) using a dependency of a
+// class denied by the Watcher (see WatcherInput's DenyDependencies).
+type DeniedError struct {
+	// ID is the template's (notifier's) ID
+	ID string
+	// Dependency is the denied dependency's ID
+	Dependency string
+	// Class is the denied dependency's class
+	Class DependencyClass
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("%s: %s dependencies are denied: %s", e.ID, e.Class,
+		e.Dependency)
+}
+
+// denyList is the Watcher's denied dependency classes and the notifiers that
+// used them.
+type denyList struct {
+	sync.Mutex
+	classes map[DependencyClass]struct{}
+	// notifier ID -> the first denied dependency it used
+	denied map[string]*DeniedError
+}
+
+func newDenyList(classes []DependencyClass) *denyList {
+	dl := &denyList{
+		classes: make(map[DependencyClass]struct{}, len(classes)),
+		denied:  make(map[string]*DeniedError),
+	}
+	for _, c := range classes {
+		dl.classes[c] = struct{}{}
+	}
+	return dl
+}
+
+// check returns true, and records the denial, if the notifier's dependency
+// is of a denied class.
+func (dl *denyList) check(n IDer, d dep.Dependency) bool {
+	if len(dl.classes) == 0 {
+		return false
+	}
+	class := dependencyClass(d)
+	if _, ok := dl.classes[class]; !ok {
+		return false
+	}
+	dl.Lock()
+	defer dl.Unlock()
+	if _, ok := dl.denied[n.ID()]; !ok {
+		dl.denied[n.ID()] = &DeniedError{
+			ID: n.ID(), Dependency: d.ID(), Class: class}
+	}
+	return true
+}
+
+// err returns the notifier's denial, nil if it hasn't used a denied
+// dependency.
+func (dl *denyList) err(n IDer) error {
+	dl.Lock()
+	defer dl.Unlock()
+	if e, ok := dl.denied[n.ID()]; ok {
+		return e
+	}
+	return nil
+}
+
+// forget clears the notifier's denial.
+func (dl *denyList) forget(n IDer) {
+	dl.Lock()
+	defer dl.Unlock()
+	delete(dl.denied, n.ID())
+}
+
+// fork returns a new, empty, denyList with the same denied classes.
+func (dl *denyList) fork() *denyList {
+	return &denyList{
+		classes: dl.classes,
+		denied:  make(map[string]*DeniedError),
+	}
+}
+
+// replace replaces the notifiers' denials with those of the other denyList.
+func (dl *denyList) replace(other *denyList) {
+	other.Lock()
+	denied := make(map[string]*DeniedError, len(other.denied))
+	for id, e := range other.denied {
+		denied[id] = e
+	}
+	other.Unlock()
+	dl.Lock()
+	defer dl.Unlock()
+	dl.denied = denied
+}
@@ -0,0 +1,126 @@
+package hcat
+
+import (
+	"testing"
+	"text/template"
+
+	"github.com/hashicorp/hcat/dep"
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+func TestDependencyClass(t *testing.T) {
+	t.Parallel()
+
+	kv, _ := idep.NewKVGetQuery("foo")
+	secret, _ := idep.NewVaultReadQuery("secret/foo")
+	file, _ := idep.NewFileQuery("/tmp/foo")
+	timer, _ := idep.NewEveryQuery("1m")
+	env, _ := idep.NewEnvQuery("FOO")
+	envFile, _ := idep.NewEnvFileQuery("/tmp/app.env", "FOO")
+	exec := &execDep{FakeDep: idep.FakeDep{Name: "uptime"}}
+	cases := []struct {
+		name string
+		d    dep.Dependency
+		exp  DependencyClass
+	}{
+		{"consul", kv, ConsulDependencies},
+		{"vault", secret, VaultDependencies},
+		{"file", file, FileDependencies},
+		{"other", timer, OtherDependencies},
+		{"env", env, OtherDependencies},
+		{"env_file", envFile, FileDependencies},
+		{"exec", exec, ExecDependencies},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if act := dependencyClass(tc.d); act != tc.exp {
+				t.Errorf("exp: %v, act: %v", tc.exp, act)
+			}
+		})
+	}
+}
+
+// execDep is a custom dependency marked as running commands
+type execDep struct {
+	idep.IsExec
+	idep.FakeDep
+}
+
+func TestWatcherDeny(t *testing.T) {
+	secretFunc := func(recall Recaller) interface{} {
+		return func(s string) interface{} {
+			value, _ := recall(&idep.FakeVaultDep{Path: s})
+			return value
+		}
+	}
+	newTmpl := func(contents string) *Template {
+		return NewTemplate(TemplateInput{
+			Contents: contents,
+			FuncMapMerge: template.FuncMap{
+				"echo":   echoFunc,
+				"secret": secretFunc,
+			},
+		})
+	}
+	w := NewWatcher(WatcherInput{
+		Clients:          NewClientSet(),
+		Cache:            NewStore(),
+		DenyDependencies: []DependencyClass{VaultDependencies},
+	})
+	defer w.Stop()
+	r := NewResolver()
+
+	t.Run("denied", func(t *testing.T) {
+		tmpl := newTmpl(`{{ echo "foo" }}{{ secret "foo" }}`)
+		w.Register(tmpl)
+		defer w.Deregister(tmpl)
+
+		_, err := r.Run(tmpl, w)
+		derr, ok := err.(*DeniedError)
+		if !ok {
+			t.Fatalf("bad error: %v", err)
+		}
+		exp := DeniedError{ID: tmpl.ID(),
+			Dependency: "test_vault_dep(foo)", Class: VaultDependencies}
+		if *derr != exp {
+			t.Errorf("bad error: %#v", derr)
+		}
+		if w.Watching("test_vault_dep(foo)") {
+			t.Error("denied dependency is watched")
+		}
+		// still denied when re-run without new data
+		if _, err := r.Run(tmpl, w); err != derr {
+			t.Errorf("bad error on re-run: %v", err)
+		}
+
+		w.Deregister(tmpl)
+		if err := w.Denied(tmpl); err != nil {
+			t.Errorf("denial not cleared: %v", err)
+		}
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		tmpl := newTmpl(`{{ echo "foo" }}`)
+		w.Register(tmpl)
+		defer w.Deregister(tmpl)
+
+		if _, err := r.Run(tmpl, w); err != nil {
+			t.Fatal(err)
+		}
+		if !w.Watching("test_dep(foo)") {
+			t.Error("dependency not watched")
+		}
+	})
+
+	t.Run("track", func(t *testing.T) {
+		n := fakeNotifier("track")
+		d := &idep.FakeVaultDep{Path: "bar"}
+		w.Track(n, d)
+		if w.Watching(d.ID()) {
+			t.Error("denied dependency is watched")
+		}
+		if _, ok := w.Denied(n).(*DeniedError); !ok {
+			t.Errorf("expected denial: %v", w.Denied(n))
+		}
+	})
+}
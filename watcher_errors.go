@@ -0,0 +1,146 @@
+package hcat
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/hcat/dep"
+)
+
+// DependencyErrorQuery is a pseudo dependency recalled for the status of the
+// watched dependency with the Target ID, so templates can render degraded
+// output (eg. a header noting stale data) while it fails (see tfunc's
+// lastError). It is never fetched or tracked: the Watcher's Recaller returns
+// the target's DependencyStatus, always found, and notifies the template
+// again when the target starts failing or recovers.
+type DependencyErrorQuery struct {
+	// Target is the ID of the dependency, eg. "health.service(web|passing)"
+	Target string
+}
+
+// Fetch isn't supported, the query is answered by the Watcher's Recaller.
+func (d *DependencyErrorQuery) Fetch(dep.Clients) (interface{},
+	*dep.ResponseMetadata, error) {
+	return nil, nil, fmt.Errorf("%s: recalled from the watcher, not fetched",
+		d.ID())
+}
+
+// ID returns the human-friendly version of this dependency.
+func (d *DependencyErrorQuery) ID() string {
+	return fmt.Sprintf("errors(%s)", d.Target)
+}
+
+// Stop is a no-op, there is nothing to stop.
+func (d *DependencyErrorQuery) Stop() {}
+
+// Stringer interface reuses ID
+func (d *DependencyErrorQuery) String() string {
+	return d.ID()
+}
+
+// errorWatchers are the notifiers that recalled the status of dependencies
+// (see DependencyErrorQuery), by dependency ID, and the dependencies whose
+// error state changed since the last take.
+type errorWatchers struct {
+	sync.Mutex
+	notifiers map[string]map[string]Notifier
+	changed   map[string]struct{}
+	// trigger is signaled when a watched dependency's error state changes
+	trigger chan struct{}
+}
+
+func newErrorWatchers() *errorWatchers {
+	return &errorWatchers{
+		notifiers: make(map[string]map[string]Notifier),
+		changed:   make(map[string]struct{}),
+		trigger:   make(chan struct{}, 1),
+	}
+}
+
+// watch records that the notifier renders the status of the dependency.
+func (e *errorWatchers) watch(n Notifier, id string) {
+	e.Lock()
+	defer e.Unlock()
+	ns, ok := e.notifiers[id]
+	if !ok {
+		ns = make(map[string]Notifier)
+		e.notifiers[id] = ns
+	}
+	ns[n.ID()] = n
+}
+
+// forget removes the notifier from all the dependencies it watched.
+func (e *errorWatchers) forget(n Notifier) {
+	e.Lock()
+	defer e.Unlock()
+	for id, ns := range e.notifiers {
+		delete(ns, n.ID())
+		if len(ns) == 0 {
+			delete(e.notifiers, id)
+		}
+	}
+}
+
+// replace replaces the watched dependencies with those of the other
+// errorWatchers, which is left empty.
+func (e *errorWatchers) replace(other *errorWatchers) {
+	other.Lock()
+	notifiers := other.notifiers
+	other.notifiers = make(map[string]map[string]Notifier)
+	other.changed = make(map[string]struct{})
+	other.Unlock()
+	e.Lock()
+	defer e.Unlock()
+	e.notifiers = notifiers
+	e.changed = make(map[string]struct{})
+}
+
+// errorChanged is the views' ErrorObserver, recording the change if the
+// dependency is watched and signaling the trigger.
+func (e *errorWatchers) errorChanged(id string) {
+	e.Lock()
+	defer e.Unlock()
+	if _, ok := e.notifiers[id]; !ok {
+		return
+	}
+	e.changed[id] = struct{}{}
+	select {
+	case e.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the notifiers watching the dependencies that changed, sorted
+// by ID, and clears the changes.
+func (e *errorWatchers) take() []Notifier {
+	e.Lock()
+	defer e.Unlock()
+	byID := make(map[string]Notifier)
+	for id := range e.changed {
+		for nid, n := range e.notifiers[id] {
+			byID[nid] = n
+		}
+	}
+	e.changed = make(map[string]struct{})
+
+	ns := make([]Notifier, 0, len(byID))
+	for _, n := range byID {
+		ns = append(ns, n)
+	}
+	sort.Slice(ns, func(i, j int) bool { return ns[i].ID() < ns[j].ID() })
+	return ns
+}
+
+// recallStatus returns the status of the query's target for the notifier,
+// which is notified again when the target starts failing or recovers.
+func (w *Watcher) recallStatus(n Notifier, d *DependencyErrorQuery) DependencyStatus {
+	w.errors.watch(n, d.Target)
+	v := w.tracker.view(d.Target)
+	if v == nil {
+		return DependencyStatus{ID: d.Target}
+	}
+	st := v.status()
+	st.Labels = w.tracker.labelsFor(d.Target)
+	return st
+}
@@ -0,0 +1,90 @@
+package hcat
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+func TestWatcherDependencyErrors(t *testing.T) {
+	w := newWatcher()
+	defer w.Stop()
+
+	d := &idep.FakeDep{Name: "foo"}
+	user, tmpl := fakeNotifier("user"), fakeNotifier("tmpl")
+	w.Track(user, d)
+	query := &DependencyErrorQuery{Target: d.ID()}
+
+	t.Run("not-watched", func(t *testing.T) {
+		missing := &DependencyErrorQuery{Target: "missing"}
+		value, ok := w.Recaller(tmpl)(missing)
+		if !ok {
+			t.Fatal("expected the status to be found")
+		}
+		if st := value.(DependencyStatus); st.ID != "missing" ||
+			st.Failures != 0 {
+			t.Errorf("bad status: %#v", st)
+		}
+		if len(w.tracker.allViews()) != 1 {
+			t.Error("the query should not be tracked")
+		}
+	})
+
+	wait := func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := w.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if tmpl.count() != 1 {
+			t.Fatalf("expected the template to be notified, got %d",
+				tmpl.count())
+		}
+		<-tmpl.deps
+		if user.count() != 0 {
+			t.Errorf("the dependency's user should not be notified")
+		}
+	}
+
+	t.Run("failing", func(t *testing.T) {
+		if _, ok := w.Recaller(tmpl)(query); !ok {
+			t.Fatal("expected the status to be found")
+		}
+		v := w.view(d.ID())
+		v.recordError(errors.New("boom"))
+		wait(t)
+
+		value, _ := w.Recaller(tmpl)(query)
+		st := value.(DependencyStatus)
+		if st.ID != d.ID() || st.Failures != 1 || st.LastError == nil {
+			t.Errorf("bad status: %#v", st)
+		}
+
+		// only the first failure notifies the template
+		v.recordError(errors.New("boom"))
+		select {
+		case <-w.errors.trigger:
+			t.Error("unexpected trigger")
+		default:
+		}
+	})
+
+	t.Run("recovered", func(t *testing.T) {
+		w.view(d.ID()).recordSuccess(false)
+		wait(t)
+	})
+
+	t.Run("deregistered", func(t *testing.T) {
+		w.Deregister(tmpl)
+		v := w.view(d.ID())
+		v.recordError(errors.New("boom"))
+		select {
+		case <-w.errors.trigger:
+			t.Error("unexpected trigger")
+		default:
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package hcat
+
+import (
+	"fmt"
+)
+
+// forkCache is a fork's view of its parent's cache. The fork doesn't delete
+// the entries of dependencies the parent is watching, or reset the cache.
+type forkCache struct {
+	Cacher
+	parent *Watcher
+}
+
+func (c forkCache) Delete(id string) {
+	if !c.parent.Watching(id) {
+		c.Cacher.Delete(id)
+	}
+}
+
+func (forkCache) Reset() {}
+
+// root returns the watcher the watcher was forked from, following the forks
+// of forks, or the watcher itself if it isn't a fork.
+func (w *Watcher) root() *Watcher {
+	for w.parent != nil {
+		w = w.parent
+	}
+	return w
+}
+
+// Fork returns a child Watcher sharing the watcher's cache, clients and
+// settings but with its own template (notifier) registrations, for staging a
+// new set of templates and cutting over to it with Swap. The fork's templates
+// use the data already cached for the watcher's dependencies, without
+// fetching it again, so they resolve as soon as the dependencies only they
+// use have data. Until the Swap they aren't notified of changes to the
+// watcher's dependencies, only of those to their own.
+//
+// Stopping a fork stops the dependencies only it uses, it doesn't stop the
+// clients or reset the cache. Buffer periods (SetBufferPeriod) are set on the
+// watcher the templates are run with after the Swap.
+func (w *Watcher) Fork() *Watcher {
+	bufferTriggerCh := make(chan string, dataBufferSize/2)
+	queue := newViewQueue(cap(w.queue.ch), w.queue.policy)
+	f := &Watcher{
+		parent:          w,
+		clients:         w.clients,
+		cache:           forkCache{Cacher: w.cache, parent: w},
+		event:           w.event,
+		tracer:          w.tracer,
+		dataCh:          queue.ch,
+		queue:           queue,
+		errCh:           make(chan error),
+		waitingCh:       make(chan struct{}, 1),
+		stopCh:          make(chan struct{}, 1),
+		doneCh:          make(chan struct{}),
+		tracker:         newTracker(),
+		bufferTrigger:   bufferTriggerCh,
+		bufferTemplates: newTimers(),
+		retryFuncConsul: w.retryFuncConsul,
+		maxStale:        w.maxStale,
+		blockWaitTime:   w.blockWaitTime,
+		fallbackAfter:   w.fallbackAfter,
+		fallbackMaxAge:  w.fallbackMaxAge,
+		retryFuncVault:  w.retryFuncVault,
+		defaultLease:    w.defaultLease,
+		leaseObserver:   w.leaseObserver,
+		cachePolicies:   w.cachePolicies,
+		deny:            w.deny.fork(),
+		errors:          newErrorWatchers(),
+		once:            w.once,
+		recorder:        w.recorder,
+		pause:           w.pause,
+
+		retryClassifierConsul: w.retryClassifierConsul,
+		retryClassifierVault:  w.retryClassifierVault,
+	}
+	go f.bufferTemplates.Run(bufferTriggerCh)
+	return f
+}
+
+// Swap atomically replaces the watcher's templates (notifiers) with those of
+// the fork, cutting over to the staged template set. The dependencies both
+// sets use keep being watched without interruption, those only the fork uses
+// are taken over from it and those only the old set used are stopped. The
+// fork is left empty and can only be stopped after the Swap, its templates
+// are run with the watcher.
+func (w *Watcher) Swap(fork *Watcher) error {
+	if fork.parent != w {
+		return fmt.Errorf("watcher: swap with a watcher that isn't its fork")
+	}
+
+	// fork's tracker first, as when its sweep calls forkCache.Delete
+	fork.tracker.Lock()
+	w.tracker.Lock()
+	if fork.swapped {
+		w.tracker.Unlock()
+		fork.tracker.Unlock()
+		return fmt.Errorf("watcher: fork already swapped")
+	}
+	fork.swapped = true
+
+	views := make(map[string]*view, len(fork.tracker.views))
+	var adopted, stopped []*view
+	for id, fv := range fork.tracker.views {
+		if v, ok := w.tracker.views[id]; ok {
+			views[id] = v
+			stopped = append(stopped, fv)
+			continue
+		}
+		views[id] = fv
+		adopted = append(adopted, fv)
+	}
+	var unused []string
+	for id, v := range w.tracker.views {
+		if _, ok := views[id]; !ok {
+			unused = append(unused, id)
+			stopped = append(stopped, v)
+		}
+	}
+	w.tracker.views = views
+	w.tracker.tracked = fork.tracker.tracked
+	w.tracker.labels = fork.tracker.labels
+	// the templates are now registered with the watcher, not the fork
+	for id, n := range w.tracker.notifiers {
+		c, ok := n.(claimer)
+		if !ok {
+			continue
+		}
+		if fc, ok := fork.tracker.notifiers[id].(claimer); !ok || fc != c {
+			c.release(w)
+		}
+	}
+	for _, n := range fork.tracker.notifiers {
+		if c, ok := n.(claimer); ok {
+			c.claim(w)
+			c.release(fork)
+		}
+	}
+	w.tracker.notifiers = fork.tracker.notifiers
+	fork.tracker.tracked = nil
+	fork.tracker.labels = make(map[string]map[string]int)
+	fork.tracker.views = make(map[string]*view)
+	fork.tracker.notifiers = make(map[string]Notifier)
+	w.tracker.Unlock()
+	fork.tracker.Unlock()
+
+	w.deny.replace(fork.deny)
+	w.errors.replace(fork.errors)
+	for _, v := range stopped {
+		v.stop()
+	}
+	for _, id := range unused {
+		w.cache.Delete(id)
+	}
+
+	// the taken over views send to the fork's queue, forward their data
+	go w.forward(fork)
+	for _, v := range adopted {
+		v.setErrorObserver(w.errors.errorChanged)
+		// the fork didn't poll those with cached data, a no-op for the others
+		go v.poll(w.dataCh, w.errCh)
+	}
+	return nil
+}
+
+// forward passes the data and errors of the fork's views on to the watcher
+// until it is stopped.
+func (w *Watcher) forward(fork *Watcher) {
+	for {
+		select {
+		case v := <-fork.dataCh:
+			fork.queue.received(v)
+			w.queue.send(v, w.doneCh)
+		case err := <-fork.errCh:
+			select {
+			case w.errCh <- err:
+			case <-w.doneCh:
+				return
+			}
+		case <-w.doneCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package hcat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+func TestWatcherFork(t *testing.T) {
+	t.Parallel()
+
+	// run runs the notifier's dependencies on the watcher until they all have
+	// data
+	run := func(t *testing.T, w *Watcher, n Notifier, deps ...*idep.FakeDep) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		w.Register(n)
+		for {
+			for _, d := range deps {
+				w.Recaller(n)(d)
+			}
+			if w.Complete(n) {
+				return
+			}
+			if err := w.Wait(ctx); err != nil || ctx.Err() != nil {
+				t.Fatalf("not resolved: %v", w.Missing(n))
+			}
+		}
+	}
+
+	old, shared, staged := &idep.FakeDep{Name: "old"},
+		&idep.FakeDep{Name: "shared"}, &idep.FakeDep{Name: "staged"}
+
+	w := newWatcher()
+	defer w.Stop()
+	run(t, w, fakeNotifier("old"), old, shared)
+	sharedView := w.view(shared.ID())
+
+	f := w.Fork()
+	defer f.Stop()
+	tmpl := fakeNotifier("new")
+	run(t, f, tmpl, shared, staged)
+	if f.view(shared.ID()).status().Polling {
+		t.Error("fork fetched the cached dependency")
+	}
+	if !w.Watching(old.ID()) || w.Watching(staged.ID()) {
+		t.Error("fork changed the parent's dependencies")
+	}
+
+	if err := w.Swap(f); err != nil {
+		t.Fatal(err)
+	}
+	if w.view(shared.ID()) != sharedView {
+		t.Error("shared dependency's watch not kept")
+	}
+	if !w.Watching(staged.ID()) || w.Watching(old.ID()) {
+		t.Errorf("bad dependencies after swap, staged: %v, old: %v",
+			w.Watching(staged.ID()), w.Watching(old.ID()))
+	}
+	if _, ok := w.cache.Recall(old.ID()); ok {
+		t.Error("old dependency's data not deleted")
+	}
+	if !w.Complete(tmpl) || f.Size() != 0 {
+		t.Errorf("templates not swapped, fork size: %d", f.Size())
+	}
+	if err := w.Register(tmpl); err != RegistryErr {
+		t.Errorf("swapped template not registered: %v", err)
+	}
+
+	// stopping the fork leaves the shared cache and the taken over views
+	f.Stop()
+	if _, ok := w.cache.Recall(shared.ID()); !ok {
+		t.Error("fork reset the cache")
+	}
+	if !w.Watching(staged.ID()) {
+		t.Error("fork stopped the taken over dependency")
+	}
+
+	if err := w.Swap(f); err == nil {
+		t.Error("expected error swapping twice")
+	}
+	if err := w.Swap(newWatcher()); err == nil {
+		t.Error("expected error swapping with a non-fork")
+	}
+}
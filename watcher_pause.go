@@ -0,0 +1,93 @@
+package hcat
+
+import (
+	"sync"
+
+	"github.com/hashicorp/hcat/events"
+)
+
+// pauseGate is the paused state of a Watcher (see Pause), shared with its
+// views.
+type pauseGate struct {
+	sync.Mutex
+	paused bool
+	// changed is closed, and replaced, when paused changes
+	changed chan struct{}
+}
+
+func newPauseGate() *pauseGate {
+	return &pauseGate{changed: make(chan struct{})}
+}
+
+// set sets the paused state, returning false if it was already set.
+func (g *pauseGate) set(paused bool) bool {
+	g.Lock()
+	defer g.Unlock()
+	if g.paused == paused {
+		return false
+	}
+	g.paused = paused
+	close(g.changed)
+	g.changed = make(chan struct{})
+	return true
+}
+
+// state returns whether it is paused and the channel closed when that
+// changes. A nil gate is never paused.
+func (g *pauseGate) state() (bool, <-chan struct{}) {
+	if g == nil {
+		return false, nil
+	}
+	g.Lock()
+	defer g.Unlock()
+	return g.paused, g.changed
+}
+
+// wait blocks while paused, returning false if the stop channel is closed
+// first.
+func (g *pauseGate) wait(stopCh <-chan struct{}) bool {
+	for {
+		paused, changed := g.state()
+		if !paused {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-stopCh:
+			return false
+		}
+	}
+}
+
+// Pause suspends the watcher, eg. to freeze the rendered configuration during
+// a maintenance window. While paused no new fetches are started, the fetches
+// in flight complete, and Wait and Watch don't deliver any notifications. The
+// views that received data in the meantime stay in the queue (see
+// WatcherInput's QueueSize and QueueOverflow) and are delivered at once on
+// Resume, so the templates render once with all the changes. Dependencies
+// templates start using while paused are fetched on Resume.
+//
+// Pause is idempotent, it returns false if the watcher was already paused.
+func (w *Watcher) Pause() bool {
+	if !w.pause.set(true) {
+		return false
+	}
+	w.event(events.Trace{ID: w.ID(), Message: "pausing watcher"})
+	return true
+}
+
+// Resume resumes the fetches and notifications suspended by Pause. It returns
+// false if the watcher wasn't paused.
+func (w *Watcher) Resume() bool {
+	if !w.pause.set(false) {
+		return false
+	}
+	w.event(events.Trace{ID: w.ID(), Message: "resuming watcher"})
+	return true
+}
+
+// Paused returns true if the watcher is paused, see Pause.
+func (w *Watcher) Paused() bool {
+	paused, _ := w.pause.state()
+	return paused
+}
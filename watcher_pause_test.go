@@ -0,0 +1,119 @@
+package hcat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+func TestWatcherPause(t *testing.T) {
+	t.Parallel()
+
+	t.Run("state", func(t *testing.T) {
+		w := newWatcher()
+		defer w.Stop()
+		if w.Paused() || w.Resume() {
+			t.Fatal("new watcher is paused")
+		}
+		if !w.Pause() || w.Pause() {
+			t.Fatal("bad pause")
+		}
+		if !w.Paused() || !w.Status().Paused {
+			t.Fatal("watcher should be paused")
+		}
+		if !w.Resume() || w.Resume() || w.Paused() {
+			t.Fatal("bad resume")
+		}
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		w := newWatcher()
+		defer w.Stop()
+		foodep := &idep.FakeDep{Name: "foo"}
+		n := fakeNotifier("foo")
+		w.Register(n)
+		w.Pause()
+		w.dataCh <- w.track(n, foodep).store("foo")
+
+		errCh := w.WaitCh(context.Background())
+		select {
+		case err := <-errCh:
+			t.Fatal("notified while paused", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+		if _, ok := w.cache.Recall(foodep.ID()); ok {
+			t.Fatal("data delivered while paused")
+		}
+
+		w.Resume()
+		select {
+		case err := <-errCh:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("not notified after resume")
+		}
+		if v, ok := w.cache.Recall(foodep.ID()); !ok || v != "foo" {
+			t.Fatal("data not delivered after resume")
+		}
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		w := newWatcher()
+		defer w.Stop()
+		foodep := &idep.FakeDep{Name: "foo"}
+		n := fakeNotifier("foo")
+		w.Register(n)
+		w.Pause()
+		w.dataCh <- w.track(n, foodep).store("foo")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		tmplCh := make(chan string, 1)
+		go w.Watch(ctx, tmplCh)
+		select {
+		case id := <-tmplCh:
+			t.Fatal("notified while paused", id)
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		w.Resume()
+		select {
+		case id := <-tmplCh:
+			if id != n.ID() {
+				t.Fatal("bad notification", id)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("not notified after resume")
+		}
+	})
+
+	t.Run("fetch", func(t *testing.T) {
+		w := newWatcher()
+		defer w.Stop()
+		foodep := &idep.FakeDep{Name: "foo"}
+		n := fakeNotifier("foo")
+		w.Register(n)
+		w.Pause()
+		w.Track(n, foodep)
+		w.Poll(foodep)
+
+		time.Sleep(20 * time.Millisecond)
+		if w.view(foodep.ID()).status().HasData || w.Pending() != 0 {
+			t.Fatal("fetched while paused")
+		}
+
+		w.Resume()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := w.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if v, ok := w.cache.Recall(foodep.ID()); !ok || v != "foo" {
+			t.Fatal("not fetched after resume")
+		}
+	})
+}
@@ -0,0 +1,195 @@
+package hcat
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/hcat/dep"
+	"github.com/hashicorp/hcat/events"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+)
+
+// names of the probed backends
+const (
+	ConsulBackend = "consul"
+	VaultBackend  = "vault"
+)
+
+// BackendStatus is the result of the health probes of a backend, see
+// WatcherInput's HealthProbeInterval.
+type BackendStatus struct {
+	// Name is the backend probed, ConsulBackend or VaultBackend
+	Name string
+	// Healthy is true if the last probe succeeded
+	Healthy bool
+	// Error is why the last probe failed, nil if Healthy
+	Error error
+	// LastProbe is the time of the last probe and LastHealthy of the last
+	// one that succeeded, zero if none did.
+	LastProbe   time.Time
+	LastHealthy time.Time
+}
+
+// backendProbe checks the health of a backend with the clients, returning
+// false if it isn't configured and an error if it is unhealthy. The probe is
+// cancelled with the context.
+type backendProbe func(context.Context, dep.Clients) (bool, error)
+
+// backendProbes are the probes of each backend. Consul is healthy if it has
+// a leader (status/leader) and Vault if it is initialized and unsealed
+// (sys/health).
+var backendProbes = map[string]backendProbe{
+	ConsulBackend: func(ctx context.Context, clients dep.Clients) (bool, error) {
+		client := clients.Consul()
+		if client == nil {
+			return false, nil
+		}
+		var leader string
+		q := (&api.QueryOptions{}).WithContext(ctx)
+		_, err := client.Raw().Query("/v1/status/leader", &leader, q)
+		switch {
+		case err != nil:
+			return true, err
+		case leader == "":
+			return true, errors.New("no cluster leader")
+		}
+		return true, nil
+	},
+	VaultBackend: func(ctx context.Context, clients dep.Clients) (bool, error) {
+		client := clients.Vault()
+		if client == nil {
+			return false, nil
+		}
+		// Sys().Health with the context, the status codes are set to 299 so
+		// an uninitialized or sealed Vault doesn't return an error
+		r := client.NewRequest("GET", "/v1/sys/health")
+		for _, code := range []string{"uninitcode", "sealedcode",
+			"standbycode", "drsecondarycode", "performancestandbycode"} {
+			r.Params.Add(code, "299")
+		}
+		resp, err := client.RawRequestWithContext(ctx, r)
+		if err != nil {
+			return true, err
+		}
+		defer resp.Body.Close()
+		var health vaultapi.HealthResponse
+		if err := resp.DecodeJSON(&health); err != nil {
+			return true, err
+		}
+		switch {
+		case !health.Initialized:
+			return true, errors.New("not initialized")
+		case health.Sealed:
+			return true, errors.New("sealed")
+		}
+		return true, nil
+	},
+}
+
+// prober periodically probes the health of the configured backends.
+type prober struct {
+	sync.RWMutex
+	clients  dep.Clients
+	event    events.EventHandler
+	interval time.Duration
+	status   map[string]BackendStatus
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// newProber returns a prober, nil if the interval isn't positive (disabled).
+func newProber(clients dep.Clients, event events.EventHandler,
+	interval time.Duration) *prober {
+	if interval <= 0 {
+		return nil
+	}
+	return &prober{
+		clients:  clients,
+		event:    event,
+		interval: interval,
+		status:   make(map[string]BackendStatus),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// run probes the backends every interval, starting right away, until stopped.
+func (p *prober) run() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.probe()
+		select {
+		case <-ticker.C:
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+// probe probes the backends once, sending a BackendHealth event for those
+// whose health changed (or were probed for the first time). Each probe times
+// out after half the interval, a backend that doesn't answer is unhealthy.
+func (p *prober) probe() {
+	timeout := p.interval / 2
+	for name, probe := range backendProbes {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		ok, err := probe(ctx, p.clients)
+		if ctx.Err() == context.DeadlineExceeded {
+			err = errors.Errorf("probe timed out after %s", timeout)
+		}
+		cancel()
+		if !ok {
+			continue
+		}
+		now := time.Now()
+
+		p.Lock()
+		prev, probed := p.status[name]
+		status := BackendStatus{
+			Name:        name,
+			Healthy:     err == nil,
+			Error:       err,
+			LastProbe:   now,
+			LastHealthy: prev.LastHealthy,
+		}
+		if status.Healthy {
+			status.LastHealthy = now
+		}
+		p.status[name] = status
+		p.Unlock()
+
+		if !probed || prev.Healthy != status.Healthy {
+			p.event(events.BackendHealth{
+				Backend: name, Healthy: status.Healthy, Error: err})
+		}
+	}
+}
+
+// backends returns the status of the probed backends, sorted by name.
+func (p *prober) backends() []BackendStatus {
+	if p == nil {
+		return nil
+	}
+	p.RLock()
+	defer p.RUnlock()
+	backends := make([]BackendStatus, 0, len(p.status))
+	for _, s := range p.status {
+		backends = append(backends, s)
+	}
+	sort.Slice(backends, func(i, j int) bool {
+		return backends[i].Name < backends[j].Name
+	})
+	return backends
+}
+
+// stop halts the probes, safe to call more than once.
+func (p *prober) stop() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() { close(p.stopCh) })
+}
@@ -0,0 +1,122 @@
+package hcat
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcat/events"
+)
+
+func TestWatcherHealthProbes(t *testing.T) {
+	// fake consul and vault, healthy until down is set, not answering when
+	// hung is
+	var down, hung int32
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&hung) == 1 {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(time.Second):
+				}
+				return
+			}
+			healthy := atomic.LoadInt32(&down) == 0
+			switch r.URL.Path {
+			case "/v1/status/leader":
+				if healthy {
+					fmt.Fprint(w, `"127.0.0.1:8300"`)
+				} else {
+					fmt.Fprint(w, `""`)
+				}
+			case "/v1/sys/health":
+				fmt.Fprintf(w, `{"initialized": true, "sealed": %t}`, !healthy)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+	defer ts.Close()
+
+	cs := NewClientSet()
+	if err := cs.AddConsul(ConsulInput{
+		Address: ts.Listener.Addr().String()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.AddVault(VaultInput{Address: ts.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	eventCh := make(chan events.BackendHealth, 10)
+	w := NewWatcher(WatcherInput{
+		Clients: cs,
+		Cache:   NewStore(),
+		EventHandler: func(e events.Event) {
+			if e, ok := e.(events.BackendHealth); ok {
+				eventCh <- e
+			}
+		},
+		HealthProbeInterval: 10 * time.Millisecond,
+	})
+	defer w.Stop()
+
+	// waitFor waits for the health events of both backends
+	waitFor := func(healthy bool) {
+		seen := make(map[string]bool)
+		for len(seen) < 2 {
+			select {
+			case e := <-eventCh:
+				if e.Healthy != healthy || (e.Error == nil) != healthy {
+					t.Fatalf("bad event: %#v", e)
+				}
+				seen[e.Backend] = true
+			case <-time.After(time.Second):
+				t.Fatalf("missing events, got: %v", seen)
+			}
+		}
+	}
+
+	waitFor(true)
+	backends := w.Status().Backends
+	if len(backends) != 2 || backends[0].Name != ConsulBackend ||
+		backends[1].Name != VaultBackend {
+		t.Fatalf("bad backends: %#v", backends)
+	}
+	for _, b := range backends {
+		if !b.Healthy || b.LastHealthy.IsZero() {
+			t.Errorf("bad status: %#v", b)
+		}
+	}
+
+	atomic.StoreInt32(&down, 1)
+	waitFor(false)
+	for _, b := range w.Status().Backends {
+		if b.Healthy || b.Error == nil || !b.LastProbe.After(b.LastHealthy) {
+			t.Errorf("bad status: %#v", b)
+		}
+	}
+
+	// probes time out before the next ones are due
+	atomic.StoreInt32(&down, 0)
+	waitFor(true)
+	atomic.StoreInt32(&hung, 1)
+	waitFor(false)
+	for _, b := range w.Status().Backends {
+		if b.Healthy || b.Error == nil ||
+			!strings.Contains(b.Error.Error(), "timed out") {
+			t.Errorf("bad hung status: %#v", b)
+		}
+	}
+	atomic.StoreInt32(&hung, 0)
+
+	t.Run("disabled", func(t *testing.T) {
+		w := NewWatcher(WatcherInput{Clients: cs, Cache: NewStore()})
+		defer w.Stop()
+		if backends := w.Status().Backends; len(backends) != 0 {
+			t.Errorf("unexpected backends: %#v", backends)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package hcat
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy is what the Watcher does when a view has new data and the
+// queue of views waiting to be processed (by Wait or Watch) is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the view, pausing its polling, until there is
+	// room in the queue. The default.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowCoalesce merges new data for a view that is already in the
+	// queue into the queued entry, the entry always has the view's latest
+	// data. So each view is in the queue at most once and it only blocks
+	// once the queue is full of distinct views.
+	OverflowCoalesce
+
+	// OverflowDropOldest drops the oldest view in the queue to make room for
+	// the new one, so it never blocks. The dropped view is queued again once
+	// there is room, its data is delayed behind the newer data but not lost.
+	OverflowDropOldest
+)
+
+// viewQueue is the bounded queue of views with new data.
+type viewQueue struct {
+	ch        chan *view
+	policy    OverflowPolicy
+	overflows uint64 // atomic
+
+	// dropped are the views dropped by OverflowDropOldest, queued again
+	// once the views are received
+	dropped   map[*view]struct{}
+	droppedMu sync.Mutex
+}
+
+func newViewQueue(size int, policy OverflowPolicy) *viewQueue {
+	if size <= 0 {
+		size = dataBufferSize
+	}
+	return &viewQueue{
+		ch:     make(chan *view, size),
+		policy: policy,
+	}
+}
+
+// send adds the view to the queue following the overflow policy. Returns
+// false if stopped while waiting for room.
+func (q *viewQueue) send(v *view, stopCh <-chan struct{}) bool {
+	if q.policy == OverflowCoalesce &&
+		!atomic.CompareAndSwapInt32(&v.queued, 0, 1) {
+		atomic.AddUint64(&q.overflows, 1)
+		return true
+	}
+
+	select {
+	case q.ch <- v:
+		return true
+	default:
+	}
+	atomic.AddUint64(&q.overflows, 1)
+
+	if q.policy == OverflowDropOldest {
+		q.droppedMu.Lock()
+		defer q.droppedMu.Unlock()
+		for {
+			select {
+			case q.ch <- v:
+				delete(q.dropped, v)
+				return true
+			default:
+			}
+			select {
+			case old := <-q.ch:
+				if q.dropped == nil {
+					q.dropped = make(map[*view]struct{})
+				}
+				q.dropped[old] = struct{}{}
+			default:
+			}
+		}
+	}
+
+	select {
+	case <-stopCh:
+		// a stopped view is never sent so it doesn't need dequeuing
+		return false
+	case q.ch <- v:
+		return true
+	}
+}
+
+// received marks the view as taken from the queue, call before reading its
+// data so no updates are missed when coalescing. It queues the dropped views
+// again that there is room for.
+func (q *viewQueue) received(v *view) {
+	atomic.StoreInt32(&v.queued, 0)
+	if q.policy != OverflowDropOldest {
+		return
+	}
+
+	q.droppedMu.Lock()
+	defer q.droppedMu.Unlock()
+	for d := range q.dropped {
+		select {
+		case q.ch <- d:
+			delete(q.dropped, d)
+		default:
+			return
+		}
+	}
+}
+
+// Overflows returns the number of times the queue was full when a view was
+// added to it, or the view was coalesced with its queued entry.
+func (q *viewQueue) Overflows() uint64 {
+	return atomic.LoadUint64(&q.overflows)
+}
@@ -0,0 +1,129 @@
+package hcat
+
+import (
+	"testing"
+	"time"
+
+	idep "github.com/hashicorp/hcat/internal/dependency"
+)
+
+func TestViewQueue(t *testing.T) {
+	t.Parallel()
+
+	newTestView := func(name string) *view {
+		return newView(&newViewInput{Dependency: &idep.FakeDep{Name: name}})
+	}
+
+	t.Run("block", func(t *testing.T) {
+		q := newViewQueue(1, OverflowBlock)
+		foo, bar := newTestView("foo"), newTestView("bar")
+		if !q.send(foo, foo.stopCh) {
+			t.Fatal("send failed")
+		}
+		sent := make(chan bool)
+		go func() { sent <- q.send(bar, bar.stopCh) }()
+		select {
+		case <-sent:
+			t.Fatal("send should block when full")
+		case <-time.After(10 * time.Millisecond):
+		}
+		if v := <-q.ch; v != foo {
+			t.Errorf("bad view: %v", v.ID())
+		}
+		if !<-sent {
+			t.Error("send failed")
+		}
+		if v := <-q.ch; v != bar {
+			t.Errorf("bad view: %v", v.ID())
+		}
+		if q.Overflows() != 1 {
+			t.Errorf("bad overflows: %d", q.Overflows())
+		}
+	})
+
+	t.Run("block-stopped", func(t *testing.T) {
+		q := newViewQueue(1, OverflowBlock)
+		foo, bar := newTestView("foo"), newTestView("bar")
+		q.send(foo, foo.stopCh)
+		close(bar.stopCh)
+		if q.send(bar, bar.stopCh) {
+			t.Error("send should fail when stopped")
+		}
+	})
+
+	t.Run("coalesce", func(t *testing.T) {
+		q := newViewQueue(2, OverflowCoalesce)
+		foo := newTestView("foo")
+		q.send(foo, foo.stopCh)
+		q.send(foo, foo.stopCh)
+		if len(q.ch) != 1 || q.Overflows() != 1 {
+			t.Fatalf("bad queue, len: %d, overflows: %d", len(q.ch),
+				q.Overflows())
+		}
+		q.received(<-q.ch)
+		q.send(foo, foo.stopCh)
+		if len(q.ch) != 1 || q.Overflows() != 1 {
+			t.Fatalf("bad queue, len: %d, overflows: %d", len(q.ch),
+				q.Overflows())
+		}
+	})
+
+	t.Run("drop-oldest", func(t *testing.T) {
+		q := newViewQueue(2, OverflowDropOldest)
+		foo, bar, baz := newTestView("foo"), newTestView("bar"),
+			newTestView("baz")
+		for _, v := range []*view{foo, bar, baz} {
+			if !q.send(v, v.stopCh) {
+				t.Fatal("send failed")
+			}
+		}
+		// the dropped view is queued again once there is room
+		for _, exp := range []*view{bar, baz, foo} {
+			select {
+			case v := <-q.ch:
+				if v != exp {
+					t.Errorf("bad view: %v, expected: %v", v.ID(), exp.ID())
+				}
+				q.received(v)
+			default:
+				t.Fatalf("view missing: %v", exp.ID())
+			}
+		}
+		if len(q.ch) != 0 {
+			t.Errorf("views left in the queue: %d", len(q.ch))
+		}
+		if q.Overflows() != 1 {
+			t.Errorf("bad overflows: %d", q.Overflows())
+		}
+	})
+
+	t.Run("watcher", func(t *testing.T) {
+		w := NewWatcher(WatcherInput{
+			QueueSize:     1,
+			QueueOverflow: OverflowDropOldest,
+		})
+		defer w.Stop()
+		if cap(w.dataCh) != 1 {
+			t.Errorf("bad queue size: %d", cap(w.dataCh))
+		}
+		n := fakeNotifier("foo")
+		w.Register(n)
+		foo := w.track(n, &idep.FakeDep{Name: "foo"})
+		bar := w.track(n, &idep.FakeDep{Name: "bar"})
+		if foo.queue != w.queue {
+			t.Error("view should use the watcher's queue")
+		}
+		foo.send(w.dataCh)
+		bar.send(w.dataCh)
+		if w.QueueOverflows() != 1 {
+			t.Errorf("bad overflows: %d", w.QueueOverflows())
+		}
+		for _, exp := range []*view{bar, foo} {
+			v := <-w.dataCh
+			if v != exp {
+				t.Errorf("bad view: %v, expected: %v", v.ID(), exp.ID())
+			}
+			w.queue.received(v)
+		}
+	})
+}
@@ -3,11 +3,14 @@ package hcat
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/hcat/dep"
+	"github.com/hashicorp/hcat/events"
 	idep "github.com/hashicorp/hcat/internal/dependency"
 	"github.com/pkg/errors"
 )
@@ -106,6 +109,53 @@ func TestWatcherRegister(t *testing.T) {
 			t.Fatal("should have errored")
 		}
 	})
+
+	t.Run("other-watcher-error", func(t *testing.T) {
+		w1, w2 := blindWatcher(), blindWatcher()
+		defer w2.Stop()
+		tt, other := echoTemplate("foo"), echoTemplate("bar")
+		if err := w1.Register(tt); err != nil {
+			t.Fatal("error should be nil, got:", err)
+		}
+		if err := w2.Register(other, tt); err != ErrOtherWatcher {
+			t.Fatal("should have errored, got:", err)
+		}
+		if _, ok := w2.tracker.notifiers[other.ID()]; ok {
+			t.Fatal("partially registered")
+		}
+		if err := w1.Register(other); err != nil {
+			t.Fatal("claim not rolled back, got:", err)
+		}
+
+		// forks share the watcher's templates and hand theirs over on swap
+		f := w1.Fork()
+		staged := echoTemplate("staged")
+		if err := f.Register(tt, staged); err != nil {
+			t.Fatal("error should be nil, got:", err)
+		}
+		if err := w1.Swap(f); err != nil {
+			t.Fatal(err)
+		}
+		f.Stop()
+		if err := w2.Register(staged); err != ErrOtherWatcher {
+			t.Fatal("swapped template released, got:", err)
+		}
+		if err := w2.Register(other); err != nil {
+			t.Fatal("swapped out template not released, got:", err)
+		}
+		w2.Deregister(other)
+
+		w1.Deregister(tt)
+		if err := w2.Register(tt); err != nil {
+			t.Fatal("deregistered template not released, got:", err)
+		}
+		w2.Deregister(tt)
+		w1.Register(tt)
+		w1.Stop()
+		if err := w2.Register(tt); err != nil {
+			t.Fatal("stopped watcher's template not released, got:", err)
+		}
+	})
 }
 
 func TestWatcherDeregister(t *testing.T) {
@@ -372,6 +422,359 @@ func TestWatcherWatching(t *testing.T) {
 	})
 }
 
+func TestWatcherSetIndex(t *testing.T) {
+	t.Run("not-watching", func(t *testing.T) {
+		w := newWatcher()
+		defer w.Stop()
+
+		d := &idep.FakeDepWaitIndex{Name: "foo"}
+		if _, ok := w.Index(d.ID()); ok {
+			t.Error("expected no index")
+		}
+		if w.SetIndex(d.ID(), 0) {
+			t.Error("expected SetIndex to fail")
+		}
+	})
+
+	t.Run("refresh-and-replay", func(t *testing.T) {
+		w := newWatcher()
+		defer w.Stop()
+
+		d := &idep.FakeDepWaitIndex{Name: "foo"}
+		n := fakeNotifier("foo")
+		w.Track(n, d)
+		if _, ok := w.Index(d.ID()); ok {
+			t.Error("expected no index before data")
+		}
+		w.Poll(d)
+
+		// waitForIndexes waits for the dependency to be fetched with the
+		// WaitIndexes and the data to be received by the watcher
+		waitForIndexes := func(exp ...uint64) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			for !reflect.DeepEqual(d.Indexes(), exp) {
+				select {
+				case <-ctx.Done():
+					t.Fatalf("bad indexes, exp: %v, act: %v", exp, d.Indexes())
+				case <-time.After(time.Millisecond):
+				}
+			}
+		}
+
+		w.Wait(context.Background())
+		waitForIndexes(0, 10) // fetched, then blocking
+		if index, ok := w.Index(d.ID()); !ok || index != 10 {
+			t.Fatalf("bad index: %v, %v", index, ok)
+		}
+		if data, _ := w.cache.Recall(d.ID()); data != "foo_1" {
+			t.Fatalf("bad data: %v", data)
+		}
+
+		// 0 interrupts the blocking query for a full refresh
+		if !w.SetIndex(d.ID(), 0) {
+			t.Fatal("SetIndex failed")
+		}
+		w.Wait(context.Background())
+		waitForIndexes(0, 10, 0, 10)
+		if data, _ := w.cache.Recall(d.ID()); data != "foo_2" {
+			t.Fatalf("bad refreshed data: %v", data)
+		}
+
+		// replay from a known index
+		w.SetIndex(d.ID(), 5)
+		waitForIndexes(0, 10, 0, 10, 5)
+		if index, _ := w.Index(d.ID()); index != 5 {
+			t.Errorf("bad replay index: %v", index)
+		}
+	})
+}
+
+func TestWatcherRefresh(t *testing.T) {
+	t.Run("not-watching", func(t *testing.T) {
+		w := newWatcher()
+		defer w.Stop()
+
+		d := &idep.FakeDepWaitIndex{Name: "foo"}
+		if w.Refresh(d.ID()) {
+			t.Error("expected Refresh to fail")
+		}
+		if w.RefreshTemplate("foo") {
+			t.Error("expected RefreshTemplate to fail")
+		}
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		w := newWatcher()
+		defer w.Stop()
+
+		d := &idep.FakeDepWaitIndex{Name: "foo"}
+		n := fakeNotifier("foo")
+		w.Track(n, d)
+		w.Poll(d)
+
+		// waitForIndexes waits for the dependency to be fetched with the
+		// WaitIndexes and the data to be received by the watcher
+		waitForIndexes := func(exp ...uint64) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			for !reflect.DeepEqual(d.Indexes(), exp) {
+				select {
+				case <-ctx.Done():
+					t.Fatalf("bad indexes, exp: %v, act: %v", exp, d.Indexes())
+				case <-time.After(time.Millisecond):
+				}
+			}
+		}
+
+		w.Wait(context.Background())
+		waitForIndexes(0, 10)
+
+		if !w.Refresh(d.ID()) {
+			t.Fatal("Refresh failed")
+		}
+		w.Wait(context.Background())
+		waitForIndexes(0, 10, 0, 10)
+		if data, _ := w.cache.Recall(d.ID()); data != "foo_2" {
+			t.Fatalf("bad refreshed data: %v", data)
+		}
+
+		if !w.RefreshTemplate(n.ID()) {
+			t.Fatal("RefreshTemplate failed")
+		}
+		w.Wait(context.Background())
+		waitForIndexes(0, 10, 0, 10, 0, 10)
+		if data, _ := w.cache.Recall(d.ID()); data != "foo_3" {
+			t.Fatalf("bad refreshed data: %v", data)
+		}
+	})
+
+	t.Run("not-polling", func(t *testing.T) {
+		w := newWatcher()
+		defer w.Stop()
+
+		d := &idep.FakeDep{Name: "foo"}
+		n := fakeNotifier("foo")
+		w.Track(n, d)
+
+		// tracked but never polled, refreshing polls it
+		if !w.RefreshTemplate(n.ID()) {
+			t.Fatal("RefreshTemplate failed")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := w.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if data, ok := w.cache.Recall(d.ID()); !ok || data != "foo" {
+			t.Fatalf("bad data: %v, %v", data, ok)
+		}
+	})
+}
+
+func TestWatcherCacheFallback(t *testing.T) {
+	newWatcher := func(after int, handler events.EventHandler) *Watcher {
+		return NewWatcher(WatcherInput{
+			Clients:      NewClientSet(),
+			Cache:        NewStore(),
+			EventHandler: handler,
+			ConsulRetryFunc: func(int) (bool, time.Duration) {
+				return true, time.Millisecond
+			},
+			ConsulFallbackAfter:  after,
+			ConsulFallbackMaxAge: time.Minute,
+		})
+	}
+
+	t.Run("fallback", func(t *testing.T) {
+		var fallbacks []events.CacheFallback
+		var mu sync.Mutex
+		w := newWatcher(2, func(e events.Event) {
+			if e, ok := e.(events.CacheFallback); ok {
+				mu.Lock()
+				fallbacks = append(fallbacks, e)
+				mu.Unlock()
+			}
+		})
+		defer w.Stop()
+
+		d := &idep.FakeDepOutage{Name: "foo"}
+		n := fakeNotifier("foo")
+		w.Track(n, d)
+		w.Poll(d)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := w.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if data, ok := w.cache.Recall(d.ID()); !ok || data != "foo" {
+			t.Fatalf("bad data: %v, %v", data, ok)
+		}
+		st := w.Status().Dependencies[0]
+		if !st.Degraded {
+			t.Errorf("expected degraded: %#v", st)
+		}
+		mu.Lock()
+		if len(fallbacks) == 0 || fallbacks[0].Failures != 2 {
+			t.Errorf("bad fallback events: %#v", fallbacks)
+		}
+		mu.Unlock()
+
+		opts := d.CacheOptions()
+		if !opts.UseCache || !opts.AllowStale || opts.MaxAge != time.Minute ||
+			opts.WaitIndex != 0 {
+			t.Errorf("bad fallback options: %#v", opts)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		w := newWatcher(0, nil)
+		defer w.Stop()
+
+		d := &idep.FakeDepOutage{Name: "foo"}
+		n := fakeNotifier("foo")
+		w.Track(n, d)
+		w.Poll(d)
+
+		ctx, cancel := context.WithTimeout(context.Background(),
+			50*time.Millisecond)
+		defer cancel()
+		if err := w.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := w.cache.Recall(d.ID()); ok {
+			t.Error("expected no data")
+		}
+		if d.CacheOptions().UseCache {
+			t.Error("expected no fallback")
+		}
+	})
+}
+
+func TestWatcherStatus(t *testing.T) {
+	w := newWatcher()
+	defer w.Stop()
+
+	if st := w.Status(); len(st.Dependencies) != 0 {
+		t.Fatalf("expected no dependencies: %#v", st)
+	}
+
+	ok := &idep.FakeDep{Name: "foo"}
+	failed := &idep.FakeDepFetchError{Name: "foo"}
+	n := fakeNotifier("foo")
+	w.Track(n, ok)
+	w.Track(n, failed)
+	w.Poll(ok, failed)
+
+	// wait for the data and the failure
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var st WatcherStatus
+	for {
+		st = w.Status()
+		if len(st.Dependencies) == 2 && st.Dependencies[0].HasData &&
+			st.Dependencies[1].Failures > 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("bad status: %#v", st)
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	a, b := st.Dependencies[0], st.Dependencies[1]
+	if a.ID != ok.ID() || b.ID != failed.ID() {
+		t.Fatalf("bad order: %#v", st)
+	}
+	if a.LastSuccess.IsZero() || a.LastError != nil || a.Index != 1 {
+		t.Errorf("bad ok status: %#v", a)
+	}
+	if b.LastError == nil || b.LastErrorTime.IsZero() || b.Failures != 1 ||
+		!b.LastSuccess.IsZero() || b.HasData || b.Retrying {
+		t.Errorf("bad failed status: %#v", b)
+	}
+}
+
+func TestWatcherLabels(t *testing.T) {
+	var mu sync.Mutex
+	var tracked []events.TrackStart
+	var newData []events.NewData
+	w := NewWatcher(WatcherInput{
+		Clients: NewClientSet(),
+		Cache:   NewStore(),
+		EventHandler: func(e events.Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch e := e.(type) {
+			case events.TrackStart:
+				tracked = append(tracked, e)
+			case events.NewData:
+				newData = append(newData, e)
+			}
+		},
+	})
+	defer w.Stop()
+
+	newTmpl := func(label string) *Template {
+		return NewTemplate(TemplateInput{Contents: label, Label: label})
+	}
+	shared := &idep.FakeDep{Name: "shared"}
+	single := &idep.FakeDep{Name: "single"}
+	a, b, a2 := newTmpl("team-b"), newTmpl("team-a"), newTmpl("team-b")
+	w.Track(a, shared)
+	w.Track(b, shared)
+	w.Track(a2, shared)
+	w.Track(b, single)
+	w.Track(fakeNotifier("unlabeled"), single)
+
+	if act := w.Labels(shared.ID()); !reflect.DeepEqual(act,
+		[]string{"team-a", "team-b"}) {
+		t.Errorf("bad shared labels: %v", act)
+	}
+	if act := w.Labels(single.ID()); !reflect.DeepEqual(act,
+		[]string{"team-a"}) {
+		t.Errorf("bad single labels: %v", act)
+	}
+	if act := w.Labels("nope"); len(act) != 0 {
+		t.Errorf("expected no labels: %v", act)
+	}
+	for _, d := range w.Status().Dependencies {
+		if !reflect.DeepEqual(d.Labels, w.Labels(d.ID)) {
+			t.Errorf("bad status labels for %s: %v", d.ID, d.Labels)
+		}
+	}
+	mu.Lock()
+	last := tracked[len(tracked)-1]
+	mu.Unlock()
+	if last.ID != single.ID() || !reflect.DeepEqual(last.Labels,
+		[]string{"team-a"}) {
+		t.Errorf("bad track start event: %#v", last)
+	}
+	w.Poll(shared)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := w.Wait(ctx); err != nil {
+		t.Fatal("Wait() error:", err)
+	}
+	mu.Lock()
+	if len(newData) != 1 || !reflect.DeepEqual(newData[0].Labels,
+		[]string{"team-a", "team-b"}) {
+		t.Errorf("bad new data events: %#v", newData)
+	}
+	mu.Unlock()
+
+	w.Deregister(b)
+	if act := w.Labels(shared.ID()); !reflect.DeepEqual(act,
+		[]string{"team-b"}) {
+		t.Errorf("bad shared labels after deregister: %v", act)
+	}
+	if act := w.Labels(single.ID()); len(act) != 0 {
+		t.Errorf("expected no single labels after deregister: %v", act)
+	}
+}
+
 func TestWatcherVaultToken(t *testing.T) {
 	t.Run("empty-token", func(t *testing.T) {
 		w := newWatcher()